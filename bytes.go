@@ -0,0 +1,173 @@
+package ezconf
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Bytes is an optional binary value for []byte config fields such as keys and salts. Env vars, flags, and config files
+// all give it as base64 text. Standard and URL safe base64 are both accepted, with or without padding, and it is always
+// written back as padded standard base64.
+type Bytes struct {
+	value []byte
+	some  bool
+}
+
+// SomeBytes returns a Bytes holding value.
+func SomeBytes(value []byte) Bytes {
+	return Bytes{value: append([]byte{}, value...), some: true}
+}
+
+// NoBytes returns an empty Bytes.
+func NoBytes() Bytes {
+	return Bytes{}
+}
+
+func (o Bytes) IsSome() bool {
+	return o.some
+}
+
+func (o Bytes) IsNone() bool {
+	return !o.some
+}
+
+// Get returns a copy of the value and whether it is set.
+func (o Bytes) Get() ([]byte, bool) {
+	if !o.some {
+		return nil, false
+	}
+	return append([]byte{}, o.value...), true
+}
+
+// GetOr returns a copy of the value, or val if it is not set.
+func (o Bytes) GetOr(val []byte) []byte {
+	value, ok := o.Get()
+	if !ok {
+		return val
+	}
+	return value
+}
+
+// Or returns o if it is set and other otherwise.
+func (o Bytes) Or(other Bytes) Bytes {
+	if o.some {
+		return o
+	}
+	return other
+}
+
+func (o *Bytes) Replace(value []byte) {
+	o.value = append([]byte{}, value...)
+	o.some = true
+}
+
+func (o *Bytes) Clear() {
+	o.value = nil
+	o.some = false
+}
+
+func (o Bytes) Type() string {
+	return "Bytes"
+}
+
+func (o Bytes) String() string {
+	if !o.some {
+		return "None[Bytes]"
+	}
+	return base64.StdEncoding.EncodeToString(o.value)
+}
+
+// Set replaces the value with the base64 decoded str.
+func (o *Bytes) Set(str string) error {
+	value, err := DecodeBase64(str)
+	if err != nil {
+		return err
+	}
+
+	o.Replace(value)
+	return nil
+}
+
+// UnmarshalText replaces the value with the base64 decoded text.
+func (o *Bytes) UnmarshalText(text []byte) error {
+	return o.Set(string(text))
+}
+
+// MarshalText returns the value as standard base64.
+func (o Bytes) MarshalText() ([]byte, error) {
+	return []byte(base64.StdEncoding.EncodeToString(o.value)), nil
+}
+
+// HexBytes is the same as Bytes, but given and written as hex text instead of base64, e.g. for keys copied from
+// openssl rand -hex. Upper and lower case digits are both accepted.
+type HexBytes struct {
+	Bytes
+}
+
+// SomeHexBytes returns a HexBytes holding value.
+func SomeHexBytes(value []byte) HexBytes {
+	return HexBytes{SomeBytes(value)}
+}
+
+// NoHexBytes returns an empty HexBytes.
+func NoHexBytes() HexBytes {
+	return HexBytes{}
+}
+
+// Or returns o if it is set and other otherwise.
+func (o HexBytes) Or(other HexBytes) HexBytes {
+	if o.some {
+		return o
+	}
+	return other
+}
+
+func (o HexBytes) Type() string {
+	return "HexBytes"
+}
+
+func (o HexBytes) String() string {
+	if !o.some {
+		return "None[HexBytes]"
+	}
+	return hex.EncodeToString(o.value)
+}
+
+// Set replaces the value with the hex decoded str.
+func (o *HexBytes) Set(str string) error {
+	value, err := hex.DecodeString(strings.TrimSpace(str))
+	if err != nil {
+		return fmt.Errorf("invalid hex value: %w", err)
+	}
+
+	o.Replace(value)
+	return nil
+}
+
+// UnmarshalText replaces the value with the hex decoded text.
+func (o *HexBytes) UnmarshalText(text []byte) error {
+	return o.Set(string(text))
+}
+
+// MarshalText returns the value as lower case hex.
+func (o HexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(o.value)), nil
+}
+
+// DecodeBase64 decodes str as standard or URL safe base64, with or without padding. The error does not include str,
+// since binary config values are often keys.
+func DecodeBase64(str string) ([]byte, error) {
+	str = strings.TrimRight(strings.TrimSpace(str), "=")
+	enc := base64.RawStdEncoding
+	if strings.ContainsAny(str, "-_") {
+		enc = base64.RawURLEncoding
+	}
+
+	value, err := enc.DecodeString(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 value: %w", err)
+	}
+	return value, nil
+}
@@ -0,0 +1,146 @@
+package ezconf_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestBytesSet(t *testing.T) {
+	want := []byte{0xfb, 0xff, 0x01, 'k', 'e', 'y'}
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "standard", value: "+/8Ba2V5"},
+		{name: "url safe", value: "-_8Ba2V5"},
+		{name: "surrounding space", value: " +/8Ba2V5\n"},
+		{name: "invalid", value: "not base64!", wantErr: "invalid base64 value"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var b ezconf.Bytes
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.Var(&b, "salt", "salt")
+
+			err := fs.Parse([]string{"-salt", tc.value})
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				assert.ErrorContains(t, err, "-salt")
+				assert.Assert(t, b.IsNone())
+				return
+			}
+
+			assert.NilError(t, err)
+			got, ok := b.Get()
+			assert.Assert(t, ok)
+			assert.DeepEqual(t, want, got)
+			assert.Equal(t, "+/8Ba2V5", b.String())
+		})
+	}
+}
+
+func TestHexBytesSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []byte
+		wantErr string
+	}{
+		{name: "lower case", value: "deadbeef", want: []byte{0xde, 0xad, 0xbe, 0xef}},
+		{name: "upper case", value: "DEADBEEF", want: []byte{0xde, 0xad, 0xbe, 0xef}},
+		{name: "odd length", value: "abc", wantErr: "invalid hex value"},
+		{name: "not hex", value: "zz", wantErr: "invalid hex value"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var b ezconf.HexBytes
+			err := b.Set(tc.value)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			got, ok := b.Get()
+			assert.Assert(t, ok)
+			assert.DeepEqual(t, tc.want, got)
+			assert.Equal(t, "deadbeef", b.String())
+		})
+	}
+}
+
+func TestBytesEnv(t *testing.T) {
+	t.Setenv("EZCONF_TEST_SALT", "c2FsdA==")
+	t.Setenv("EZCONF_TEST_KEY", "6b6579")
+	t.Setenv("EZCONF_TEST_BAD", "%%%")
+
+	var salt ezconf.Bytes
+	assert.NilError(t, ezconf.LoadEnv(&salt, "EZCONF_TEST_SALT"))
+	assert.DeepEqual(t, []byte("salt"), salt.GetOr(nil))
+
+	var key ezconf.HexBytes
+	assert.NilError(t, ezconf.LoadEnv(&key, "EZCONF_TEST_KEY"))
+	assert.DeepEqual(t, []byte("key"), key.GetOr(nil))
+
+	var bad ezconf.Bytes
+	err := ezconf.LoadEnv(&bad, "EZCONF_TEST_BAD")
+	assert.ErrorContains(t, err, "failed to load env var EZCONF_TEST_BAD: invalid base64 value")
+}
+
+type bytesTarget struct {
+	Salt ezconf.Bytes
+	Key  ezconf.HexBytes
+}
+
+func TestBytesDecodeFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		data    string
+		wantErr string
+	}{
+		{name: "toml", file: "app.toml", data: "Salt = \"c2FsdA==\"\nKey = \"6b6579\"\n"},
+		{name: "json", file: "app.json", data: `{"Salt": "c2FsdA==", "Key": "6b6579"}`},
+		{name: "yaml", file: "app.yaml", data: "salt: c2FsdA==\nkey: \"6b6579\"\n"},
+		{name: "invalid", file: "app.toml", data: "Salt = \"%%%\"\n", wantErr: "invalid base64 value"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			assert.NilError(t, os.WriteFile(path, []byte(tc.data), 0600))
+
+			var target bytesTarget
+			err := ezconf.DecodeFile(path, &target)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.DeepEqual(t, []byte("salt"), target.Salt.GetOr(nil))
+			assert.DeepEqual(t, []byte("key"), target.Key.GetOr(nil))
+		})
+	}
+}
+
+func TestBytesEncodeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.json")
+	saved := bytesTarget{Salt: ezconf.SomeBytes([]byte("salt")), Key: ezconf.SomeHexBytes([]byte("key"))}
+	err := ezconf.EncodeFile(path, saved)
+	assert.NilError(t, err)
+
+	var target bytesTarget
+	assert.NilError(t, ezconf.DecodeFile(path, &target))
+	assert.DeepEqual(t, []byte("salt"), target.Salt.GetOr(nil))
+	assert.DeepEqual(t, []byte("key"), target.Key.GetOr(nil))
+}
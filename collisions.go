@@ -0,0 +1,81 @@
+package ezconf
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// CheckEnvCollisions walks the struct tree of a loader and returns an error naming both field paths for every env var
+// which is read by more than one field. Two fields sharing an env var means one silently shadows the other, so this is
+// worth calling from a test for every loader. Besides the env tags, the deprecated names of fields tagged
+// deprecated:"OldName" are checked as LoadEnvStruct reads them. Duplicate flag names do not need the same check since
+// the flag package already panics when a name is registered twice.
+func CheckEnvCollisions(loader any) error {
+	return checkEnvCollisions(loader, false)
+}
+
+// CheckDerivedEnvCollisions is CheckEnvCollisions for loaders read by LoadEnvStruct with derive set, so fields without
+// an env tag are checked under the name derived by EnvName as well, e.g. DB.SSLMode and DB.SSL.Mode both reading
+// DB_SSL_MODE.
+func CheckDerivedEnvCollisions(loader any) error {
+	return checkEnvCollisions(loader, true)
+}
+
+func checkEnvCollisions(loader any, derive bool) error {
+	seen := make(map[string]string)
+	var errs []error
+	use := func(name, p string) {
+		prev, ok := seen[name]
+		if ok {
+			errs = append(errs, fmt.Errorf("env var %s is used by both %s and %s", name, prev, p))
+			return
+		}
+		seen[name] = p
+	}
+
+	var walk func(t reflect.Type, path string, visiting map[reflect.Type]bool)
+	walk = func(t reflect.Type, path string, visiting map[reflect.Type]bool) {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || visiting[t] {
+			return
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() || Ignored(f) {
+				continue
+			}
+
+			p := f.Tag.Get("field")
+			if p == "" {
+				p = f.Name
+			}
+			p = joinKey(path, p)
+
+			leaf := f.Type.Implements(flagValueType) || reflect.PointerTo(f.Type).Implements(flagValueType)
+			name, _, _ := strings.Cut(f.Tag.Get("env"), ",")
+			if name == "" && derive && leaf {
+				name = EnvName(p)
+			}
+			if name == "" {
+				walk(f.Type, p, visiting)
+				continue
+			}
+
+			use(name, p)
+			old := f.Tag.Get("deprecated")
+			if old != "" {
+				use(EnvName(joinKey(path, old)), fmt.Sprintf("%s (deprecated name %s)", p, old))
+			}
+		}
+	}
+
+	walk(reflect.TypeOf(loader), "", make(map[reflect.Type]bool))
+	return errors.Join(errs...)
+}
@@ -0,0 +1,84 @@
+package ezconf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/ezconf/file"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+type dbLoader struct {
+	Address optional.Str    `env:"APP_DB_ADDRESS"`
+	Port    optional.Uint16 `env:"APP_PORT"`
+}
+
+type serviceLoader struct {
+	Name optional.Str    `env:"APP_NAME"`
+	Port optional.Uint16 `env:"APP_PORT"`
+	Key  file.SecretFile `env:"APP_NAME"`
+}
+
+type appLoader struct {
+	Service serviceLoader
+	DB      *dbLoader
+	Legacy  optional.Str `env:"APP_DB_ADDRESS" config:"-"`
+	ignored optional.Str `env:"APP_DB_ADDRESS"`
+}
+
+func TestCheckEnvCollisions(t *testing.T) {
+	err := ezconf.CheckEnvCollisions(dbLoader{})
+	assert.NilError(t, err)
+
+	err = ezconf.CheckEnvCollisions(&appLoader{})
+	assert.ErrorContains(t, err, "env var APP_NAME is used by both Service.Name and Service.Key")
+	assert.ErrorContains(t, err, "env var APP_PORT is used by both Service.Port and DB.Port")
+	assert.Assert(t, !strings.Contains(err.Error(), "APP_DB_ADDRESS"), err)
+}
+
+type renamedLoader struct {
+	Host    optional.Str `env:"HOST"`
+	Address optional.Str `env:"DB_ADDRESS" deprecated:"Host"`
+	Timeout optional.Duration
+	DB      struct {
+		Timeout optional.Duration
+	}
+}
+
+type derivedLoader struct {
+	DB struct {
+		SSLMode optional.Str
+		SSL     struct {
+			Mode optional.Str
+		}
+	}
+	Port optional.Uint16 `env:"DB_SSL_MODE"`
+	Node optional.Uint32 `field:"node"`
+}
+
+func TestCheckEnvCollisionsDeprecated(t *testing.T) {
+	err := ezconf.CheckEnvCollisions(&renamedLoader{})
+	assert.Error(t, err, "env var HOST is used by both Host and Address (deprecated name Host)")
+}
+
+func TestCheckDerivedEnvCollisions(t *testing.T) {
+	// Without derive, only the env tags are read, so nothing collides.
+	assert.NilError(t, ezconf.CheckEnvCollisions(&derivedLoader{}))
+
+	err := ezconf.CheckDerivedEnvCollisions(&derivedLoader{})
+	assert.ErrorContains(t, err, "env var DB_SSL_MODE is used by both DB.SSLMode and DB.SSL.Mode")
+	assert.ErrorContains(t, err, "env var DB_SSL_MODE is used by both DB.SSLMode and Port")
+	assert.Assert(t, !strings.Contains(err.Error(), "NODE"), err)
+
+	// A deprecated name may also collide with a derived one, e.g. DB.Timeout with DB_TIMEOUT derived from Timeout.
+	type loader struct {
+		DB struct {
+			QueryTimeout optional.Duration `deprecated:"Timeout"`
+		}
+		DBTimeout optional.Duration
+	}
+	err = ezconf.CheckDerivedEnvCollisions(&loader{})
+	assert.Error(t, err, "env var DB_TIMEOUT is used by both DB.QueryTimeout (deprecated name Timeout) and DBTimeout")
+}
@@ -0,0 +1,65 @@
+package ezconf
+
+import (
+	"flag"
+
+	"github.com/brnsampson/ezconf/file"
+)
+
+// CompletionKind describes what sort of value a flag accepts so that shell completion scripts can suggest values.
+type CompletionKind int
+
+const (
+	CompleteFree      CompletionKind = iota // Any value. Nothing useful to suggest.
+	CompleteEnum                            // One of a fixed list of values.
+	CompleteFile                            // A path to a file.
+	CompleteNone                            // A boolean flag which takes no value.
+	CompleteDirectory                       // A path to a directory.
+)
+
+func (k CompletionKind) String() string {
+	switch k {
+	case CompleteFree:
+		return "free"
+	case CompleteEnum:
+		return "enum"
+	case CompleteFile:
+		return "file"
+	case CompleteNone:
+		return "none"
+	case CompleteDirectory:
+		return "directory"
+	default:
+		return "unknown"
+	}
+}
+
+// Completion is the completion metadata for a single flag. Values is only set for CompleteEnum.
+type Completion struct {
+	Kind   CompletionKind
+	Values []string
+}
+
+// FlagCompletions returns completion metadata for every flag registered on fs, keyed by flag name. The kind is derived
+// from the flag's value type: Enum flags complete their allowed values, file.Dir flags complete directories, the other
+// file package types complete file paths, and boolean flags take no value.
+func FlagCompletions(fs *flag.FlagSet) map[string]Completion {
+	completions := make(map[string]Completion)
+	fs.VisitAll(func(f *flag.Flag) {
+		var c Completion
+		switch v := f.Value.(type) {
+		case *Enum:
+			c = Completion{CompleteEnum, v.Allowed()}
+		case *file.File, *file.Files, *file.SecretFile, *file.Cert, *file.PubKey, *file.PrivateKey, *file.PKCS12, *file.Glob:
+			c = Completion{Kind: CompleteFile}
+		case *file.Dir:
+			c = Completion{Kind: CompleteDirectory}
+		case interface{ IsBoolFlag() bool }:
+			if v.IsBoolFlag() {
+				c = Completion{Kind: CompleteNone}
+			}
+		}
+		completions[f.Name] = c
+	})
+	return completions
+}
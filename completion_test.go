@@ -0,0 +1,39 @@
+package ezconf_test
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/ezconf/file"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+func TestFlagCompletions(t *testing.T) {
+	format := ezconf.NoEnum("json", "text", "logfmt")
+	cert := file.NoCert()
+	dir := file.NoDir()
+	var name optional.Str
+	var verbose bool
+	var feature optional.Bool
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&format, "logFormat", "log format")
+	fs.Var(&cert, "cert", "TLS certificate")
+	fs.Var(&dir, "dir", "config directory")
+	fs.Var(&name, "name", "service name")
+	fs.BoolVar(&verbose, "v", false, "verbose output")
+	ezconf.BoolVar(fs, &feature, "feature-x", "enable feature x")
+
+	want := map[string]ezconf.Completion{
+		"logFormat":    {Kind: ezconf.CompleteEnum, Values: []string{"json", "text", "logfmt"}},
+		"cert":         {Kind: ezconf.CompleteFile},
+		"dir":          {Kind: ezconf.CompleteDirectory},
+		"name":         {Kind: ezconf.CompleteFree},
+		"v":            {Kind: ezconf.CompleteNone},
+		"feature-x":    {Kind: ezconf.CompleteNone},
+		"no-feature-x": {Kind: ezconf.CompleteNone},
+	}
+	assert.DeepEqual(t, want, ezconf.FlagCompletions(fs))
+}
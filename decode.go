@@ -0,0 +1,291 @@
+package ezconf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/brnsampson/ezconf/file"
+	"gopkg.in/yaml.v3"
+)
+
+// StdinPath is the config file path which stands for stdin, as in -config -. DecodeFile does not treat it specially, so
+// loaders read stdin themselves and decode it with Decode.
+const StdinPath = "-"
+
+// Config file formats understood by Decode and DecodeFileFormat.
+const (
+	FormatJSON = "json"
+	FormatTOML = "toml"
+	FormatYAML = "yaml"
+)
+
+// Decoder decodes config data into the value the pointer v points to, which is normally a loader or a struct of
+// loaders. Decoders should set only the fields which appear in data, matching them by struct tags or field names, as
+// the built-in decoders do. Unmarshal functions such as json.Unmarshal are given v.Interface().
+type Decoder func(data []byte, v reflect.Value) error
+
+// unmarshal returns a Decoder which calls f, an unmarshal function such as json.Unmarshal.
+func unmarshal(f func([]byte, any) error) Decoder {
+	return func(data []byte, v reflect.Value) error {
+		return f(data, v.Interface())
+	}
+}
+
+var decoders = struct {
+	sync.RWMutex
+	m map[string]Decoder
+}{m: map[string]Decoder{
+	FormatTOML: unmarshal(toml.Unmarshal),
+	FormatJSON: unmarshal(json.Unmarshal),
+	FormatYAML: unmarshal(yaml.Unmarshal),
+	"yml":      unmarshal(yaml.Unmarshal),
+}}
+
+// RegisterDecoder makes d decode config files with the extension ext, e.g. .hcl or .ini, and config data given the
+// format ext without its leading dot, e.g. through the -configFormat flag. Extensions are not case sensitive.
+// Registering an extension again replaces the earlier decoder, including the built-in ones for .toml, .json, .yaml, and
+// .yml, and a nil d removes it.
+func RegisterDecoder(ext string, d Decoder) {
+	format := strings.ToLower(strings.TrimPrefix(ext, "."))
+	decoders.Lock()
+	defer decoders.Unlock()
+	if d == nil {
+		delete(decoders.m, format)
+		return
+	}
+	decoders.m[format] = d
+}
+
+// decoder returns the decoder registered for format.
+func decoder(format string) (Decoder, bool) {
+	decoders.RLock()
+	defer decoders.RUnlock()
+	d, ok := decoders.m[strings.ToLower(format)]
+	return d, ok
+}
+
+// formats returns the sorted names of the registered formats.
+func formats() []string {
+	decoders.RLock()
+	defer decoders.RUnlock()
+	names := make([]string, 0, len(decoders.m))
+	for name := range decoders.m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// DecodeFile decodes the config file at path into v. The format is chosen by the file extension, which must be one of
+// .toml, .json, .yaml, or .yml or have a decoder added with RegisterDecoder, or detected from the contents with
+// DetectFormat if path has no extension, e.g. /etc/app/config. Gzipped files are decompressed first, and the format of
+// a file with the .gz extension is chosen by the extension before it, e.g. app.toml.gz. Fields are matched using the
+// toml, json, and yaml struct tags respectively. Note that yaml expects untagged field names in lowercase. v is
+// normally a loader or a struct of loaders, whose optional fields are only set if they appear in the file.
+func DecodeFile[T any](path string, v *T) error {
+	return DecodeFileContext(context.Background(), path, v)
+}
+
+// DecodeFileContext is the same as DecodeFile, but gives up on reading the file once ctx is done, e.g. when the file is
+// on a slow network mount or is a pipe which nothing writes to. The returned error then wraps ctx.Err().
+func DecodeFileContext[T any](ctx context.Context, path string, v *T) error {
+	return DecodeFileFormat(ctx, path, "", v)
+}
+
+// DecodeFileFormat is the same as DecodeFileContext, but decodes the file as format, e.g. json, toml, yaml, or one
+// added with RegisterDecoder, regardless of its extension. An empty format falls back to the extension as in
+// DecodeFile.
+func DecodeFileFormat[T any](ctx context.Context, path, format string, v *T) error {
+	return decodeFile(ctx, path, format, v, Decode[T])
+}
+
+// decodeFile reads the file at path and decodes it into v with decode. A file with the .gz extension must be gzipped.
+func decodeFile[T any](ctx context.Context, path, format string, v *T, decode func([]byte, string, *T) error) error {
+	ext, gz := configFormat(path)
+	if format == "" && ext != "" {
+		format = ext
+		_, ok := decoder(format)
+		if !ok {
+			err := fmt.Errorf("unsupported config file extension %q", "."+ext)
+			return &FileLoadError{Path: path, Op: "decode", Err: err}
+		}
+	}
+
+	data, err := readFile(ctx, path)
+	if err != nil {
+		return &FileLoadError{Path: path, Op: "read", Err: err}
+	}
+	if gz && !IsGzip(data) {
+		err = fmt.Errorf("corrupt gzip stream: %w", gzip.ErrHeader)
+		return &FileLoadError{Path: path, Op: "decode", Err: err}
+	}
+
+	err = decode(data, format, v)
+	if err != nil {
+		return &FileLoadError{Path: path, Op: "decode", Err: err}
+	}
+	return nil
+}
+
+// Decode decodes config data, e.g. read from stdin, into v as format, e.g. json, toml, yaml, or one added with
+// RegisterDecoder. An empty format is detected with DetectFormat. Gzipped data is decompressed first, see Gunzip.
+func Decode[T any](data []byte, format string, v *T) error {
+	data, err := Gunzip(data)
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		format, err = DetectFormat(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	d, ok := decoder(format)
+	if !ok {
+		return fmt.Errorf("unsupported config file format %q: must be one of [%s]", format, strings.Join(formats(), ", "))
+	}
+	return d(data, reflect.ValueOf(v))
+}
+
+// DetectFormat returns the format of config data by trying JSON, then TOML, then YAML, and returning the first one it
+// parses as. Only YAML mappings count as YAML, since almost any text is a valid YAML scalar. Formats added with
+// RegisterDecoder are never detected, so files in them need an extension or an explicit format.
+func DetectFormat(data []byte) (string, error) {
+	if json.Valid(data) {
+		return FormatJSON, nil
+	}
+
+	var m map[string]any
+	if toml.Unmarshal(data, &m) == nil {
+		return FormatTOML, nil
+	}
+	if yaml.Unmarshal(data, &m) == nil {
+		return FormatYAML, nil
+	}
+	return "", fmt.Errorf("cannot detect the config file format: not valid JSON, TOML, or YAML")
+}
+
+// FileLoadError is returned by DecodeFile when the config file at Path could not be read or decoded, and by loaders
+// when the file a field such as a secret is read from could not be, in which case Field holds the path of that field.
+// Op is "read" or "decode", and Err is the underlying error, so that e.g. errors.Is(err, fs.ErrNotExist) finds missing
+// files.
+type FileLoadError struct {
+	Path  string
+	Op    string
+	Field string
+	Err   error
+}
+
+func (e *FileLoadError) Error() string {
+	op := e.Op
+	if op == "" {
+		op = "load"
+	}
+	what := "config file"
+	if e.Field != "" {
+		what = e.Field + " file"
+	}
+	return fmt.Sprintf("failed to %s %s %s: %v", op, what, e.Path, e.Err)
+}
+
+func (e *FileLoadError) Unwrap() error {
+	return e.Err
+}
+
+// readFile reads the file at path in the background so that it can return as soon as ctx is done. A read which is stuck
+// keeps its goroutine until the read returns, but its result is dropped.
+func readFile(ctx context.Context, path string) ([]byte, error) {
+	if ctx.Done() == nil {
+		return os.ReadFile(path)
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := os.ReadFile(path)
+		done <- result{data, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// EncodeFile writes v to the config file at path, choosing the format by the file extension in the same way as
+// DecodeFile. Any existing file is overwritten.
+func EncodeFile(path string, v any) error {
+	data, err := encodeFile(path, v)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// EncodeSecretFile is the same as EncodeFile, but the file is only readable by its owner, for configs which hold key
+// material. The permissions of an existing file are tightened as well, before anything is written to it.
+func EncodeSecretFile(path string, v any) error {
+	data, err := encodeFile(path, v)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.KeyFilePerms)
+	if err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	err = f.Chmod(file.KeyFilePerms)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	err = errors.Join(err, f.Close())
+	if err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// encodeFile encodes v in the format given by the extension of path.
+func encodeFile(path string, v any) ([]byte, error) {
+	var data []byte
+	var err error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		var buf bytes.Buffer
+		err = toml.NewEncoder(&buf).Encode(v)
+		data = buf.Bytes()
+	case ".json":
+		data, err = json.MarshalIndent(v, "", "  ")
+	case ".yaml", ".yml":
+		data, err = yaml.Marshal(v)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q for %s", ext, path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config file %s: %w", path, err)
+	}
+	return data, nil
+}
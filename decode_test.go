@@ -0,0 +1,259 @@
+package ezconf_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+type decodeTarget struct {
+	Name    optional.Str
+	Port    optional.Uint16
+	Enabled optional.Bool
+	Unset   optional.Str
+}
+
+func TestDecodeFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		data    string
+		wantErr string
+	}{
+		{name: "toml", file: "app.toml", data: "Name = \"app\"\nPort = 8080\nEnabled = true\n"},
+		{name: "json", file: "app.json", data: `{"Name": "app", "Port": 8080, "Enabled": true}`},
+		{name: "yaml", file: "app.yaml", data: "name: app\nport: 8080\nenabled: true\n"},
+		{name: "yml", file: "app.yml", data: "name: app\nport: 8080\nenabled: true\n"},
+		{name: "unknown extension", file: "app.ini", data: "Name=app", wantErr: `unsupported config file extension ".ini"`},
+		{name: "malformed", file: "app.json", data: `{"Name": `, wantErr: "failed to decode config file"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			assert.NilError(t, os.WriteFile(path, []byte(tc.data), 0600))
+
+			var target decodeTarget
+			err := ezconf.DecodeFile(path, &target)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, optional.SomeStr("app"), target.Name)
+			assert.Equal(t, optional.SomeUint16(8080), target.Port)
+			assert.Equal(t, optional.SomeBool(true), target.Enabled)
+			assert.Assert(t, target.Unset.IsNone())
+		})
+	}
+}
+
+func TestDecodeFileDetectFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr string
+	}{
+		{name: "json", data: `{"Name": "app", "Port": 8080, "Enabled": true}`, want: ezconf.FormatJSON},
+		{name: "toml", data: "Name = \"app\"\nPort = 8080\nEnabled = true\n", want: ezconf.FormatTOML},
+		{name: "yaml", data: "name: app\nport: 8080\nenabled: true\n", want: ezconf.FormatYAML},
+		{name: "none", data: "just some text", wantErr: "cannot detect the config file format"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			format, err := ezconf.DetectFormat([]byte(tc.data))
+			path := filepath.Join(t.TempDir(), "config")
+			assert.NilError(t, os.WriteFile(path, []byte(tc.data), 0600))
+			var target decodeTarget
+			decodeErr := ezconf.DecodeFile(path, &target)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				assert.ErrorContains(t, decodeErr, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, format)
+			assert.NilError(t, decodeErr)
+			assert.Equal(t, optional.SomeStr("app"), target.Name)
+			assert.Equal(t, optional.SomeUint16(8080), target.Port)
+			assert.Equal(t, optional.SomeBool(true), target.Enabled)
+		})
+	}
+}
+
+func TestDecodeFileFormat(t *testing.T) {
+	// The format overrides a misleading extension.
+	path := filepath.Join(t.TempDir(), "app.conf.json")
+	assert.NilError(t, os.WriteFile(path, []byte("Name = \"app\"\n"), 0600))
+
+	var target decodeTarget
+	err := ezconf.DecodeFileFormat(context.Background(), path, ezconf.FormatTOML, &target)
+	assert.NilError(t, err)
+	assert.Equal(t, optional.SomeStr("app"), target.Name)
+
+	err = ezconf.DecodeFile(path, &target)
+	assert.ErrorContains(t, err, "failed to decode config file")
+
+	err = ezconf.DecodeFileFormat(context.Background(), path, "ini", &target)
+	assert.ErrorContains(t, err, `unsupported config file format "ini"`)
+}
+
+// decodeKV is a trivial decoder for key=value lines, which it hands to json so that fields are matched the same way.
+func decodeKV(data []byte, v reflect.Value) error {
+	m := make(map[string]any)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("invalid line %q", line)
+		}
+		n, err := strconv.Atoi(value)
+		m[key] = value
+		if err == nil {
+			m[key] = n
+		}
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v.Interface())
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	ezconf.RegisterDecoder(".KV", decodeKV)
+	t.Cleanup(func() { ezconf.RegisterDecoder(".kv", nil) })
+
+	tests := []struct {
+		name    string
+		file    string
+		format  string
+		data    string
+		wantErr string
+	}{
+		{name: "by extension", file: "app.kv", data: "Name=app\nPort=8080\n"},
+		{name: "upper case extension", file: "app.KV", data: "Name=app\nPort=8080\n"},
+		{name: "by format", file: "app.conf", format: "kv", data: "Name=app\nPort=8080\n"},
+		{name: "decoder error", file: "app.kv", data: "Name app\n", wantErr: `failed to decode config file`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			assert.NilError(t, os.WriteFile(path, []byte(tc.data), 0600))
+
+			var target decodeTarget
+			err := ezconf.DecodeFileFormat(context.Background(), path, tc.format, &target)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, optional.SomeStr("app"), target.Name)
+			assert.Equal(t, optional.SomeUint16(8080), target.Port)
+			assert.Assert(t, target.Unset.IsNone())
+		})
+	}
+
+	var target decodeTarget
+	err := ezconf.Decode([]byte("Name=app"), "ini", &target)
+	assert.ErrorContains(t, err, `unsupported config file format "ini": must be one of [json, kv, toml, yaml, yml]`)
+
+	// Removing a decoder makes its extension unsupported again.
+	ezconf.RegisterDecoder("kv", nil)
+	path := filepath.Join(t.TempDir(), "app.kv")
+	assert.NilError(t, os.WriteFile(path, []byte("Name=app"), 0600))
+	err = ezconf.DecodeFile(path, &target)
+	assert.ErrorContains(t, err, `unsupported config file extension ".kv"`)
+}
+
+func TestDecodeFileMissing(t *testing.T) {
+	var target decodeTarget
+	err := ezconf.DecodeFile(filepath.Join(t.TempDir(), "missing.toml"), &target)
+	assert.ErrorContains(t, err, "failed to read config file")
+	assert.Assert(t, errors.Is(err, fs.ErrNotExist))
+
+	var loadErr *ezconf.FileLoadError
+	assert.Assert(t, errors.As(err, &loadErr))
+	assert.Equal(t, "read", loadErr.Op)
+	assert.Equal(t, "missing.toml", filepath.Base(loadErr.Path))
+}
+
+func TestEncodeFile(t *testing.T) {
+	type config struct {
+		Name  string
+		Port  uint16
+		Hosts []string
+	}
+
+	for _, ext := range []string{".toml", ".json", ".yaml"} {
+		t.Run(ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app"+ext)
+			want := config{Name: "app", Port: 8080, Hosts: []string{"a", "b"}}
+
+			err := ezconf.EncodeFile(path, want)
+			assert.NilError(t, err)
+
+			var got config
+			err = ezconf.DecodeFile(path, &got)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, want, got)
+		})
+	}
+
+	err := ezconf.EncodeFile(filepath.Join(t.TempDir(), "app.ini"), config{})
+	assert.ErrorContains(t, err, `unsupported config file extension ".ini"`)
+}
+
+func TestEncodeSecretFile(t *testing.T) {
+	type config struct {
+		Key string
+	}
+
+	tests := []struct {
+		name  string
+		exist bool
+	}{
+		{name: "new file"},
+		{name: "existing file", exist: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.toml")
+			if tc.exist {
+				err := os.WriteFile(path, []byte("Key = \"old\"\n"), 0644)
+				assert.NilError(t, err)
+			}
+
+			err := ezconf.EncodeSecretFile(path, config{Key: "secret"})
+			assert.NilError(t, err)
+
+			info, err := os.Stat(path)
+			assert.NilError(t, err)
+			assert.Equal(t, fs.FileMode(0600), info.Mode().Perm())
+
+			var got config
+			err = ezconf.DecodeFile(path, &got)
+			assert.NilError(t, err)
+			assert.Equal(t, "secret", got.Key)
+		})
+	}
+}
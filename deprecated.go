@@ -0,0 +1,68 @@
+package ezconf
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// deprecationKey identifies a deprecation warning so that each one is only logged once.
+type deprecationKey struct {
+	where, old, current string
+	ignored             bool
+}
+
+var deprecationsWarned sync.Map // deprecationKey -> struct{}
+
+// WarnDeprecated logs a warning to slog.Default that the config value given under the deprecated name old in where,
+// e.g. a config file path or "env", should be given as current instead. Set ignored if current was given too, so that
+// the value under old was not used. Each warning is only logged once per process, however often the config is reloaded.
+func WarnDeprecated(where, old, current string, ignored bool) {
+	_, warned := deprecationsWarned.LoadOrStore(deprecationKey{where, old, current, ignored}, struct{}{})
+	if warned {
+		return
+	}
+
+	if ignored {
+		slog.Warn("ignoring config value given under a deprecated name, since the current name is set too",
+			"where", where, "deprecated", old, "current", current)
+		return
+	}
+	slog.Warn("config value given under a deprecated name", "where", where, "deprecated", old, "current", current)
+}
+
+// UseDeprecated returns current, a value given under its current name currentName, if it is set, and otherwise old, the
+// same value given under the deprecated name oldName in where. A warning is logged with WarnDeprecated whenever old is
+// set.
+func UseDeprecated[T interface{ IsSome() bool }](current, old T, where, oldName, currentName string) T {
+	if !old.IsSome() {
+		return current
+	}
+
+	WarnDeprecated(where, oldName, currentName, current.IsSome())
+	if current.IsSome() {
+		return current
+	}
+	return old
+}
+
+// LoadDeprecatedEnv sets v from the env var old, the deprecated name of the env var current, with a warning logged by
+// WarnDeprecated. If current is set too, old is ignored, as current is expected to have been loaded into v already.
+func LoadDeprecatedEnv(v flag.Value, old, current string) error {
+	return LoadDeprecatedEnvFrom(os.Getenv, v, old, current)
+}
+
+// LoadDeprecatedEnvFrom is LoadDeprecatedEnv with the env vars looked up by getenv rather than os.Getenv.
+func LoadDeprecatedEnvFrom(getenv func(string) string, v flag.Value, old, current string) error {
+	if getenv(old) == "" {
+		return nil
+	}
+
+	ignored := getenv(current) != ""
+	WarnDeprecated("env", old, current, ignored)
+	if ignored {
+		return nil
+	}
+	return LoadEnvFrom(getenv, v, old)
+}
@@ -0,0 +1,96 @@
+package ezconf_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+// captureWarnings sends slog.Default to a buffer for the rest of the test and returns it.
+func captureWarnings(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestUseDeprecated(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     optional.Str
+		old         optional.Str
+		want        optional.Str
+		wantWarning string
+	}{
+		{name: "current only", current: optional.SomeStr("new"), want: optional.SomeStr("new")},
+		{
+			name: "deprecated only", old: optional.SomeStr("old"), want: optional.SomeStr("old"),
+			wantWarning: "config value given under a deprecated name",
+		},
+		{
+			name: "both", current: optional.SomeStr("new"), old: optional.SomeStr("old"), want: optional.SomeStr("new"),
+			wantWarning: "ignoring config value given under a deprecated name",
+		},
+		{name: "neither"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			warnings := captureWarnings(t)
+			got := ezconf.UseDeprecated(tc.current, tc.old, "use-deprecated-"+tc.name, "Host", "Address")
+			assert.Equal(t, tc.want, got)
+			if tc.wantWarning == "" {
+				assert.Equal(t, "", warnings.String())
+				return
+			}
+
+			assert.Assert(t, strings.Contains(warnings.String(), tc.wantWarning), warnings.String())
+			assert.Assert(t, strings.Contains(warnings.String(), "deprecated=Host current=Address"), warnings.String())
+
+			// The same warning is only logged once.
+			ezconf.UseDeprecated(tc.current, tc.old, "use-deprecated-"+tc.name, "Host", "Address")
+			assert.Equal(t, 1, strings.Count(warnings.String(), "level=WARN"))
+		})
+	}
+}
+
+type deprecatedEnvLoader struct {
+	DB struct {
+		Address optional.Str `env:"DB_ADDRESS" deprecated:"Host"`
+		Timeout optional.Duration
+	}
+}
+
+func TestLoadEnvStructDeprecated(t *testing.T) {
+	warnings := captureWarnings(t)
+	t.Setenv("EZCONF_DEPRECATED_DB_HOST", "db.old")
+
+	var l deprecatedEnvLoader
+	assert.NilError(t, ezconf.LoadEnvStruct(&l, "EZCONF_DEPRECATED_", false))
+	assert.Equal(t, optional.SomeStr("db.old"), l.DB.Address)
+	want := "where=env deprecated=EZCONF_DEPRECATED_DB_HOST current=EZCONF_DEPRECATED_DB_ADDRESS"
+	assert.Assert(t, strings.Contains(warnings.String(), want), warnings.String())
+
+	// The current name wins.
+	t.Setenv("EZCONF_DEPRECATED_DB_ADDRESS", "db.new")
+	l = deprecatedEnvLoader{}
+	assert.NilError(t, ezconf.LoadEnvStruct(&l, "EZCONF_DEPRECATED_", false))
+	assert.Equal(t, optional.SomeStr("db.new"), l.DB.Address)
+	want = "ignoring config value given under a deprecated name"
+	assert.Assert(t, strings.Contains(warnings.String(), want), warnings.String())
+
+	// Malformed values under the deprecated name are reported by that name.
+	t.Setenv("EZCONF_DEPRECATED_TIMEOUT", "soon")
+	var bad struct {
+		QueryTimeout optional.Duration `env:"QUERY_TIMEOUT" deprecated:"Timeout"`
+	}
+	err := ezconf.LoadEnvStruct(&bad, "EZCONF_DEPRECATED_", false)
+	assert.ErrorContains(t, err, "failed to load env var EZCONF_DEPRECATED_TIMEOUT")
+}
@@ -0,0 +1,108 @@
+package ezconf
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/brnsampson/optional"
+)
+
+// FieldChange describes a field which differs between two configs. Path is the dotted field path, e.g.
+// MyService.ServerConfig.Port. Old and New are only filled in for values which are safe to print. Secrets and byte
+// slices, which usually hold keys, leave them empty, so a FieldChange can be logged as is.
+type FieldChange struct {
+	Path string
+	Old  string
+	New  string
+}
+
+func (c FieldChange) String() string {
+	if c.Old == "" && c.New == "" {
+		return c.Path + " changed"
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Path, c.Old, c.New)
+}
+
+var stringerType = reflect.TypeFor[fmt.Stringer]()
+
+// secretTypes are never printed by Diff, not even redacted, so that a FieldChange only says that they changed.
+var secretTypes = map[reflect.Type]bool{
+	reflect.TypeFor[optional.Secret](): true,
+	reflect.TypeFor[Secret]():          true,
+	reflect.TypeFor[SecretFlag]():      true,
+}
+
+// Diff returns every exported field which differs between old and next, in field order, skipping Ignored fields.
+// Structs are compared field by field, except for types with a String method such as optional.Secret, which are
+// compared as a whole. Fields are compared with reflect.DeepEqual, so a pointer to a struct holding funcs, such as a
+// *tls.Config with a VerifyConnection callback, is reported as changed whenever the two configs hold different
+// pointers.
+func Diff[T any](old, next T) []FieldChange {
+	var changes []FieldChange
+	diff(reflect.ValueOf(old), reflect.ValueOf(next), "", &changes)
+	return changes
+}
+
+func diff(old, next reflect.Value, path string, changes *[]FieldChange) {
+	t := old.Type()
+	if t.Kind() == reflect.Struct && !t.Implements(stringerType) {
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() || Ignored(f) {
+				continue
+			}
+
+			p := f.Name
+			if path != "" {
+				p = path + "." + f.Name
+			}
+			diff(old.Field(i), next.Field(i), p, changes)
+		}
+		return
+	}
+
+	if reflect.DeepEqual(old.Interface(), next.Interface()) {
+		return
+	}
+	*changes = append(*changes, FieldChange{path, describe(old), describe(next)})
+}
+
+// IgnoreChanges returns changes without those to the fields in ignore, which are dotted paths as in FieldChange.Path. A
+// path also ignores every field below it, e.g. MyService.ServerConfig ignores MyService.ServerConfig.Port.
+func IgnoreChanges(changes []FieldChange, ignore []string) []FieldChange {
+	return slices.DeleteFunc(changes, func(c FieldChange) bool {
+		return slices.ContainsFunc(ignore, func(path string) bool {
+			return c.Path == path || strings.HasPrefix(c.Path, path+".")
+		})
+	})
+}
+
+// describe prints v if that is known to be safe, and returns an empty string otherwise.
+func describe(v reflect.Value) string {
+	if secretTypes[v.Type()] {
+		return ""
+	}
+	if v.Type().Implements(stringerType) || printable(v.Type()) {
+		return fmt.Sprint(v.Interface())
+	}
+	return ""
+}
+
+func printable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice, reflect.Array:
+		// Byte slices such as session keys and salts are treated as secrets.
+		return t.Elem().Kind() != reflect.Uint8 && printable(t.Elem())
+	case reflect.Map:
+		return printable(t.Key()) && printable(t.Elem())
+	default:
+		return false
+	}
+}
@@ -0,0 +1,118 @@
+package ezconf_test
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+type diffServer struct {
+	Port    uint16
+	Timeout time.Duration
+	Tls     *tls.Config
+}
+
+type diffConfig struct {
+	Name   string
+	Hosts  []string
+	Secret optional.Secret
+	Key    []byte
+	Ticket [4]byte
+	Server diffServer
+	Cache  map[string]string `config:"-"`
+	Hits   int               `ezconf:"-"`
+	hidden int
+}
+
+func TestDiff(t *testing.T) {
+	base := diffConfig{
+		Name:   "app",
+		Hosts:  []string{"a"},
+		Secret: optional.SomeSecret("hunter2"),
+		Key:    []byte("hunter2"),
+		Server: diffServer{Port: 80, Timeout: time.Second},
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *diffConfig)
+		want   []ezconf.FieldChange
+	}{
+		{name: "no change", change: func(c *diffConfig) {}},
+		{name: "unexported", change: func(c *diffConfig) { c.hidden = 1 }},
+		{name: "ignored", change: func(c *diffConfig) { c.Cache = map[string]string{"a": "b"}; c.Hits = 1 }},
+		{
+			name:   "nested field",
+			change: func(c *diffConfig) { c.Server.Port = 443 },
+			want:   []ezconf.FieldChange{{Path: "Server.Port", Old: "80", New: "443"}},
+		},
+		{
+			name: "several fields",
+			change: func(c *diffConfig) {
+				c.Name = "other"
+				c.Hosts = []string{"a", "b"}
+				c.Server.Timeout = time.Minute
+			},
+			want: []ezconf.FieldChange{
+				{Path: "Name", Old: "app", New: "other"},
+				{Path: "Hosts", Old: "[a]", New: "[a b]"},
+				{Path: "Server.Timeout", Old: "1s", New: "1m0s"},
+			},
+		},
+		{
+			name:   "secret",
+			change: func(c *diffConfig) { c.Secret = optional.SomeSecret("hunter3") },
+			want:   []ezconf.FieldChange{{Path: "Secret"}},
+		},
+		{
+			name: "keys",
+			change: func(c *diffConfig) {
+				c.Key = []byte("hunter3")
+				c.Ticket = [4]byte{1, 2, 3, 4}
+			},
+			want: []ezconf.FieldChange{{Path: "Key"}, {Path: "Ticket"}},
+		},
+		{
+			name:   "unprintable",
+			change: func(c *diffConfig) { c.Server.Tls = &tls.Config{ServerName: "example.com"} },
+			want:   []ezconf.FieldChange{{Path: "Server.Tls"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			next := base
+			next.Hosts = append([]string{}, base.Hosts...)
+			tc.change(&next)
+
+			got := ezconf.Diff(base, next)
+			assert.DeepEqual(t, tc.want, got)
+		})
+	}
+}
+
+func TestFieldChangeString(t *testing.T) {
+	assert.Equal(t, "Server.Port: 80 -> 443", ezconf.FieldChange{Path: "Server.Port", Old: "80", New: "443"}.String())
+	assert.Equal(t, "Server.Tls changed", ezconf.FieldChange{Path: "Server.Tls"}.String())
+}
+
+func TestIgnoreChanges(t *testing.T) {
+	changes := []ezconf.FieldChange{
+		{Path: "Service.NodeID", Old: "1", New: "2"},
+		{Path: "Service.Server.Port", Old: "80", New: "443"},
+		{Path: "Service.Server.Address", Old: "a", New: "b"},
+		{Path: "Service.ServerName", Old: "a", New: "b"},
+		{Path: "DB.Port", Old: "5432", New: "5433"},
+	}
+
+	got := ezconf.IgnoreChanges(changes, []string{"Service.NodeID", "Service.Server"})
+	want := []ezconf.FieldChange{
+		{Path: "Service.ServerName", Old: "a", New: "b"},
+		{Path: "DB.Port", Old: "5432", New: "5433"},
+	}
+	assert.DeepEqual(t, want, got)
+}
@@ -0,0 +1,135 @@
+package ezconf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ParseDotEnv parses a .env file as used for local development, one NAME=value pair per line. Blank lines and lines
+// starting with # are skipped, and a leading export is allowed so that the file can also be sourced by a shell.
+// Unquoted values are trimmed and end at a # preceded by a space. Values in single quotes are taken literally, while
+// values in double quotes may span lines and have \n, \t, \", and \\ escapes. A later line for the same name wins.
+func ParseDotEnv(r io.Reader) (map[string]string, error) {
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("line %d: expected NAME=value, got %q", n, line)
+		}
+
+		value = strings.TrimSpace(value)
+		start := n
+		for strings.HasPrefix(value, `"`) && !closedQuote(value) && scanner.Scan() {
+			n++
+			value += "\n" + scanner.Text()
+		}
+		value, err := dotEnvValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", start, name, err)
+		}
+		vars[name] = value
+	}
+	return vars, scanner.Err()
+}
+
+// closedQuote reports whether the double quoted value, which may continue on later lines, has its closing quote.
+func closedQuote(value string) bool {
+	escaped := false
+	for _, r := range value[1:] {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return true
+		}
+	}
+	return false
+}
+
+// dotEnvValue returns the value of a .env line with its quotes, escapes, and trailing comment handled.
+func dotEnvValue(value string) (string, error) {
+	if strings.HasPrefix(value, "'") {
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single quote")
+		}
+		return value[1 : end+1], trailingComment(value[end+2:])
+	}
+
+	if !strings.HasPrefix(value, `"`) {
+		i := strings.Index(value, " #")
+		if i >= 0 {
+			value = value[:i]
+		}
+		return strings.TrimSpace(value), nil
+	}
+
+	var b strings.Builder
+	escaped := false
+	for i, r := range value[1:] {
+		switch {
+		case escaped:
+			escaped = false
+			switch r {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			case '"', '\\':
+				b.WriteRune(r)
+			default:
+				b.WriteRune('\\')
+				b.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return b.String(), trailingComment(value[i+2:])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", fmt.Errorf("unterminated double quote")
+}
+
+// trailingComment checks that nothing but a comment follows a quoted value.
+func trailingComment(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected %q after quoted value", rest)
+	}
+	return nil
+}
+
+// ReadDotEnv reads the .env file at path with ParseDotEnv. The vars are returned rather than set in the process
+// environment, so that callers can layer them under the real env vars, e.g. with LoadEnvFrom, and read the file again
+// on every reload without leftovers from an earlier version of it. A missing file is a *FileLoadError wrapping
+// fs.ErrNotExist.
+func ReadDotEnv(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &FileLoadError{Path: path, Op: "read", Err: err}
+	}
+	defer f.Close()
+
+	vars, err := ParseDotEnv(f)
+	if err != nil {
+		return nil, &FileLoadError{Path: path, Op: "decode", Err: err}
+	}
+	return vars, nil
+}
@@ -0,0 +1,91 @@
+package ezconf_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "plain", data: "PORT=8080\nHOST = db.internal \n",
+			want: map[string]string{"PORT": "8080", "HOST": "db.internal"},
+		},
+		{
+			name: "comments", data: "# local settings\n\nPORT=8080 # the dev port\nTAG=a#b\n",
+			want: map[string]string{"PORT": "8080", "TAG": "a#b"},
+		},
+		{name: "export", data: "export PORT=8080\n", want: map[string]string{"PORT": "8080"}},
+		{name: "empty", data: "PORT=\n", want: map[string]string{"PORT": ""}},
+		{name: "single quotes", data: `NAME='a "b" \n # c' # comment`, want: map[string]string{"NAME": `a "b" \n # c`}},
+		{
+			name: "double quotes", data: `NAME="a \"b\"\tc\n\\ # d" # comment`,
+			want: map[string]string{"NAME": "a \"b\"\tc\n\\ # d"},
+		},
+		{
+			name: "multiline", data: "KEY=\"line one\nline two\"\nPORT=8080\n",
+			want: map[string]string{"KEY": "line one\nline two", "PORT": "8080"},
+		},
+		{name: "later wins", data: "PORT=1\nPORT=2\n", want: map[string]string{"PORT": "2"}},
+		{name: "no equals", data: "PORT=1\nPORT\n", wantErr: `line 2: expected NAME=value, got "PORT"`},
+		{name: "space in name", data: "MY PORT=1\n", wantErr: "line 1: expected NAME=value"},
+		{name: "unterminated single", data: "NAME='abc\n", wantErr: "line 1: NAME: unterminated single quote"},
+		{name: "unterminated double", data: "NAME=\"abc\nPORT=1\n", wantErr: "line 1: NAME: unterminated double quote"},
+		{name: "text after quote", data: `NAME="abc" def`, wantErr: `unexpected "def" after quoted value`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ezconf.ParseDotEnv(strings.NewReader(tc.data))
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.want, got)
+		})
+	}
+}
+
+func TestReadDotEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	data := "EZCONF_TEST_PORT=8080\nEZCONF_TEST_HOST=\"from-file\"\n"
+	assert.NilError(t, os.WriteFile(path, []byte(data), 0600))
+	t.Setenv("EZCONF_TEST_HOST", "from-env")
+
+	vars, err := ezconf.ReadDotEnv(path)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]string{"EZCONF_TEST_PORT": "8080", "EZCONF_TEST_HOST": "from-file"}, vars)
+	// The vars are only returned, never set in the process environment.
+	assert.Equal(t, "", os.Getenv("EZCONF_TEST_PORT"))
+	assert.Equal(t, "from-env", os.Getenv("EZCONF_TEST_HOST"))
+
+	port := optional.NoInt()
+	assert.NilError(t, ezconf.LoadEnvFrom(func(name string) string { return vars[name] }, &port, "EZCONF_TEST_PORT"))
+	assert.Equal(t, 8080, port.MustGet())
+
+	_, err = ezconf.ReadDotEnv(filepath.Join(t.TempDir(), "missing.env"))
+	var loadErr *ezconf.FileLoadError
+	assert.Assert(t, errors.As(err, &loadErr))
+	assert.Equal(t, "read", loadErr.Op)
+	assert.Assert(t, errors.Is(err, fs.ErrNotExist))
+
+	assert.NilError(t, os.WriteFile(path, []byte("not an assignment\n"), 0600))
+	_, err = ezconf.ReadDotEnv(path)
+	assert.Assert(t, errors.As(err, &loadErr))
+	assert.Equal(t, "decode", loadErr.Op)
+}
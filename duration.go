@@ -0,0 +1,85 @@
+package ezconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/brnsampson/optional"
+)
+
+// Duration is an optional.Duration for fields documented in a fixed unit such as seconds. A bare number without a unit
+// suffix, e.g. 30, is read in the default unit while values like 500ms are parsed with time.ParseDuration as usual.
+// This removes the ambiguity of bare numbers when migrating numeric configs.
+type Duration struct {
+	optional.Duration
+	unit time.Duration
+}
+
+// SomeDuration returns a Duration holding value which reads bare numbers in the given unit, e.g. "s" or "ms".
+func SomeDuration(value time.Duration, unit string) (Duration, error) {
+	o, err := NoDuration(unit)
+	o.Replace(value)
+	return o, err
+}
+
+// NoDuration returns a None Duration which reads bare numbers in the given unit, e.g. "s" or "ms". An error is
+// returned if unit is not a valid time.Duration unit.
+func NoDuration(unit string) (Duration, error) {
+	d, err := time.ParseDuration("1" + unit)
+	if err != nil {
+		return Duration{}, fmt.Errorf("invalid default duration unit %q: %w", unit, err)
+	}
+	return Duration{optional.NoDuration(), d}, nil
+}
+
+func (o *Duration) Set(str string) error {
+	return o.UnmarshalText([]byte(str))
+}
+
+func (o *Duration) UnmarshalText(text []byte) error {
+	f, err := strconv.ParseFloat(string(text), 64)
+	if err != nil || o.unit == 0 {
+		return o.Duration.UnmarshalText(text)
+	}
+
+	o.Replace(time.Duration(f * float64(o.unit)))
+	return nil
+}
+
+// ParseDuration parses s with time.ParseDuration, unless s is a bare number such as 30, which is read in unit, e.g.
+// "s". This is how fields tagged defaultUnit are read. An error is returned if unit is not a valid time.Duration unit,
+// even if s has a unit of its own, so that a bad tag is caught early. An empty unit reads s with time.ParseDuration
+// alone.
+func ParseDuration(s, unit string) (time.Duration, error) {
+	if unit == "" {
+		return time.ParseDuration(s)
+	}
+
+	d, err := time.ParseDuration("1" + unit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid default duration unit %q: %w", unit, err)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.ParseDuration(s)
+	}
+	return time.Duration(f * float64(d)), nil
+}
+
+// UnmarshalJSON accepts both strings and bare numbers, which are read in the default unit.
+func (o *Duration) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		o.Clear()
+		return nil
+	}
+
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return o.UnmarshalText(data)
+	}
+
+	return o.UnmarshalText([]byte(s))
+}
@@ -0,0 +1,95 @@
+package ezconf_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestDurationSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		unit    string
+		value   string
+		want    time.Duration
+		wantErr string
+	}{
+		{name: "bare seconds", unit: "s", value: "30", want: 30 * time.Second},
+		{name: "fractional", unit: "s", value: "1.5", want: 1500 * time.Millisecond},
+		{name: "explicit unit wins", unit: "s", value: "500ms", want: 500 * time.Millisecond},
+		{name: "bare minutes", unit: "m", value: "2", want: 2 * time.Minute},
+		{name: "garbage", unit: "s", value: "soon", wantErr: "invalid duration"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := ezconf.NoDuration(tc.unit)
+			assert.NilError(t, err)
+
+			err = o.Set(tc.value)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, o.MustGet())
+		})
+	}
+}
+
+func TestDurationInvalidUnit(t *testing.T) {
+	_, err := ezconf.NoDuration("MB")
+	assert.ErrorContains(t, err, `invalid default duration unit "MB"`)
+}
+
+func TestDurationJSON(t *testing.T) {
+	o, err := ezconf.NoDuration("s")
+	assert.NilError(t, err)
+
+	err = json.Unmarshal([]byte(`30`), &o)
+	assert.NilError(t, err)
+	assert.Equal(t, 30*time.Second, o.MustGet())
+
+	err = json.Unmarshal([]byte(`"1m"`), &o)
+	assert.NilError(t, err)
+	assert.Equal(t, time.Minute, o.MustGet())
+
+	err = json.Unmarshal([]byte(`null`), &o)
+	assert.NilError(t, err)
+	assert.Assert(t, o.IsNone())
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		unit    string
+		want    time.Duration
+		wantErr string
+	}{
+		{name: "bare seconds", value: "30", unit: "s", want: 30 * time.Second},
+		{name: "explicit unit wins", value: "500ms", unit: "s", want: 500 * time.Millisecond},
+		{name: "no default unit", value: "1m", want: time.Minute},
+		{name: "bare without a unit", value: "30", wantErr: `missing unit in duration "30"`},
+		{name: "size unit", value: "30", unit: "MB", wantErr: `invalid default duration unit "MB"`},
+		{name: "size unit with explicit unit", value: "30s", unit: "MB", wantErr: `invalid default duration unit "MB"`},
+		{name: "size value", value: "30MB", unit: "s", wantErr: `unknown unit "MB" in duration "30MB"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ezconf.ParseDuration(tc.value, tc.unit)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Package ezconf holds the runtime pieces shared by generated config loaders.
+package ezconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/brnsampson/optional"
+)
+
+// Enum is an optional string which may only hold one of a fixed set of allowed values. It is a simpler alternative to
+// implementing encoding.TextUnmarshaler for small string enums such as a log format of json, text, or logfmt.
+// Matching is case sensitive unless CaseInsensitive is used, in which case the allowed spelling is stored.
+type Enum struct {
+	optional.Str
+	allowed     []string
+	insensitive bool
+}
+
+// SomeEnum returns an Enum holding value, or an error if value is not one of the allowed values.
+func SomeEnum(value string, allowed ...string) (Enum, error) {
+	o := NoEnum(allowed...)
+	err := o.Set(value)
+	return o, err
+}
+
+// NoEnum returns a None Enum which will only accept the allowed values when it is later set.
+func NoEnum(allowed ...string) Enum {
+	return Enum{optional.NoStr(), allowed, false}
+}
+
+// CaseInsensitive returns a copy of the Enum which matches allowed values regardless of case.
+func (o Enum) CaseInsensitive() Enum {
+	o.insensitive = true
+	return o
+}
+
+// Allowed returns the set of values the Enum accepts, e.g. for use in help text.
+func (o Enum) Allowed() []string {
+	return o.allowed
+}
+
+func (o Enum) Type() string {
+	return "Enum"
+}
+
+func (o *Enum) Set(str string) error {
+	return o.UnmarshalText([]byte(str))
+}
+
+func (o Enum) String() string {
+	if o.IsNone() {
+		return "None[Enum]"
+	}
+
+	tmp, ok := o.Get()
+	if !ok {
+		return "Error[Enum]"
+	}
+	return tmp
+}
+
+func (o *Enum) UnmarshalText(text []byte) error {
+	tmp := string(text)
+	if tmp == "None" || tmp == "none" || tmp == "null" || tmp == "nil" {
+		o.Clear()
+		return nil
+	}
+
+	i := indexAllowed(o.allowed, tmp, o.insensitive)
+	if i < 0 {
+		return fmt.Errorf("invalid value %q: must be one of [%s]", tmp, strings.Join(o.allowed, ", "))
+	}
+
+	o.Replace(o.allowed[i])
+	return nil
+}
+
+// UnmarshalJSON overrides the inner Str so that values from config files are validated the same way as Set.
+func (o *Enum) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		o.Clear()
+		return nil
+	}
+
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+
+	return o.UnmarshalText([]byte(s))
+}
+
+// OneOfValues returns the spelling in allowed which value matches, or a *ValidationError for field listing the allowed
+// values. Generated loaders call it for fields tagged oneOfValues:"a,b,c", which also match regardless of case if they
+// are tagged ignoreCase:"true".
+func OneOfValues(field, value string, allowed []string, ignoreCase bool) (string, error) {
+	i := indexAllowed(allowed, value, ignoreCase)
+	if i < 0 {
+		reason := fmt.Sprintf("%q must be one of [%s]", value, strings.Join(allowed, ", "))
+		return "", &ValidationError{Field: field, Reason: reason}
+	}
+	return allowed[i], nil
+}
+
+func indexAllowed(allowed []string, value string, ignoreCase bool) int {
+	return slices.IndexFunc(allowed, func(a string) bool {
+		if ignoreCase {
+			return strings.EqualFold(a, value)
+		}
+		return a == value
+	})
+}
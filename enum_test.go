@@ -0,0 +1,98 @@
+package ezconf_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestEnumSet(t *testing.T) {
+	allowed := []string{"json", "text", "logfmt"}
+
+	tests := []struct {
+		name        string
+		value       string
+		insensitive bool
+		want        string
+		wantErr     string
+	}{
+		{name: "allowed", value: "json", want: "json"},
+		{name: "wrong case", value: "JSON", wantErr: `invalid value "JSON": must be one of [json, text, logfmt]`},
+		{name: "case insensitive", value: "LogFmt", insensitive: true, want: "logfmt"},
+		{
+			name: "not allowed", value: "xml", insensitive: true,
+			wantErr: `invalid value "xml": must be one of [json, text, logfmt]`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := ezconf.NoEnum(allowed...)
+			if tc.insensitive {
+				o = o.CaseInsensitive()
+			}
+
+			err := o.Set(tc.value)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+				assert.Assert(t, o.IsNone())
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, o.String())
+		})
+	}
+}
+
+func TestEnumJSON(t *testing.T) {
+	o, err := ezconf.SomeEnum("text", "json", "text")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"json", "text"}, o.Allowed())
+
+	err = json.Unmarshal([]byte(`"yaml"`), &o)
+	assert.ErrorContains(t, err, "must be one of [json, text]")
+
+	err = json.Unmarshal([]byte(`null`), &o)
+	assert.NilError(t, err)
+	assert.Assert(t, o.IsNone())
+	assert.Equal(t, "None[Enum]", o.String())
+}
+
+func TestOneOfValues(t *testing.T) {
+	allowed := []string{"json", "text", "logfmt"}
+
+	tests := []struct {
+		name       string
+		value      string
+		ignoreCase bool
+		want       string
+		wantErr    string
+	}{
+		{name: "allowed", value: "text", want: "text"},
+		{name: "wrong case", value: "Text", wantErr: `invalid LogFormat: "Text" must be one of [json, text, logfmt]`},
+		{name: "ignore case", value: "LOGFMT", ignoreCase: true, want: "logfmt"},
+		{
+			name: "not allowed", value: "xml", ignoreCase: true,
+			wantErr: `invalid LogFormat: "xml" must be one of [json, text, logfmt]`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ezconf.OneOfValues("LogFormat", tc.value, allowed, tc.ignoreCase)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+				var verr *ezconf.ValidationError
+				assert.Assert(t, errors.As(err, &verr))
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
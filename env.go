@@ -0,0 +1,151 @@
+package ezconf
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/brnsampson/optional"
+)
+
+// LoadEnv sets v from the env var name. Unset and empty env vars are both treated as unset and leave v untouched.
+// Booleans accept everything ParseBool does so that MY_APP_FEATURE_X=disabled works as expected.
+func LoadEnv(v flag.Value, name string) error {
+	return LoadEnvFrom(os.Getenv, v, name)
+}
+
+// LoadEnvFrom is LoadEnv with the env var looked up by getenv rather than os.Getenv, e.g. in the vars of a .env file
+// read by ReadDotEnv.
+func LoadEnvFrom(getenv func(string) string, v flag.Value, name string) error {
+	str := getenv(name)
+	if str == "" {
+		return nil
+	}
+
+	b, ok := v.(*optional.Bool)
+	if ok {
+		v = &boolFlag{b, false}
+	}
+
+	err := v.Set(str)
+	if err != nil {
+		return fmt.Errorf("failed to load env var %s: %w", name, err)
+	}
+	return nil
+}
+
+var flagValueType = reflect.TypeFor[flag.Value]()
+
+// LoadEnvStruct sets the fields of the loader pointed to by loader from env vars, e.g. for hand written loaders. Every
+// field which is a flag.Value, such as the optional types, is set with LoadEnv from the env var named by prefix
+// followed by its env tag, and nested structs are walked. With derive set, fields without an env tag read the env var
+// named by prefix followed by EnvName of their field path instead, so DB.QueryTimeout reads MY_APP_DB_QUERY_TIMEOUT for
+// the prefix MY_APP_. Explicit env tags always win over derived names, and ignored fields and nil pointers are skipped.
+// Fields tagged deprecated:"OldName", because they were renamed from OldName, also read the env var derived from
+// OldName with LoadDeprecatedEnv, e.g. MY_APP_DB_TIMEOUT for DB.QueryTimeout tagged deprecated:"Timeout".
+func LoadEnvStruct[Loader any](loader *Loader, prefix string, derive bool) error {
+	v := reflect.ValueOf(loader)
+	if loader == nil || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot load env vars into %T: not a pointer to a struct", loader)
+	}
+	return loadEnvStruct(v.Elem(), "", prefix, derive)
+}
+
+func loadEnvStruct(v reflect.Value, path, prefix string, derive bool) error {
+	var errs []error
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		if !f.IsExported() || Ignored(f) {
+			continue
+		}
+
+		p := f.Tag.Get("field")
+		if p == "" {
+			p = f.Name
+		}
+		if path != "" {
+			p = path + "." + p
+		}
+
+		fv := v.Field(i)
+		for fv.Kind() == reflect.Pointer && !fv.Type().Implements(flagValueType) {
+			if fv.IsNil() {
+				break
+			}
+			fv = fv.Elem()
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("env"), ",")
+		if name == "" && derive {
+			name = EnvName(p)
+		}
+
+		value, ok := envValue(fv)
+		if ok && name != "" {
+			errs = append(errs, LoadEnv(value, prefix+name))
+		}
+		old := f.Tag.Get("deprecated")
+		if ok && name != "" && old != "" {
+			errs = append(errs, LoadDeprecatedEnv(value, prefix+EnvName(joinKey(path, old)), prefix+name))
+		}
+		if ok {
+			continue
+		}
+		if fv.Kind() == reflect.Struct {
+			errs = append(errs, loadEnvStruct(fv, p, prefix, derive))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// envValue returns v as a flag.Value if it or a pointer to it is one.
+func envValue(v reflect.Value) (flag.Value, bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() || !v.Type().Implements(flagValueType) {
+			return nil, false
+		}
+		return v.Interface().(flag.Value), true
+	}
+	if !v.CanAddr() || !v.Addr().Type().Implements(flagValueType) {
+		return nil, false
+	}
+	return v.Addr().Interface().(flag.Value), true
+}
+
+// EnvName derives an env var name from a field path in SCREAMING_SNAKE_CASE, e.g. MY_DB_SSL_MODE for MyDB.SSLMode. Each
+// path element is split into words at case changes, keeping acronyms such as SSL and ID together, and the words of
+// every element are joined with underscores.
+func EnvName(path string) string {
+	var b strings.Builder
+	for i, elem := range strings.Split(path, ".") {
+		if i > 0 {
+			b.WriteByte('_')
+		}
+		runes := []rune(elem)
+		for j, r := range runes {
+			if r == '-' || r == ' ' {
+				b.WriteByte('_')
+				continue
+			}
+			if j > 0 && unicode.IsUpper(r) && wordStart(runes, j) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// wordStart reports whether the upper case rune at runes[i] starts a new word: either the rune before it is lower case
+// or a digit, or it is the last letter of an acronym followed by a lower case letter.
+func wordStart(runes []rune, i int) bool {
+	prev := runes[i-1]
+	if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+		return true
+	}
+	return unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+}
@@ -0,0 +1,153 @@
+package ezconf_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+func TestLoadEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    optional.Uint16
+		wantErr string
+	}{
+		{name: "set", value: "8080", want: optional.SomeUint16(8080)},
+		{name: "empty", value: "", want: optional.NoUint16()},
+		{name: "invalid", value: "eighty", wantErr: "failed to load env var EZCONF_TEST_PORT"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("EZCONF_TEST_PORT", tc.value)
+
+			var o optional.Uint16
+			err := ezconf.LoadEnv(&o, "EZCONF_TEST_PORT")
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, o)
+		})
+	}
+}
+
+func TestLoadEnvBool(t *testing.T) {
+	t.Setenv("EZCONF_TEST_FEATURE", "disabled")
+
+	var b optional.Bool
+	err := ezconf.LoadEnv(&b, "EZCONF_TEST_FEATURE")
+	assert.NilError(t, err)
+	assert.Equal(t, optional.SomeBool(false), b)
+}
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "Port", want: "PORT"},
+		{path: "QueryTimeout", want: "QUERY_TIMEOUT"},
+		{path: "MyDB.SSLMode", want: "MY_DB_SSL_MODE"},
+		{path: "MyService.NodeID", want: "MY_SERVICE_NODE_ID"},
+		{path: "Server.HTTP3Port", want: "SERVER_HTTP3_PORT"},
+		{path: "Service.node", want: "SERVICE_NODE"},
+		{path: "Service.log-level", want: "SERVICE_LOG_LEVEL"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.want, ezconf.EnvName(tc.path))
+		})
+	}
+}
+
+type envDBLoader struct {
+	Address      optional.Str
+	Port         optional.Uint16 `env:"DB_PORT"`
+	QueryTimeout optional.Duration
+}
+
+type envAppLoader struct {
+	Name    optional.Str    `env:"SERVICE_NAME"`
+	NodeID  optional.Uint32 `field:"node"`
+	DB      envDBLoader
+	Replica *envDBLoader
+	Unset   *envDBLoader
+	Cache   optional.Str `config:"-"`
+}
+
+func TestLoadEnvStruct(t *testing.T) {
+	t.Setenv("EZCONF_TEST_SERVICE_NAME", "tagged")
+	t.Setenv("EZCONF_TEST_NAME", "derived")
+	t.Setenv("EZCONF_TEST_NODE", "7")
+	t.Setenv("EZCONF_TEST_DB_ADDRESS", "db.internal")
+	t.Setenv("EZCONF_TEST_DB_PORT", "5432")
+	t.Setenv("EZCONF_TEST_DB_QUERY_TIMEOUT", "30s")
+	t.Setenv("EZCONF_TEST_REPLICA_ADDRESS", "replica.internal")
+	t.Setenv("EZCONF_TEST_CACHE", "ignored")
+
+	tests := []struct {
+		name   string
+		derive bool
+		want   envAppLoader
+	}{
+		{
+			name: "env tags only",
+			want: envAppLoader{
+				Name:    optional.SomeStr("tagged"),
+				DB:      envDBLoader{Port: optional.SomeUint16(5432)},
+				Replica: &envDBLoader{Port: optional.SomeUint16(5432)},
+			},
+		},
+		{
+			name:   "derived names",
+			derive: true,
+			want: envAppLoader{
+				// The env tag wins over the derived EZCONF_TEST_NAME.
+				Name:   optional.SomeStr("tagged"),
+				NodeID: optional.SomeUint32(7),
+				DB: envDBLoader{
+					Address:      optional.SomeStr("db.internal"),
+					Port:         optional.SomeUint16(5432),
+					QueryTimeout: optional.SomeDuration(30 * time.Second),
+				},
+				Replica: &envDBLoader{Address: optional.SomeStr("replica.internal"), Port: optional.SomeUint16(5432)},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := envAppLoader{Replica: &envDBLoader{}}
+			err := ezconf.LoadEnvStruct(&l, "EZCONF_TEST_", tc.derive)
+			assert.NilError(t, err)
+			assert.Equal(t, *tc.want.Replica, *l.Replica)
+			assert.Assert(t, l.Unset == nil)
+			l.Replica, tc.want.Replica = nil, nil
+			assert.Equal(t, tc.want, l)
+		})
+	}
+}
+
+func TestLoadEnvStructErrors(t *testing.T) {
+	t.Setenv("EZCONF_TEST_DB_ADDRESS", "db.internal")
+	t.Setenv("EZCONF_TEST_DB_QUERY_TIMEOUT", "soon")
+
+	var l envAppLoader
+	err := ezconf.LoadEnvStruct(&l, "EZCONF_TEST_", true)
+	assert.ErrorContains(t, err, "failed to load env var EZCONF_TEST_DB_QUERY_TIMEOUT")
+	// Other fields are still loaded.
+	assert.Equal(t, optional.SomeStr("db.internal"), l.DB.Address)
+
+	err = ezconf.LoadEnvStruct((*envAppLoader)(nil), "EZCONF_TEST_", true)
+	assert.ErrorContains(t, err, "not a pointer to a struct")
+	err = ezconf.LoadEnvStruct(new(int), "EZCONF_TEST_", true)
+	assert.ErrorContains(t, err, "not a pointer to a struct")
+}
@@ -4,29 +4,69 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/brnsampson/ezconf"
 	"github.com/brnsampson/ezconf/httpconf"
-	"github.com/brnsampson/optional"
 )
 
 type MyServiceConfig struct {
-	Name         string
-	Description  string
-	NodeID       uint32 `flag:"true" required:"true" field:"node"`
-	Priority     uint16
-	SecretKey    optional.Secret `flag:"true" default:"secretkey.txt"`
+	Name string `required:"true"`
+	// Description is a Go template executed once the rest of the config is loaded, with ezconf.TemplateData as its data,
+	// e.g. {{.Config.MyService.Name}} node {{.Config.MyService.NodeID}}.
+	Description string `template:"true"`
+	NodeID      uint32 `flag:"true" required:"true" field:"node" access:"admin"`
+	Priority    uint16
+	// LogFormat only accepts one of its oneOfValues, which the -myServiceLogFormat help text lists. With ignoreCase
+	// JSON is accepted as well, and the spelling from the tag is stored.
+	LogFormat string        `flag:"true" default:"text" oneOfValues:"json,text,logfmt" ignoreCase:"true"`
+	SecretKey ezconf.Secret `flag:"true" default:"secretkey.txt" access:"admin"`
+	// Salt is given as base64 in env vars, flags, and config files.
+	Salt []byte `flag:"true"`
+	// SessionKey is given as hex instead because of its encoding tag.
+	SessionKey []byte `encoding:"hex"`
+	// Plugins is given as a glob pattern, relative to the config dir, and holds the paths matching it. Watch reloads the
+	// config when a match is added or removed.
+	Plugins      []string `glob:"plugins/*.so"`
 	ServerConfig httpconf.HttpServerConfig
+	// StartedAt is runtime state set once the server is up. The config tag keeps it out of every config source and Save.
+	StartedAt time.Time `config:"-"`
 }
 
 type MyDBConfig struct {
-	Address string `flag:"true" default:"127.0.0.1"`
-	Port    uint16 `flag:"true" default:"8080"`
+	// Address was called Host before, which config files and env vars still accept with a deprecation warning.
+	Address  string            `flag:"true" default:"127.0.0.1" validate:"nonempty" deprecated:"Host" access:"admin"`
+	Port     uint16            `flag:"true" default:"8080" validate:"min=1024,max=49151"`
+	SSLMode  string            `default:"prefer" validate:"oneof=disable prefer require verify-full"`
+	Replicas []string          `flag:"true"`
+	Params   map[string]string `flag:"true"`
+	// QueryTimeout is given with a unit, e.g. 1500ms or 1h30m, in env vars, flags, and config files alike.
+	QueryTimeout time.Duration `flag:"true" default:"5s"`
+	// ConnectTimeout used to be a number of seconds, which is still how bare numbers are read, e.g. 30. Values with a
+	// unit such as 500ms work as well.
+	ConnectTimeout time.Duration `flag:"true" default:"30" defaultUnit:"s"`
+	// MaxMessageSize is a size such as 512KiB, or a bare number of MiB.
+	MaxMessageSize uint64 `default:"16" defaultUnit:"MiB"`
+	// Pooling is a boolean flag, so it is turned off with -no-myDBPooling as well as -myDBPooling=false.
+	Pooling bool `flag:"true" default:"true"`
+	// Password is fetched from a SecretProvider registered with ezconf unless an env var or the config file sets it.
+	Password ezconf.Secret `secret:"file:/run/secrets/myapp-db-password" access:"admin"`
+	// AdminPassword is only needed to run migrations, so the lazy tag defers fetching it from its SecretProvider until
+	// the first call to Get. Update only checks that it can be reached. No other source sets it.
+	AdminPassword ezconf.LazySecret `secret:"file:/run/secrets/myapp-db-admin-password" lazy:"true" access:"admin"`
+}
+
+type BackendConfig struct {
+	Address string `default:"127.0.0.1" access:"admin"`
+	Port    uint16 `default:"8080"`
+	Weight  uint16 `default:"1"`
 }
 
 //go:generate ezconf -path=/etc/myapp/ -flagDefault=false
 type MyAppConfig struct {
 	MyService MyServiceConfig
 	MyDB      MyDBConfig
+	Backends  []BackendConfig
 }
 
 func main() {
@@ -36,7 +76,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	conf := l.Prev()
+	conf := l.Previous()
 	log.Println("Starting server for Name: ", conf.MyService.Name, " NodeID: ", conf.MyService.NodeID)
 
 	myServer := conf.MyService.ServerConfig.NewHttpServer()
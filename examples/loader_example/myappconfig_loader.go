@@ -1,170 +1,2537 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"github.com/brnsampson/ezconf"
 	"github.com/brnsampson/ezconf/file"
 	"github.com/brnsampson/ezconf/httpconf"
 	"github.com/brnsampson/optional"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Default values for MyServiceConfig
 const (
 	DefaultMyServiceConfigDescription   = ""
-	DefaultMyServiceConfigNodeID        = 1
 	DefaultMyServiceConfigPriority      = 1
-	DefaultMyServiceConfigSecretKey     = "/etc/myapp/secretkey.txt"
+	DefaultMyServiceConfigLogFormat     = "text"
+	DefaultMyServiceConfigSecretKey     = "secretkey.txt"
+	DefaultMyServiceConfigPlugins       = "plugins/*.so"
 	DefaultMyServiceConfigAddress       = "127.0.0.1"
 	DefaultMyServiceConfigPort          = 443
-	DefaultMyServiceConfigTlsCert       = "/etc/myapp/tls/cert.pem"
-	DefaultMyServiceConfigTlsPrivateKey = "/etc/myapp/tls/key.pem"
+	DefaultMyServiceConfigTlsCert       = "tls/cert.pem"
+	DefaultMyServiceConfigTlsPrivateKey = "tls/key.pem"
 	DefaultMyServiceConfigTlsEnabled    = true
 	DefaultMyServiceConfigTlsSkipVerify = false
 )
 
 // Default values for MyDBConfig
 const (
-	DefaultMyDBConfigAddress = "127.0.0.1"
-	DefaultMyDBConfigPort    = 8080
+	DefaultMyDBConfigAddress        = "127.0.0.1"
+	DefaultMyDBConfigPort           = 8080
+	DefaultMyDBConfigSSLMode        = "prefer"
+	DefaultMyDBConfigQueryTimeout   = 5 * time.Second
+	DefaultMyDBConfigConnectTimeout = 30 * time.Second
+	DefaultMyDBConfigMaxMessageSize = 16 << 20
+	DefaultMyDBConfigPooling        = true
+	// DefaultMyDBConfigPasswordSecret names the secret Password is fetched with when no other source sets it. It comes
+	// from the secret tag of the field. Register a SecretProvider with ezconf to fetch it from a store other than files.
+	DefaultMyDBConfigPasswordSecret = "file:/run/secrets/myapp-db-password"
+	// DefaultMyDBConfigAdminPasswordSecret names the secret AdminPassword is fetched with on its first Get. It comes
+	// from the secret tag of the field, which is tagged lazy as well.
+	DefaultMyDBConfigAdminPasswordSecret = "file:/run/secrets/myapp-db-admin-password"
 )
 
-// flag variables
-var (
-	flagSetupper      sync.Once
-	myServiceNodeFlag optional.Uint32
-	myDBAddressFlag   optional.Str
-	myDBPortFlag      optional.Uint16
+// DefaultMyAppConfigDir is the directory relative default file paths, such as DefaultMyServiceConfigSecretKey, are
+// joined with unless MyAppConfigLoader.ConfigDir, the -configDir flag, or the CONFIG_DIR env var is set. It is set with
+// the -path generator flag.
+const DefaultMyAppConfigDir = "/etc/myapp/"
+
+// DefaultMyAppConfigFiles are the '|' separated config files searched for in the config dir when none are given with
+// ConfigFile, ConfigFiles, or the -config flag. Only the first which exists is loaded.
+const DefaultMyAppConfigFiles = "myapp.toml|myapp.yaml|myapp.json"
+
+// DefaultMyAppConfigEnvPrefix is prepended to the env tag of every field to get the name of its env var. Set
+// MyAppConfigLoader.EnvPrefix to use another prefix at runtime.
+const DefaultMyAppConfigEnvPrefix = "MY_APP_"
+
+// Default values for BackendConfig
+const (
+	DefaultBackendConfigAddress = "127.0.0.1"
+	DefaultBackendConfigPort    = 8080
+	DefaultBackendConfigWeight  = 1
 )
 
-type loader[T any] interface {
-	Update() (T, error)
-	Prev() T
+// myServiceConfigLogFormatValues are the values MyServiceConfig.LogFormat accepts regardless of case, from its
+// oneOfValues tag.
+var myServiceConfigLogFormatValues = []string{"json", "text", "logfmt"}
+
+var flagSetupper sync.Once
+
+// myAppConfigFlags holds the values of the flags registered by RegisterMyAppConfigFlags.
+type myAppConfigFlags struct {
+	config             file.Files
+	configFormat       optional.Str
+	strictConfig       optional.Bool
+	envFile            file.File
+	configDir          file.Dir
+	myServiceNode      optional.Uint32
+	myServiceLogFormat ezconf.Enum
+	myServiceSecretKey ezconf.SecretFlag
+	myServiceNoTls     optional.Bool
+	myServiceSalt      ezconf.Bytes
+	myDBAddress        optional.Str
+	myDBPort           optional.Uint16
+	myDBReplicas       ezconf.List[string]
+	myDBParams         ezconf.Map[string]
+	myDBQueryTimeout   optional.Duration
+	myDBConnectTimeout ezconf.UnitValue
+	myDBPooling        optional.Bool
 }
 
-// SetupMyAppConfigFlags adds command line flags to support this config. It is suggested that
+var (
+	ErrNoStagedConfig   = errors.New("no staged MyAppConfig")
+	ErrNoRollbackConfig = errors.New("no promoted MyAppConfig to roll back")
+)
+
+// SetupMyAppConfigFlags adds command line flags to support this config to flag.CommandLine. It is suggested that
 // you just call NewLoader() which does this for you, but you may do this yourself if you want more
-// control. As an example:
+// control. It is safe to call more than once. As an example:
 //
 // SetupMyAppConfigFlags()
 // l := MyAppConfigLoader{}
-// myappconf, err := l.Update(flag.CommandLine)
+// myappconf, err := l.Update()
 func SetupMyAppConfigFlags() {
-	onceBody := func() {
-		flag.Var(&myServiceNodeFlag, "myServiceNode", "MyServiceConfig Node Value. Type: uint32, Required: true")
-		flag.Var(&myDBAddressFlag, "myDBAddress", "MyDBConfig Address Value. Type: String, Default: '127.0.0.1'")
-		flag.Var(&myDBPortFlag, "myDBPort", "MyDBConfig Port Value. Type: uint16, Default: 8080")
+	flagSetupper.Do(func() { RegisterMyAppConfigFlags(flag.CommandLine) })
+}
+
+// RegisterMyAppConfigFlags adds command line flags to support this config to fs, e.g. to embed them in a larger CLI or
+// to give each test its own flags. Set MyAppConfigLoader.Flags to fs so that the loader reads them. Like any FlagSet,
+// fs panics if the flags are registered on it twice.
+func RegisterMyAppConfigFlags(fs *flag.FlagSet) {
+	f := &myAppConfigFlags{}
+	fs.Var(&f.config, "config",
+		"Path to a MyAppConfig file, - to read it from stdin, or an http or https URL if the program allows remote "+
+			"config. Type: .toml, .json, or .yaml files, optionally gzipped as e.g. .toml.gz, or files without an extension "+
+			"whose format is detected, comma separated or repeated. Later files override earlier ones")
+	fs.Var(&f.configFormat, "configFormat",
+		"Format of every -config file, one of json, toml, or yaml. Defaults to the file extension, or the detected format "+
+			"for files without one")
+	fs.Var(&f.envFile, "envFile",
+		"Path to a .env file of NAME=value lines to read env vars from, e.g. for local development. Env vars which are "+
+			"already set keep their value")
+	fs.Var(&f.configDir, "configDir",
+		"Directory relative default file paths, such as the MyServiceConfig SecretKey file, are joined with. Overrides "+
+			"the CONFIG_DIR env var, Default: '/etc/myapp/'")
+	ezconf.BoolVar(fs, &f.strictConfig, "strictConfig",
+		"Fail to load -config files which have keys that match no MyAppConfig field, e.g. misspelled field names, instead "+
+			"of ignoring them. Type: bool, Default: false")
+	fs.Var(&f.myServiceNode, "myServiceNode", "MyServiceConfig Node Value. Type: uint32, Required: true")
+	f.myServiceLogFormat = ezconf.NoEnum(myServiceConfigLogFormatValues...).CaseInsensitive()
+	fs.Var(&f.myServiceLogFormat, "myServiceLogFormat",
+		"MyServiceConfig LogFormat Value. Type: String, one of json, text, or logfmt in any case, Default: 'text'")
+	fs.Var(&f.myServiceSecretKey, "myServiceSecretKey",
+		"MyServiceConfig SecretKey Value. Type: secret, given as @path to read it from a file or @- to read it from stdin "+
+			"so that it stays out of shell history. Used instead of the secret file")
+	fs.Var(&f.myServiceSalt, "myServiceSalt", "MyServiceConfig Salt Value. Type: []byte as base64")
+	fs.Var(&f.myServiceNoTls, "myServiceNoTls",
+		"Serve MyServiceConfig ServerConfig over plain HTTP regardless of its TLS settings. Type: bool, Default: false")
+	fs.Var(&f.myDBAddress, "myDBAddress", "MyDBConfig Address Value. Type: String, Default: '127.0.0.1'")
+	fs.Var(&f.myDBPort, "myDBPort", "MyDBConfig Port Value. Type: uint16, Default: 8080")
+	fs.Var(&f.myDBReplicas, "myDBReplicas", "MyDBConfig Replicas Value. Type: []String, comma separated or repeated")
+	fs.Var(&f.myDBParams, "myDBParams",
+		"MyDBConfig Params Value. Type: map[String]String, key=value pairs, comma separated or repeated")
+	fs.Var(&f.myDBQueryTimeout, "myDBQueryTimeout",
+		"MyDBConfig QueryTimeout Value. Type: Duration with a unit, e.g. 1500ms or 1h30m, Default: 5s")
+	fs.Var(&f.myDBConnectTimeout, "myDBConnectTimeout",
+		"MyDBConfig ConnectTimeout Value. Type: Duration, e.g. 1500ms, or a bare number of seconds, Default: 30")
+	ezconf.BoolVar(fs, &f.myDBPooling, "myDBPooling", "MyDBConfig Pooling Value. Type: bool, Default: true")
+}
+
+// lookupMyAppConfigFlags returns the values of the flags registered on fs by RegisterMyAppConfigFlags. Flags which are
+// not registered on fs are left None.
+func lookupMyAppConfigFlags(fs *flag.FlagSet) (f myAppConfigFlags) {
+	lookupFlag(fs, "config", &f.config)
+	lookupFlag(fs, "configFormat", &f.configFormat)
+	lookupFlag(fs, "strictConfig", &f.strictConfig)
+	lookupFlag(fs, "envFile", &f.envFile)
+	lookupFlag(fs, "configDir", &f.configDir)
+	lookupFlag(fs, "myServiceNode", &f.myServiceNode)
+	lookupFlag(fs, "myServiceLogFormat", &f.myServiceLogFormat)
+	lookupFlag(fs, "myServiceSecretKey", &f.myServiceSecretKey)
+	lookupFlag(fs, "myServiceNoTls", &f.myServiceNoTls)
+	lookupFlag(fs, "myServiceSalt", &f.myServiceSalt)
+	lookupFlag(fs, "myDBAddress", &f.myDBAddress)
+	lookupFlag(fs, "myDBPort", &f.myDBPort)
+	lookupFlag(fs, "myDBReplicas", &f.myDBReplicas)
+	lookupFlag(fs, "myDBParams", &f.myDBParams)
+	lookupFlag(fs, "myDBQueryTimeout", &f.myDBQueryTimeout)
+	lookupFlag(fs, "myDBConnectTimeout", &f.myDBConnectTimeout)
+	lookupFlag(fs, "myDBPooling", &f.myDBPooling)
+	return f
+}
+
+// lookupFlag copies the value of the flag name on fs into dst, unless no flag of type T was registered with that name.
+func lookupFlag[T any, P interface {
+	*T
+	flag.Value
+}](fs *flag.FlagSet, name string, dst P) {
+	fl := fs.Lookup(name)
+	if fl == nil {
+		return
+	}
+	v, ok := fl.Value.(P)
+	if ok {
+		*dst = *v
+		return
+	}
+
+	// Flags such as ezconf.BoolVar wrap the value they set and hand it back through flag.Getter.
+	g, ok := fl.Value.(flag.Getter)
+	if !ok {
+		return
+	}
+	t, ok := g.Get().(T)
+	if ok {
+		*dst = t
+	}
+}
+
+// DefaultMyAppConfig returns a MyAppConfig holding the default of every field, and the zero value for fields without
+// one. No env vars, flags, or files are read, so file fields such as MyService.SecretKey are left None and nested
+// library configs such as ServerConfig are left as their zero value. The lazy MyDB.AdminPassword is set, since it reads
+// nothing until its first Get.
+func DefaultMyAppConfig() MyAppConfig {
+	return MyAppConfig{MyService: DefaultMyServiceConfig(), MyDB: DefaultMyDBConfig()}
+}
+
+// DefaultMyServiceConfig returns a MyServiceConfig holding the default of every field. See DefaultMyAppConfig.
+func DefaultMyServiceConfig() MyServiceConfig {
+	return MyServiceConfig{
+		Description: DefaultMyServiceConfigDescription,
+		Priority:    DefaultMyServiceConfigPriority,
+		LogFormat:   DefaultMyServiceConfigLogFormat,
+	}
+}
+
+// DefaultMyDBConfig returns a MyDBConfig holding the default of every field. See DefaultMyAppConfig.
+func DefaultMyDBConfig() MyDBConfig {
+	return MyDBConfig{
+		Address:        DefaultMyDBConfigAddress,
+		Port:           DefaultMyDBConfigPort,
+		SSLMode:        DefaultMyDBConfigSSLMode,
+		QueryTimeout:   DefaultMyDBConfigQueryTimeout,
+		ConnectTimeout: DefaultMyDBConfigConnectTimeout,
+		MaxMessageSize: DefaultMyDBConfigMaxMessageSize,
+		Pooling:        DefaultMyDBConfigPooling,
+		AdminPassword:  ezconf.LazySecretRef(DefaultMyDBConfigAdminPasswordSecret),
 	}
-	flagSetupper.Do(onceBody)
+}
+
+// DefaultBackendConfig returns a BackendConfig holding the default of every field. See DefaultMyAppConfig.
+func DefaultBackendConfig() BackendConfig {
+	return BackendConfig{
+		Address: DefaultBackendConfigAddress,
+		Port:    DefaultBackendConfigPort,
+		Weight:  DefaultBackendConfigWeight,
+	}
+}
+
+// myAppConfigReference documents every field of MyAppConfig for PrintConfigReference. Nested library configs such as
+// ServerConfig are configured through their own loaders and are not listed, apart from the overrides read by this one.
+var myAppConfigReference = []ezconf.FieldReference{
+	// Relative default file paths are joined with ConfigDir.
+	{Path: "ConfigDir", Type: "directory", Default: DefaultMyAppConfigDir, Env: "CONFIG_DIR", Flag: "configDir"},
+	{Path: "MyService.Name", Type: "string", Env: "MY_SERVICE_NAME", Required: true},
+	{Path: "MyService.Description", Type: "string", Env: "MY_SERVICE_DESCRIPTION"},
+	{Path: "MyService.NodeID", Type: "uint32", Env: "MY_SERVICE_NODE", Flag: "myServiceNode", Required: true,
+		Access: "admin"},
+	{Path: "MyService.Priority", Type: "uint16", Default: "1", Env: ezconf.EnvName("MyService.Priority")},
+	// LogFormat is matched regardless of case.
+	{Path: "MyService.LogFormat", Type: "json, text, or logfmt", Default: DefaultMyServiceConfigLogFormat,
+		Env: "MY_SERVICE_LOG_FORMAT", Flag: "myServiceLogFormat"},
+	// The SecretKey flag takes the secret itself, or @path.
+	{Path: "MyService.SecretKey", Type: "secret file", Default: DefaultMyServiceConfigSecretKey,
+		Env: "MY_SERVICE_SECRET_KEY", Flag: "myServiceSecretKey", Access: "admin"},
+	{Path: "MyService.Salt", Type: "[]byte as base64", Env: "MY_SERVICE_SALT", Flag: "myServiceSalt"},
+	{Path: "MyService.SessionKey", Type: "[]byte as hex", Env: "MY_SERVICE_SESSION_KEY"},
+	// Plugins is expanded into the matching paths.
+	{Path: "MyService.Plugins", Type: "glob", Default: DefaultMyServiceConfigPlugins, Env: "MY_SERVICE_PLUGINS"},
+	{Path: "MyService.ServerConfig.DisableTls", Type: "bool", Default: "false", Env: "MY_SERVICE_NO_TLS",
+		Flag: "myServiceNoTls"},
+	{Path: "MyDB.Address", Type: "string", Default: DefaultMyDBConfigAddress, Env: "MY_DB_ADDRESS", Flag: "myDBAddress",
+		Access: "admin"},
+	{Path: "MyDB.Port", Type: "uint16", Default: "8080", Env: "MY_DB_PORT", Flag: "myDBPort"},
+	{Path: "MyDB.SSLMode", Type: "string", Default: DefaultMyDBConfigSSLMode, Env: ezconf.EnvName("MyDB.SSLMode")},
+	{Path: "MyDB.Replicas", Type: "[]string", Env: "MY_DB_REPLICAS", Flag: "myDBReplicas"},
+	{Path: "MyDB.Params", Type: "map[string]string", Env: "MY_DB_PARAMS", Flag: "myDBParams"},
+	{Path: "MyDB.Password", Type: "secret", Default: DefaultMyDBConfigPasswordSecret, Env: "MY_DB_PASSWORD",
+		Access: "admin"},
+	// AdminPassword is only fetched from its secret, on first use.
+	{Path: "MyDB.AdminPassword", Type: "lazy secret", Default: DefaultMyDBConfigAdminPasswordSecret, Access: "admin"},
+	{Path: "MyDB.QueryTimeout", Type: "duration", Default: "5s", Env: "MY_DB_QUERY_TIMEOUT", Flag: "myDBQueryTimeout"},
+	{Path: "MyDB.ConnectTimeout", Type: "duration, bare numbers in s", Default: "30", Env: "MY_DB_CONNECT_TIMEOUT",
+		Flag: "myDBConnectTimeout"},
+	{Path: "MyDB.MaxMessageSize", Type: "size, bare numbers in MiB", Default: "16", Env: "MY_DB_MAX_MESSAGE_SIZE"},
+	{Path: "MyDB.Pooling", Type: "bool", Default: "true", Env: "MY_DB_POOLING", Flag: "myDBPooling"},
+	{Path: "Backends[N].Address", Type: "string", Default: DefaultBackendConfigAddress, Env: "BACKENDS_N_ADDRESS",
+		Access: "admin"},
+	{Path: "Backends[N].Port", Type: "uint16", Default: "8080", Env: "BACKENDS_N_PORT"},
+	{Path: "Backends[N].Weight", Type: "uint16", Default: "1", Env: "BACKENDS_N_WEIGHT"},
+}
+
+// PrintConfigReference writes a table of every MyAppConfig field with its type, default, env var, flag, and whether it
+// is required, e.g. for a --help message. Env vars are listed with the loader's EnvPrefix.
+func (l *MyAppConfigLoader) PrintConfigReference(w io.Writer) error {
+	return ezconf.PrintReference(w, l.envPrefix(), myAppConfigReference)
+}
+
+// FieldAccess returns the access tag of the MyAppConfig field at path, e.g. admin for MyDB.Address, or an empty string
+// for fields without one. It does not change how the config is loaded, but lets an admin endpoint decide which fields a
+// caller may change. Paths are the same as for Sources, e.g. Backends[0].Address.
+func (l *MyAppConfigLoader) FieldAccess(path string) string {
+	return ezconf.FieldAccess(myAppConfigReference, path)
+}
+
+// MyAppConfigSchema returns a JSON Schema document describing MyAppConfig files, e.g. to validate them in CI or for
+// editor autocompletion. The copy checked in as myappconfig.schema.json is compared with it by TestMyAppConfigSchema,
+// so write the output of MyAppConfigSchema to that file again whenever MyAppConfig changes.
+func MyAppConfigSchema() ([]byte, error) {
+	schema, err := ezconf.JSONSchema(MyAppConfig{})
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(schema, "", "  ")
 }
 
 // NewLoader sets up required flags, creates a new loader, updates it, and returns the loaded loader.
-func NewLoader() (MyAppConfigLoader, error) {
+func NewLoader() (*MyAppConfigLoader, error) {
 	SetupMyAppConfigFlags()
 
-	l := MyAppConfigLoader{}
+	l := &MyAppConfigLoader{}
 	_, err := l.Update()
 	return l, err
 }
 
+// MyAppConfigLoader loads a MyAppConfig. Sources are applied with the following precedence, highest first:
+//
+//   - programmatic: values set directly on the loader fields, e.g. l.MyDB.Port = optional.SomeUint16(9000)
+//   - flags
+//   - env vars, named by EnvPrefix followed by the env tag on the loader field. Empty env vars are treated as unset.
+//   - the .env file named by EnvFile or the -envFile flag, if any, which is read like the env vars it holds.
+//   - Keyed, if it is set, e.g. a *viper.Viper. Values are looked up by field path and parsed like env vars.
+//   - the config files named by ConfigFile and ConfigFiles or the -config flag, each decoded according to ConfigFormat,
+//     its extension, or its contents if it has no extension. The path - reads a config piped to Stdin instead, e.g.
+//     -config base.toml,- to lay it over a base file. http and https URLs are fetched with ConfigClient, if it is set.
+//     Gzipped files are decompressed first, and myapp.toml.gz is decoded as TOML.
+//     Later files override the values set by earlier ones, e.g. base.toml followed by prod.toml. If none are given, the
+//     first of DefaultMyAppConfigFiles which exists in the config dir is loaded.
+//   - defaults computed at load time by functions registered with DefaultFunc.
+//   - defaults. Relative default file paths are joined with ConfigDir, -configDir, CONFIG_DIR, or
+//     DefaultMyAppConfigDir.
+//
+// Config files are merged field by field, so a later file only overrides the fields it sets and leaves the rest of a
+// nested struct alone. Maps are merged key by key. Keys in a config file which match no field, e.g. a misspelled
+// field name, are ignored unless StrictConfig or the -strictConfig flag is set, in which case loading fails with an
+// *ezconf.UnknownFieldsError naming them.
+//
+// Renamed fields are still read under their deprecated names from config files and env vars, e.g. MyDB.Host and
+// MY_APP_MY_DB_HOST for MyDB.Address, with a warning logged to slog.Default once per name. The current name wins if
+// both are given.
+//
+// Slices of structs such as Backends are merged element by element, so element i set on the loader overrides element i
+// of the config file, where they are written as an array of tables. Env vars address elements by index, e.g.
+// MY_APP_BACKENDS_0_ADDRESS, and only override elements which exist on the loader or in the config file. There are no
+// flags for them.
+//
+// Update never writes resolved values back into the loader fields, so anything set on them is always a deliberate
+// programmatic override that flags cannot clobber. Clear the field to fall back to the other sources again.
+//
+// Update, Reload, Previous, and the staging methods are safe to call from multiple goroutines, e.g. a Watch goroutine
+// reloading the config while request handlers call Previous. Setting loader fields or calling Compute or DefaultFunc is
+// not, so do that before the loader is shared.
 type MyAppConfigLoader struct {
+	MyService  MyServiceConfigLoader
+	MyDB       MyDBConfigLoader
+	Backends   []BackendConfigLoader
+	ConfigFile file.File // Overrides the -config flag.
+	// ConfigFiles are loaded after ConfigFile, in order. Together they override the -config flag.
+	ConfigFiles  file.Files
+	ConfigFormat optional.Str  // json, toml, or yaml. Overrides every file extension and the -configFormat flag.
+	StrictConfig optional.Bool // Fail on config file keys which match no field. Overrides the -strictConfig flag.
+	EnvPrefix    optional.Str  // Replaces DefaultMyAppConfigEnvPrefix. Set it to an empty string to use no prefix.
+	ConfigDir    optional.Str  // Replaces DefaultMyAppConfigDir. Overrides the -configDir flag and the CONFIG_DIR env var.
+	EnvFile      file.File     // A .env file read by ezconf.ReadDotEnv. Overrides the -envFile flag.
+	Flags        *flag.FlagSet // The FlagSet given to RegisterMyAppConfigFlags. Defaults to flag.CommandLine.
+	// FileRetry retries reads of config files and secret files which fail, e.g. while a mounted secret is rotated. The
+	// zero value never retries.
+	FileRetry ezconf.RetryPolicy
+	// Keyed is read between the config files and env vars, e.g. a *viper.Viper while moving a service over from viper.
+	// Keys are field paths such as MyDB.Port. Backends are not read from it.
+	Keyed ezconf.KeyedSource
+	// ConfigClient fetches config files given as http or https URLs, e.g. -config https://config.internal/myapp.toml. Set
+	// its Timeout and Transport for a timeout and TLS settings. URLs are refused while it is nil, so that the -config flag
+	// alone cannot make the service load its config from the network.
+	ConfigClient *http.Client
+	// Stdin is read for the config file path -. Defaults to os.Stdin. It can only be read once, so the document read on
+	// first use is decoded again by every later Update or Reload.
+	Stdin     io.Reader
+	stdinOnce sync.Once
+	stdin     []byte
+	stdinErr  error
+	computed  []computedField
+	defaults  []defaultFunc
+	before    []func(source string) error
+	after     []func(source string, err error) error
+	mu        sync.RWMutex
+	previous  MyAppConfig
+	pending   *MyAppConfig
+	rollback  *MyAppConfig
+	// What was recorded while resolving previous, pending, and rollback.
+	meta         myAppConfigMeta
+	pendingMeta  myAppConfigMeta
+	rollbackMeta myAppConfigMeta
+}
+
+// myAppConfigMeta is what resolve records about a MyAppConfig besides its values: the source of every field, returned
+// by Sources, and the paths that file fields were read from, which Save writes in place of their contents.
+type myAppConfigMeta struct {
+	sources   map[string]string
+	secretKey string // The file MyService.SecretKey was read from.
+	plugins   string // The glob pattern MyService.Plugins was expanded from.
+	// The template MyService.Description was executed from.
+	description string
+}
+
+// myAppConfigTemplates executes the value of every field of c tagged template:"true" as a Go text/template. The data
+// holds c as it was before any of them was executed, see ezconf.TemplateData.
+func myAppConfigTemplates(c *MyAppConfig, now time.Time) (err error) {
+	data := ezconf.TemplateData[MyAppConfig]{Config: *c, Now: now}
+	c.MyService.Description, err = ezconf.ExecTemplate("MyService.Description", c.MyService.Description, data)
+	return err
+}
+
+// myAppConfigFile is the layout of a MyAppConfig file. Nested library loaders such as ServerConfig are not read from
+// the file.
+type myAppConfigFile struct {
 	MyService MyServiceConfigLoader
 	MyDB      MyDBConfigLoader
-	Flags     *flag.FlagSet
-	previous  MyAppConfig
+	Backends  []BackendConfigLoader
+	env       map[string]string // The vars of the .env file, which are already applied to the fields above.
 }
 
-func (l *MyAppConfigLoader) Prev() MyAppConfig {
-	return l.previous
+// myAppConfigDocument is what a single MyAppConfig file is decoded into: myAppConfigFile along with the deprecated
+// names of renamed fields, which file moves to their current names.
+type myAppConfigDocument struct {
+	MyService MyServiceConfigLoader
+	MyDB      myDBConfigDocument
+	Backends  []BackendConfigLoader
+}
+
+// myDBConfigDocument is MyDBConfigLoader along with the deprecated names of its fields.
+type myDBConfigDocument struct {
+	MyDBConfigLoader `yaml:",inline"`
+	Host             optional.Str // Deprecated name of Address.
+}
+
+// file returns d with the values given under deprecated names moved to their current names, warning about each one.
+// Values given under both names keep the one under the current name. where is the path d was read from.
+func (d myAppConfigDocument) file(where string) myAppConfigFile {
+	f := myAppConfigFile{MyService: d.MyService, MyDB: d.MyDB.MyDBConfigLoader, Backends: d.Backends}
+	f.MyDB.Address = ezconf.UseDeprecated(f.MyDB.Address, d.MyDB.Host, where, "MyDB.Host", "MyDB.Address")
+	return f
+}
+
+// envPrefix returns the prefix of every env var read by the loader.
+func (l *MyAppConfigLoader) envPrefix() string {
+	return optional.GetOr(l.EnvPrefix, DefaultMyAppConfigEnvPrefix)
+}
+
+// configDir returns the directory relative default file paths are joined with.
+func (l *MyAppConfigLoader) configDir() (string, error) {
+	env := optional.NoStr()
+	err := ezconf.LoadEnv(&env, l.envPrefix()+"CONFIG_DIR")
+	return optional.GetOr(optional.Or(l.ConfigDir, optional.Or(l.flags().configDir.Str, env)), DefaultMyAppConfigDir), err
+}
+
+// flags returns the values of the flags registered on Flags.
+func (l *MyAppConfigLoader) flags() myAppConfigFlags {
+	fs := l.Flags
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	return lookupMyAppConfigFlags(fs)
+}
+
+// configPaths returns the config files to load in order. Files set on the loader replace those given with -config, and
+// the default config file is only searched for if neither gives any.
+func (l *MyAppConfigLoader) configPaths() []string {
+	paths, _ := l.ConfigFiles.Get()
+	path, ok := l.ConfigFile.Get()
+	if ok {
+		paths = append([]string{path}, paths...)
+	}
+	if len(paths) > 0 {
+		return paths
+	}
+
+	paths, _ = l.flags().config.Get()
+	if len(paths) > 0 {
+		return paths
+	}
+	return l.defaultConfigPaths()
+}
+
+// defaultConfigPaths returns the first of DefaultMyAppConfigFiles which exists in the config dir, if any. An invalid
+// CONFIG_DIR env var is ignored here, as resolve reports it.
+func (l *MyAppConfigLoader) defaultConfigPaths() []string {
+	dir, _ := l.configDir()
+	candidates := strings.Split(DefaultMyAppConfigFiles, "|")
+	for i, candidate := range candidates {
+		candidates[i] = ezconf.DefaultPath(dir, candidate)
+	}
+	path, ok := file.FirstExisting(candidates...).Get()
+	if !ok {
+		return nil
+	}
+	return []string{path}
+}
+
+// readConfigFile decodes the config files, if any were given, into loaders holding only the values set in the files.
+// Each file is decoded on its own and then laid over the ones before it. The vars of the .env file are read last and
+// laid over the files, so that the real env vars read on top of the result win over both. The .env file is read again
+// on every call and never set in the process environment, so edits to it and removed vars take effect on the next
+// Update. Reading the config from stdin is an error if -myServiceSecretKey @- read the secret from there already.
+func (l *MyAppConfigLoader) readConfigFile(ctx context.Context) (f myAppConfigFile, err error) {
+	return l.readConfigPaths(ctx, l.configPaths())
 }
 
-func (l *MyAppConfigLoader) Update() (config MyAppConfig, err error) {
-	// TODO: check myAppConfigPath for the value of the -config flag and use that as the config file to load.
-	// TODO: load l from env vars.
-	myService, err := l.MyService.Update()
+// readConfigPaths is readConfigFile for the config files at paths instead of those given to the loader.
+func (l *MyAppConfigLoader) readConfigPaths(ctx context.Context, paths []string) (f myAppConfigFile, err error) {
+	flags := l.flags()
+	if flags.myServiceSecretKey.FromStdin() && slices.Contains(paths, ezconf.StdinPath) {
+		err = errors.New("-myServiceSecretKey @- and the config path - both read stdin, which can only be read once")
+		return f, err
+	}
+
+	format := optional.GetOr(optional.Or(l.ConfigFormat, flags.configFormat), "")
+	strict := optional.GetOr(optional.Or(l.StrictConfig, flags.strictConfig), false)
+	for _, path := range paths {
+		var next myAppConfigDocument
+		err = l.FileRetry.Do(ctx, func() error {
+			next = myAppConfigDocument{}
+			if path == ezconf.StdinPath {
+				return l.decodeStdin(format, strict, &next)
+			}
+			if ezconf.IsURL(path) {
+				return l.decodeURL(ctx, path, format, strict, &next)
+			}
+			if strict {
+				return ezconf.DecodeFileStrict(ctx, path, format, &next)
+			}
+			return ezconf.DecodeFileFormat(ctx, path, format, &next)
+		})
+		if err != nil {
+			return f, err
+		}
+		f = myAppConfigFileOverlay(f, next.file(path))
+	}
+
+	envFile, ok := optional.Or(l.EnvFile, flags.envFile).Get()
+	if !ok {
+		return f, nil
+	}
+	f.env, err = ezconf.ReadDotEnv(envFile)
 	if err != nil {
-		return
+		return f, err
+	}
+	return f.dotEnvLayer(l.envPrefix())
+}
+
+// dotEnvLayer returns f with the vars of the .env file in f.env laid over it, the same way the real env vars are.
+func (f myAppConfigFile) dotEnvLayer(prefix string) (myAppConfigFile, error) {
+	getenv := func(name string) string { return f.env[name] }
+	var errs []error
+	var err error
+	f.MyService, err = myServiceConfigEnv(getenv, f.MyService, prefix)
+	errs = append(errs, err)
+	f.MyDB, err = myDBConfigEnv(getenv, f.MyDB, prefix)
+	errs = append(errs, err)
+	for i, backend := range f.Backends {
+		f.Backends[i], err = backendConfigEnv(getenv, backend, fmt.Sprintf("%sBACKENDS_%d_", prefix, i))
+		errs = append(errs, err)
+	}
+	return f, errors.Join(errs...)
+}
+
+// getenv returns the env var name, or its value in the .env file if it is not set.
+func (f myAppConfigFile) getenv(name string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		return f.env[name]
+	}
+	return value
+}
+
+// decodeStdin decodes the config read from Stdin into next as format, or the detected format if it is empty. Unknown
+// keys are an error if strict is set.
+func (l *MyAppConfigLoader) decodeStdin(format string, strict bool, next *myAppConfigDocument) error {
+	l.stdinOnce.Do(func() {
+		r := l.Stdin
+		if r == nil {
+			r = os.Stdin
+		}
+		l.stdin, l.stdinErr = io.ReadAll(r)
+	})
+	if l.stdinErr != nil {
+		return &ezconf.FileLoadError{Path: ezconf.StdinPath, Op: "read", Err: l.stdinErr}
 	}
 
-	myDB, err := l.MyDB.Update()
+	err := configDecoder(strict)(l.stdin, format, next)
 	if err != nil {
-		return
+		return &ezconf.FileLoadError{Path: ezconf.StdinPath, Op: "decode", Err: err}
+	}
+	return nil
+}
+
+// decodeURL fetches the config at the http or https URL u with ConfigClient and decodes it into next as format, or the
+// format given by the extension of u if it is empty. Unknown keys are an error if strict is set.
+func (l *MyAppConfigLoader) decodeURL(ctx context.Context, u, format string, strict bool,
+	next *myAppConfigDocument) error {
+	if l.ConfigClient == nil {
+		err := errors.New("remote config files are disabled, set MyAppConfigLoader.ConfigClient to enable them")
+		return &ezconf.FileLoadError{Path: u, Op: "read", Err: err}
 	}
 
-	l.previous = MyAppConfig{MyService: myService, MyDB: myDB}
+	data, ext, err := ezconf.FetchURL(ctx, l.ConfigClient, u)
+	if err != nil {
+		return &ezconf.FileLoadError{Path: u, Op: "read", Err: err}
+	}
+	if format == "" {
+		format = ext
+	}
 
-	return l.previous, err
+	err = configDecoder(strict)(data, format, next)
+	if err != nil {
+		return &ezconf.FileLoadError{Path: u, Op: "decode", Err: err}
+	}
+	return nil
 }
 
-// Loader for MyServiceConfig type
-type MyServiceConfigLoader struct {
-	Name         optional.Str    `env:"MY_APP_MY_SERVICE_NAME"`
-	Description  optional.Str    `env:"MY_APP_MY_SERVICE_DESCRIPTION"`
-	NodeID       optional.Uint32 `json:"node" toml:"node" yaml:"node" env:"MY_APP_MY_SERVICE_NODE"`
-	Priority     optional.Uint16 `env:"MY_APP_MY_SERVICE_PRIORITY"`
-	SecretKey    file.SecretFile `env:"MY_APP_MY_SERVICE_SECRET_KEY"`
-	ServerConfig httpconf.HttpServerLoader
-	previous     MyServiceConfig
+// configDecoder returns ezconf.DecodeStrict if strict is set and ezconf.Decode otherwise.
+func configDecoder(strict bool) func([]byte, string, *myAppConfigDocument) error {
+	if strict {
+		return ezconf.DecodeStrict[myAppConfigDocument]
+	}
+	return ezconf.Decode[myAppConfigDocument]
+}
+
+// myAppConfigFileOverlay returns base with every value set in over, a config file loaded after it, laid on top. Nested
+// structs are merged field by field and Backends element by element, so over only replaces the values it sets.
+func myAppConfigFileOverlay(base, over myAppConfigFile) myAppConfigFile {
+	base.MyService = myServiceConfigOverlay(base.MyService, over.MyService)
+	base.MyDB = myDBConfigOverlay(base.MyDB, over.MyDB)
+	for i, b := range over.Backends {
+		if i >= len(base.Backends) {
+			base.Backends = append(base.Backends, b)
+			continue
+		}
+		base.Backends[i] = backendConfigOverlay(base.Backends[i], b)
+	}
+	return base
 }
 
-func (l *MyServiceConfigLoader) Update() (c MyServiceConfig, err error) {
-	var ok bool
-	// Flags are defined as package variables above. Flags override all other config sources.
-	l.NodeID = optional.Or(myServiceNodeFlag, l.NodeID)
+// computedField is a user function which derives the value of the field at path from the rest of the loaded config.
+type computedField struct {
+	path    string
+	compute func(*MyAppConfig) error
+}
 
-	// Update sub-loaders
-	if l.SecretKey.IsNone() {
-		l.SecretKey.Set(DefaultMyServiceConfigSecretKey)
+// Compute registers a function which sets the field at path, e.g. "MyService.Description", from the rest of the
+// loaded config. Compute functions run in registration order after all sources have been applied, so they are
+// recomputed on every Resolve, Update, or Reload. Registering the same path again replaces the earlier function.
+// Computed fields take no value from any source: resolving fails if the loader, a flag, an env var, or a config file
+// sets one, rather than the computed value silently replacing it. Save leaves them out for the same reason.
+// Register compute functions before the loader is shared with other goroutines.
+func (l *MyAppConfigLoader) Compute(path string, f func(*MyAppConfig) error) {
+	for i, c := range l.computed {
+		if c.path == path {
+			l.computed[i].compute = f
+			return
+		}
 	}
+	l.computed = append(l.computed, computedField{path, f})
+}
 
-	// Read values from file types
-	secretKey, ok := l.SecretKey.ReadFile()
-	if !ok {
-		return c, fmt.Errorf("MyServiceConfig missing required field: SecretKey")
+// isComputed reports whether a compute function was registered for path.
+func (l *MyAppConfigLoader) isComputed(path string) bool {
+	return slices.ContainsFunc(l.computed, func(c computedField) bool { return c.path == path })
+}
+
+// defaultFunc is a user function which computes the default of the field at path when the config is loaded.
+type defaultFunc struct {
+	path  string
+	value func() (string, error)
+}
+
+// DefaultFunc registers a function which computes the default of the field at path, e.g. "MyService.NodeID", for
+// defaults which are only known at load time such as the hostname or the number of CPUs. f returns the value as it
+// would be given in an env var and is called on every Resolve, Update, or Reload. Its value sits below the config
+// files, so every other source overrides it, and it replaces the compiled default. Like compiled defaults, it is not
+// applied by Into and is reported by Sources as a default. Registering the same path again replaces the earlier
+// function. Register default functions before the loader is shared with other goroutines.
+func (l *MyAppConfigLoader) DefaultFunc(path string, f func() (string, error)) {
+	for i, d := range l.defaults {
+		if d.path == path {
+			l.defaults[i].value = f
+			return
+		}
 	}
+	l.defaults = append(l.defaults, defaultFunc{path, f})
+}
 
-	serverConfig, err := l.ServerConfig.Update()
+// BeforeSource registers a function which is called before each source is read by Resolve, Update, or Reload, e.g. to
+// open a connection or start a timer. Sources are read from the lowest precedence to the highest: default, file, keyed,
+// env, and flag, and are named by the ezconf.Source constants. An error from f aborts the load. Register hooks before
+// the loader is shared with other goroutines.
+func (l *MyAppConfigLoader) BeforeSource(f func(source string) error) {
+	l.before = append(l.before, f)
+}
 
-	newConfig := l.previous
-	newConfig.Name, ok = l.Name.Get()
-	if !ok {
-		return c, fmt.Errorf("MyServiceConfig missing required field: Name")
+// AfterSource registers a function which is called after each source is read, with the error reading it returned, if
+// any, e.g. to close a connection or log how long the source took. It is called even if reading failed. An error from f
+// aborts the load. Register hooks before the loader is shared with other goroutines.
+func (l *MyAppConfigLoader) AfterSource(f func(source string, err error) error) {
+	l.after = append(l.after, f)
+}
+
+// source calls read, which reads the named source, between the hooks registered with BeforeSource and AfterSource.
+func (l *MyAppConfigLoader) source(source string, read func() error) error {
+	for _, h := range l.before {
+		err := h(source)
+		if err != nil {
+			return fmt.Errorf("hook before the %s source failed: %w", source, err)
+		}
 	}
-	newConfig.Description = optional.GetOr(l.Description, DefaultMyServiceConfigDescription)
-	newConfig.NodeID = optional.GetOr(l.NodeID, DefaultMyServiceConfigNodeID)
-	newConfig.Priority = optional.GetOr(l.Priority, DefaultMyServiceConfigPriority)
-	newConfig.SecretKey = secretKey
-	newConfig.ServerConfig = serverConfig
 
-	l.previous = newConfig
-	return newConfig, nil
+	err := read()
+	errs := []error{err}
+	for _, h := range l.after {
+		hookErr := h(source, err)
+		if hookErr != nil {
+			errs = append(errs, fmt.Errorf("hook after the %s source failed: %w", source, hookErr))
+		}
+	}
+	return errors.Join(errs...)
 }
 
-func (l MyServiceConfigLoader) Prev() MyServiceConfig {
-	return l.previous
+// readEnv reads the env vars of every field on top of layers, for the env source hooks. The values are read again while
+// resolving, so only the error is returned.
+func (l *MyAppConfigLoader) readEnv(layers myAppConfigFile, prefix string) error {
+	_, serviceErr := myServiceConfigEnv(os.Getenv, layers.MyService, prefix)
+	_, dbErr := myDBConfigEnv(os.Getenv, layers.MyDB, prefix)
+	errs := []error{serviceErr, dbErr}
+	for i := range max(len(l.Backends), len(layers.Backends)) {
+		_, _, err := l.backendLayers(layers.Backends, i)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
 
-// Loader for MyDBConfig type
-type MyDBConfigLoader struct {
-	Address  optional.Str    `env:"MY_APP_MY_DB_ADDRESS"`
-	Port     optional.Uint16 `env:"MY_APP_MY_DB_PORT"`
-	previous MyDBConfig
+// defaultLayer returns the values of the functions registered with DefaultFunc laid out like a config file.
+func (l *MyAppConfigLoader) defaultLayer() (f myAppConfigFile, err error) {
+	for _, d := range l.defaults {
+		v, ok := f.field(d.path)
+		if !ok {
+			return f, fmt.Errorf("failed to compute default of MyAppConfig field %s: no such field", d.path)
+		}
+
+		str, err := d.value()
+		if err == nil {
+			err = v.Set(str)
+		}
+		if err != nil {
+			return f, fmt.Errorf("failed to compute default of MyAppConfig field %s: %w", d.path, err)
+		}
+	}
+	return f, nil
+}
+
+// keyedLayer returns the values of Keyed laid out like a config file. Every field which field can set is looked up by
+// its path.
+func (l *MyAppConfigLoader) keyedLayer() (f myAppConfigFile, err error) {
+	if l.Keyed == nil {
+		return f, nil
+	}
+
+	fields := f.fields()
+	var errs []error
+	for _, path := range slices.Sorted(maps.Keys(fields)) {
+		errs = append(errs, ezconf.LoadKeyed(l.Keyed, fields[path], path))
+	}
+	err = errors.Join(errs...)
+	if err != nil {
+		return f, fmt.Errorf("failed to load MyAppConfig from Keyed: %w", err)
+	}
+	return f, nil
 }
 
-func (l *MyDBConfigLoader) Update() (c MyDBConfig, err error) {
-	// Flags are defined as package variables above. Flags override all other config sources.
-	l.Address = optional.Or(myDBAddressFlag, l.Address)
-	l.Port = optional.Or(myDBPortFlag, l.Port)
+// configLayers returns Keyed laid over the config files f, which are in turn laid over the defaults registered with
+// DefaultFunc.
+func (l *MyAppConfigLoader) configLayers(f myAppConfigFile) (myAppConfigFile, error) {
+	d, err := l.defaultLayer()
+	if err != nil {
+		return f, err
+	}
+	k, err := l.keyedLayer()
+	if err != nil {
+		return f, err
+	}
+	return myAppConfigFileOverlay(myAppConfigFileOverlay(d, f), k), nil
+}
 
-	newConfig := l.previous
+// readConfigLayers returns the config files read by readConfigFile along with the layers around them, see configLayers.
+func (l *MyAppConfigLoader) readConfigLayers(ctx context.Context) (myAppConfigFile, error) {
+	f, err := l.readConfigFile(ctx)
+	if err != nil {
+		return f, err
+	}
+	return l.configLayers(f)
+}
 
-	newConfig.Address = optional.GetOr(l.Address, DefaultMyDBConfigAddress)
-	newConfig.Port = optional.GetOr(l.Port, DefaultMyDBConfigPort)
+// field returns the value of f at path, e.g. MyService.NodeID, to set it from a string.
+func (f *myAppConfigFile) field(path string) (flag.Value, bool) {
+	v, ok := f.fields()[path]
+	return v, ok
+}
 
-	l.previous = newConfig
-	return l.previous, nil
+// fields returns every value of f which can be set from a string, keyed by path. Backends and nested library configs
+// such as ServerConfig are not included.
+func (f *myAppConfigFile) fields() map[string]flag.Value {
+	return map[string]flag.Value{
+		"MyService.Name":        &f.MyService.Name,
+		"MyService.Description": &f.MyService.Description,
+		"MyService.NodeID":      &f.MyService.NodeID,
+		"MyService.Priority":    &f.MyService.Priority,
+		"MyService.LogFormat":   &f.MyService.LogFormat,
+		"MyService.SecretKey":   &f.MyService.SecretKey,
+		"MyService.Plugins":     &f.MyService.Plugins,
+		"MyService.Salt":        &f.MyService.Salt,
+		"MyService.SessionKey":  &f.MyService.SessionKey,
+		"MyDB.Address":          &f.MyDB.Address,
+		"MyDB.Port":             &f.MyDB.Port,
+		"MyDB.SSLMode":          &f.MyDB.SSLMode,
+		"MyDB.Replicas":         &f.MyDB.Replicas,
+		"MyDB.Params":           &f.MyDB.Params,
+		"MyDB.QueryTimeout":     &f.MyDB.QueryTimeout,
+		"MyDB.ConnectTimeout":   &f.MyDB.ConnectTimeout,
+		"MyDB.MaxMessageSize":   &f.MyDB.MaxMessageSize,
+		"MyDB.Pooling":          &f.MyDB.Pooling,
+		"MyDB.Password":         &f.MyDB.Password,
+	}
 }
 
-func (l MyDBConfigLoader) Prev() MyDBConfig {
+func (l *MyAppConfigLoader) Previous() MyAppConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 	return l.previous
 }
+
+// Sources returns the source which supplied each field of the config returned by Previous, keyed by paths such as
+// MyDB.Port or Backends[0].Address, e.g. to log at startup why a field holds the value it does. Sources are named by
+// the ezconf.Source constants: loader, flag, env, keyed, file, secret, computed, or default for fields no source set.
+// Only the source is recorded, never the value, so the result is safe to log even for secrets. Params is merged from
+// every source and reports the highest one which set any key. Nested library configs such as ServerConfig are not
+// reported. Sources returns nil until a config has been loaded.
+func (l *MyAppConfigLoader) Sources() map[string]string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return maps.Clone(l.meta.sources)
+}
+
+// MarshalJSON returns the most recently loaded config as JSON so that it can be served directly from a read-only API.
+// Secrets and the key bytes in MyService.Salt and MyService.SessionKey are redacted, and it is safe to call while
+// another goroutine reloads the config. Unlike Save, this is meant for live exposure rather than for writing a config
+// file back out.
+func (l *MyAppConfigLoader) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.Previous())
+}
+
+// redacted replaces the value of secrets in the output of Redacted.
+const redacted = "[REDACTED]"
+
+// Redacted renders every field of the config on its own line as path: value, e.g. to log the effective config at
+// startup. Secrets such as MyService.SecretKey and MyDB.Password, and key bytes such as MyService.Salt, are replaced by
+// [REDACTED] whether they came from a file, env var, or SecretProvider, so the output is safe to log. Nested library
+// configs such as ServerConfig are rendered from their MarshalJSON, which leaves out TLS keys. Runtime state such as
+// StartedAt is left out.
+func (c MyAppConfig) Redacted() string {
+	var b strings.Builder
+	c.MyService.writeRedacted(&b, "MyService.")
+	c.MyDB.writeRedacted(&b, "MyDB.")
+	for i, backend := range c.Backends {
+		backend.writeRedacted(&b, fmt.Sprintf("Backends[%d].", i))
+	}
+	return b.String()
+}
+
+// redactSecret returns what Redacted shows for a secret, which only tells whether it is set.
+func redactSecret(o ezconf.Secret) string {
+	if o.IsNone() {
+		return "none"
+	}
+	return redacted
+}
+
+// bytesSecret wraps key material held as bytes so that it is redacted like any other secret. Empty bytes are None.
+func bytesSecret(b []byte) ezconf.Secret {
+	if len(b) == 0 {
+		return ezconf.NoSecret()
+	}
+	return ezconf.SomeSecret(string(b))
+}
+
+// writeRedactedJSON writes the fields of a nested library config as summarized by its MarshalJSON, which decides itself
+// what is safe to show, e.g. whether TLS is enabled rather than the certificates and keys.
+func writeRedactedJSON(b *strings.Builder, prefix string, v json.Marshaler) {
+	data, err := v.MarshalJSON()
+	var fields map[string]json.RawMessage
+	if err == nil {
+		err = json.Unmarshal(data, &fields)
+	}
+	if err != nil {
+		fmt.Fprintf(b, "%s: %v\n", strings.TrimSuffix(prefix, "."), err)
+		return
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		fmt.Fprintf(b, "%s%s: %s\n", prefix, key, fields[key])
+	}
+}
+
+// Update resolves a new MyAppConfig and stores it to be returned by Previous. It is the stateful convenience layer on
+// top of Resolve. The whole config is resolved before anything is stored, so if any source or field fails the loader
+// keeps its last good config and Previous is unchanged.
+func (l *MyAppConfigLoader) Update() (MyAppConfig, error) {
+	return l.UpdateContext(context.Background())
+}
+
+// UpdateContext is the same as Update, but gives up once ctx is done, e.g. while the config file is on a slow network
+// mount. The returned error then wraps ctx.Err() and Previous is unchanged.
+func (l *MyAppConfigLoader) UpdateContext(ctx context.Context) (MyAppConfig, error) {
+	config, meta, err := l.resolve(ctx)
+	if err != nil {
+		return config, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.previous = config
+	l.meta = meta
+	return config, nil
+}
+
+// Reload runs Update and returns the previously stored config, the new config, and whether they differ. On error the
+// stored config is left untouched and returned as both old and next. Configs are compared with reflect.DeepEqual, so a
+// handler func set on the server config will always be reported as changed.
+func (l *MyAppConfigLoader) Reload() (old, next MyAppConfig, changed bool, err error) {
+	next, meta, err := l.resolve(context.Background())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	old = l.previous
+	if err != nil {
+		return old, old, false, err
+	}
+
+	l.previous = next
+	l.meta = meta
+	return old, next, !reflect.DeepEqual(old, next), nil
+}
+
+// WatchDebounce is how long the config file must be quiet after a change before Watch reloads it.
+var WatchDebounce = 100 * time.Millisecond
+
+// Watch reruns Update every time one of the config files named by ConfigFile, ConfigFiles, or the -config flag, or the
+// .env file, changes on disk, and calls cb with the result until ctx is done. Paths matching the MyService.Plugins glob
+// pattern being added or removed count as a change too, as long as the directory they are in exists when Watch starts.
+// On error cb is handed the last good config along with the error, and Previous keeps returning it. cb is called from a
+// separate goroutine. Stdin is not watched, since it never changes once read, and neither are URLs, which are only
+// fetched again by Update or Reload. An error is returned if there is no file to watch.
+func (l *MyAppConfigLoader) Watch(ctx context.Context, cb func(MyAppConfig, error)) error {
+	paths := slices.DeleteFunc(l.configPaths(),
+		func(path string) bool { return path == ezconf.StdinPath || ezconf.IsURL(path) })
+	envFile, ok := optional.Or(l.EnvFile, l.flags().envFile).Get()
+	if ok {
+		paths = append(paths, envFile)
+	}
+	// Sources which cannot be read yet are reported by the first Update rather than here, so the glob pattern is only
+	// watched if it resolves.
+	var patterns []string
+	plugins, err := l.pluginsGlob()
+	pattern, _ := plugins.Get()
+	stat, statErr := os.Stat(filepath.Dir(pattern))
+	if err == nil && statErr == nil && stat.IsDir() {
+		patterns = append(patterns, pattern)
+	}
+	if len(paths) == 0 && len(patterns) == 0 {
+		return fmt.Errorf("cannot watch MyAppConfig: no config file or .env file was given")
+	}
+
+	return ezconf.WatchGlobs(ctx, paths, patterns, WatchDebounce, func(err error) {
+		if err != nil {
+			cb(l.Previous(), err)
+			return
+		}
+
+		config, err := l.UpdateContext(ctx)
+		if err != nil {
+			cb(l.Previous(), err)
+			return
+		}
+		cb(config, nil)
+	})
+}
+
+// Diff returns the fields which differ between two configs, e.g. those returned by Reload, using paths such as
+// MyService.ServerConfig.Port. Secrets are compared but never printed, so the result is safe to log.
+func (l *MyAppConfigLoader) Diff(old, next MyAppConfig) []ezconf.FieldChange {
+	return ezconf.Diff(old, next)
+}
+
+// CompareToFile resolves the config and compares it with the one resolved from the reference config file at path
+// instead of the loader's config files, e.g. a reviewed baseline checked in CI. Every field which differs is returned,
+// apart from the dotted paths in ignore and the fields below them, e.g. MyService.NodeID for a value expected to differ
+// between nodes. Old holds the value in effect and New the one from the reference. Both configs read the same flags,
+// env vars, and programmatic overrides, so only differences coming from the config files are reported.
+func (l *MyAppConfigLoader) CompareToFile(path string, ignore []string) ([]ezconf.FieldChange, error) {
+	ctx := context.Background()
+	config, _, err := l.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reference, _, err := l.resolvePaths(ctx, []string{path})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reference config %s: %w", path, err)
+	}
+	return ezconf.IgnoreChanges(l.Diff(config, reference), ignore), nil
+}
+
+// Lint checks the config file at path, e.g. in a pre-deploy check, and returns every problem found rather than stopping
+// at the first: keys which match no field, values which do not decode, keys under deprecated names, and required fields
+// which neither the file nor any other source sets. The file is resolved along with the loader's other sources in
+// place of its config files, so problems such as a bad value in an env var are reported too. None means the file is
+// fine.
+func (l *MyAppConfigLoader) Lint(path string) []ezconf.LintIssue {
+	ctx := context.Background()
+	format := optional.GetOr(optional.Or(l.ConfigFormat, l.flags().configFormat), "")
+	decode := func(strict bool, doc *myAppConfigDocument) error {
+		if ezconf.IsURL(path) {
+			return l.decodeURL(ctx, path, format, strict, doc)
+		}
+		if strict {
+			return ezconf.DecodeFileStrict(ctx, path, format, doc)
+		}
+		return ezconf.DecodeFileFormat(ctx, path, format, doc)
+	}
+
+	var issues []ezconf.LintIssue
+	var doc myAppConfigDocument
+	var unknown *ezconf.UnknownFieldsError
+	err := decode(true, &doc)
+	if errors.As(err, &unknown) {
+		for _, key := range unknown.Keys {
+			issues = append(issues, ezconf.LintIssue{Path: key, Message: "unknown key"})
+		}
+		err = decode(false, &doc)
+	}
+	if err != nil {
+		// Nothing more can be checked in a file which does not decode.
+		return append(issues, ezconf.LintIssue{Message: err.Error()})
+	}
+
+	if doc.MyDB.Host.IsSome() {
+		issues = append(issues, ezconf.LintIssue{Path: "MyDB.Host", Message: "deprecated name, use MyDB.Address instead"})
+	}
+	_, _, err = l.resolvePaths(ctx, []string{path})
+	return append(issues, ezconf.LintErrors(err)...)
+}
+
+// StageUpdate resolves a new MyAppConfig and holds it as pending without making it active, replacing any config which
+// was already staged. Previous keeps returning the active config until the pending one is promoted, which leaves room
+// to validate or soak the pending config before committing to it.
+func (l *MyAppConfigLoader) StageUpdate() (pending MyAppConfig, err error) {
+	pending, meta, err := l.resolve(context.Background())
+	if err != nil {
+		return pending, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending = &pending
+	l.pendingMeta = meta
+	return pending, nil
+}
+
+// Promote makes the staged config active and remembers the config it replaced so that Rollback can restore it.
+func (l *MyAppConfigLoader) Promote() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pending == nil {
+		return ErrNoStagedConfig
+	}
+
+	prev := l.previous
+	l.rollback = &prev
+	l.rollbackMeta = l.meta
+	l.previous = *l.pending
+	l.meta = l.pendingMeta
+	l.pending = nil
+	l.pendingMeta = myAppConfigMeta{}
+	return nil
+}
+
+// Rollback discards the staged config if there is one. Otherwise it restores the config which was active before the
+// last Promote. Only one level of rollback is kept.
+func (l *MyAppConfigLoader) Rollback() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pending != nil {
+		l.pending = nil
+		l.pendingMeta = myAppConfigMeta{}
+		return nil
+	}
+
+	if l.rollback == nil {
+		return ErrNoRollbackConfig
+	}
+
+	l.previous = *l.rollback
+	l.meta = l.rollbackMeta
+	l.rollback = nil
+	l.rollbackMeta = myAppConfigMeta{}
+	return nil
+}
+
+// Resolve reads all config sources and produces a new MyAppConfig. It does not store anything on the loader, so it is
+// safe to call as often as needed, e.g. from tests or to preview a reload.
+func (l *MyAppConfigLoader) Resolve() (MyAppConfig, error) {
+	return l.ResolveContext(context.Background())
+}
+
+// ResolveContext is the same as Resolve, but gives up once ctx is done. ctx is passed on to the config file read and to
+// nested library loaders such as ServerConfig.
+func (l *MyAppConfigLoader) ResolveContext(ctx context.Context) (MyAppConfig, error) {
+	config, _, err := l.resolve(ctx)
+	return config, err
+}
+
+// resolve is the same as ResolveContext, but also returns the source of every field and the paths of file fields.
+func (l *MyAppConfigLoader) resolve(ctx context.Context) (config MyAppConfig, meta myAppConfigMeta, err error) {
+	return l.resolvePaths(ctx, l.configPaths())
+}
+
+// resolvePaths is resolve with the config files at paths instead of those given to the loader.
+func (l *MyAppConfigLoader) resolvePaths(ctx context.Context, paths []string) (config MyAppConfig, meta myAppConfigMeta,
+	err error) {
+	// Each source is read in turn from the lowest precedence to the highest, between the hooks registered for it. Values
+	// set on the loader are not read from anywhere, so they have no hooks.
+	var defaults, f, keyed, layers myAppConfigFile
+	var flags myAppConfigFlags
+	prefix := l.envPrefix()
+	steps := []struct {
+		source string
+		read   func() error
+	}{
+		{ezconf.SourceDefault, func() (err error) {
+			defaults, err = l.defaultLayer()
+			return err
+		}},
+		{ezconf.SourceFile, func() (err error) {
+			f, err = l.readConfigPaths(ctx, paths)
+			return err
+		}},
+		{ezconf.SourceKeyed, func() (err error) {
+			keyed, err = l.keyedLayer()
+			return err
+		}},
+		{ezconf.SourceEnv, func() error {
+			layers = myAppConfigFileOverlay(myAppConfigFileOverlay(defaults, f), keyed)
+			return l.readEnv(layers, prefix)
+		}},
+		{ezconf.SourceFlag, func() error {
+			flags = l.flags()
+			return nil
+		}},
+	}
+	for _, s := range steps {
+		err = l.source(s.source, s.read)
+		if err != nil {
+			return
+		}
+	}
+
+	// Both sub-configs are resolved before checking for errors so that missing required fields are reported together.
+	dir, err := l.configDir()
+	if err != nil {
+		return
+	}
+
+	myService, serviceErr := l.MyService.resolve(ctx, layers.MyService, flags, prefix, dir, l.FileRetry)
+	myDB, dbErr := l.MyDB.resolve(layers.MyDB, flags, prefix)
+	backends, backendsErr := l.resolveBackends(layers.Backends)
+	err = errors.Join(serviceErr, dbErr, backendsErr)
+	if err != nil {
+		return
+	}
+
+	config = MyAppConfig{MyService: myService, MyDB: myDB, Backends: backends}
+	sources := l.resolveSources(f, flags, prefix, config)
+
+	// Templated fields are executed once every source is applied. Save writes their templates rather than the results.
+	meta.description = config.MyService.Description
+	err = myAppConfigTemplates(&config, time.Now())
+	if err != nil {
+		return config, meta, err
+	}
+
+	for _, c := range l.computed {
+		source := sources[c.path]
+		if source != ezconf.SourceDefault && source != ezconf.SourceSecret {
+			err = fmt.Errorf("MyAppConfig field %s is computed, so it cannot be set by the %s source", c.path, source)
+			return config, meta, err
+		}
+		sources[c.path] = ezconf.SourceComputed
+
+		err = c.compute(&config)
+		if err != nil {
+			return config, meta, fmt.Errorf("failed to compute MyAppConfig field %s: %w", c.path, err)
+		}
+	}
+
+	// Computed fields may have broken a rule, so the finished config is checked as a whole. Required fields which no
+	// source set were already reported by the sub-loaders, so every required field counts as set here and only zero
+	// values are reported.
+	err = validateMyAppConfig(config, func(string) bool { return true })
+	if err != nil {
+		return config, meta, err
+	}
+
+	// The env vars were already read without error by MyService.resolve, which reads SecretKey from the same file unless
+	// it was given by flag, in which case it has no path to record.
+	env, _ := myServiceConfigEnv(os.Getenv, layers.MyService, prefix)
+	meta.sources = sources
+	if l.MyService.SecretKey.IsSome() || flags.myServiceSecretKey.IsNone() {
+		meta.secretKey, _ = l.MyService.secretKeyFile(env, dir).Get()
+	}
+	meta.plugins, _ = l.MyService.pluginsGlob(env, dir).Get()
+	return config, meta, nil
+}
+
+// resolveSources returns the source of every field of config, which was resolved from the config files f, flags, and
+// the env vars starting with prefix. Each layer is checked on its own, in the same order of precedence as resolve.
+func (l *MyAppConfigLoader) resolveSources(f myAppConfigFile, flags myAppConfigFlags, prefix string,
+	config MyAppConfig) map[string]string {
+	// The env vars were already read without error while resolving config, so only the env layer is read here. Values
+	// from the .env file count as env vars.
+	service, _ := myServiceConfigEnv(f.getenv, MyServiceConfigLoader{}, prefix)
+	db, _ := myDBConfigEnv(f.getenv, MyDBConfigLoader{}, prefix)
+
+	s := map[string]string{
+		"MyService.Name": ezconf.Source(l.MyService.Name.IsSome(), false, service.Name.IsSome(),
+			f.MyService.Name.IsSome()),
+		"MyService.Description": ezconf.Source(l.MyService.Description.IsSome(), false, service.Description.IsSome(),
+			f.MyService.Description.IsSome()),
+		"MyService.NodeID": ezconf.Source(l.MyService.NodeID.IsSome(), flags.myServiceNode.IsSome(),
+			service.NodeID.IsSome(), f.MyService.NodeID.IsSome()),
+		"MyService.Priority": ezconf.Source(l.MyService.Priority.IsSome(), false, service.Priority.IsSome(),
+			f.MyService.Priority.IsSome()),
+		"MyService.LogFormat": ezconf.Source(l.MyService.LogFormat.IsSome(), flags.myServiceLogFormat.IsSome(),
+			service.LogFormat.IsSome(), f.MyService.LogFormat.IsSome()),
+		"MyService.SecretKey": ezconf.Source(l.MyService.SecretKey.IsSome(), flags.myServiceSecretKey.IsSome(),
+			service.SecretKey.IsSome(), f.MyService.SecretKey.IsSome()),
+		"MyService.Salt": ezconf.Source(l.MyService.Salt.IsSome(), flags.myServiceSalt.IsSome(), service.Salt.IsSome(),
+			f.MyService.Salt.IsSome()),
+		"MyService.SessionKey": ezconf.Source(l.MyService.SessionKey.IsSome(), false, service.SessionKey.IsSome(),
+			f.MyService.SessionKey.IsSome()),
+		"MyService.Plugins": ezconf.Source(l.MyService.Plugins.IsSome(), false, service.Plugins.IsSome(),
+			f.MyService.Plugins.IsSome()),
+		"MyDB.Address": ezconf.Source(l.MyDB.Address.IsSome(), flags.myDBAddress.IsSome(), db.Address.IsSome(),
+			f.MyDB.Address.IsSome()),
+		"MyDB.Port": ezconf.Source(l.MyDB.Port.IsSome(), flags.myDBPort.IsSome(), db.Port.IsSome(),
+			f.MyDB.Port.IsSome()),
+		"MyDB.SSLMode": ezconf.Source(l.MyDB.SSLMode.IsSome(), false, db.SSLMode.IsSome(), f.MyDB.SSLMode.IsSome()),
+		"MyDB.Replicas": ezconf.Source(l.MyDB.Replicas.IsSome(), flags.myDBReplicas.IsSome(), db.Replicas.IsSome(),
+			f.MyDB.Replicas.IsSome()),
+		"MyDB.Params": ezconf.Source(l.MyDB.Params.IsSome(), flags.myDBParams.IsSome(), db.Params.IsSome(),
+			f.MyDB.Params.IsSome()),
+		"MyDB.QueryTimeout": ezconf.Source(l.MyDB.QueryTimeout.IsSome(), flags.myDBQueryTimeout.IsSome(),
+			db.QueryTimeout.IsSome(), f.MyDB.QueryTimeout.IsSome()),
+		"MyDB.ConnectTimeout": ezconf.Source(l.MyDB.ConnectTimeout.IsSome(), flags.myDBConnectTimeout.IsSome(),
+			db.ConnectTimeout.IsSome(), f.MyDB.ConnectTimeout.IsSome()),
+		"MyDB.MaxMessageSize": ezconf.Source(l.MyDB.MaxMessageSize.IsSome(), false, db.MaxMessageSize.IsSome(),
+			f.MyDB.MaxMessageSize.IsSome()),
+		"MyDB.Pooling": ezconf.Source(l.MyDB.Pooling.IsSome(), flags.myDBPooling.IsSome(), db.Pooling.IsSome(),
+			f.MyDB.Pooling.IsSome()),
+		"MyDB.Password": ezconf.Source(l.MyDB.Password.IsSome(), false, db.Password.IsSome(),
+			f.MyDB.Password.IsSome()),
+	}
+	if s["MyDB.Password"] == ezconf.SourceDefault && config.MyDB.Password.IsSome() {
+		s["MyDB.Password"] = ezconf.SourceSecret
+	}
+	s["MyDB.AdminPassword"] = ezconf.SourceSecret
+
+	for i := range config.Backends {
+		loader := new(BackendConfigLoader)
+		if i < len(l.Backends) {
+			loader = &l.Backends[i]
+		}
+		var file BackendConfigLoader
+		if i < len(f.Backends) {
+			file = f.Backends[i]
+		}
+		env, _ := backendConfigEnv(f.getenv, BackendConfigLoader{}, fmt.Sprintf("%sBACKENDS_%d_", prefix, i))
+		path := fmt.Sprintf("Backends[%d].", i)
+		s[path+"Address"] = ezconf.Source(loader.Address.IsSome(), false, env.Address.IsSome(), file.Address.IsSome())
+		s[path+"Port"] = ezconf.Source(loader.Port.IsSome(), false, env.Port.IsSome(), file.Port.IsSome())
+		s[path+"Weight"] = ezconf.Source(loader.Weight.IsSome(), false, env.Weight.IsSome(), file.Weight.IsSome())
+	}
+
+	// Keyed sits between the config files and env vars, so it only takes over from those two.
+	for path, source := range s {
+		if l.Keyed != nil && (source == ezconf.SourceFile || source == ezconf.SourceDefault) && l.Keyed.IsSet(path) {
+			s[path] = ezconf.SourceKeyed
+		}
+	}
+	return s
+}
+
+// ValidateMyAppConfig applies every required and validate tag of MyAppConfig to c, e.g. for a config built by hand from
+// a UI rather than loaded. A plain config cannot tell an unset field from one set to its zero value, so required fields
+// holding their zero value, e.g. a NodeID of 0, are reported as missing. Resolve and Update apply the same tags to
+// every config they load, but know which fields a source set, so they report a required field which a source set to
+// its zero value as an invalid value instead.
+func ValidateMyAppConfig(c MyAppConfig) error {
+	set := map[string]bool{"MyService.Name": c.MyService.Name != "", "MyService.NodeID": c.MyService.NodeID != 0}
+	return validateMyAppConfig(c, func(path string) bool { return set[path] })
+}
+
+// validateMyAppConfig is ValidateMyAppConfig with set reporting whether the required field at path, e.g.
+// MyService.NodeID, was set by a source.
+func validateMyAppConfig(c MyAppConfig, set func(path string) bool) error {
+	service := c.MyService.validate(func(field string) bool { return set("MyService." + field) })
+	return errors.Join(service, c.MyDB.validate())
+}
+
+// backendLayers returns a pointer to the loader layer of element i of Backends, so that it is not copied, along with
+// the config file layer, given by base, with the env vars for that element applied on top. Either layer is empty if it
+// has fewer elements.
+func (l *MyAppConfigLoader) backendLayers(base []BackendConfigLoader, i int) (loader *BackendConfigLoader,
+	env BackendConfigLoader, err error) {
+	loader = new(BackendConfigLoader)
+	if i < len(l.Backends) {
+		loader = &l.Backends[i]
+	}
+	if i < len(base) {
+		env = base[i]
+	}
+
+	env, err = backendConfigEnv(os.Getenv, env, fmt.Sprintf("%sBACKENDS_%d_", l.envPrefix(), i))
+	if err != nil {
+		err = fmt.Errorf("failed to load MyAppConfig.Backends[%d]: %w", i, err)
+	}
+	return loader, env, err
+}
+
+// resolveBackends resolves every element of Backends set on the loader or in the config file, given by base.
+func (l *MyAppConfigLoader) resolveBackends(base []BackendConfigLoader) ([]BackendConfig, error) {
+	n := max(len(l.Backends), len(base))
+	if n == 0 {
+		return nil, nil
+	}
+
+	backends := make([]BackendConfig, n)
+	var errs []error
+	for i := range n {
+		loader, env, err := l.backendLayers(base, i)
+		errs = append(errs, err)
+		backends[i] = loader.resolve(env)
+	}
+	return backends, errors.Join(errs...)
+}
+
+// intoBackends writes every element of Backends set on the loader or in the config file into backends, growing it as
+// needed. Existing elements are updated in place like any other nested config.
+func (l *MyAppConfigLoader) intoBackends(backends *[]BackendConfig, base []BackendConfigLoader) error {
+	n := max(len(l.Backends), len(base))
+	tmp := slices.Clone(*backends)
+	if len(tmp) < n {
+		tmp = append(tmp, make([]BackendConfig, n-len(tmp))...)
+	}
+
+	var errs []error
+	for i := range n {
+		loader, env, err := l.backendLayers(base, i)
+		errs = append(errs, err)
+		loader.into(&tmp[i], env)
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		return err
+	}
+
+	*backends = tmp
+	return nil
+}
+
+// myAppConfigSaved is the layout written by Save. It mirrors MyAppConfig, except that file fields hold their path.
+type myAppConfigSaved struct {
+	MyService myServiceConfigSaved
+	MyDB      myDBConfigSaved
+	Backends  []BackendConfig `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+}
+
+// Fields are nil when Save leaves them out, which is what savedField decides.
+type myServiceConfigSaved struct {
+	Name        *string `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+	Description *string `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+	NodeID      *uint32 `json:"node,omitempty" toml:"node,omitempty" yaml:"node,omitempty"`
+	Priority    *uint16 `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+	LogFormat   *string `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+	SecretKey   *string `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+	Salt        *string `json:",omitempty" toml:",omitempty" yaml:",omitempty"` // base64, as it is read.
+	SessionKey  *string `json:",omitempty" toml:",omitempty" yaml:",omitempty"` // hex, as it is read.
+	Plugins     *string `json:",omitempty" toml:",omitempty" yaml:",omitempty"` // The glob, not the paths it matched.
+}
+
+type myDBConfigSaved struct {
+	Address  *string            `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+	Port     *uint16            `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+	SSLMode  *string            `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+	Replicas *[]string          `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+	Params   *map[string]string `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+	// Written as e.g. 1m30s, since a bare number of nanoseconds would not load again.
+	QueryTimeout *string `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+	// Written with a unit as well, although a bare number would load as seconds.
+	ConnectTimeout *string `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+	// Written as a number of bytes with a B suffix, since a bare number would load as MiB.
+	MaxMessageSize *string `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+	Pooling        *bool   `json:",omitempty" toml:",omitempty" yaml:",omitempty"`
+}
+
+// savedField returns a pointer to v for Save to write, or nil to leave the field out of the file when omit is set.
+func savedField[T any](omit bool, v T) *T {
+	if omit {
+		return nil
+	}
+	return &v
+}
+
+// Save writes the config most recently loaded by Update to path as TOML, JSON, or YAML depending on the extension. The
+// file can be loaded again with -config. Secrets and other file fields are written as the path they were read from,
+// never their contents, using the paths recorded when the config was loaded so that later changes to the flags, env
+// vars, or config files do not affect it. Secrets given by flag or fetched from a SecretProvider, such as
+// MyDB.Password, and nested library configs such as ServerConfig are not saved. Neither are computed fields, since
+// setting them in a config file is an error. Salt and SessionKey are saved as they are, so a file which holds either
+// one is written with 0600 permissions.
+func (l *MyAppConfigLoader) Save(path string) error {
+	return l.save(path, false)
+}
+
+// SaveMinimal is the same as Save, but leaves out every field which holds its default, so that the file only holds the
+// intentional overrides. Required fields are always written, as is every Backends entry in full.
+func (l *MyAppConfigLoader) SaveMinimal(path string) error {
+	return l.save(path, true)
+}
+
+// save implements Save, leaving out the fields which hold their default if minimal is set.
+func (l *MyAppConfigLoader) save(path string, minimal bool) error {
+	l.mu.RLock()
+	c := l.previous
+	meta := l.meta
+	l.mu.RUnlock()
+
+	d := DefaultMyAppConfig()
+	// omit reports whether the field at p is left out, given whether it holds its default. Empty bytes, lists, and maps
+	// are their default and never written.
+	omit := func(p string, dflt bool) bool {
+		return l.isComputed(p) || (minimal && dflt)
+	}
+	// File fields hold their default path if no source set them.
+	fromDefault := func(p string) bool {
+		return meta.sources[p] == ezconf.SourceDefault
+	}
+	saved := myAppConfigSaved{
+		MyService: myServiceConfigSaved{
+			Name: savedField(omit("MyService.Name", false), c.MyService.Name),
+			Description: savedField(omit("MyService.Description", meta.description == d.MyService.Description),
+				meta.description),
+			NodeID: savedField(omit("MyService.NodeID", false), c.MyService.NodeID),
+			Priority: savedField(omit("MyService.Priority", c.MyService.Priority == d.MyService.Priority),
+				c.MyService.Priority),
+			LogFormat: savedField(omit("MyService.LogFormat", c.MyService.LogFormat == d.MyService.LogFormat),
+				c.MyService.LogFormat),
+			SecretKey: savedField(omit("MyService.SecretKey", fromDefault("MyService.SecretKey")) || meta.secretKey == "",
+				meta.secretKey),
+			Salt: savedField(l.isComputed("MyService.Salt") || len(c.MyService.Salt) == 0,
+				base64.StdEncoding.EncodeToString(c.MyService.Salt)),
+			SessionKey: savedField(l.isComputed("MyService.SessionKey") || len(c.MyService.SessionKey) == 0,
+				hex.EncodeToString(c.MyService.SessionKey)),
+			Plugins: savedField(omit("MyService.Plugins", fromDefault("MyService.Plugins")), meta.plugins),
+		},
+		MyDB: myDBConfigSaved{
+			Address:  savedField(omit("MyDB.Address", c.MyDB.Address == d.MyDB.Address), c.MyDB.Address),
+			Port:     savedField(omit("MyDB.Port", c.MyDB.Port == d.MyDB.Port), c.MyDB.Port),
+			SSLMode:  savedField(omit("MyDB.SSLMode", c.MyDB.SSLMode == d.MyDB.SSLMode), c.MyDB.SSLMode),
+			Replicas: savedField(l.isComputed("MyDB.Replicas") || len(c.MyDB.Replicas) == 0, c.MyDB.Replicas),
+			Params:   savedField(l.isComputed("MyDB.Params") || len(c.MyDB.Params) == 0, c.MyDB.Params),
+			QueryTimeout: savedField(omit("MyDB.QueryTimeout", c.MyDB.QueryTimeout == d.MyDB.QueryTimeout),
+				c.MyDB.QueryTimeout.String()),
+			ConnectTimeout: savedField(omit("MyDB.ConnectTimeout", c.MyDB.ConnectTimeout == d.MyDB.ConnectTimeout),
+				c.MyDB.ConnectTimeout.String()),
+			MaxMessageSize: savedField(omit("MyDB.MaxMessageSize", c.MyDB.MaxMessageSize == d.MyDB.MaxMessageSize),
+				fmt.Sprintf("%dB", c.MyDB.MaxMessageSize)),
+			Pooling: savedField(omit("MyDB.Pooling", c.MyDB.Pooling == d.MyDB.Pooling), c.MyDB.Pooling),
+		},
+		Backends: c.Backends,
+	}
+	if saved.MyService.Salt != nil || saved.MyService.SessionKey != nil {
+		return ezconf.EncodeSecretFile(path, saved)
+	}
+	return ezconf.EncodeFile(path, saved)
+}
+
+// Into resolves all config sources and writes the result into cfg. Unlike Update, compiled defaults are not applied:
+// any field not set by a source keeps whatever value the caller already had in cfg. Secret files are read the same way
+// as in Update, and nested loaders such as the server config are always resolved in full. On error cfg is unchanged.
+func (l *MyAppConfigLoader) Into(cfg *MyAppConfig) error {
+	f, err := l.readConfigFile(context.Background())
+	if err != nil {
+		return err
+	}
+
+	tmp := *cfg
+	flags := l.flags()
+	err = errors.Join(
+		l.MyService.into(&tmp.MyService, f.MyService, flags, l.envPrefix(), l.FileRetry),
+		l.MyDB.into(&tmp.MyDB, f.MyDB, flags, l.envPrefix()),
+		l.intoBackends(&tmp.Backends, f.Backends),
+	)
+	if err != nil {
+		return err
+	}
+
+	*cfg = tmp
+	return nil
+}
+
+// fieldLayer returns the config file value of a single field with its env var read on top, for the Get accessors below.
+// Only the env var name is read, so malformed env vars for other fields are not reported.
+func fieldLayer[T any, P interface {
+	*T
+	flag.Value
+}](file T, name string) (T, error) {
+	err := ezconf.LoadEnv(P(&file), name)
+	return file, err
+}
+
+// The Get accessors resolve a single MyAppConfig field from its own sources, with the same precedence and defaults as
+// Resolve, for libraries which only need one value. Other fields are never resolved, so their required fields and
+// validate rules cannot fail the call. Backends and nested library configs such as ServerConfig have no accessors.
+
+// GetMyServiceName resolves MyService.Name on its own. It is required, so an error is returned if no source sets it.
+func (l *MyAppConfigLoader) GetMyServiceName() (string, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return "", err
+	}
+	env, err := fieldLayer(f.MyService.Name, l.envPrefix()+"MY_SERVICE_NAME")
+	if err != nil {
+		return "", err
+	}
+
+	name, ok := optional.Or(l.MyService.Name, env).Get()
+	if !ok {
+		return "", ezconf.Required("MyServiceConfig", []string{"Name"})
+	}
+	return name, nil
+}
+
+// GetMyServiceDescription resolves MyService.Description on its own.
+func (l *MyAppConfigLoader) GetMyServiceDescription() (string, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return "", err
+	}
+	env, err := fieldLayer(f.MyService.Description, l.envPrefix()+"MY_SERVICE_DESCRIPTION")
+	if err != nil {
+		return "", err
+	}
+	return optional.GetOr(optional.Or(l.MyService.Description, env), DefaultMyServiceConfigDescription), nil
+}
+
+// GetMyServiceNodeID resolves MyService.NodeID on its own. It is required, so an error is returned if no source sets
+// it.
+func (l *MyAppConfigLoader) GetMyServiceNodeID() (uint32, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	env, err := fieldLayer(f.MyService.NodeID, l.envPrefix()+"MY_SERVICE_NODE")
+	if err != nil {
+		return 0, err
+	}
+
+	nodeID, ok := optional.Or(l.MyService.NodeID, optional.Or(l.flags().myServiceNode, env)).Get()
+	if !ok {
+		return 0, ezconf.Required("MyServiceConfig", []string{"NodeID"})
+	}
+	return nodeID, nil
+}
+
+// GetMyServicePriority resolves MyService.Priority on its own.
+func (l *MyAppConfigLoader) GetMyServicePriority() (uint16, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	env, err := fieldLayer(f.MyService.Priority, l.envPrefix()+ezconf.EnvName("MyService.Priority"))
+	if err != nil {
+		return 0, err
+	}
+	return optional.GetOr(optional.Or(l.MyService.Priority, env), DefaultMyServiceConfigPriority), nil
+}
+
+// GetMyServiceLogFormat resolves MyService.LogFormat on its own. An error is returned if it is not one of json, text,
+// or logfmt.
+func (l *MyAppConfigLoader) GetMyServiceLogFormat() (string, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return "", err
+	}
+	env, err := fieldLayer(f.MyService.LogFormat, l.envPrefix()+"MY_SERVICE_LOG_FORMAT")
+	if err != nil {
+		return "", err
+	}
+
+	logFormat := optional.Or(l.MyService.LogFormat, optional.Or(l.flags().myServiceLogFormat.Str, env))
+	return myServiceConfigLogFormat(optional.GetOr(logFormat, DefaultMyServiceConfigLogFormat))
+}
+
+// GetMyServiceSecretKey resolves MyService.SecretKey on its own by reading the secret file, unless the
+// -myServiceSecretKey flag gave the secret itself.
+func (l *MyAppConfigLoader) GetMyServiceSecretKey() (optional.Secret, error) {
+	flagSecret := l.flags().myServiceSecretKey
+	if l.MyService.SecretKey.IsNone() && flagSecret.IsSome() {
+		return flagSecret.Secret, nil
+	}
+
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return optional.NoSecret(), err
+	}
+	var env MyServiceConfigLoader
+	env.SecretKey, err = fieldLayer(f.MyService.SecretKey, l.envPrefix()+"MY_SERVICE_SECRET_KEY")
+	if err != nil {
+		return optional.NoSecret(), err
+	}
+	dir, err := l.configDir()
+	if err != nil {
+		return optional.NoSecret(), err
+	}
+
+	return readSecretFile(context.Background(), l.FileRetry, l.MyService.secretKeyFile(env, dir),
+		"MyServiceConfig.SecretKey")
+}
+
+// GetMyServicePlugins resolves MyService.Plugins on its own by expanding its glob pattern.
+func (l *MyAppConfigLoader) GetMyServicePlugins() ([]string, error) {
+	plugins, err := l.pluginsGlob()
+	if err != nil {
+		return nil, err
+	}
+	return expandGlob(plugins, "MyServiceConfig.Plugins")
+}
+
+// pluginsGlob resolves the glob pattern MyService.Plugins is expanded from.
+func (l *MyAppConfigLoader) pluginsGlob() (file.Glob, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return file.NoGlob(), err
+	}
+	var env MyServiceConfigLoader
+	env.Plugins, err = fieldLayer(f.MyService.Plugins, l.envPrefix()+"MY_SERVICE_PLUGINS")
+	if err != nil {
+		return file.NoGlob(), err
+	}
+	dir, err := l.configDir()
+	if err != nil {
+		return file.NoGlob(), err
+	}
+	return l.MyService.pluginsGlob(env, dir), nil
+}
+
+// GetMyServiceSalt resolves MyService.Salt on its own.
+func (l *MyAppConfigLoader) GetMyServiceSalt() ([]byte, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	env, err := fieldLayer(f.MyService.Salt, l.envPrefix()+"MY_SERVICE_SALT")
+	if err != nil {
+		return nil, err
+	}
+	return l.MyService.Salt.Or(l.flags().myServiceSalt.Or(env)).GetOr(nil), nil
+}
+
+// GetMyServiceSessionKey resolves MyService.SessionKey on its own.
+func (l *MyAppConfigLoader) GetMyServiceSessionKey() ([]byte, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	env, err := fieldLayer(f.MyService.SessionKey, l.envPrefix()+"MY_SERVICE_SESSION_KEY")
+	if err != nil {
+		return nil, err
+	}
+	return l.MyService.SessionKey.Or(env).GetOr(nil), nil
+}
+
+// GetMyDBAddress resolves MyDB.Address on its own.
+func (l *MyAppConfigLoader) GetMyDBAddress() (string, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return "", err
+	}
+	env, err := fieldLayer(f.MyDB.Address, l.envPrefix()+"MY_DB_ADDRESS")
+	if err != nil {
+		return "", err
+	}
+	err = ezconf.LoadDeprecatedEnv(&env, l.envPrefix()+"MY_DB_HOST", l.envPrefix()+"MY_DB_ADDRESS")
+	if err != nil {
+		return "", err
+	}
+
+	address := optional.GetOr(optional.Or(l.MyDB.Address, optional.Or(l.flags().myDBAddress, env)),
+		DefaultMyDBConfigAddress)
+	err = MyDBConfig{Address: address}.validateField("Address")
+	if err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// GetMyDBPort resolves MyDB.Port on its own.
+func (l *MyAppConfigLoader) GetMyDBPort() (uint16, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	env, err := fieldLayer(f.MyDB.Port, l.envPrefix()+"MY_DB_PORT")
+	if err != nil {
+		return 0, err
+	}
+
+	port := optional.GetOr(optional.Or(l.MyDB.Port, optional.Or(l.flags().myDBPort, env)), DefaultMyDBConfigPort)
+	err = MyDBConfig{Port: port}.validateField("Port")
+	if err != nil {
+		return 0, err
+	}
+	return port, nil
+}
+
+// GetMyDBSSLMode resolves MyDB.SSLMode on its own.
+func (l *MyAppConfigLoader) GetMyDBSSLMode() (string, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return "", err
+	}
+	env, err := fieldLayer(f.MyDB.SSLMode, l.envPrefix()+ezconf.EnvName("MyDB.SSLMode"))
+	if err != nil {
+		return "", err
+	}
+
+	sslMode := optional.GetOr(optional.Or(l.MyDB.SSLMode, env), DefaultMyDBConfigSSLMode)
+	err = MyDBConfig{SSLMode: sslMode}.validateField("SSLMode")
+	if err != nil {
+		return "", err
+	}
+	return sslMode, nil
+}
+
+// GetMyDBReplicas resolves MyDB.Replicas on its own.
+func (l *MyAppConfigLoader) GetMyDBReplicas() ([]string, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	env, err := fieldLayer(f.MyDB.Replicas, l.envPrefix()+"MY_DB_REPLICAS")
+	if err != nil {
+		return nil, err
+	}
+	return l.MyDB.Replicas.Or(l.flags().myDBReplicas.Or(env)).GetOr(nil), nil
+}
+
+// GetMyDBParams resolves MyDB.Params on its own, merging the maps from every source key by key.
+func (l *MyAppConfigLoader) GetMyDBParams() (map[string]string, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	env, err := fieldLayer(f.MyDB.Params, l.envPrefix()+"MY_DB_PARAMS")
+	if err != nil {
+		return nil, err
+	}
+	return l.MyDB.Params.Merge(l.flags().myDBParams.Merge(env)).GetOr(nil), nil
+}
+
+// GetMyDBQueryTimeout resolves MyDB.QueryTimeout on its own.
+func (l *MyAppConfigLoader) GetMyDBQueryTimeout() (time.Duration, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	env, err := fieldLayer(f.MyDB.QueryTimeout, l.envPrefix()+"MY_DB_QUERY_TIMEOUT")
+	if err != nil {
+		return 0, err
+	}
+	return optional.GetOr(optional.Or(l.MyDB.QueryTimeout, optional.Or(l.flags().myDBQueryTimeout, env)),
+		DefaultMyDBConfigQueryTimeout), nil
+}
+
+// GetMyDBConnectTimeout resolves MyDB.ConnectTimeout on its own. Bare numbers are read as seconds.
+func (l *MyAppConfigLoader) GetMyDBConnectTimeout() (time.Duration, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	env, err := fieldLayer(f.MyDB.ConnectTimeout, l.envPrefix()+"MY_DB_CONNECT_TIMEOUT")
+	if err != nil {
+		return 0, err
+	}
+
+	connectTimeout, err := optional.Or(l.MyDB.ConnectTimeout, optional.Or(l.flags().myDBConnectTimeout,
+		env)).Duration("MyDBConfig.ConnectTimeout", "s")
+	if err != nil {
+		return 0, err
+	}
+	return optional.GetOr(connectTimeout, DefaultMyDBConfigConnectTimeout), nil
+}
+
+// GetMyDBMaxMessageSize resolves MyDB.MaxMessageSize on its own. Bare numbers are read as MiB.
+func (l *MyAppConfigLoader) GetMyDBMaxMessageSize() (uint64, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	env, err := fieldLayer(f.MyDB.MaxMessageSize, l.envPrefix()+"MY_DB_MAX_MESSAGE_SIZE")
+	if err != nil {
+		return 0, err
+	}
+
+	maxMessageSize, err := optional.Or(l.MyDB.MaxMessageSize, env).Size("MyDBConfig.MaxMessageSize", "MiB")
+	if err != nil {
+		return 0, err
+	}
+	return optional.GetOr(maxMessageSize, DefaultMyDBConfigMaxMessageSize), nil
+}
+
+// GetMyDBPooling resolves MyDB.Pooling on its own.
+func (l *MyAppConfigLoader) GetMyDBPooling() (bool, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return false, err
+	}
+	env, err := fieldLayer(f.MyDB.Pooling, l.envPrefix()+"MY_DB_POOLING")
+	if err != nil {
+		return false, err
+	}
+	return optional.GetOr(optional.Or(l.MyDB.Pooling, optional.Or(l.flags().myDBPooling, env)),
+		DefaultMyDBConfigPooling), nil
+}
+
+// GetMyDBPassword resolves MyDB.Password on its own, fetching it from its SecretProvider if no other source sets it.
+func (l *MyAppConfigLoader) GetMyDBPassword() (optional.Secret, error) {
+	f, err := l.readConfigLayers(context.Background())
+	if err != nil {
+		return optional.NoSecret(), err
+	}
+	var env MyDBConfigLoader
+	env.Password, err = fieldLayer(f.MyDB.Password, l.envPrefix()+"MY_DB_PASSWORD")
+	if err != nil {
+		return optional.NoSecret(), err
+	}
+	return l.MyDB.password(env)
+}
+
+// Loader for MyServiceConfig type
+type MyServiceConfigLoader struct {
+	Name         optional.Str    `env:"MY_SERVICE_NAME"`
+	Description  optional.Str    `env:"MY_SERVICE_DESCRIPTION"`
+	NodeID       optional.Uint32 `json:"node" toml:"node" yaml:"node" env:"MY_SERVICE_NODE"`
+	Priority     optional.Uint16 // Read from the env var named by ezconf.EnvName, MY_SERVICE_PRIORITY.
+	LogFormat    optional.Str    `env:"MY_SERVICE_LOG_FORMAT"`
+	SecretKey    file.SecretFile `env:"MY_SERVICE_SECRET_KEY"`
+	Salt         ezconf.Bytes    `env:"MY_SERVICE_SALT"`
+	SessionKey   ezconf.HexBytes `env:"MY_SERVICE_SESSION_KEY"`
+	Plugins      file.Glob       `env:"MY_SERVICE_PLUGINS"`
+	ServerConfig httpconf.HttpServerLoader
+	previous     ezconf.Snapshot[MyServiceConfig]
+}
+
+func (l *MyServiceConfigLoader) Update() (MyServiceConfig, error) {
+	c, err := l.Resolve()
+	if err != nil {
+		return c, err
+	}
+
+	l.previous.Store(c)
+	return c, nil
+}
+
+// secretKeyFile returns the path SecretKey is read from, falling back to the default path joined with dir.
+func (l *MyServiceConfigLoader) secretKeyFile(env MyServiceConfigLoader, dir string) file.SecretFile {
+	secretKeyFile := l.SecretKey
+	if secretKeyFile.IsNone() {
+		secretKeyFile = env.SecretKey
+	}
+	if secretKeyFile.IsNone() {
+		secretKeyFile.Set(ezconf.DefaultPath(dir, DefaultMyServiceConfigSecretKey))
+	}
+	return secretKeyFile
+}
+
+// pluginsGlob returns the glob pattern Plugins is expanded from, falling back to the default pattern joined with dir.
+func (l *MyServiceConfigLoader) pluginsGlob(env MyServiceConfigLoader, dir string) file.Glob {
+	plugins := optional.Or(l.Plugins, env.Plugins)
+	if plugins.IsNone() {
+		plugins = file.SomeGlob(ezconf.DefaultPath(dir, DefaultMyServiceConfigPlugins))
+	}
+	return plugins
+}
+
+// myServiceConfigLogFormat returns the spelling of logFormat from the oneOfValues tag of MyServiceConfig.LogFormat.
+func myServiceConfigLogFormat(logFormat string) (string, error) {
+	return ezconf.OneOfValues("MyServiceConfig.LogFormat", logFormat, myServiceConfigLogFormatValues, true)
+}
+
+// expandGlob returns the paths matching g for the field at path. A pattern which matches nothing is not an error.
+func expandGlob(g file.Glob, path string) ([]string, error) {
+	paths, err := g.Expand()
+	if err != nil {
+		return nil, &ezconf.ValidationError{Field: path, Reason: fmt.Sprintf("bad glob pattern %q", g.String())}
+	}
+	return paths, nil
+}
+
+// readSecretFile reads the secret file f for the field at path, retrying failed reads according to retry. A path which
+// is not set is reported as a missing field, while one which is set but cannot be read, such as a default path with no
+// file, is a FileLoadError naming the path.
+func readSecretFile(ctx context.Context, retry ezconf.RetryPolicy, f file.SecretFile, path string) (optional.Secret,
+	error) {
+	var secret optional.Secret
+	err := retry.Do(ctx, func() (err error) {
+		secret, err = f.Read()
+		return err
+	})
+	if errors.Is(err, file.ErrFileNone) {
+		return secret, &ezconf.MissingRequiredError{Field: path}
+	}
+	if err != nil {
+		name, _ := f.Get()
+		return secret, &ezconf.FileLoadError{Path: name, Op: "read", Field: path, Err: err}
+	}
+	return secret, nil
+}
+
+// myServiceConfigEnv reads the env vars for MyServiceConfig with getenv on top of base, which holds the values from the
+// config file. Every env var name starts with prefix.
+func myServiceConfigEnv(getenv func(string) string, base MyServiceConfigLoader,
+	prefix string) (env MyServiceConfigLoader, err error) {
+	env = base
+	err = errors.Join(
+		ezconf.LoadEnvFrom(getenv, &env.Name, prefix+"MY_SERVICE_NAME"),
+		ezconf.LoadEnvFrom(getenv, &env.Description, prefix+"MY_SERVICE_DESCRIPTION"),
+		ezconf.LoadEnvFrom(getenv, &env.NodeID, prefix+"MY_SERVICE_NODE"),
+		ezconf.LoadEnvFrom(getenv, &env.Priority, prefix+ezconf.EnvName("MyService.Priority")),
+		ezconf.LoadEnvFrom(getenv, &env.LogFormat, prefix+"MY_SERVICE_LOG_FORMAT"),
+		ezconf.LoadEnvFrom(getenv, &env.SecretKey, prefix+"MY_SERVICE_SECRET_KEY"),
+		ezconf.LoadEnvFrom(getenv, &env.Salt, prefix+"MY_SERVICE_SALT"),
+		ezconf.LoadEnvFrom(getenv, &env.SessionKey, prefix+"MY_SERVICE_SESSION_KEY"),
+		ezconf.LoadEnvFrom(getenv, &env.Plugins, prefix+"MY_SERVICE_PLUGINS"),
+		ezconf.LoadEnvFrom(getenv, &env.ServerConfig.DisableTls, prefix+"MY_SERVICE_NO_TLS"),
+	)
+	return
+}
+
+// myServiceConfigNoTls returns the DisableTls override for ServerConfig from the flag and env var. It is passed to
+// ResolveDisableTls instead of being set on a copy of ServerConfig, which keeps flags and env vars out of the
+// programmatic layer set on l.
+func myServiceConfigNoTls(env MyServiceConfigLoader, flags myAppConfigFlags) optional.Bool {
+	return optional.Or(flags.myServiceNoTls, env.ServerConfig.DisableTls)
+}
+
+// myServiceConfigOverlay returns base with every field set in over, which was read from a later config file, on top.
+func myServiceConfigOverlay(base, over MyServiceConfigLoader) MyServiceConfigLoader {
+	base.Name = optional.Or(over.Name, base.Name)
+	base.Description = optional.Or(over.Description, base.Description)
+	base.NodeID = optional.Or(over.NodeID, base.NodeID)
+	base.Priority = optional.Or(over.Priority, base.Priority)
+	base.LogFormat = optional.Or(over.LogFormat, base.LogFormat)
+	base.SecretKey = optional.Or(over.SecretKey, base.SecretKey)
+	base.Salt = over.Salt.Or(base.Salt)
+	base.SessionKey = over.SessionKey.Or(base.SessionKey)
+	base.Plugins = optional.Or(over.Plugins, base.Plugins)
+	return base
+}
+
+func (l *MyServiceConfigLoader) Resolve() (MyServiceConfig, error) {
+	return l.resolve(context.Background(), MyServiceConfigLoader{}, lookupMyAppConfigFlags(flag.CommandLine),
+		DefaultMyAppConfigEnvPrefix, DefaultMyAppConfigDir, ezconf.RetryPolicy{})
+}
+
+func (l *MyServiceConfigLoader) resolve(ctx context.Context, base MyServiceConfigLoader, flags myAppConfigFlags, prefix,
+	dir string, retry ezconf.RetryPolicy) (c MyServiceConfig, err error) {
+	env, err := myServiceConfigEnv(os.Getenv, base, prefix)
+	if err != nil {
+		return c, err
+	}
+
+	// Flags are read from the FlagSet given to RegisterMyAppConfigFlags. Values set on the loader itself are the
+	// programmatic layer and override flags, which in turn override env vars and then the config file.
+	name := optional.Or(l.Name, env.Name)
+	description := optional.Or(l.Description, env.Description)
+	nodeID := optional.Or(l.NodeID, optional.Or(flags.myServiceNode, env.NodeID))
+	priority := optional.Or(l.Priority, env.Priority)
+	logFormat := optional.Or(l.LogFormat, optional.Or(flags.myServiceLogFormat.Str, env.LogFormat))
+	salt := l.Salt.Or(flags.myServiceSalt.Or(env.Salt))
+	sessionKey := l.SessionKey.Or(env.SessionKey)
+
+	// Read values from file types. A secret given by flag is used instead of the file, unless the loader names one.
+	secretKey := flags.myServiceSecretKey.Secret
+	if l.SecretKey.IsSome() || secretKey.IsNone() {
+		secretKey, err = readSecretFile(ctx, retry, l.secretKeyFile(env, dir), "MyServiceConfig.SecretKey")
+		if err != nil {
+			return c, err
+		}
+	}
+	plugins, err := expandGlob(l.pluginsGlob(env, dir), "MyServiceConfig.Plugins")
+	if err != nil {
+		return c, err
+	}
+
+	serverConfig, err := l.ServerConfig.ResolveDisableTls(ctx, myServiceConfigNoTls(env, flags))
+	if err != nil {
+		return c, err
+	}
+
+	// Every field tagged required is checked so that all of the missing ones are reported together, along with any
+	// invalid value. Required fields are missing if no source sets them, and invalid if a source sets their zero value.
+	var newConfig MyServiceConfig
+	newConfig.Name = optional.GetOr(name, "")
+	newConfig.NodeID = optional.GetOr(nodeID, 0)
+	set := map[string]bool{"Name": name.IsSome(), "NodeID": nodeID.IsSome()}
+	requiredErr := newConfig.required(func(field string) bool { return set[field] })
+
+	newConfig.Description = optional.GetOr(description, DefaultMyServiceConfigDescription)
+	newConfig.Priority = optional.GetOr(priority, DefaultMyServiceConfigPriority)
+	newConfig.LogFormat, err = myServiceConfigLogFormat(optional.GetOr(logFormat, DefaultMyServiceConfigLogFormat))
+	err = errors.Join(requiredErr, err)
+	if err != nil {
+		return c, err
+	}
+	newConfig.SecretKey.Secret = secretKey
+	newConfig.Salt = salt.GetOr(nil)
+	newConfig.SessionKey = sessionKey.GetOr(nil)
+	newConfig.Plugins = plugins
+	newConfig.ServerConfig = serverConfig
+
+	return newConfig, nil
+}
+
+// validate checks the required fields of MyServiceConfig, as reported by set, and that LogFormat is one of its
+// oneOfValues.
+func (c MyServiceConfig) validate(set func(field string) bool) error {
+	_, err := myServiceConfigLogFormat(c.LogFormat)
+	return errors.Join(c.required(set), err)
+}
+
+// required reports the required fields of MyServiceConfig for which set returns false as missing, and those which set
+// reports as set but hold their zero value, e.g. a NodeID of 0, as invalid values.
+func (c MyServiceConfig) required(set func(field string) bool) error {
+	var missing []string
+	var errs []error
+	if !set("Name") {
+		missing = append(missing, "Name")
+	}
+	if set("Name") && c.Name == "" {
+		errs = append(errs, &ezconf.ValidationError{Field: "MyServiceConfig.Name", Reason: "required field is empty"})
+	}
+	if !set("NodeID") {
+		missing = append(missing, "NodeID")
+	}
+	if set("NodeID") && c.NodeID == 0 {
+		errs = append(errs, &ezconf.ValidationError{Field: "MyServiceConfig.NodeID", Reason: "required field is 0"})
+	}
+	return errors.Join(ezconf.Required("MyServiceConfig", missing), errors.Join(errs...))
+}
+
+// writeRedacted writes the fields of c for MyAppConfig.Redacted, with each path starting with prefix.
+func (c MyServiceConfig) writeRedacted(b *strings.Builder, prefix string) {
+	fmt.Fprintf(b, "%sName: %q\n", prefix, c.Name)
+	fmt.Fprintf(b, "%sDescription: %q\n", prefix, c.Description)
+	fmt.Fprintf(b, "%sNodeID: %d\n", prefix, c.NodeID)
+	fmt.Fprintf(b, "%sPriority: %d\n", prefix, c.Priority)
+	fmt.Fprintf(b, "%sLogFormat: %q\n", prefix, c.LogFormat)
+	fmt.Fprintf(b, "%sSecretKey: %s\n", prefix, redactSecret(c.SecretKey))
+	fmt.Fprintf(b, "%sSalt: %s\n", prefix, redactSecret(bytesSecret(c.Salt)))
+	fmt.Fprintf(b, "%sSessionKey: %s\n", prefix, redactSecret(bytesSecret(c.SessionKey)))
+	fmt.Fprintf(b, "%sPlugins: %q\n", prefix, c.Plugins)
+	writeRedactedJSON(b, prefix+"ServerConfig.", c.ServerConfig)
+}
+
+// MarshalJSON marshals c with Salt and SessionKey redacted like SecretKey, so that neither json.Marshal nor a JSON log
+// handler shows key material. Unmarshaling into MyServiceConfig is unaffected.
+func (c MyServiceConfig) MarshalJSON() ([]byte, error) {
+	type plain MyServiceConfig
+	return json.Marshal(struct {
+		plain
+		Salt       ezconf.Secret
+		SessionKey ezconf.Secret
+	}{plain(c), bytesSecret(c.Salt), bytesSecret(c.SessionKey)})
+}
+
+// Into writes every field set by a config source into c, leaving the rest as they were. On error c is unchanged.
+func (l *MyServiceConfigLoader) Into(c *MyServiceConfig) error {
+	return l.into(c, MyServiceConfigLoader{}, lookupMyAppConfigFlags(flag.CommandLine), DefaultMyAppConfigEnvPrefix,
+		ezconf.RetryPolicy{})
+}
+
+func (l *MyServiceConfigLoader) into(c *MyServiceConfig, base MyServiceConfigLoader, flags myAppConfigFlags,
+	prefix string, retry ezconf.RetryPolicy) error {
+	tmp := *c
+	env, err := myServiceConfigEnv(os.Getenv, base, prefix)
+	if err != nil {
+		return err
+	}
+
+	name := optional.Or(l.Name, env.Name)
+	description := optional.Or(l.Description, env.Description)
+	nodeID := optional.Or(l.NodeID, optional.Or(flags.myServiceNode, env.NodeID))
+	priority := optional.Or(l.Priority, env.Priority)
+	logFormat := optional.Or(l.LogFormat, optional.Or(flags.myServiceLogFormat.Str, env.LogFormat))
+	salt := l.Salt.Or(flags.myServiceSalt.Or(env.Salt))
+	sessionKey := l.SessionKey.Or(env.SessionKey)
+	secretKeyFile := l.SecretKey
+	if secretKeyFile.IsNone() {
+		secretKeyFile = env.SecretKey
+	}
+	if l.SecretKey.IsNone() && flags.myServiceSecretKey.IsSome() {
+		secretKeyFile = file.NoSecretFile()
+		tmp.SecretKey.Secret = flags.myServiceSecretKey.Secret
+	}
+
+	if secretKeyFile.IsSome() {
+		tmp.SecretKey.Secret, err = readSecretFile(context.Background(), retry, secretKeyFile, "MyServiceConfig.SecretKey")
+		if err != nil {
+			return err
+		}
+	}
+	plugins := optional.Or(l.Plugins, env.Plugins)
+	if plugins.IsSome() {
+		tmp.Plugins, err = expandGlob(plugins, "MyServiceConfig.Plugins")
+		if err != nil {
+			return err
+		}
+	}
+
+	serverConfig, err := l.ServerConfig.ResolveDisableTls(context.Background(), myServiceConfigNoTls(env, flags))
+	if err != nil {
+		return err
+	}
+
+	// Required fields are missing if no source sets them and the caller left them at their zero value in c, and invalid
+	// if a source sets their zero value. They are reported along with every invalid value so that all of the problems
+	// can be fixed at once.
+	set := map[string]bool{"Name": name.IsSome() || tmp.Name != "", "NodeID": nodeID.IsSome() || tmp.NodeID != 0}
+	tmp.Name = optional.GetOr(name, tmp.Name)
+	tmp.NodeID = optional.GetOr(nodeID, tmp.NodeID)
+	requiredErr := tmp.required(func(field string) bool { return set[field] })
+
+	tmp.Description = optional.GetOr(description, tmp.Description)
+	tmp.Priority = optional.GetOr(priority, tmp.Priority)
+	var logFormatErr error
+	value, ok := logFormat.Get()
+	if ok {
+		tmp.LogFormat, logFormatErr = myServiceConfigLogFormat(value)
+	}
+	err = errors.Join(requiredErr, logFormatErr)
+	if err != nil {
+		return err
+	}
+	tmp.Salt = salt.GetOr(tmp.Salt)
+	tmp.SessionKey = sessionKey.GetOr(tmp.SessionKey)
+	tmp.ServerConfig = serverConfig
+
+	*c = tmp
+	return nil
+}
+
+func (l *MyServiceConfigLoader) Previous() MyServiceConfig {
+	return l.previous.Load()
+}
+
+// Loader for MyDBConfig type
+type MyDBConfigLoader struct {
+	Address  optional.Str        `env:"MY_DB_ADDRESS" deprecated:"Host"`
+	Port     optional.Uint16     `env:"MY_DB_PORT"`
+	SSLMode  optional.Str        // Read from the env var named by ezconf.EnvName, MY_DB_SSL_MODE.
+	Replicas ezconf.List[string] `env:"MY_DB_REPLICAS"`
+	Params   ezconf.Map[string]  `env:"MY_DB_PARAMS"`
+	// QueryTimeout is parsed with time.ParseDuration from every source, so it needs a unit, e.g. 1500ms or 1h30m. Bare
+	// numbers such as 30 are rejected rather than read as nanoseconds. Config files must give it as a string.
+	QueryTimeout optional.Duration `env:"MY_DB_QUERY_TIMEOUT"`
+	// ConnectTimeout and MaxMessageSize are kept as given until every source is merged, since bare numbers are read in
+	// the unit from their defaultUnit tag, seconds and MiB, which the zero value a config file is decoded into lacks.
+	ConnectTimeout ezconf.UnitValue `env:"MY_DB_CONNECT_TIMEOUT"`
+	MaxMessageSize ezconf.UnitValue `env:"MY_DB_MAX_MESSAGE_SIZE"`
+	Pooling        optional.Bool    `env:"MY_DB_POOLING"`
+	// Password falls back to the secret named by DefaultMyDBConfigPasswordSecret when no other source sets it.
+	Password optional.Secret `env:"MY_DB_PASSWORD"`
+	previous ezconf.Snapshot[MyDBConfig]
+}
+
+func (l *MyDBConfigLoader) Update() (MyDBConfig, error) {
+	c, err := l.Resolve()
+	if err != nil {
+		return c, err
+	}
+
+	l.previous.Store(c)
+	return c, nil
+}
+
+// myDBConfigEnv reads the env vars for MyDBConfig with getenv on top of base, which holds the values from the config
+// file. Every env var name starts with prefix.
+func myDBConfigEnv(getenv func(string) string, base MyDBConfigLoader, prefix string) (env MyDBConfigLoader, err error) {
+	env = base
+	err = errors.Join(
+		ezconf.LoadEnvFrom(getenv, &env.Address, prefix+"MY_DB_ADDRESS"),
+		ezconf.LoadDeprecatedEnvFrom(getenv, &env.Address, prefix+"MY_DB_HOST", prefix+"MY_DB_ADDRESS"),
+		ezconf.LoadEnvFrom(getenv, &env.Port, prefix+"MY_DB_PORT"),
+		ezconf.LoadEnvFrom(getenv, &env.SSLMode, prefix+ezconf.EnvName("MyDB.SSLMode")),
+		ezconf.LoadEnvFrom(getenv, &env.Replicas, prefix+"MY_DB_REPLICAS"),
+		ezconf.LoadEnvFrom(getenv, &env.Params, prefix+"MY_DB_PARAMS"),
+		ezconf.LoadEnvFrom(getenv, &env.QueryTimeout, prefix+"MY_DB_QUERY_TIMEOUT"),
+		ezconf.LoadEnvFrom(getenv, &env.ConnectTimeout, prefix+"MY_DB_CONNECT_TIMEOUT"),
+		ezconf.LoadEnvFrom(getenv, &env.MaxMessageSize, prefix+"MY_DB_MAX_MESSAGE_SIZE"),
+		ezconf.LoadEnvFrom(getenv, &env.Pooling, prefix+"MY_DB_POOLING"),
+		ezconf.LoadEnvFrom(getenv, &env.Password, prefix+"MY_DB_PASSWORD"),
+	)
+	return
+}
+
+// myDBConfigOverlay returns base with every field set in over, which was read from a later config file, on top. Params
+// is merged key by key.
+func myDBConfigOverlay(base, over MyDBConfigLoader) MyDBConfigLoader {
+	base.Address = optional.Or(over.Address, base.Address)
+	base.Port = optional.Or(over.Port, base.Port)
+	base.SSLMode = optional.Or(over.SSLMode, base.SSLMode)
+	base.Replicas = over.Replicas.Or(base.Replicas)
+	base.Params = over.Params.Merge(base.Params)
+	base.QueryTimeout = optional.Or(over.QueryTimeout, base.QueryTimeout)
+	base.ConnectTimeout = optional.Or(over.ConnectTimeout, base.ConnectTimeout)
+	base.MaxMessageSize = optional.Or(over.MaxMessageSize, base.MaxMessageSize)
+	base.Pooling = optional.Or(over.Pooling, base.Pooling)
+	base.Password = optional.Or(over.Password, base.Password)
+	return base
+}
+
+func (l *MyDBConfigLoader) Resolve() (MyDBConfig, error) {
+	return l.resolve(MyDBConfigLoader{}, lookupMyAppConfigFlags(flag.CommandLine), DefaultMyAppConfigEnvPrefix)
+}
+
+func (l *MyDBConfigLoader) resolve(base MyDBConfigLoader, flags myAppConfigFlags, prefix string) (c MyDBConfig,
+	err error) {
+	env, err := myDBConfigEnv(os.Getenv, base, prefix)
+	if err != nil {
+		return c, err
+	}
+
+	// Flags are read from the FlagSet given to RegisterMyAppConfigFlags. Values set on the loader itself are the
+	// programmatic layer and override flags, which in turn override env vars and then the config file.
+	address := optional.Or(l.Address, optional.Or(flags.myDBAddress, env.Address))
+	port := optional.Or(l.Port, optional.Or(flags.myDBPort, env.Port))
+	sslMode := optional.Or(l.SSLMode, env.SSLMode)
+	replicas := l.Replicas.Or(flags.myDBReplicas.Or(env.Replicas))
+	// Maps are merged key by key across all sources instead.
+	params := l.Params.Merge(flags.myDBParams.Merge(env.Params))
+	queryTimeout := optional.Or(l.QueryTimeout, optional.Or(flags.myDBQueryTimeout, env.QueryTimeout))
+	pooling := optional.Or(l.Pooling, optional.Or(flags.myDBPooling, env.Pooling))
+	connectTimeout, maxMessageSize, err := l.units(env, flags)
+	if err != nil {
+		return c, err
+	}
+	password, err := l.password(env)
+	if err != nil {
+		return c, err
+	}
+	adminPassword, err := adminPassword()
+	if err != nil {
+		return c, err
+	}
+
+	var newConfig MyDBConfig
+	newConfig.Address = optional.GetOr(address, DefaultMyDBConfigAddress)
+	newConfig.Port = optional.GetOr(port, DefaultMyDBConfigPort)
+	newConfig.SSLMode = optional.GetOr(sslMode, DefaultMyDBConfigSSLMode)
+	newConfig.Replicas = replicas.GetOr(nil)
+	newConfig.Params = params.GetOr(nil)
+	newConfig.QueryTimeout = optional.GetOr(queryTimeout, DefaultMyDBConfigQueryTimeout)
+	newConfig.ConnectTimeout = optional.GetOr(connectTimeout, DefaultMyDBConfigConnectTimeout)
+	newConfig.MaxMessageSize = optional.GetOr(maxMessageSize, DefaultMyDBConfigMaxMessageSize)
+	newConfig.Pooling = optional.GetOr(pooling, DefaultMyDBConfigPooling)
+	newConfig.Password.Secret = password
+	newConfig.AdminPassword = adminPassword
+
+	err = newConfig.validate()
+	if err != nil {
+		return c, err
+	}
+	return newConfig, nil
+}
+
+// units reads ConnectTimeout and MaxMessageSize in the unit from their defaultUnit tag once the sources are merged.
+func (l *MyDBConfigLoader) units(env MyDBConfigLoader, flags myAppConfigFlags) (optional.Duration, optional.Uint64,
+	error) {
+	connectTimeout, err := optional.Or(l.ConnectTimeout, optional.Or(flags.myDBConnectTimeout,
+		env.ConnectTimeout)).Duration("MyDBConfig.ConnectTimeout", "s")
+	if err != nil {
+		return connectTimeout, optional.NoUint64(), err
+	}
+	maxMessageSize, err := optional.Or(l.MaxMessageSize, env.MaxMessageSize).Size("MyDBConfig.MaxMessageSize", "MiB")
+	return connectTimeout, maxMessageSize, err
+}
+
+// password returns Password from the loader, env vars, or config file, and fetches it from the secret provider named by
+// DefaultMyDBConfigPasswordSecret otherwise.
+func (l *MyDBConfigLoader) password(env MyDBConfigLoader) (optional.Secret, error) {
+	password := optional.Or(l.Password, env.Password)
+	if password.IsSome() {
+		return password, nil
+	}
+
+	err := ezconf.LoadSecret(&password, DefaultMyDBConfigPasswordSecret)
+	return password, err
+}
+
+// adminPassword returns the lazy AdminPassword once its secret provider has checked that the secret named by
+// DefaultMyDBConfigAdminPasswordSecret can be reached, without fetching it. Every resolve returns the same LazySecret,
+// so the secret is fetched at most once however often the config is updated.
+func adminPassword() (ezconf.LazySecret, error) {
+	secret := ezconf.LazySecretRef(DefaultMyDBConfigAdminPasswordSecret)
+	err := secret.Check()
+	if err != nil {
+		return secret, fmt.Errorf("failed to load MyDBConfig.AdminPassword: %w", err)
+	}
+	return secret, nil
+}
+
+// validate applies the validate tags of MyDBConfig to c once all sources have been merged.
+func (c MyDBConfig) validate() error {
+	return c.validateFields(func(string) bool { return true })
+}
+
+// validateFields applies the validate tags of the fields of MyDBConfig for which check returns true to c. It holds
+// every validate rule of MyDBConfig, so that validate, Into, and the Get accessors check the same ones.
+func (c MyDBConfig) validateFields(check func(field string) bool) error {
+	var errs []error
+	if check("Address") {
+		errs = append(errs, ezconf.Validate("MyDBConfig.Address", c.Address, "nonempty"))
+	}
+	if check("Port") {
+		errs = append(errs, ezconf.Validate("MyDBConfig.Port", c.Port, "min=1024,max=49151"))
+	}
+	if check("SSLMode") {
+		errs = append(errs, ezconf.Validate("MyDBConfig.SSLMode", c.SSLMode, "oneof=disable prefer require verify-full"))
+	}
+	return errors.Join(errs...)
+}
+
+// validateField applies the validate tags of the single field of MyDBConfig named field to c, for the Get accessors.
+func (c MyDBConfig) validateField(field string) error {
+	return c.validateFields(func(f string) bool { return f == field })
+}
+
+// writeRedacted writes the fields of c for MyAppConfig.Redacted, with each path starting with prefix.
+func (c MyDBConfig) writeRedacted(b *strings.Builder, prefix string) {
+	fmt.Fprintf(b, "%sAddress: %q\n", prefix, c.Address)
+	fmt.Fprintf(b, "%sPort: %d\n", prefix, c.Port)
+	fmt.Fprintf(b, "%sSSLMode: %q\n", prefix, c.SSLMode)
+	fmt.Fprintf(b, "%sReplicas: %q\n", prefix, c.Replicas)
+	fmt.Fprintf(b, "%sParams: %q\n", prefix, c.Params)
+	fmt.Fprintf(b, "%sQueryTimeout: %s\n", prefix, c.QueryTimeout)
+	fmt.Fprintf(b, "%sConnectTimeout: %s\n", prefix, c.ConnectTimeout)
+	fmt.Fprintf(b, "%sMaxMessageSize: %d\n", prefix, c.MaxMessageSize)
+	fmt.Fprintf(b, "%sPooling: %t\n", prefix, c.Pooling)
+	fmt.Fprintf(b, "%sPassword: %s\n", prefix, redactSecret(c.Password))
+	fmt.Fprintf(b, "%sAdminPassword: %s\n", prefix, c.AdminPassword)
+}
+
+// Into writes every field set by a config source into c, leaving the rest as they were.
+func (l *MyDBConfigLoader) Into(c *MyDBConfig) error {
+	return l.into(c, MyDBConfigLoader{}, lookupMyAppConfigFlags(flag.CommandLine), DefaultMyAppConfigEnvPrefix)
+}
+
+func (l *MyDBConfigLoader) into(c *MyDBConfig, base MyDBConfigLoader, flags myAppConfigFlags, prefix string) error {
+	env, err := myDBConfigEnv(os.Getenv, base, prefix)
+	if err != nil {
+		return err
+	}
+
+	address := optional.Or(l.Address, optional.Or(flags.myDBAddress, env.Address))
+	port := optional.Or(l.Port, optional.Or(flags.myDBPort, env.Port))
+	sslMode := optional.Or(l.SSLMode, env.SSLMode)
+	replicas := l.Replicas.Or(flags.myDBReplicas.Or(env.Replicas))
+	params := l.Params.Merge(flags.myDBParams.Merge(env.Params))
+	queryTimeout := optional.Or(l.QueryTimeout, optional.Or(flags.myDBQueryTimeout, env.QueryTimeout))
+	pooling := optional.Or(l.Pooling, optional.Or(flags.myDBPooling, env.Pooling))
+	connectTimeout, maxMessageSize, err := l.units(env, flags)
+	if err != nil {
+		return err
+	}
+	password, err := l.password(env)
+	if err != nil {
+		return err
+	}
+	adminPassword, err := adminPassword()
+	if err != nil {
+		return err
+	}
+
+	tmp := *c
+	tmp.Address = optional.GetOr(address, tmp.Address)
+	tmp.Port = optional.GetOr(port, tmp.Port)
+	tmp.SSLMode = optional.GetOr(sslMode, tmp.SSLMode)
+	tmp.Replicas = replicas.GetOr(tmp.Replicas)
+	tmp.Params = params.GetOr(tmp.Params)
+	tmp.QueryTimeout = optional.GetOr(queryTimeout, tmp.QueryTimeout)
+	tmp.ConnectTimeout = optional.GetOr(connectTimeout, tmp.ConnectTimeout)
+	tmp.MaxMessageSize = optional.GetOr(maxMessageSize, tmp.MaxMessageSize)
+	tmp.Pooling = optional.GetOr(pooling, tmp.Pooling)
+	if password.IsSome() {
+		tmp.Password.Secret = password
+	}
+	tmp.AdminPassword = adminPassword
+
+	// Only values set by a config source are validated, since the rest are whatever the caller put there.
+	set := map[string]bool{"Address": address.IsSome(), "Port": port.IsSome(), "SSLMode": sslMode.IsSome()}
+	err = tmp.validateFields(func(field string) bool { return set[field] })
+	if err != nil {
+		return err
+	}
+
+	*c = tmp
+	return nil
+}
+
+func (l *MyDBConfigLoader) Previous() MyDBConfig {
+	return l.previous.Load()
+}
+
+// Loader for BackendConfig type. Env vars for elements of MyAppConfig.Backends are read by MyAppConfigLoader, so a
+// standalone BackendConfigLoader only uses the values set on it and the defaults.
+type BackendConfigLoader struct {
+	Address  optional.Str
+	Port     optional.Uint16
+	Weight   optional.Uint16
+	previous ezconf.Snapshot[BackendConfig]
+}
+
+func (l *BackendConfigLoader) Update() (BackendConfig, error) {
+	c, err := l.Resolve()
+	if err != nil {
+		return c, err
+	}
+
+	l.previous.Store(c)
+	return c, nil
+}
+
+// backendConfigEnv reads the env vars for a BackendConfig element with getenv on top of base, which holds the values
+// from the config file. The env var names start with prefix, e.g. MY_APP_BACKENDS_0_.
+func backendConfigEnv(getenv func(string) string, base BackendConfigLoader, prefix string) (env BackendConfigLoader,
+	err error) {
+	env = base
+	err = errors.Join(
+		ezconf.LoadEnvFrom(getenv, &env.Address, prefix+"ADDRESS"),
+		ezconf.LoadEnvFrom(getenv, &env.Port, prefix+"PORT"),
+		ezconf.LoadEnvFrom(getenv, &env.Weight, prefix+"WEIGHT"),
+	)
+	return
+}
+
+// backendConfigOverlay returns base with every field set in over, which was read from a later config file, on top.
+func backendConfigOverlay(base, over BackendConfigLoader) BackendConfigLoader {
+	base.Address = optional.Or(over.Address, base.Address)
+	base.Port = optional.Or(over.Port, base.Port)
+	base.Weight = optional.Or(over.Weight, base.Weight)
+	return base
+}
+
+func (l *BackendConfigLoader) Resolve() (BackendConfig, error) {
+	return l.resolve(BackendConfigLoader{}), nil
+}
+
+// resolve applies the values set on the loader over env, which holds the config file and env var layers.
+func (l *BackendConfigLoader) resolve(env BackendConfigLoader) BackendConfig {
+	address := optional.Or(l.Address, env.Address)
+	port := optional.Or(l.Port, env.Port)
+	weight := optional.Or(l.Weight, env.Weight)
+
+	var newConfig BackendConfig
+	newConfig.Address = optional.GetOr(address, DefaultBackendConfigAddress)
+	newConfig.Port = optional.GetOr(port, DefaultBackendConfigPort)
+	newConfig.Weight = optional.GetOr(weight, DefaultBackendConfigWeight)
+	return newConfig
+}
+
+// Into writes every field set on the loader into c, leaving the rest as they were.
+func (l *BackendConfigLoader) Into(c *BackendConfig) error {
+	l.into(c, BackendConfigLoader{})
+	return nil
+}
+
+func (l *BackendConfigLoader) into(c *BackendConfig, env BackendConfigLoader) {
+	c.Address = optional.GetOr(optional.Or(l.Address, env.Address), c.Address)
+	c.Port = optional.GetOr(optional.Or(l.Port, env.Port), c.Port)
+	c.Weight = optional.GetOr(optional.Or(l.Weight, env.Weight), c.Weight)
+}
+
+// writeRedacted writes the fields of c for MyAppConfig.Redacted, with each path starting with prefix.
+func (c BackendConfig) writeRedacted(b *strings.Builder, prefix string) {
+	fmt.Fprintf(b, "%sAddress: %q\n", prefix, c.Address)
+	fmt.Fprintf(b, "%sPort: %d\n", prefix, c.Port)
+	fmt.Fprintf(b, "%sWeight: %d\n", prefix, c.Weight)
+}
+
+func (l *BackendConfigLoader) Previous() BackendConfig {
+	return l.previous.Load()
+}
@@ -0,0 +1,3080 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/ezconf/file"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+var (
+	_ ezconf.Loader[MyAppConfig]        = (*MyAppConfigLoader)(nil)
+	_ ezconf.ContextLoader[MyAppConfig] = (*MyAppConfigLoader)(nil)
+	_ ezconf.Loader[MyServiceConfig]    = (*MyServiceConfigLoader)(nil)
+	_ ezconf.Loader[MyDBConfig]         = (*MyDBConfigLoader)(nil)
+	_ ezconf.Loader[BackendConfig]      = (*BackendConfigLoader)(nil)
+)
+
+// noTls is a stand-in TLS loader for tests which do not care about TLS.
+type noTls struct{}
+
+func (noTls) Resolve() (*tls.Config, error) { return nil, nil }
+func (noTls) Update() (*tls.Config, error)  { return nil, nil }
+func (noTls) Previous() *tls.Config         { return nil }
+
+// badTls is a TLS loader which always fails.
+type badTls struct{}
+
+var errBadTls = errors.New("bad tls")
+
+func (badTls) Resolve() (*tls.Config, error) { return nil, errBadTls }
+func (badTls) Update() (*tls.Config, error)  { return nil, errBadTls }
+func (badTls) Previous() *tls.Config         { return nil }
+
+// testLoader returns a MyAppConfigLoader with every required field set and the secret key in a temporary file.
+func testLoader(t *testing.T) *MyAppConfigLoader {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secretkey.txt")
+	assert.NilError(t, os.WriteFile(path, []byte("hunter2"), 0600))
+
+	l := &MyAppConfigLoader{}
+	l.MyService.Name = optional.SomeStr("test")
+	l.MyService.NodeID = optional.SomeUint32(1)
+	l.MyService.SecretKey = file.SomeSecretFile(path)
+	l.MyService.ServerConfig.Tls = noTls{}
+	return l
+}
+
+// testFlags returns a new FlagSet with the MyAppConfig flags registered and args parsed.
+func testFlags(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterMyAppConfigFlags(fs)
+	assert.NilError(t, fs.Parse(args))
+	return fs
+}
+
+func TestRegisterMyAppConfigFlags(t *testing.T) {
+	// The global flags may be set up any number of times.
+	SetupMyAppConfigFlags()
+	SetupMyAppConfigFlags()
+
+	a := testFlags(t, "-myDBPort", "9001")
+	b := testFlags(t, "-myDBPort", "9002", "-myDBAddress", "db.b.internal")
+
+	tests := []struct {
+		name        string
+		flags       *flag.FlagSet
+		wantPort    uint16
+		wantAddress string
+	}{
+		{name: "a", flags: a, wantPort: 9001, wantAddress: DefaultMyDBConfigAddress},
+		{name: "b", flags: b, wantPort: 9002, wantAddress: "db.b.internal"},
+		{
+			name: "none", flags: flag.NewFlagSet("empty", flag.ContinueOnError), wantPort: DefaultMyDBConfigPort,
+			wantAddress: DefaultMyDBConfigAddress,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := testLoader(t)
+			l.Flags = tc.flags
+
+			c, err := l.Update()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantPort, c.MyDB.Port)
+			assert.Equal(t, tc.wantAddress, c.MyDB.Address)
+		})
+	}
+}
+
+func TestMyDBConfigLoaderPrecedence(t *testing.T) {
+	tests := []struct {
+		name   string
+		manual optional.Uint16
+		flags  []string
+		env    string
+		want   uint16
+	}{
+		{name: "default", want: DefaultMyDBConfigPort},
+		{name: "empty env is unset", env: "", want: DefaultMyDBConfigPort},
+		{name: "env over default", env: "9002", want: 9002},
+		{name: "flag over env", flags: []string{"-myDBPort", "9001"}, env: "9002", want: 9001},
+		{
+			name: "programmatic over flag", manual: optional.SomeUint16(9000), flags: []string{"-myDBPort", "9001"}, env: "9002",
+			want: 9000,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("MY_APP_MY_DB_PORT", tc.env)
+			l := testLoader(t)
+			l.Flags = testFlags(t, tc.flags...)
+			l.MyDB.Port = tc.manual
+
+			c, err := l.Update()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, c.MyDB.Port)
+
+			// Resolving must not write flag values back into the programmatic layer.
+			assert.Equal(t, tc.manual, l.MyDB.Port)
+		})
+	}
+}
+
+func TestMyAppConfigLoaderEnv(t *testing.T) {
+	l := testLoader(t)
+	l.MyService.Name.Clear()
+	t.Setenv("MY_APP_MY_SERVICE_NAME", "from-env")
+	t.Setenv("MY_APP_MY_SERVICE_PRIORITY", "7")
+	t.Setenv("MY_APP_MY_DB_ADDRESS", "db.example.com")
+
+	c, err := l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, "from-env", c.MyService.Name)
+	assert.Equal(t, uint16(7), c.MyService.Priority)
+	assert.Equal(t, "db.example.com", c.MyDB.Address)
+
+	t.Setenv("MY_APP_MY_DB_PORT", "not-a-port")
+	_, err = l.Update()
+	assert.ErrorContains(t, err, "failed to load env var MY_APP_MY_DB_PORT")
+}
+
+func TestMyServiceConfigLoaderNoTls(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        string
+		loader     optional.Bool
+		wantRemote string
+	}{
+		{name: "unset", wantRemote: "https://127.0.0.1"},
+		{name: "env", env: "true", wantRemote: "http://127.0.0.1"},
+		{name: "env false", env: "false", wantRemote: "https://127.0.0.1"},
+		{name: "loader over env", env: "true", loader: optional.SomeBool(false), wantRemote: "https://127.0.0.1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := testLoader(t)
+			l.MyService.ServerConfig.DisableTls = tc.loader
+			if tc.env != "" {
+				t.Setenv("MY_APP_MY_SERVICE_NO_TLS", tc.env)
+			}
+
+			c, err := l.Update()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantRemote, c.MyService.ServerConfig.RemoteAddress)
+			// The env var is not written back into the loader.
+			assert.Equal(t, tc.loader, l.MyService.ServerConfig.DisableTls)
+		})
+	}
+}
+
+func TestMyAppConfigFlagCompletions(t *testing.T) {
+	completions := ezconf.FlagCompletions(testFlags(t))
+	tests := []struct {
+		flag string
+		want ezconf.Completion
+	}{
+		{flag: "config", want: ezconf.Completion{Kind: ezconf.CompleteFile}},
+		{flag: "configDir", want: ezconf.Completion{Kind: ezconf.CompleteDirectory}},
+		{
+			flag: "myServiceLogFormat",
+			want: ezconf.Completion{Kind: ezconf.CompleteEnum, Values: []string{"json", "text", "logfmt"}},
+		},
+		{flag: "myDBPort", want: ezconf.Completion{Kind: ezconf.CompleteFree}},
+		{flag: "no-myDBPooling", want: ezconf.Completion{Kind: ezconf.CompleteNone}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.flag, func(t *testing.T) {
+			assert.DeepEqual(t, tc.want, completions[tc.flag])
+		})
+	}
+}
+
+func TestMyServiceConfigLoaderLogFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   []string
+		env     string
+		file    string
+		want    string
+		wantErr string
+	}{
+		{name: "default", want: DefaultMyServiceConfigLogFormat},
+		{name: "env", env: "json", want: "json"},
+		{name: "any case", env: "LogFmt", want: "logfmt"},
+		{name: "file", file: "JSON", want: "json"},
+		{name: "flag over env", flags: []string{"-myServiceLogFormat", "TEXT"}, env: "json", want: "text"},
+		{
+			name: "not allowed", env: "xml",
+			wantErr: `invalid MyServiceConfig.LogFormat: "xml" must be one of [json, text, logfmt]`,
+		},
+		{
+			name: "not allowed in file", file: "yaml",
+			wantErr: `invalid MyServiceConfig.LogFormat: "yaml" must be one of [json, text, logfmt]`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("MY_APP_MY_SERVICE_LOG_FORMAT", tc.env)
+			l := testLoader(t)
+			l.Flags = testFlags(t, tc.flags...)
+			if tc.file != "" {
+				path := filepath.Join(t.TempDir(), "myapp.toml")
+				assert.NilError(t, os.WriteFile(path, []byte(fmt.Sprintf("[MyService]\nLogFormat = %q\n", tc.file)), 0600))
+				l.ConfigFile = file.SomeFile(path)
+			}
+
+			c, err := l.Update()
+			_, getErr := l.GetMyServiceLogFormat()
+			into := DefaultMyAppConfig()
+			intoErr := l.Into(&into)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+				assert.Error(t, getErr, tc.wantErr)
+				assert.Error(t, intoErr, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, c.MyService.LogFormat)
+			assert.NilError(t, intoErr)
+			assert.Equal(t, tc.want, into.MyService.LogFormat)
+			logFormat, err := l.GetMyServiceLogFormat()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, logFormat)
+		})
+	}
+
+	// The flag rejects other values while parsing, and its help text lists the allowed ones.
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	RegisterMyAppConfigFlags(fs)
+	err := fs.Parse([]string{"-myServiceLogFormat", "xml"})
+	assert.ErrorContains(t, err, `must be one of [json, text, logfmt]`)
+	assert.Assert(t, strings.Contains(fs.Lookup("myServiceLogFormat").Usage, "one of json, text, or logfmt in any case"))
+}
+
+func TestMyDBConfigLoaderDefaultUnits(t *testing.T) {
+	tests := []struct {
+		name        string
+		flags       []string
+		env         string
+		file        string
+		wantTimeout time.Duration
+		wantSize    uint64
+		wantErr     string
+	}{
+		{name: "defaults", wantTimeout: DefaultMyDBConfigConnectTimeout, wantSize: DefaultMyDBConfigMaxMessageSize},
+		{
+			name: "bare numbers in a file", file: "ConnectTimeout = 10\nMaxMessageSize = 4\n", wantTimeout: 10 * time.Second,
+			wantSize: 4 << 20,
+		},
+		{
+			name: "units in a file", file: "ConnectTimeout = \"1.5s\"\nMaxMessageSize = \"512KiB\"\n",
+			wantTimeout: 1500 * time.Millisecond, wantSize: 512 << 10,
+		},
+		{name: "env", env: "45", wantTimeout: 45 * time.Second, wantSize: DefaultMyDBConfigMaxMessageSize},
+		{
+			name: "flag over env", flags: []string{"-myDBConnectTimeout", "500ms"}, env: "45",
+			wantTimeout: 500 * time.Millisecond, wantSize: DefaultMyDBConfigMaxMessageSize,
+		},
+		{
+			name: "size in a duration", env: "30MB",
+			wantErr: `invalid MyDBConfig.ConnectTimeout: time: unknown unit "MB" in duration "30MB"`,
+		},
+		{
+			name: "bad size", file: "MaxMessageSize = \"16mb\"\n",
+			wantErr: `invalid MyDBConfig.MaxMessageSize: invalid size "16mb"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("MY_APP_MY_DB_CONNECT_TIMEOUT", tc.env)
+			l := testLoader(t)
+			l.Flags = testFlags(t, tc.flags...)
+			if tc.file != "" {
+				path := filepath.Join(t.TempDir(), "myapp.toml")
+				assert.NilError(t, os.WriteFile(path, []byte("[MyDB]\n"+tc.file), 0600))
+				l.ConfigFile = file.SomeFile(path)
+			}
+
+			c, err := l.Update()
+			_, getErr := l.GetMyDBConnectTimeout()
+			_, getSizeErr := l.GetMyDBMaxMessageSize()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				assert.ErrorContains(t, errors.Join(getErr, getSizeErr), tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantTimeout, c.MyDB.ConnectTimeout)
+			assert.Equal(t, tc.wantSize, c.MyDB.MaxMessageSize)
+			timeout, err := l.GetMyDBConnectTimeout()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantTimeout, timeout)
+			size, err := l.GetMyDBMaxMessageSize()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantSize, size)
+
+			// Saved values keep their unit, so that they load back the same.
+			saved := filepath.Join(t.TempDir(), "saved.toml")
+			assert.NilError(t, l.Save(saved))
+			t.Setenv("MY_APP_MY_DB_CONNECT_TIMEOUT", "")
+			reloaded := testLoader(t)
+			reloaded.ConfigFile = file.SomeFile(saved)
+			reloaded.MyService.SecretKey = l.MyService.SecretKey
+			c, err = reloaded.Update()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantTimeout, c.MyDB.ConnectTimeout)
+			assert.Equal(t, tc.wantSize, c.MyDB.MaxMessageSize)
+		})
+	}
+}
+
+func TestMyAppConfigLoaderGetField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	assert.NilError(t, os.WriteFile(path, []byte("[MyDB]\nSSLMode = \"require\"\nReplicas = [\"file-a\"]\n"), 0600))
+
+	// No required field is set anywhere and there is no default SecretKey file, so resolving the whole config fails.
+	flags := testFlags(t, "-myDBAddress", "db.flag.internal", "-no-myDBPooling")
+	l := &MyAppConfigLoader{ConfigFile: file.SomeFile(path), Flags: flags}
+	l.MyService.ServerConfig.Tls = noTls{}
+	_, err := l.Resolve()
+	assert.ErrorContains(t, err, "failed to read MyServiceConfig.SecretKey file")
+
+	t.Setenv("MY_APP_MY_DB_PORT", "6543")
+	t.Setenv("MY_APP_MY_SERVICE_PRIORITY", "not-a-number")
+	l.MyDB.QueryTimeout = optional.SomeDuration(time.Minute)
+
+	port, err := l.GetMyDBPort()
+	assert.NilError(t, err)
+	assert.Equal(t, uint16(6543), port)
+
+	address, err := l.GetMyDBAddress()
+	assert.NilError(t, err)
+	assert.Equal(t, "db.flag.internal", address)
+
+	sslMode, err := l.GetMyDBSSLMode()
+	assert.NilError(t, err)
+	assert.Equal(t, "require", sslMode)
+
+	replicas, err := l.GetMyDBReplicas()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"file-a"}, replicas)
+
+	timeout, err := l.GetMyDBQueryTimeout()
+	assert.NilError(t, err)
+	assert.Equal(t, time.Minute, timeout)
+
+	pooling, err := l.GetMyDBPooling()
+	assert.NilError(t, err)
+	assert.Equal(t, false, pooling)
+
+	description, err := l.GetMyServiceDescription()
+	assert.NilError(t, err)
+	assert.Equal(t, DefaultMyServiceConfigDescription, description)
+
+	// Only the env var of the field asked for is read.
+	_, err = l.GetMyServicePriority()
+	assert.ErrorContains(t, err, "failed to load env var MY_APP_MY_SERVICE_PRIORITY")
+}
+
+func TestMyAppConfigLoaderGetFieldErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		value   string
+		get     func(l *MyAppConfigLoader) error
+		wantErr string
+	}{
+		{
+			name:    "required name",
+			get:     func(l *MyAppConfigLoader) error { _, err := l.GetMyServiceName(); return err },
+			wantErr: "MyServiceConfig missing required field",
+		},
+		{
+			name:    "required node",
+			get:     func(l *MyAppConfigLoader) error { _, err := l.GetMyServiceNodeID(); return err },
+			wantErr: "MyServiceConfig missing required field",
+		},
+		{
+			name:    "validated port",
+			env:     "MY_APP_MY_DB_PORT",
+			value:   "80",
+			get:     func(l *MyAppConfigLoader) error { _, err := l.GetMyDBPort(); return err },
+			wantErr: "MyDBConfig.Port",
+		},
+		{
+			name:    "validated ssl mode",
+			env:     "MY_APP_MY_DB_SSL_MODE",
+			value:   "sometimes",
+			get:     func(l *MyAppConfigLoader) error { _, err := l.GetMyDBSSLMode(); return err },
+			wantErr: "MyDBConfig.SSLMode",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.env != "" {
+				t.Setenv(tc.env, tc.value)
+			}
+			l := &MyAppConfigLoader{Flags: testFlags(t)}
+			assert.ErrorContains(t, tc.get(l), tc.wantErr)
+		})
+	}
+}
+
+func TestMyServiceConfigLoaderBytes(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		flags          []string
+		file           string
+		wantSalt       []byte
+		wantSessionKey []byte
+		wantErr        string
+	}{
+		{name: "unset"},
+		{
+			name:           "env",
+			env:            map[string]string{"MY_APP_MY_SERVICE_SALT": "c2FsdA==", "MY_APP_MY_SERVICE_SESSION_KEY": "6b6579"},
+			wantSalt:       []byte("salt"),
+			wantSessionKey: []byte("key"),
+		},
+		{
+			name: "flag over env", env: map[string]string{"MY_APP_MY_SERVICE_SALT": "c2FsdA=="},
+			flags: []string{"-myServiceSalt", "ZmxhZw"}, wantSalt: []byte("flag"),
+		},
+		{
+			name: "file", file: "Salt = \"ZmlsZQ==\"\nSessionKey = \"66696c65\"", wantSalt: []byte("file"),
+			wantSessionKey: []byte("file"),
+		},
+		{
+			name: "env over file", env: map[string]string{"MY_APP_MY_SERVICE_SALT": "c2FsdA=="}, file: "Salt = \"ZmlsZQ==\"",
+			wantSalt: []byte("salt"),
+		},
+		{
+			name: "invalid env", env: map[string]string{"MY_APP_MY_SERVICE_SALT": "%%%"},
+			wantErr: "failed to load env var MY_APP_MY_SERVICE_SALT: invalid base64 value",
+		},
+		{
+			name: "invalid hex env", env: map[string]string{"MY_APP_MY_SERVICE_SESSION_KEY": "c2FsdA=="},
+			wantErr: "failed to load env var MY_APP_MY_SERVICE_SESSION_KEY: invalid hex value",
+		},
+		{name: "invalid file", file: "Salt = \"%%%\"", wantErr: "invalid base64 value"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			l := testLoader(t)
+			l.Flags = testFlags(t, tc.flags...)
+			if tc.file != "" {
+				path := filepath.Join(t.TempDir(), "myapp.toml")
+				assert.NilError(t, os.WriteFile(path, []byte("[MyService]\n"+tc.file+"\n"), 0600))
+				l.ConfigFile = file.SomeFile(path)
+			}
+
+			c, err := l.Update()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.wantSalt, c.MyService.Salt)
+			assert.DeepEqual(t, tc.wantSessionKey, c.MyService.SessionKey)
+		})
+	}
+}
+
+func TestMyServiceConfigLoaderBytesFlagError(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	RegisterMyAppConfigFlags(fs)
+
+	err := fs.Parse([]string{"-myServiceSalt", "%%%"})
+	assert.ErrorContains(t, err, "-myServiceSalt: invalid base64 value")
+}
+
+func TestMyAppConfigLoaderIgnoredField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	assert.NilError(t, os.WriteFile(path, []byte("[MyService]\nStartedAt = 2024-01-01T00:00:00Z\n"), 0600))
+	t.Setenv("MY_APP_MY_SERVICE_STARTED_AT", "2024-01-01T00:00:00Z")
+
+	l := testLoader(t)
+	l.ConfigFile = file.SomeFile(path)
+	c, err := l.Update()
+	assert.NilError(t, err)
+	assert.Assert(t, c.MyService.StartedAt.IsZero())
+
+	next := c
+	next.MyService.StartedAt = time.Now()
+	assert.Equal(t, 0, len(l.Diff(c, next)))
+
+	saved := filepath.Join(t.TempDir(), "saved.toml")
+	assert.NilError(t, l.Save(saved))
+	data, err := os.ReadFile(saved)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(data), "StartedAt"))
+
+	schema, err := MyAppConfigSchema()
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(schema), "StartedAt"))
+}
+
+func TestMyAppConfigLoaderRequired(t *testing.T) {
+	tests := []struct {
+		name  string
+		clear func(l *MyAppConfigLoader)
+		want  []string
+	}{
+		{name: "all set", clear: func(*MyAppConfigLoader) {}},
+		{name: "one missing", clear: func(l *MyAppConfigLoader) { l.MyService.Name.Clear() }, want: []string{"Name"}},
+		{
+			name: "several missing",
+			clear: func(l *MyAppConfigLoader) {
+				l.MyService.Name.Clear()
+				l.MyService.NodeID.Clear()
+			},
+			want: []string{"Name", "NodeID"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := testLoader(t)
+			tc.clear(l)
+
+			_, err := l.Resolve()
+			if tc.want == nil {
+				assert.NilError(t, err)
+				return
+			}
+
+			var missing *ezconf.MissingFieldsError
+			assert.Assert(t, errors.As(err, &missing))
+			assert.DeepEqual(t, tc.want, missing.Fields)
+
+			err = l.Into(&MyAppConfig{})
+			assert.Assert(t, errors.As(err, &missing))
+			assert.DeepEqual(t, tc.want, missing.Fields)
+
+			var field *ezconf.MissingRequiredError
+			assert.Assert(t, errors.As(err, &field))
+			assert.Equal(t, "MyServiceConfig."+tc.want[0], field.Field)
+		})
+	}
+
+	l := testLoader(t)
+	l.MyService.Name.Clear()
+	l.MyService.NodeID.Clear()
+	_, err := l.Resolve()
+	assert.ErrorContains(t, err, "MyServiceConfig missing required fields: Name, NodeID")
+
+	// A required field which a source set to its zero value is an invalid value rather than a missing one.
+	l = testLoader(t)
+	l.MyService.NodeID.Clear()
+	t.Setenv("MY_APP_MY_SERVICE_NODE", "0")
+	_, err = l.Resolve()
+	assert.ErrorContains(t, err, "invalid MyServiceConfig.NodeID: required field is 0")
+	assert.Assert(t, errors.Is(err, &ezconf.ValidationError{Field: "MyServiceConfig.NodeID"}))
+	assert.Assert(t, !errors.Is(err, &ezconf.MissingRequiredError{}))
+}
+
+func TestMyDBConfigLoaderValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		port    optional.Uint16
+		sslMode optional.Str
+		wantErr []string
+	}{
+		{name: "defaults are valid"},
+		{name: "valid values", port: optional.SomeUint16(5432), sslMode: optional.SomeStr("require")},
+		{
+			name: "port below min", port: optional.SomeUint16(80),
+			wantErr: []string{"invalid MyDBConfig.Port: value 80 is less than min 1024"},
+		},
+		{
+			name: "port above max", port: optional.SomeUint16(50000),
+			wantErr: []string{"invalid MyDBConfig.Port: value 50000 is greater than max 49151"},
+		},
+		{
+			name:    "ssl mode not oneof",
+			sslMode: optional.SomeStr("sometimes"),
+			wantErr: []string{`invalid MyDBConfig.SSLMode: "sometimes" must be one of [disable, prefer, require, verify-full]`},
+		},
+		{
+			name:    "every field is reported",
+			port:    optional.SomeUint16(80),
+			sslMode: optional.SomeStr("sometimes"),
+			wantErr: []string{"MyDBConfig.Port", "MyDBConfig.SSLMode"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := MyDBConfigLoader{Port: tc.port, SSLMode: tc.sslMode}
+			_, err := l.Resolve()
+			intoErr := l.Into(&MyDBConfig{})
+			if tc.wantErr == nil {
+				assert.NilError(t, err)
+				assert.NilError(t, intoErr)
+				return
+			}
+
+			for _, want := range tc.wantErr {
+				assert.ErrorContains(t, err, want)
+				assert.ErrorContains(t, intoErr, want)
+			}
+			var invalid *ezconf.ValidationError
+			assert.Assert(t, errors.As(err, &invalid))
+			assert.Assert(t, strings.HasPrefix(invalid.Field, "MyDBConfig."))
+		})
+	}
+}
+
+func TestValidateMyAppConfig(t *testing.T) {
+	valid := func() MyAppConfig {
+		c := DefaultMyAppConfig()
+		c.MyService.Name = "hand-built"
+		c.MyService.NodeID = 7
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		edit    func(c *MyAppConfig)
+		wantErr []string
+	}{
+		{name: "all fields set", edit: func(*MyAppConfig) {}},
+		{
+			name: "missing name", edit: func(c *MyAppConfig) { c.MyService.Name = "" },
+			wantErr: []string{"MyServiceConfig missing required field: Name"},
+		},
+		{
+			name:    "missing required fields",
+			edit:    func(c *MyAppConfig) { c.MyService = MyServiceConfig{} },
+			wantErr: []string{"MyServiceConfig missing required fields: Name, NodeID", `invalid MyServiceConfig.LogFormat: ""`},
+		},
+		{
+			name:    "log format",
+			edit:    func(c *MyAppConfig) { c.MyService.LogFormat = "xml" },
+			wantErr: []string{`invalid MyServiceConfig.LogFormat: "xml" must be one of [json, text, logfmt]`},
+		},
+		{
+			name: "required and validate tags together",
+			edit: func(c *MyAppConfig) {
+				c.MyService.Name = ""
+				c.MyDB.Port = 80
+				c.MyDB.SSLMode = "sometimes"
+			},
+			wantErr: []string{
+				"MyServiceConfig missing required field: Name",
+				"invalid MyDBConfig.Port",
+				"invalid MyDBConfig.SSLMode",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := valid()
+			tc.edit(&c)
+
+			err := ValidateMyAppConfig(c)
+			if len(tc.wantErr) == 0 {
+				assert.NilError(t, err)
+				return
+			}
+			for _, want := range tc.wantErr {
+				assert.ErrorContains(t, err, want)
+			}
+		})
+	}
+}
+
+func TestMyAppConfigLoaderValidatesComputedFields(t *testing.T) {
+	l := testLoader(t)
+	good, err := l.Update()
+	assert.NilError(t, err)
+
+	l.Compute("MyDB.Port", func(c *MyAppConfig) error {
+		c.MyDB.Port = 80
+		return nil
+	})
+	_, err = l.Update()
+	assert.ErrorContains(t, err, "invalid MyDBConfig.Port")
+	assert.Assert(t, reflect.DeepEqual(good, l.Previous()))
+}
+
+func TestMyAppConfigLoaderConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	data := `
+[MyService]
+Name = "from-file"
+Description = "loaded from toml"
+node = 3
+
+[MyDB]
+Address = "db.internal"
+Port = 5432
+`
+	assert.NilError(t, os.WriteFile(path, []byte(data), 0600))
+
+	l := testLoader(t)
+	l.MyService.Name.Clear()
+	l.MyService.NodeID.Clear()
+	l.ConfigFile = file.SomeFile(path)
+	t.Setenv("MY_APP_MY_DB_PORT", "6543")
+
+	c, err := l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, "from-file", c.MyService.Name)
+	assert.Equal(t, "loaded from toml", c.MyService.Description)
+	assert.Equal(t, uint32(3), c.MyService.NodeID)
+	assert.Equal(t, "db.internal", c.MyDB.Address)
+	// env vars override the file
+	assert.Equal(t, uint16(6543), c.MyDB.Port)
+	assert.Equal(t, uint16(DefaultMyServiceConfigPriority), c.MyService.Priority)
+
+	l.ConfigFile = file.SomeFile(filepath.Join(t.TempDir(), "missing.toml"))
+	_, err = l.Update()
+	assert.ErrorContains(t, err, "failed to read config file")
+	var loadErr *ezconf.FileLoadError
+	assert.Assert(t, errors.As(err, &loadErr))
+	assert.Equal(t, l.ConfigFile.String(), loadErr.Path)
+}
+
+func TestMyAppConfigLoaderConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.toml")
+	prod := filepath.Join(dir, "prod.toml")
+	assert.NilError(t, os.WriteFile(base, []byte(`
+[MyService]
+Name = "from-base"
+Description = "base description"
+
+[MyDB]
+Address = "db.internal"
+Port = 5432
+Params = { sslcert = "base.pem", timeout = "5" }
+
+[[Backends]]
+Address = "10.0.0.1"
+Port = 9001
+`), 0600))
+	assert.NilError(t, os.WriteFile(prod, []byte(`
+[MyService]
+Name = "from-prod"
+
+[MyDB]
+Port = 6432
+Params = { timeout = "30" }
+
+[[Backends]]
+Weight = 5
+
+[[Backends]]
+Address = "10.0.0.2"
+`), 0600))
+
+	tests := []struct {
+		name  string
+		setup func(l *MyAppConfigLoader)
+	}{
+		{name: "loader", setup: func(l *MyAppConfigLoader) { l.ConfigFiles = file.SomeFiles(base, prod) }},
+		{name: "loader single then list", setup: func(l *MyAppConfigLoader) {
+			l.ConfigFile = file.SomeFile(base)
+			l.ConfigFiles = file.SomeFiles(prod)
+		}},
+		{name: "repeated flag", setup: func(l *MyAppConfigLoader) {
+			l.Flags = testFlags(t, "-config", base, "-config", prod)
+		}},
+		{name: "comma separated flag", setup: func(l *MyAppConfigLoader) {
+			l.Flags = testFlags(t, "-config", base+","+prod)
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := testLoader(t)
+			l.MyService.Name.Clear()
+			tc.setup(l)
+
+			c, err := l.Update()
+			assert.NilError(t, err)
+			// The later file wins for the fields it sets...
+			assert.Equal(t, "from-prod", c.MyService.Name)
+			assert.Equal(t, uint16(6432), c.MyDB.Port)
+			// ...without clobbering the rest of the nested struct.
+			assert.Equal(t, "base description", c.MyService.Description)
+			assert.Equal(t, "db.internal", c.MyDB.Address)
+			assert.DeepEqual(t, map[string]string{"sslcert": "base.pem", "timeout": "30"}, c.MyDB.Params)
+			assert.DeepEqual(t, []BackendConfig{
+				{Address: "10.0.0.1", Port: 9001, Weight: 5},
+				{Address: "10.0.0.2", Port: DefaultBackendConfigPort, Weight: DefaultBackendConfigWeight},
+			}, c.Backends)
+		})
+	}
+}
+
+func TestMyAppConfigLoaderConfigFormat(t *testing.T) {
+	toml := "[MyService]\nName = \"from-file\"\n\n[MyDB]\nPort = 5432\n"
+	tests := []struct {
+		name  string
+		data  string
+		setup func(l *MyAppConfigLoader)
+	}{
+		{name: "detected json", data: `{"MyService": {"Name": "from-file"}, "MyDB": {"Port": 5432}}`},
+		{name: "detected toml", data: toml},
+		{name: "detected yaml", data: "myservice:\n  name: from-file\nmydb:\n  port: 5432\n"},
+		{name: "loader format", data: toml, setup: func(l *MyAppConfigLoader) { l.ConfigFormat = optional.SomeStr("toml") }},
+		{
+			name: "flag format", data: toml,
+			setup: func(l *MyAppConfigLoader) { l.Flags = testFlags(t, "-configFormat", "toml") },
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config")
+			assert.NilError(t, os.WriteFile(path, []byte(tc.data), 0600))
+
+			l := testLoader(t)
+			l.MyService.Name.Clear()
+			l.ConfigFile = file.SomeFile(path)
+			if tc.setup != nil {
+				tc.setup(l)
+			}
+
+			c, err := l.Update()
+			assert.NilError(t, err)
+			assert.Equal(t, "from-file", c.MyService.Name)
+			assert.Equal(t, uint16(5432), c.MyDB.Port)
+		})
+	}
+
+	// The format applies regardless of the extension.
+	path := filepath.Join(t.TempDir(), "myapp.json")
+	assert.NilError(t, os.WriteFile(path, []byte(toml), 0600))
+	l := testLoader(t)
+	l.ConfigFile = file.SomeFile(path)
+	_, err := l.Update()
+	assert.ErrorContains(t, err, "failed to decode config file")
+
+	l.Flags = testFlags(t, "-configFormat", "toml")
+	_, err = l.Update()
+	assert.NilError(t, err)
+
+	l.ConfigFormat = optional.SomeStr("ini")
+	_, err = l.Update()
+	assert.ErrorContains(t, err, `unsupported config file format "ini"`)
+}
+
+func TestMyAppConfigLoaderStrictConfig(t *testing.T) {
+	data := "[MyService]\nName = \"from-file\"\nNmae = \"typo\"\n\n[MyDB]\nPort = 5432\n\n" +
+		"[[Backends]]\nAddress = \"b0\"\nWieght = 2\n"
+	tests := []struct {
+		name    string
+		setup   func(l *MyAppConfigLoader)
+		wantErr string
+	}{
+		{name: "lenient"},
+		{
+			name: "loader strict", setup: func(l *MyAppConfigLoader) { l.StrictConfig = optional.SomeBool(true) },
+			wantErr: "unknown config file fields Backends[0].Wieght, MyService.Nmae",
+		},
+		{
+			name: "flag strict", setup: func(l *MyAppConfigLoader) { l.Flags = testFlags(t, "-strictConfig") },
+			wantErr: "unknown config file fields",
+		},
+		{name: "loader overrides flag", setup: func(l *MyAppConfigLoader) {
+			l.Flags = testFlags(t, "-strictConfig")
+			l.StrictConfig = optional.SomeBool(false)
+		}},
+		{name: "stdin strict", setup: func(l *MyAppConfigLoader) {
+			l.ConfigFile = file.SomeFile(ezconf.StdinPath)
+			l.Stdin = strings.NewReader(data)
+			l.StrictConfig = optional.SomeBool(true)
+		}, wantErr: "failed to decode config file -: unknown config file fields"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "myapp.toml")
+			assert.NilError(t, os.WriteFile(path, []byte(data), 0600))
+
+			l := testLoader(t)
+			l.MyService.Name.Clear()
+			l.ConfigFile = file.SomeFile(path)
+			if tc.setup != nil {
+				tc.setup(l)
+			}
+
+			c, err := l.Update()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				var unknown *ezconf.UnknownFieldsError
+				assert.Assert(t, errors.As(err, &unknown))
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, "from-file", c.MyService.Name)
+			assert.Equal(t, uint16(5432), c.MyDB.Port)
+			assert.Equal(t, "b0", c.Backends[0].Address)
+		})
+	}
+
+	// A file which uses only known keys, including nested library loaders, passes strict mode.
+	path := filepath.Join(t.TempDir(), "myapp.yaml")
+	data = "myservice:\n  name: from-file\n  node: 2\n  serverconfig:\n    bindport: 8443\nmydb:\n  replicas: [a, b]\n"
+	assert.NilError(t, os.WriteFile(path, []byte(data), 0600))
+	l := testLoader(t)
+	l.ConfigFile = file.SomeFile(path)
+	l.StrictConfig = optional.SomeBool(true)
+	_, err := l.Update()
+	assert.NilError(t, err)
+}
+
+// captureWarnings sends slog.Default to a buffer for the rest of the test and returns it.
+func captureWarnings(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestMyAppConfigLoaderDeprecatedName(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		data        string
+		env         map[string]string
+		want        string
+		wantWarning string
+	}{
+		{
+			name: "toml", file: "myapp.toml", data: "[MyDB]\nHost = \"db.old\"\n", want: "db.old",
+			wantWarning: "deprecated=MyDB.Host current=MyDB.Address",
+		},
+		{
+			name: "json", file: "myapp.json", data: `{"MyDB": {"host": "db.old", "Port": 5432}}`, want: "db.old",
+			wantWarning: "deprecated=MyDB.Host",
+		},
+		{
+			name: "yaml", file: "myapp.yaml", data: "mydb:\n  host: db.old\n  port: 5432\n", want: "db.old",
+			wantWarning: "deprecated=MyDB.Host",
+		},
+		{
+			name: "file prefers the current name", file: "myapp.toml", data: "[MyDB]\nHost = \"db.old\"\nAddress = \"db.new\"\n",
+			want: "db.new", wantWarning: "ignoring config value given under a deprecated name",
+		},
+		{
+			name: "env", env: map[string]string{"MY_DB_HOST": "db.old"}, want: "db.old",
+			wantWarning: "where=env deprecated=PREFIX_MY_DB_HOST current=PREFIX_MY_DB_ADDRESS",
+		},
+		{
+			name: "env prefers the current name", env: map[string]string{"MY_DB_HOST": "db.old", "MY_DB_ADDRESS": "db.new"},
+			want: "db.new", wantWarning: "ignoring config value given under a deprecated name",
+		},
+		{
+			name: "deprecated env overrides the file", file: "myapp.toml", data: "[MyDB]\nAddress = \"db.file\"\n",
+			env: map[string]string{"MY_DB_HOST": "db.old"}, want: "db.old", wantWarning: "deprecated=PREFIX_MY_DB_HOST",
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			warnings := captureWarnings(t)
+			l := testLoader(t)
+			// Warnings are only logged once per name, so each case uses its own env vars.
+			prefix := fmt.Sprintf("DEPRECATED_%d_", i)
+			l.EnvPrefix = optional.SomeStr(prefix)
+			for name, value := range tc.env {
+				t.Setenv(prefix+name, value)
+			}
+			if tc.file != "" {
+				path := filepath.Join(t.TempDir(), tc.file)
+				assert.NilError(t, os.WriteFile(path, []byte(tc.data), 0600))
+				l.ConfigFile = file.SomeFile(path)
+				l.StrictConfig = optional.SomeBool(true)
+			}
+
+			c, err := l.Update()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, c.MyDB.Address)
+			address, err := l.GetMyDBAddress()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, address)
+
+			wantWarning := strings.ReplaceAll(tc.wantWarning, "PREFIX_", prefix)
+			assert.Assert(t, strings.Contains(warnings.String(), wantWarning), warnings.String())
+			// Reloading does not warn again.
+			n := strings.Count(warnings.String(), "level=WARN")
+			_, err = l.Update()
+			assert.NilError(t, err)
+			assert.Equal(t, n, strings.Count(warnings.String(), "level=WARN"))
+		})
+	}
+
+	// Nothing is logged for configs which use the current names.
+	warnings := captureWarnings(t)
+	l := testLoader(t)
+	_, err := l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, "", warnings.String())
+}
+
+func TestMyAppConfigLoaderStdin(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "base.toml")
+	data := "[MyService]\nName = \"from-base\"\nDescription = \"base description\"\n"
+	assert.NilError(t, os.WriteFile(base, []byte(data), 0600))
+	stdin := "[MyService]\nName = \"from-stdin\"\n\n[MyDB]\nPort = 5432\n"
+
+	tests := []struct {
+		name            string
+		args            []string
+		wantDescription string
+	}{
+		{name: "stdin only", args: []string{"-config", "-"}, wantDescription: DefaultMyServiceConfigDescription},
+		{name: "stdin over a file", args: []string{"-config", base + ",-"}, wantDescription: "base description"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := testLoader(t)
+			l.MyService.Name.Clear()
+			l.Flags = testFlags(t, tc.args...)
+			l.Stdin = strings.NewReader(stdin)
+
+			c, err := l.Update()
+			assert.NilError(t, err)
+			assert.Equal(t, "from-stdin", c.MyService.Name)
+			assert.Equal(t, uint16(5432), c.MyDB.Port)
+			assert.Equal(t, tc.wantDescription, c.MyService.Description)
+
+			// Stdin was drained by the first Update, but later ones see the same document.
+			c, err = l.Update()
+			assert.NilError(t, err)
+			assert.Equal(t, "from-stdin", c.MyService.Name)
+		})
+	}
+
+	l := testLoader(t)
+	l.ConfigFile = file.SomeFile(ezconf.StdinPath)
+	l.Stdin = strings.NewReader("not a config")
+	_, err := l.Update()
+	assert.ErrorContains(t, err, "failed to decode config file -")
+
+	// There is nothing to watch when the only config comes from stdin.
+	err = l.Watch(context.Background(), func(MyAppConfig, error) {})
+	assert.ErrorContains(t, err, "no config file or .env file was given")
+}
+
+func TestMyAppConfigLoaderConfigURL(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/myapp.toml":
+			w.Write([]byte("[MyService]\nName = \"from-url\"\nDescription = \"remote\"\n\n[MyDB]\nPort = 5432\n"))
+		case "/slow.toml":
+			<-release
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	// Cleanups run last first, so the slow handler is released before srv.Close waits for it.
+	t.Cleanup(func() { close(release) })
+
+	slow := srv.Client()
+	slow.Timeout = 50 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		path    string
+		client  *http.Client
+		wantErr string
+	}{
+		{name: "remote config", path: srv.URL + "/myapp.toml", client: srv.Client()},
+		{name: "disabled", path: srv.URL + "/myapp.toml", wantErr: "remote config files are disabled"},
+		{name: "untrusted certificate", path: srv.URL + "/myapp.toml", client: &http.Client{}, wantErr: "certificate"},
+		{
+			name: "not found", path: srv.URL + "/missing.toml", client: srv.Client(), wantErr: "unexpected status 404 Not Found",
+		},
+		{name: "timeout", path: srv.URL + "/slow.toml", client: slow, wantErr: "Client.Timeout exceeded"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := testLoader(t)
+			l.MyService.Name.Clear()
+			l.ConfigClient = tc.client
+			l.Flags = testFlags(t, "-config", tc.path)
+
+			c, err := l.Update()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				assert.ErrorContains(t, err, "failed to read config file "+tc.path)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, "from-url", c.MyService.Name)
+			assert.Equal(t, uint16(5432), c.MyDB.Port)
+			assert.Equal(t, "file", l.Sources()["MyService.Name"])
+		})
+	}
+
+	// A local file can be laid over the remote one.
+	local := filepath.Join(t.TempDir(), "local.toml")
+	assert.NilError(t, os.WriteFile(local, []byte("[MyService]\nName = \"from-local\"\n"), 0600))
+	l := testLoader(t)
+	l.MyService.Name.Clear()
+	l.ConfigClient = srv.Client()
+	l.ConfigFiles = file.SomeFiles(srv.URL+"/myapp.toml", local)
+	c, err := l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, "from-local", c.MyService.Name)
+	assert.Equal(t, "remote", c.MyService.Description)
+}
+
+func TestMyServiceConfigLoaderSecretKeyFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flag-secret")
+	assert.NilError(t, os.WriteFile(path, []byte("from-flag-file\n"), 0600))
+	stdin := filepath.Join(t.TempDir(), "stdin")
+	assert.NilError(t, os.WriteFile(stdin, []byte("from-stdin\n"), 0600))
+
+	tests := []struct {
+		name       string
+		value      string
+		loaderFile bool // Whether the loader itself names a secret file, which takes precedence over the flag.
+		want       string
+	}{
+		{name: "literal", value: "from-flag", want: "from-flag"},
+		{name: "file", value: "@" + path, want: "from-flag-file"},
+		{name: "stdin", value: "@-", want: "from-stdin"},
+		{name: "loader file wins", value: "from-flag", loaderFile: true, want: "hunter2"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := os.Open(stdin)
+			assert.NilError(t, err)
+			defer f.Close()
+			orig := os.Stdin
+			os.Stdin = f
+			defer func() { os.Stdin = orig }()
+
+			l := testLoader(t)
+			if !tc.loaderFile {
+				l.MyService.SecretKey = file.NoSecretFile()
+			}
+			l.Flags = testFlags(t, "-myServiceSecretKey", tc.value)
+
+			c, err := l.Update()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, c.MyService.SecretKey.MustGet())
+
+			key, err := l.GetMyServiceSecretKey()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, key.MustGet())
+
+			var into MyAppConfig
+			assert.NilError(t, l.Into(&into))
+			assert.Equal(t, tc.want, into.MyService.SecretKey.MustGet())
+
+			wantSource := ezconf.SourceFlag
+			if tc.loaderFile {
+				wantSource = ezconf.SourceLoader
+			}
+			assert.Equal(t, wantSource, l.Sources()["MyService.SecretKey"])
+		})
+	}
+}
+
+func TestMyServiceConfigLoaderSecretKeyStdinConflict(t *testing.T) {
+	stdin := filepath.Join(t.TempDir(), "stdin")
+	assert.NilError(t, os.WriteFile(stdin, []byte("from-stdin\n"), 0600))
+
+	tests := []struct {
+		name string
+		args []string
+		conf bool // Whether the loader itself names stdin as its config file.
+	}{
+		{name: "secret first", args: []string{"-myServiceSecretKey", "@-", "-config", "-"}},
+		{name: "config first", args: []string{"-config", "-", "-myServiceSecretKey", "@-"}},
+		{name: "loader config", args: []string{"-myServiceSecretKey", "@-"}, conf: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := os.Open(stdin)
+			assert.NilError(t, err)
+			defer f.Close()
+			orig := os.Stdin
+			os.Stdin = f
+			defer func() { os.Stdin = orig }()
+
+			l := testLoader(t)
+			l.Flags = testFlags(t, tc.args...)
+			l.Stdin = strings.NewReader("")
+			if tc.conf {
+				l.ConfigFile = file.SomeFile(ezconf.StdinPath)
+			}
+
+			_, err = l.Update()
+			assert.ErrorContains(t, err, "-myServiceSecretKey @- and the config path - both read stdin")
+		})
+	}
+}
+
+func TestMyDBConfigLoaderReplicas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	assert.NilError(t, os.WriteFile(path, []byte("[MyDB]\nReplicas = [\"file-a\", \"file-b\"]\n"), 0600))
+
+	tests := []struct {
+		name  string
+		file  bool
+		env   string
+		flags []string
+		want  []string
+	}{
+		{name: "unset", want: nil},
+		{name: "file", file: true, want: []string{"file-a", "file-b"}},
+		{name: "env over file", file: true, env: "env-a,env-b,env-c", want: []string{"env-a", "env-b", "env-c"}},
+		{
+			name: "repeated flag over env", env: "env-a", flags: []string{"-myDBReplicas", "flag-a", "-myDBReplicas", "flag-b"},
+			want: []string{"flag-a", "flag-b"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("MY_APP_MY_DB_REPLICAS", tc.env)
+
+			l := testLoader(t)
+			l.Flags = testFlags(t, tc.flags...)
+			if tc.file {
+				l.ConfigFile = file.SomeFile(path)
+			}
+
+			c, err := l.Update()
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.want, c.MyDB.Replicas)
+		})
+	}
+}
+
+func TestMyDBConfigLoaderParams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	data := `
+[MyDB.Params]
+sslmode = "require"
+timeout = "10"
+pool = "5"
+`
+	assert.NilError(t, os.WriteFile(path, []byte(data), 0600))
+
+	t.Setenv("MY_APP_MY_DB_PARAMS", "timeout=30,app=myapp")
+
+	l := testLoader(t)
+	l.Flags = testFlags(t, "-myDBParams", "pool=20")
+	l.ConfigFile = file.SomeFile(path)
+
+	c, err := l.Update()
+	assert.NilError(t, err)
+	want := map[string]string{
+		"sslmode": "require", // file
+		"timeout": "30",      // env over file
+		"app":     "myapp",   // env
+		"pool":    "20",      // flag over file
+	}
+	assert.DeepEqual(t, want, c.MyDB.Params)
+}
+
+func TestMyDBConfigLoaderQueryTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		flag    string
+		file    string
+		want    time.Duration
+		wantErr string
+	}{
+		{name: "default", want: DefaultMyDBConfigQueryTimeout},
+		{name: "env", env: "1500ms", want: 1500 * time.Millisecond},
+		{name: "flag", flag: "1500ms", want: 1500 * time.Millisecond},
+		{name: "toml string", file: `QueryTimeout = "1500ms"`, want: 1500 * time.Millisecond},
+		{name: "compound", env: "1h30m", want: 90 * time.Minute},
+		{name: "bare number env", env: "30", wantErr: "failed to load env var MY_APP_MY_DB_QUERY_TIMEOUT"},
+		{name: "bare number toml", file: `QueryTimeout = 30`, wantErr: "failed to decode config file"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("MY_APP_MY_DB_QUERY_TIMEOUT", tc.env)
+			l := testLoader(t)
+			if tc.flag != "" {
+				l.Flags = testFlags(t, "-myDBQueryTimeout", tc.flag)
+			}
+			if tc.file != "" {
+				path := filepath.Join(t.TempDir(), "myapp.toml")
+				assert.NilError(t, os.WriteFile(path, []byte("[MyDB]\n"+tc.file+"\n"), 0600))
+				l.ConfigFile = file.SomeFile(path)
+			}
+
+			c, err := l.Update()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, c.MyDB.QueryTimeout)
+		})
+	}
+}
+
+// passwordSecrets stands in for a secret store such as Vault.
+type passwordSecrets struct {
+	err error
+}
+
+func (p passwordSecrets) Get(key string) (string, bool, error) {
+	return "from-provider", key == "/run/secrets/myapp-db-password", p.err
+}
+
+func TestMyDBConfigLoaderPooling(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   string
+		flags []string
+		file  string
+		want  bool
+	}{
+		{name: "unset", want: DefaultMyDBConfigPooling},
+		{name: "no flag", flags: []string{"-no-myDBPooling"}, want: false},
+		{name: "flag", flags: []string{"-myDBPooling"}, env: "false", want: true},
+		{name: "flag false", flags: []string{"-myDBPooling=false"}, want: false},
+		{name: "no flag over env", flags: []string{"-no-myDBPooling"}, env: "on", want: false},
+		{name: "last flag wins", flags: []string{"-no-myDBPooling", "-myDBPooling"}, want: true},
+		{name: "env", env: "disabled", want: false},
+		{name: "no flag over file", flags: []string{"-no-myDBPooling"}, file: "Pooling = true", want: false},
+		{name: "file", file: "Pooling = false", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("MY_APP_MY_DB_POOLING", tc.env)
+			l := testLoader(t)
+			l.Flags = testFlags(t, tc.flags...)
+			if tc.file != "" {
+				path := filepath.Join(t.TempDir(), "myapp.toml")
+				assert.NilError(t, os.WriteFile(path, []byte("[MyDB]\n"+tc.file+"\n"), 0600))
+				l.ConfigFile = file.SomeFile(path)
+			}
+
+			c, err := l.Update()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, c.MyDB.Pooling)
+		})
+	}
+}
+
+func TestMyDBConfigLoaderPassword(t *testing.T) {
+	t.Cleanup(func() { ezconf.RegisterSecretProvider("file", ezconf.FileSecretProvider{}) })
+
+	tests := []struct {
+		name     string
+		provider passwordSecrets
+		env      string
+		want     string
+		wantErr  string
+	}{
+		{name: "provider", want: "from-provider"},
+		{name: "env over provider", env: "from-env", want: "from-env"},
+		{
+			name: "provider error", provider: passwordSecrets{errors.New("sealed")},
+			wantErr: "failed to load secret file:/run/secrets/myapp-db-password: sealed",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ezconf.RegisterSecretProvider("file", tc.provider)
+			t.Setenv("MY_APP_MY_DB_PASSWORD", tc.env)
+
+			l := testLoader(t)
+			c, err := l.Update()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, c.MyDB.Password.MustGet())
+			data, err := json.Marshal(l)
+			assert.NilError(t, err)
+			assert.Assert(t, !strings.Contains(string(data), tc.want))
+		})
+	}
+}
+
+// adminSecrets is a secret store which counts how often it is asked for MyDB.AdminPassword and checks whether it can
+// reach it, failing every check with err if it is set.
+type adminSecrets struct {
+	gets   *int
+	checks *int
+	err    error
+}
+
+func (a adminSecrets) Get(key string) (string, bool, error) {
+	if key != "/run/secrets/myapp-db-admin-password" {
+		return "", false, nil
+	}
+	*a.gets++
+	return "from-provider", true, nil
+}
+
+func (a adminSecrets) Check(key string) error {
+	*a.checks++
+	return a.err
+}
+
+func TestMyDBConfigLoaderAdminPassword(t *testing.T) {
+	t.Cleanup(func() { ezconf.RegisterSecretProvider("file", ezconf.FileSecretProvider{}) })
+	var gets, checks int
+	ezconf.RegisterSecretProvider("file", adminSecrets{gets: &gets, checks: &checks})
+
+	// Update only checks that the secret can be reached, and the first Get fetches it for every config loaded since.
+	l := testLoader(t)
+	first, err := l.Update()
+	assert.NilError(t, err)
+	_, next, changed, err := l.Reload()
+	assert.NilError(t, err)
+	assert.Assert(t, !changed)
+	assert.Equal(t, 2, checks)
+	assert.Equal(t, 0, gets)
+	for _, c := range []MyAppConfig{first, next, first} {
+		secret, err := c.MyDB.AdminPassword.Get()
+		assert.NilError(t, err)
+		assert.Equal(t, "from-provider", secret.MustGet())
+	}
+	assert.Equal(t, 1, gets)
+	assert.Equal(t, ezconf.SourceSecret, l.Sources()["MyDB.AdminPassword"])
+	data, err := json.Marshal(next)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(data), "from-provider"))
+
+	// A secret which cannot be reached fails the update without being fetched.
+	ezconf.RegisterSecretProvider("file", adminSecrets{gets: &gets, checks: &checks, err: errors.New("sealed")})
+	_, err = l.Update()
+	assert.ErrorContains(t, err,
+		"failed to load MyDBConfig.AdminPassword: failed to check secret file:/run/secrets/myapp-db-admin-password: sealed")
+	assert.Equal(t, 1, gets)
+}
+
+// keyed is an ezconf.KeyedSource backed by a map, standing in for a *viper.Viper.
+type keyed map[string]string
+
+func (k keyed) IsSet(key string) bool {
+	_, ok := k[key]
+	return ok
+}
+
+func (k keyed) GetString(key string) string {
+	return k[key]
+}
+
+func TestMyAppConfigLoaderKeyed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	data := "[MyService]\nDescription = \"from the file\"\n\n[MyDB]\nAddress = \"db.internal\"\nPort = 5432\n"
+	assert.NilError(t, os.WriteFile(path, []byte(data), 0600))
+	t.Setenv("MY_APP_MY_DB_PORT", "6543")
+
+	l := testLoader(t)
+	l.ConfigFile = file.SomeFile(path)
+	l.Keyed = keyed{
+		"MyService.Description": "from keyed",
+		"MyService.Priority":    "7",
+		"MyDB.Port":             "7654",
+		"MyDB.SSLMode":          "require",
+	}
+	c, err := l.Update()
+	assert.NilError(t, err)
+
+	assert.Equal(t, "from keyed", c.MyService.Description)
+	assert.Equal(t, uint16(7), c.MyService.Priority)
+	assert.Equal(t, "db.internal", c.MyDB.Address)
+	assert.Equal(t, uint16(6543), c.MyDB.Port)
+	assert.Equal(t, "require", c.MyDB.SSLMode)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "MyService.Description", want: ezconf.SourceKeyed},
+		{path: "MyService.Priority", want: ezconf.SourceKeyed},
+		{path: "MyDB.Address", want: ezconf.SourceFile},
+		{path: "MyDB.Port", want: ezconf.SourceEnv},
+		{path: "MyDB.SSLMode", want: ezconf.SourceKeyed},
+		{path: "MyDB.QueryTimeout", want: ezconf.SourceDefault},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.want, l.Sources()[tc.path])
+		})
+	}
+
+	// Values which do not parse fail the update, naming the key.
+	l.Keyed = keyed{"MyDB.Port": "not-a-port"}
+	_, err = l.Update()
+	assert.ErrorContains(t, err, "failed to load key MyDB.Port")
+}
+
+func TestMyAppConfigLoaderSources(t *testing.T) {
+	t.Cleanup(func() { ezconf.RegisterSecretProvider("file", ezconf.FileSecretProvider{}) })
+	ezconf.RegisterSecretProvider("file", passwordSecrets{})
+
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	data := `
+[MyService]
+Description = "from the file"
+
+[MyDB]
+Address = "db.internal"
+Port = 5432
+
+[[Backends]]
+Address = "10.0.0.1"
+`
+	assert.NilError(t, os.WriteFile(path, []byte(data), 0600))
+	t.Setenv("MY_APP_MY_DB_PORT", "6543")
+	t.Setenv("MY_APP_BACKENDS_0_PORT", "9000")
+
+	l := testLoader(t)
+	l.ConfigFile = file.SomeFile(path)
+	l.Flags = testFlags(t, "-myDBQueryTimeout", "1m")
+	assert.Assert(t, l.Sources() == nil)
+
+	_, err := l.Update()
+	assert.NilError(t, err)
+	sources := l.Sources()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "MyService.Name", want: ezconf.SourceLoader},
+		{path: "MyService.Description", want: ezconf.SourceFile},
+		{path: "MyService.Priority", want: ezconf.SourceDefault},
+		{path: "MyService.SecretKey", want: ezconf.SourceLoader},
+		{path: "MyDB.Address", want: ezconf.SourceFile},
+		{path: "MyDB.Port", want: ezconf.SourceEnv},
+		{path: "MyDB.QueryTimeout", want: ezconf.SourceFlag},
+		{path: "MyDB.SSLMode", want: ezconf.SourceDefault},
+		{path: "MyDB.Password", want: ezconf.SourceSecret},
+		{path: "Backends[0].Address", want: ezconf.SourceFile},
+		{path: "Backends[0].Port", want: ezconf.SourceEnv},
+		{path: "Backends[0].Weight", want: ezconf.SourceDefault},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.want, sources[tc.path])
+		})
+	}
+
+	// Only sources are recorded, never values.
+	for path, source := range sources {
+		assert.Assert(t, !strings.Contains(source, "from-provider"), path)
+	}
+
+	// A failed update keeps the sources of the config which is still active.
+	t.Setenv("MY_APP_MY_DB_PORT", "not-a-port")
+	_, err = l.Update()
+	assert.Assert(t, err != nil)
+	assert.Equal(t, ezconf.SourceEnv, l.Sources()["MyDB.Port"])
+
+	t.Setenv("MY_APP_MY_DB_PORT", "")
+	l.Compute("MyService.Priority", func(c *MyAppConfig) error {
+		c.MyService.Priority = 2
+		return nil
+	})
+	_, err = l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, ezconf.SourceFile, l.Sources()["MyDB.Port"])
+	assert.Equal(t, ezconf.SourceComputed, l.Sources()["MyService.Priority"])
+}
+
+func TestMyAppConfigLoaderEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	data := `
+# local development settings
+MY_APP_MY_DB_ADDRESS="db.local" # quoted
+MY_APP_MY_DB_PORT=5432
+export MY_APP_MY_SERVICE_DESCRIPTION='from .env # not a comment'
+`
+	assert.NilError(t, os.WriteFile(path, []byte(data), 0600))
+
+	// Real env vars win over the file, and empty ones count as unset.
+	t.Setenv("MY_APP_MY_DB_ADDRESS", "")
+	t.Setenv("MY_APP_MY_SERVICE_DESCRIPTION", "")
+	t.Setenv("MY_APP_MY_DB_PORT", "9000")
+
+	l := testLoader(t)
+	l.Flags = testFlags(t, "-envFile", path)
+	c, err := l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, "db.local", c.MyDB.Address)
+	assert.Equal(t, "from .env # not a comment", c.MyService.Description)
+	assert.Equal(t, uint16(9000), c.MyDB.Port)
+	assert.Equal(t, ezconf.SourceEnv, l.Sources()["MyDB.Address"])
+	assert.Equal(t, "", os.Getenv("MY_APP_MY_DB_ADDRESS"), "the .env file must not change the process environment")
+
+	// Edits to the file apply on the next Update, and vars removed from it are gone.
+	data = "MY_APP_MY_DB_ADDRESS=db.edited\n"
+	assert.NilError(t, os.WriteFile(path, []byte(data), 0600))
+	c, err = l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, "db.edited", c.MyDB.Address)
+	assert.Equal(t, DefaultMyServiceConfigDescription, c.MyService.Description)
+
+	// The .env file is read by the Get accessors as well.
+	address, err := l.GetMyDBAddress()
+	assert.NilError(t, err)
+	assert.Equal(t, "db.edited", address)
+
+	// Watch reloads the config when only the .env file is given.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan MyAppConfig, 10)
+	err = l.Watch(ctx, func(c MyAppConfig, err error) {
+		assert.Check(t, err)
+		changed <- c
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(path, []byte("MY_APP_MY_DB_ADDRESS=db.watched\n"), 0600))
+	select {
+	case c = <-changed:
+		assert.Equal(t, "db.watched", c.MyDB.Address)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the watch callback")
+	}
+	cancel()
+
+	l.EnvFile = file.SomeFile(filepath.Join(t.TempDir(), "missing.env"))
+	_, err = l.Update()
+	assert.Assert(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestMyAppConfigLoaderEnvPrefix(t *testing.T) {
+	t.Setenv("MY_APP_MY_DB_PORT", "9000")
+	t.Setenv("FOO_MY_DB_PORT", "9001")
+	t.Setenv("BAR_MY_DB_PORT", "9002")
+	t.Setenv("BAR_BACKENDS_0_WEIGHT", "4")
+	t.Setenv("MY_DB_PORT", "9003")
+
+	tests := []struct {
+		name   string
+		prefix optional.Str
+		port   uint16
+		weight uint16
+	}{
+		{name: "default", prefix: optional.NoStr(), port: 9000, weight: DefaultBackendConfigWeight},
+		{name: "foo", prefix: optional.SomeStr("FOO_"), port: 9001, weight: DefaultBackendConfigWeight},
+		{name: "bar", prefix: optional.SomeStr("BAR_"), port: 9002, weight: 4},
+		{name: "no prefix", prefix: optional.SomeStr(""), port: 9003, weight: DefaultBackendConfigWeight},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := testLoader(t)
+			l.EnvPrefix = tc.prefix
+			l.Backends = []BackendConfigLoader{{}}
+
+			c, err := l.Resolve()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.port, c.MyDB.Port)
+			assert.Equal(t, tc.weight, c.Backends[0].Weight)
+		})
+	}
+}
+
+func TestMyAppConfigLoaderBackends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	data := `
+[[Backends]]
+Address = "b1.internal"
+Port = 9001
+
+[[Backends]]
+Address = "b2.internal"
+Weight = 5
+`
+	assert.NilError(t, os.WriteFile(path, []byte(data), 0600))
+
+	l := testLoader(t)
+	l.ConfigFile = file.SomeFile(path)
+	c, err := l.Update()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []BackendConfig{
+		{Address: "b1.internal", Port: 9001, Weight: DefaultBackendConfigWeight},
+		{Address: "b2.internal", Port: DefaultBackendConfigPort, Weight: 5},
+	}, c.Backends)
+
+	// Elements are merged by index: env vars override the file, and the loader overrides both or adds elements.
+	t.Setenv("MY_APP_BACKENDS_1_PORT", "9002")
+	t.Setenv("MY_APP_BACKENDS_5_PORT", "9005")
+	l.Backends = []BackendConfigLoader{{}, {Weight: optional.SomeUint16(7)}, {Address: optional.SomeStr("b3.internal")}}
+	c, err = l.Update()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []BackendConfig{
+		{Address: "b1.internal", Port: 9001, Weight: DefaultBackendConfigWeight},
+		{Address: "b2.internal", Port: 9002, Weight: 7},
+		{Address: "b3.internal", Port: DefaultBackendConfigPort, Weight: DefaultBackendConfigWeight},
+	}, c.Backends)
+
+	var cfg MyAppConfig
+	cfg.Backends = []BackendConfig{{Port: 1}}
+	err = l.Into(&cfg)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []BackendConfig{
+		{Address: "b1.internal", Port: 9001},
+		{Address: "b2.internal", Port: 9002, Weight: 7},
+		{Address: "b3.internal"},
+	}, cfg.Backends)
+
+	t.Setenv("MY_APP_BACKENDS_0_WEIGHT", "heavy")
+	_, err = l.Update()
+	assert.ErrorContains(t, err, "failed to load MyAppConfig.Backends[0]")
+	assert.ErrorContains(t, err, "MY_APP_BACKENDS_0_WEIGHT")
+	err = l.Into(&cfg)
+	assert.ErrorContains(t, err, "failed to load MyAppConfig.Backends[0]")
+	assert.Equal(t, 3, len(cfg.Backends))
+	assert.Equal(t, uint16(9001), cfg.Backends[0].Port)
+}
+
+func TestMyAppConfigLoaderSave(t *testing.T) {
+	for _, ext := range []string{".toml", ".json", ".yaml"} {
+		t.Run(ext, func(t *testing.T) {
+			l := testLoader(t)
+			l.MyService.Description = optional.SomeStr("saved")
+			l.MyService.Salt = ezconf.SomeBytes([]byte{0xfb, 0xff, 0x01})
+			l.MyService.SessionKey = ezconf.SomeHexBytes([]byte{0xde, 0xad})
+			l.MyDB.Port = optional.SomeUint16(9000)
+			l.MyDB.Replicas = ezconf.SomeList("a", "b")
+			l.MyDB.Params = ezconf.SomeMap(map[string]string{"sslmode": "require"})
+			l.Backends = []BackendConfigLoader{{Address: optional.SomeStr("b1.internal")}, {Weight: optional.SomeUint16(3)}}
+			want, err := l.Update()
+			assert.NilError(t, err)
+
+			path := filepath.Join(t.TempDir(), "myapp"+ext)
+			err = l.Save(path)
+			assert.NilError(t, err)
+
+			data, err := os.ReadFile(path)
+			assert.NilError(t, err)
+			assert.Assert(t, !strings.Contains(string(data), "hunter2"))
+			assert.Assert(t, strings.Contains(string(data), l.MyService.SecretKey.File.String()))
+			assert.Assert(t, strings.Contains(string(data), "+/8B"))
+			assert.Assert(t, strings.Contains(string(data), "dead"))
+			// Salt and SessionKey are key material, so only the owner may read the file.
+			info, err := os.Stat(path)
+			assert.NilError(t, err)
+			assert.Equal(t, fs.FileMode(0600), info.Mode().Perm())
+
+			reloaded := &MyAppConfigLoader{ConfigFile: file.SomeFile(path)}
+			reloaded.MyService.ServerConfig.Tls = noTls{}
+			got, err := reloaded.Update()
+			assert.NilError(t, err)
+			assert.Assert(t, reflect.DeepEqual(want, got))
+		})
+	}
+}
+
+func TestMyAppConfigLoaderSaveLoadedPaths(t *testing.T) {
+	l := testLoader(t)
+	loaded := l.MyService.SecretKey.File.String()
+	_, err := l.Update()
+	assert.NilError(t, err)
+
+	// Sources which change after the config was loaded do not change what is saved.
+	l.MyService.SecretKey = file.SomeSecretFile(filepath.Join(t.TempDir(), "changed.txt"))
+	t.Setenv("MY_APP_MY_SERVICE_PLUGINS", "/changed/*.so")
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	assert.NilError(t, l.Save(path))
+
+	data, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(data), loaded))
+	assert.Assert(t, !strings.Contains(string(data), "changed"))
+
+	// A secret given by flag has no path to save.
+	l = testLoader(t)
+	l.MyService.SecretKey = file.NoSecretFile()
+	l.Flags = testFlags(t, "-myServiceSecretKey", "hunter2")
+	_, err = l.Update()
+	assert.NilError(t, err)
+	assert.NilError(t, l.Save(path))
+	data, err = os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(data), "SecretKey"))
+}
+
+func TestMyAppConfigLoaderSaveMinimal(t *testing.T) {
+	l := testLoader(t)
+	l.MyService.Description = optional.SomeStr("saved")
+	l.MyDB.Port = optional.SomeUint16(9000)
+	// Set, but to the default, so it is left out all the same.
+	l.MyDB.Address = optional.SomeStr(DefaultMyDBConfigAddress)
+	want, err := l.Update()
+	assert.NilError(t, err)
+
+	path := filepath.Join(t.TempDir(), "myapp.json")
+	assert.NilError(t, l.SaveMinimal(path))
+
+	data, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	var saved map[string]map[string]any
+	assert.NilError(t, json.Unmarshal(data, &saved))
+	assert.DeepEqual(t, []string{"Description", "Name", "SecretKey", "node"}, slices.Sorted(maps.Keys(saved["MyService"])))
+	assert.DeepEqual(t, []string{"Port"}, slices.Sorted(maps.Keys(saved["MyDB"])))
+
+	reloaded := &MyAppConfigLoader{ConfigFile: file.SomeFile(path)}
+	reloaded.MyService.ServerConfig.Tls = noTls{}
+	got, err := reloaded.Update()
+	assert.NilError(t, err)
+	assert.Assert(t, reflect.DeepEqual(want, got))
+}
+
+func TestDefaultMyAppConfig(t *testing.T) {
+	// Defaults are not affected by the environment.
+	t.Setenv("MY_APP_MY_DB_PORT", "9000")
+
+	c := DefaultMyAppConfig()
+	assert.Equal(t, "", c.MyService.Name)
+	assert.Equal(t, uint32(0), c.MyService.NodeID)
+	assert.Equal(t, uint16(DefaultMyServiceConfigPriority), c.MyService.Priority)
+	assert.Assert(t, c.MyService.SecretKey.IsNone())
+	assert.Equal(t, DefaultMyDBConfigAddress, c.MyDB.Address)
+	assert.Equal(t, uint16(DefaultMyDBConfigPort), c.MyDB.Port)
+	assert.Equal(t, DefaultMyDBConfigSSLMode, c.MyDB.SSLMode)
+	assert.Assert(t, c.Backends == nil)
+	backendDefaults := BackendConfig{
+		Address: DefaultBackendConfigAddress,
+		Port:    DefaultBackendConfigPort,
+		Weight:  DefaultBackendConfigWeight,
+	}
+	assert.DeepEqual(t, backendDefaults, DefaultBackendConfig())
+
+	// A loader with nothing set resolves every defaulted field to the same value.
+	db, err := (&MyDBConfigLoader{}).resolve(MyDBConfigLoader{}, myAppConfigFlags{}, "UNUSED_")
+	assert.NilError(t, err)
+	assert.Assert(t, reflect.DeepEqual(c.MyDB, db))
+	backend, err := (&BackendConfigLoader{}).Resolve()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, DefaultBackendConfig(), backend)
+}
+
+func TestMyAppConfigLoaderConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, DefaultMyServiceConfigSecretKey), []byte("from-dir"), 0600))
+	other := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(other, DefaultMyServiceConfigSecretKey), []byte("from-env"), 0600))
+
+	l := testLoader(t)
+	l.MyService.SecretKey.Clear()
+
+	// The default directory does not exist in the test environment.
+	_, err := l.Resolve()
+	secretKey := filepath.Join(DefaultMyAppConfigDir, DefaultMyServiceConfigSecretKey)
+	assert.ErrorContains(t, err, "failed to read MyServiceConfig.SecretKey file "+secretKey)
+
+	t.Setenv("MY_APP_CONFIG_DIR", other)
+	c, err := l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, "from-env", c.MyService.SecretKey.MustGet())
+
+	l.Flags = testFlags(t, "-configDir", dir)
+	c, err = l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, "from-dir", c.MyService.SecretKey.MustGet())
+
+	l.Flags = testFlags(t, "-configDir", other)
+	l.ConfigDir = optional.SomeStr(dir)
+	c, err = l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, "from-dir", c.MyService.SecretKey.MustGet())
+
+	// The resolved path is what gets saved.
+	path := filepath.Join(t.TempDir(), "saved.toml")
+	assert.NilError(t, l.Save(path))
+	data, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(data), filepath.Join(dir, DefaultMyServiceConfigSecretKey)), string(data))
+
+	// Absolute paths are left alone.
+	abs := filepath.Join(other, DefaultMyServiceConfigSecretKey)
+	l.MyService.SecretKey = file.SomeSecretFile(abs)
+	c, err = l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, "from-env", c.MyService.SecretKey.MustGet())
+}
+
+func TestMyAppConfigLoaderDefaultConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NilError(t, os.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+
+	l := testLoader(t)
+	l.ConfigDir = optional.SomeStr(dir)
+	l.Flags = testFlags(t)
+	c, err := l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, DefaultMyServiceConfigPriority, int(c.MyService.Priority))
+
+	// The candidates are searched in order and only the first which exists is loaded.
+	write("myapp.json", `{"MyService": {"Priority": 3}}`)
+	c, err = l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, 3, int(c.MyService.Priority))
+
+	yaml := write("myapp.yaml", "myservice:\n  priority: 2\n")
+	c, err = l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, 2, int(c.MyService.Priority))
+
+	write("myapp.toml", "[MyService]\nPriority = 4\n")
+	c, err = l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, 4, int(c.MyService.Priority))
+	assert.Equal(t, ezconf.SourceFile, l.Sources()["MyService.Priority"])
+
+	// A config file given with -config or on the loader replaces the search.
+	l.Flags = testFlags(t, "-config", yaml)
+	c, err = l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, 2, int(c.MyService.Priority))
+
+	l.ConfigFile = file.SomeFile(filepath.Join(dir, "myapp.json"))
+	c, err = l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, 3, int(c.MyService.Priority))
+}
+
+func TestMyServiceConfigLoaderPlugins(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.Mkdir(filepath.Join(dir, "plugins"), 0700))
+	for _, name := range []string{"b.so", "a.so", "README"} {
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, "plugins", name), []byte(name), 0600))
+	}
+	other := filepath.Join(t.TempDir(), "*.plugin")
+	assert.NilError(t, os.WriteFile(strings.TrimSuffix(other, "*.plugin")+"x.plugin", []byte("x"), 0600))
+
+	// The default pattern is joined with the config dir, and expands to nothing if the directory does not exist.
+	l := testLoader(t)
+	c, err := l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(c.MyService.Plugins))
+
+	l.ConfigDir = optional.SomeStr(dir)
+	c, err = l.Update()
+	assert.NilError(t, err)
+	want := []string{filepath.Join(dir, "plugins", "a.so"), filepath.Join(dir, "plugins", "b.so")}
+	assert.DeepEqual(t, want, c.MyService.Plugins)
+	assert.Equal(t, ezconf.SourceDefault, l.Sources()["MyService.Plugins"])
+	plugins, err := l.GetMyServicePlugins()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, want, plugins)
+
+	// The pattern can be given like any other field, and is what gets saved.
+	conf := filepath.Join(t.TempDir(), "myapp.toml")
+	assert.NilError(t, os.WriteFile(conf, []byte(fmt.Sprintf("[MyService]\nPlugins = %q\n", other)), 0600))
+	l.ConfigFile = file.SomeFile(conf)
+	c, err = l.Update()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{strings.TrimSuffix(other, "*.plugin") + "x.plugin"}, c.MyService.Plugins)
+	assert.Equal(t, ezconf.SourceFile, l.Sources()["MyService.Plugins"])
+	saved := filepath.Join(t.TempDir(), "saved.toml")
+	assert.NilError(t, l.Save(saved))
+	data, err := os.ReadFile(saved)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(data), other), string(data))
+
+	t.Setenv("MY_APP_MY_SERVICE_PLUGINS", filepath.Join(dir, "plugins", "a.*"))
+	c, err = l.Resolve()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, want[:1], c.MyService.Plugins)
+
+	var into MyAppConfig
+	assert.NilError(t, l.Into(&into))
+	assert.DeepEqual(t, want[:1], into.MyService.Plugins)
+
+	l.MyService.Plugins = file.SomeGlob("[")
+	_, err = l.Resolve()
+	assert.ErrorContains(t, err, `invalid MyServiceConfig.Plugins: bad glob pattern "["`)
+}
+
+func TestMyAppConfigLoaderWatchPlugins(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.Mkdir(filepath.Join(dir, "plugins"), 0700))
+
+	// Only the plugins directory is watched, as no config file is given.
+	l := testLoader(t)
+	l.ConfigDir = optional.SomeStr(dir)
+	_, err := l.Update()
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan MyAppConfig, 10)
+	err = l.Watch(ctx, func(c MyAppConfig, err error) {
+		assert.Check(t, err)
+		changed <- c
+	})
+	assert.NilError(t, err)
+
+	next := func() MyAppConfig {
+		t.Helper()
+		select {
+		case c := <-changed:
+			return c
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the watch callback")
+		}
+		return MyAppConfig{}
+	}
+
+	plugin := filepath.Join(dir, "plugins", "new.so")
+	assert.NilError(t, os.WriteFile(plugin, []byte("new"), 0600))
+	assert.DeepEqual(t, []string{plugin}, next().MyService.Plugins)
+
+	assert.NilError(t, os.Remove(plugin))
+	assert.Equal(t, 0, len(next().MyService.Plugins))
+}
+
+func TestMyServiceConfigLoaderSecretKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+
+	tests := []struct {
+		name     string
+		path     file.SecretFile
+		wantPath string
+	}{
+		{name: "unset field with no default file", wantPath: filepath.Join(dir, DefaultMyServiceConfigSecretKey)},
+		{name: "missing file", path: file.SomeSecretFile(missing), wantPath: missing},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := testLoader(t)
+			l.ConfigDir = optional.SomeStr(dir)
+			l.MyService.SecretKey = tc.path
+
+			_, err := l.Resolve()
+			var loadErr *ezconf.FileLoadError
+			assert.Assert(t, errors.As(err, &loadErr))
+			assert.Equal(t, tc.wantPath, loadErr.Path)
+			assert.Equal(t, "MyServiceConfig.SecretKey", loadErr.Field)
+			assert.Assert(t, errors.Is(err, fs.ErrNotExist))
+			assert.Assert(t, !errors.Is(err, &ezconf.MissingRequiredError{}))
+
+			_, err = l.GetMyServiceSecretKey()
+			assert.Assert(t, errors.As(err, &loadErr))
+			assert.Equal(t, tc.wantPath, loadErr.Path)
+		})
+	}
+
+	// Into leaves an unset field alone rather than reading the default file, but still reports a missing one.
+	l := testLoader(t)
+	l.MyService.SecretKey = file.NoSecretFile()
+	assert.NilError(t, l.Into(&MyAppConfig{}))
+	l.MyService.SecretKey = file.SomeSecretFile(missing)
+	err := l.Into(&MyAppConfig{})
+	assert.ErrorContains(t, err, "failed to read MyServiceConfig.SecretKey file "+missing)
+}
+
+func TestMyAppConfigLoaderFileRetry(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ezconf.RetryPolicy
+		wantErr string
+	}{
+		{name: "no retry by default", wantErr: "failed to read MyServiceConfig.SecretKey file"},
+		{
+			name:   "retry until the secret is rotated in",
+			policy: ezconf.RetryPolicy{Attempts: 100, Backoff: 5 * time.Millisecond, MaxBackoff: 10 * time.Millisecond},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "secretkey.txt")
+			l := testLoader(t)
+			l.MyService.SecretKey = file.SomeSecretFile(path)
+			l.FileRetry = tc.policy
+
+			// The secret is written to a temporary file and renamed into place, as mounted secrets are rotated.
+			done := make(chan error, 1)
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				err := os.WriteFile(path+".tmp", []byte("rotated"), 0600)
+				if err == nil {
+					err = os.Rename(path+".tmp", path)
+				}
+				done <- err
+			}()
+
+			c, err := l.Resolve()
+			assert.NilError(t, <-done)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, "rotated", c.MyService.SecretKey.MustGet())
+		})
+	}
+}
+
+func TestMyAppConfigLoaderFieldAccess(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "MyService.Name", want: ""},
+		{path: "MyService.NodeID", want: "admin"},
+		{path: "MyService.SecretKey", want: "admin"},
+		{path: "MyDB.Address", want: "admin"},
+		{path: "MyDB.Port", want: ""},
+		{path: "MyDB.Password", want: "admin"},
+		{path: "Backends[2].Address", want: "admin"},
+		{path: "Backends[2].Port", want: ""},
+		{path: "MyDB.Nope", want: ""},
+	}
+
+	l := &MyAppConfigLoader{}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.want, l.FieldAccess(tc.path))
+		})
+	}
+}
+
+func TestMyAppConfigLoaderPrintConfigReference(t *testing.T) {
+	var b strings.Builder
+	l := &MyAppConfigLoader{}
+	err := l.PrintConfigReference(&b)
+	assert.NilError(t, err)
+	out := b.String()
+
+	tests := []struct {
+		field string
+		env   string
+		value string
+	}{
+		{field: "MyService.Name", env: "MY_APP_MY_SERVICE_NAME", value: "yes"},
+		{field: "MyService.NodeID", env: "MY_APP_MY_SERVICE_NODE", value: "-myServiceNode"},
+		{field: "MyService.Priority", env: "MY_APP_MY_SERVICE_PRIORITY", value: "1"},
+		{field: "MyService.SecretKey", env: "MY_APP_MY_SERVICE_SECRET_KEY", value: DefaultMyServiceConfigSecretKey},
+		{field: "MyDB.Address", env: "MY_APP_MY_DB_ADDRESS", value: DefaultMyDBConfigAddress},
+		{field: "MyDB.Port", env: "MY_APP_MY_DB_PORT", value: "8080"},
+		{field: "MyDB.SSLMode", env: "MY_APP_MY_DB_SSL_MODE", value: DefaultMyDBConfigSSLMode},
+		{field: "MyDB.Replicas", env: "MY_APP_MY_DB_REPLICAS", value: "-myDBReplicas"},
+		{field: "MyDB.Params", env: "MY_APP_MY_DB_PARAMS", value: "-myDBParams"},
+		{field: "Backends[N].Weight", env: "MY_APP_BACKENDS_N_WEIGHT", value: "1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.field, func(t *testing.T) {
+			i := strings.Index(out, tc.field+" ")
+			assert.Assert(t, i >= 0, "missing field %s in:\n%s", tc.field, out)
+			line, _, _ := strings.Cut(out[i:], "\n")
+			assert.Assert(t, strings.Contains(line, " "+tc.env+" "), line)
+			assert.Assert(t, strings.Contains(line, " "+tc.value+" ") || strings.HasSuffix(line, " "+tc.value), line)
+		})
+	}
+
+	// Every env var read by the loader is listed.
+	for _, typ := range []reflect.Type{reflect.TypeFor[MyServiceConfigLoader](), reflect.TypeFor[MyDBConfigLoader]()} {
+		for _, f := range reflect.VisibleFields(typ) {
+			env := f.Tag.Get("env")
+			if env != "" {
+				assert.Assert(t, strings.Contains(out, "MY_APP_"+env+" "), "missing env var %s", env)
+			}
+		}
+	}
+
+	b.Reset()
+	l.EnvPrefix = optional.SomeStr("FOO_")
+	err = l.PrintConfigReference(&b)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(b.String(), "FOO_MY_DB_PORT"))
+}
+
+func TestMyAppConfigSchema(t *testing.T) {
+	data, err := MyAppConfigSchema()
+	assert.NilError(t, err)
+
+	// The checked in schema must be rewritten from MyAppConfigSchema whenever MyAppConfig changes.
+	golden, err := os.ReadFile("myappconfig.schema.json")
+	assert.NilError(t, err)
+	assert.Equal(t, string(golden), string(data)+"\n")
+
+	var schema ezconf.Schema
+	assert.NilError(t, json.Unmarshal(data, &schema))
+	service := schema.Properties["MyService"]
+	assert.DeepEqual(t, []string{"Name", "node"}, service.Required)
+
+	tests := []struct {
+		name   string
+		schema *ezconf.Schema
+		want   string
+	}{
+		{name: "node", schema: service.Properties["node"], want: "integer"},
+		{name: "secret file path", schema: service.Properties["SecretKey"], want: "string"},
+		{name: "server config", schema: service.Properties["ServerConfig"], want: "object"},
+		{name: "port", schema: schema.Properties["MyDB"].Properties["Port"], want: "integer"},
+		{name: "duration", schema: schema.Properties["MyDB"].Properties["QueryTimeout"], want: "string"},
+		{name: "replicas", schema: schema.Properties["MyDB"].Properties["Replicas"], want: "array"},
+		{name: "backends", schema: schema.Properties["Backends"], want: "array"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Assert(t, tc.schema != nil)
+			assert.Equal(t, tc.want, tc.schema.Type)
+		})
+	}
+	assert.Assert(t, service.Properties["ServerConfig"].ReadOnly)
+	assert.Equal(t, "prefer", schema.Properties["MyDB"].Properties["SSLMode"].Default)
+}
+
+func TestMyAppConfigLoaderEnvCollisions(t *testing.T) {
+	// Priority and SSLMode have no env tag, so the names derived from the field paths are checked as well.
+	err := ezconf.CheckDerivedEnvCollisions(&MyAppConfigLoader{})
+	assert.NilError(t, err)
+}
+
+func TestMyAppConfigLoaderDerivedEnvNames(t *testing.T) {
+	t.Setenv("FOO_MY_SERVICE_PRIORITY", "9")
+	t.Setenv("FOO_MY_DB_SSL_MODE", "require")
+
+	l := testLoader(t)
+	l.EnvPrefix = optional.SomeStr("FOO_")
+	c, err := l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, uint16(9), c.MyService.Priority)
+	assert.Equal(t, "require", c.MyDB.SSLMode)
+	assert.Equal(t, ezconf.SourceEnv, l.Sources()["MyService.Priority"])
+	assert.Equal(t, ezconf.SourceEnv, l.Sources()["MyDB.SSLMode"])
+}
+
+func TestMyAppConfigLoaderSubLoaderError(t *testing.T) {
+	l := testLoader(t)
+	l.MyService.ServerConfig.Tls = badTls{}
+
+	_, err := l.Update()
+	assert.ErrorIs(t, err, errBadTls)
+
+	var c MyAppConfig
+	err = l.Into(&c)
+	assert.ErrorIs(t, err, errBadTls)
+}
+
+func TestMyDBConfigLoaderResolveIsPure(t *testing.T) {
+	l := MyDBConfigLoader{Port: optional.SomeUint16(9000)}
+
+	c, err := l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, uint16(9000), c.Port)
+	assert.Assert(t, reflect.DeepEqual(MyDBConfig{}, l.Previous()))
+
+	c, err = l.Update()
+	assert.NilError(t, err)
+	assert.Assert(t, reflect.DeepEqual(c, l.Previous()))
+}
+
+func TestMyDBConfigLoaderCopyPrevious(t *testing.T) {
+	l := MyDBConfigLoader{Port: optional.SomeUint16(9000)}
+	_, err := l.Update()
+	assert.NilError(t, err)
+
+	// A copy starts out with the config stored so far, but later updates to either loader do not change the other.
+	c := l
+	assert.Equal(t, uint16(9000), c.Previous().Port)
+
+	l.Port = optional.SomeUint16(9001)
+	_, err = l.Update()
+	assert.NilError(t, err)
+	c.Port = optional.SomeUint16(9002)
+	_, err = c.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, uint16(9001), l.Previous().Port)
+	assert.Equal(t, uint16(9002), c.Previous().Port)
+}
+
+func TestMyAppConfigLoaderUpdateIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	data := "[MyService]\nDescription = \"from-file\"\n\n[MyDB]\nPort = 5432\n"
+	assert.NilError(t, os.WriteFile(path, []byte(data), 0600))
+	t.Setenv("MY_APP_MY_DB_SSL_MODE", "require")
+
+	l := testLoader(t)
+	l.ConfigFile = file.SomeFile(path)
+	l.Flags = testFlags(t, "-myDBAddress", "db.flag")
+	l.MyDB.Replicas = ezconf.SomeList("r1")
+
+	first, err := l.Update()
+	assert.NilError(t, err)
+	second, err := l.Update()
+	assert.NilError(t, err)
+	assert.Assert(t, reflect.DeepEqual(first, second))
+	assert.Equal(t, "from-file", second.MyService.Description)
+	assert.Equal(t, "db.flag", second.MyDB.Address)
+	assert.Equal(t, "require", second.MyDB.SSLMode)
+
+	// Resolved values are never written back into the loader fields, so only the programmatic layer is set on them.
+	assert.Assert(t, l.MyService.Description.IsNone())
+	assert.Assert(t, l.MyDB.Address.IsNone())
+	assert.Assert(t, l.MyDB.Port.IsNone())
+	assert.Assert(t, l.MyDB.SSLMode.IsNone())
+	assert.DeepEqual(t, []string{"r1"}, l.MyDB.Replicas.GetOr(nil))
+}
+
+func TestMyAppConfigLoaderReload(t *testing.T) {
+	l := testLoader(t)
+	first, err := l.Update()
+	assert.NilError(t, err)
+
+	old, next, changed, err := l.Reload()
+	assert.NilError(t, err)
+	assert.Assert(t, !changed)
+	assert.Assert(t, reflect.DeepEqual(first, old))
+
+	l.MyDB.Port = optional.SomeUint16(9000)
+	old, next, changed, err = l.Reload()
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+	assert.Equal(t, uint16(DefaultMyDBConfigPort), old.MyDB.Port)
+	assert.Equal(t, uint16(9000), next.MyDB.Port)
+
+	l.MyService.Name.Clear()
+	old, next, changed, err = l.Reload()
+	assert.ErrorContains(t, err, "missing required field: Name")
+	assert.Assert(t, !changed)
+	assert.Equal(t, uint16(9000), l.Previous().MyDB.Port)
+}
+
+func TestMyAppConfigLoaderFailedUpdateKeepsPrevious(t *testing.T) {
+	l := testLoader(t)
+	good, err := l.Update()
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name    string
+		env     string
+		value   string
+		wantErr string
+	}{
+		// MyService resolves fine before MyDB fails.
+		{
+			name: "late field", env: "MY_APP_MY_DB_PORT", value: "not-a-port",
+			wantErr: "failed to load env var MY_APP_MY_DB_PORT",
+		},
+		{
+			name: "early field", env: "MY_APP_MY_SERVICE_NODE", value: "-1",
+			wantErr: "failed to load env var MY_APP_MY_SERVICE_NODE",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("MY_APP_MY_DB_ADDRESS", "changed.internal")
+			t.Setenv(tc.env, tc.value)
+
+			_, err := l.Update()
+			assert.ErrorContains(t, err, tc.wantErr)
+			_, _, _, err = l.Reload()
+			assert.ErrorContains(t, err, tc.wantErr)
+
+			assert.Assert(t, reflect.DeepEqual(good, l.Previous()))
+			assert.Equal(t, optional.SomeUint32(1), l.MyService.NodeID)
+			assert.Assert(t, l.MyDB.Address.IsNone())
+		})
+	}
+}
+
+func TestMyAppConfigLoaderWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	assert.NilError(t, os.WriteFile(path, []byte("[MyDB]\nPort = 5432\n"), 0600))
+
+	l := testLoader(t)
+	err := l.Watch(context.Background(), func(MyAppConfig, error) {})
+	assert.ErrorContains(t, err, "no config file or .env file was given")
+
+	l.ConfigFile = file.SomeFile(path)
+	_, err = l.Update()
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type result struct {
+		config MyAppConfig
+		err    error
+	}
+	results := make(chan result, 10)
+	err = l.Watch(ctx, func(c MyAppConfig, err error) { results <- result{c, err} })
+	assert.NilError(t, err)
+
+	next := func() result {
+		t.Helper()
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the watch callback")
+		}
+		return result{}
+	}
+
+	assert.NilError(t, os.WriteFile(path, []byte("[MyDB]\nPort = 6543\n"), 0600))
+	r := next()
+	assert.NilError(t, r.err)
+	assert.Equal(t, uint16(6543), r.config.MyDB.Port)
+	assert.Equal(t, uint16(6543), l.Previous().MyDB.Port)
+
+	assert.NilError(t, os.WriteFile(path, []byte("[MyDB]\nPort = \"not a port\"\n"), 0600))
+	r = next()
+	assert.ErrorContains(t, r.err, "failed to decode config file")
+	assert.Equal(t, uint16(6543), r.config.MyDB.Port)
+}
+
+// TestMyAppConfigLoaderConcurrent is meant to be run with -race. It reloads the config while other goroutines read it.
+func TestMyAppConfigLoaderConcurrent(t *testing.T) {
+	l := testLoader(t)
+	_, err := l.Update()
+	assert.NilError(t, err)
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				c := l.Previous()
+				assert.Check(t, c.MyService.Name == "test")
+				assert.Check(t, l.MyService.Previous().Name == "" || l.MyService.Previous().Name == "test")
+			}
+		}()
+	}
+
+	for range 20 {
+		_, err := l.Update()
+		assert.NilError(t, err)
+		_, _, _, err = l.Reload()
+		assert.NilError(t, err)
+		_, err = l.MyService.Update()
+		assert.NilError(t, err)
+	}
+	wg.Wait()
+}
+
+func TestMyAppConfigLoaderUpdateContext(t *testing.T) {
+	l := testLoader(t)
+	good, err := l.Update()
+	assert.NilError(t, err)
+
+	// Reading a FIFO blocks until something opens it for writing, which stands in for a hung network mount.
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	assert.NilError(t, syscall.Mkfifo(path, 0600))
+	t.Cleanup(func() {
+		// Unblock the abandoned read so its goroutine can exit.
+		w, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err == nil {
+			w.Close()
+		}
+	})
+	l.ConfigFile = file.SomeFile(path)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err = l.UpdateContext(ctx)
+	assert.Assert(t, errors.Is(err, context.Canceled), "got %v", err)
+	assert.Assert(t, reflect.DeepEqual(good, l.Previous()))
+}
+
+func TestMyAppConfigLoaderDiff(t *testing.T) {
+	l := testLoader(t)
+	_, err := l.Update()
+	assert.NilError(t, err)
+
+	old, next, _, err := l.Reload()
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(l.Diff(old, next)))
+
+	l.MyService.ServerConfig.BindPort = optional.SomeUint16(8443)
+	old, next, _, err = l.Reload()
+	assert.NilError(t, err)
+	want := []ezconf.FieldChange{
+		{Path: "MyService.ServerConfig.Port", Old: "443", New: "8443"},
+		{Path: "MyService.ServerConfig.RemoteAddress", Old: "https://127.0.0.1", New: "https://127.0.0.1:8443"},
+	}
+	assert.DeepEqual(t, want, l.Diff(old, next))
+
+	// Keys and secrets are reported as changed without their values.
+	path := filepath.Join(t.TempDir(), "rotated.txt")
+	assert.NilError(t, os.WriteFile(path, []byte("hunter3"), 0600))
+	l.MyService.SecretKey = file.SomeSecretFile(path)
+	l.MyService.SessionKey = ezconf.SomeHexBytes([]byte("topsecret"))
+	old, next, _, err = l.Reload()
+	assert.NilError(t, err)
+	want = []ezconf.FieldChange{{Path: "MyService.SecretKey"}, {Path: "MyService.SessionKey"}}
+	changes := l.Diff(old, next)
+	assert.DeepEqual(t, want, changes)
+	assert.Equal(t, "[MyService.SecretKey changed MyService.SessionKey changed]", fmt.Sprint(changes))
+}
+
+func TestMyAppConfigLoaderCompareToFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "myapp.toml")
+	assert.NilError(t, os.WriteFile(path, []byte("[MyDB]\nPort = 5433\n"), 0600))
+	reference := filepath.Join(dir, "reference.toml")
+	assert.NilError(t, os.WriteFile(reference, []byte("[MyDB]\nPort = 5432\nAddress = \"db.internal\"\n"), 0600))
+
+	l := testLoader(t)
+	l.ConfigFile = file.SomeFile(path)
+	changes, err := l.CompareToFile(reference, nil)
+	assert.NilError(t, err)
+	want := []ezconf.FieldChange{
+		{Path: "MyDB.Address", Old: "127.0.0.1", New: "db.internal"},
+		{Path: "MyDB.Port", Old: "5433", New: "5432"},
+	}
+	assert.DeepEqual(t, want, changes)
+
+	changes, err = l.CompareToFile(reference, []string{"MyDB.Address"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, want[1:], changes)
+
+	changes, err = l.CompareToFile(reference, []string{"MyDB"})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(changes))
+
+	// The loader's own config file is compared with itself, so nothing differs.
+	changes, err = l.CompareToFile(path, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(changes))
+
+	_, err = l.CompareToFile(filepath.Join(dir, "missing.toml"), nil)
+	assert.ErrorContains(t, err, "failed to load reference config")
+}
+
+func TestMyAppConfigLoaderLint(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []ezconf.LintIssue
+	}{
+		{name: "clean", data: "[MyService]\nName = \"app\"\nnode = 1\n"},
+		{
+			name: "unknown and deprecated keys",
+			data: "[MyService]\nNmae = \"app\"\n[MyDB]\nHost = \"db.internal\"\n",
+			want: []ezconf.LintIssue{
+				{Path: "MyService.Nmae", Message: "unknown key"},
+				{Path: "MyDB.Host", Message: "deprecated name, use MyDB.Address instead"},
+				{Path: "MyServiceConfig.Name", Message: "required field is not set by any source"},
+				{Path: "MyServiceConfig.NodeID", Message: "required field is not set by any source"},
+			},
+		},
+		{
+			name: "bad value",
+			data: "[MyService]\nName = \"app\"\nnode = 1\nLogFormat = \"xml\"\n",
+			want: []ezconf.LintIssue{
+				{Path: "MyServiceConfig.LogFormat", Message: `"xml" must be one of [json, text, logfmt]`},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "myapp.toml")
+			assert.NilError(t, os.WriteFile(path, []byte(tc.data), 0600))
+
+			l := testLoader(t)
+			l.MyService.Name = optional.NoStr()
+			l.MyService.NodeID = optional.NoUint32()
+			assert.DeepEqual(t, tc.want, l.Lint(path))
+		})
+	}
+
+	// A file which does not decode is a single issue.
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	assert.NilError(t, os.WriteFile(path, []byte("[MyDB]\nPort = \"high\"\n"), 0600))
+	issues := testLoader(t).Lint(path)
+	assert.Equal(t, 1, len(issues))
+	assert.Equal(t, "", issues[0].Path)
+	assert.ErrorContains(t, errors.New(issues[0].Message), "failed to decode config file")
+}
+
+func TestMyAppConfigLoaderStagedUpdate(t *testing.T) {
+	l := testLoader(t)
+	_, err := l.Update()
+	assert.NilError(t, err)
+
+	err = l.Promote()
+	assert.ErrorIs(t, err, ErrNoStagedConfig)
+	err = l.Rollback()
+	assert.ErrorIs(t, err, ErrNoRollbackConfig)
+
+	l.MyDB.Port = optional.SomeUint16(9000)
+	pending, err := l.StageUpdate()
+	assert.NilError(t, err)
+	assert.Equal(t, uint16(9000), pending.MyDB.Port)
+	assert.Equal(t, uint16(DefaultMyDBConfigPort), l.Previous().MyDB.Port)
+
+	// Rolling back a staged config just discards it.
+	err = l.Rollback()
+	assert.NilError(t, err)
+	err = l.Promote()
+	assert.ErrorIs(t, err, ErrNoStagedConfig)
+	assert.Equal(t, uint16(DefaultMyDBConfigPort), l.Previous().MyDB.Port)
+
+	_, err = l.StageUpdate()
+	assert.NilError(t, err)
+	assert.Equal(t, ezconf.SourceDefault, l.Sources()["MyDB.Port"])
+	err = l.Promote()
+	assert.NilError(t, err)
+	assert.Equal(t, uint16(9000), l.Previous().MyDB.Port)
+	assert.Equal(t, ezconf.SourceLoader, l.Sources()["MyDB.Port"])
+
+	err = l.Rollback()
+	assert.NilError(t, err)
+	assert.Equal(t, uint16(DefaultMyDBConfigPort), l.Previous().MyDB.Port)
+	assert.Equal(t, ezconf.SourceDefault, l.Sources()["MyDB.Port"])
+	err = l.Rollback()
+	assert.ErrorIs(t, err, ErrNoRollbackConfig)
+
+	l.MyService.Name.Clear()
+	_, err = l.StageUpdate()
+	assert.ErrorContains(t, err, "missing required field: Name")
+	err = l.Promote()
+	assert.ErrorIs(t, err, ErrNoStagedConfig)
+}
+
+func TestMyAppConfigLoaderMarshalJSON(t *testing.T) {
+	l := testLoader(t)
+	_, err := l.Update()
+	assert.NilError(t, err)
+
+	data, err := json.Marshal(l)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(data), "hunter2"))
+	assert.Assert(t, strings.Contains(string(data), `"SecretKey":"***REDACTED***"`))
+	assert.Assert(t, strings.Contains(string(data), `"RemoteAddress":"https://127.0.0.1"`))
+
+	// Marshaling redacts a copy, so the loaded secret itself is untouched.
+	assert.Equal(t, "hunter2", l.Previous().MyService.SecretKey.MustGet())
+}
+
+func TestMyAppConfigRedacted(t *testing.T) {
+	l := testLoader(t)
+	l.MyDB.Password = optional.SomeSecret("db-hunter2")
+	l.MyService.Salt = ezconf.SomeBytes([]byte("hunter2-salt"))
+	l.Backends = []BackendConfigLoader{{Address: optional.SomeStr("10.0.0.2")}}
+	c, err := l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, "hunter2", c.MyService.SecretKey.MustGet())
+
+	for _, verb := range []string{"%v", "%+v", "%#v"} {
+		out := fmt.Sprintf(verb, c)
+		assert.Assert(t, !strings.Contains(out, "hunter2"), "%s leaked the secret: %s", verb, out)
+	}
+
+	// Secrets nested in a logged struct are marshaled rather than formatted, so they are redacted by ezconf.Secret.
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("loaded", "conf", c)
+	assert.Assert(t, !strings.Contains(buf.String(), "hunter2"), buf.String())
+	assert.Assert(t, strings.Contains(buf.String(), `"Password":"***REDACTED***"`), buf.String())
+	assert.Assert(t, strings.Contains(buf.String(), `"Salt":"***REDACTED***","SessionKey":null`), buf.String())
+
+	// The loader serves the same redacted JSON, and the redaction does not get in the way of decoding a config.
+	data, err := json.Marshal(&l)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(data), "hunter2"), string(data))
+	assert.Assert(t, !strings.Contains(string(data), "aHVudGVyMi1zYWx0"), string(data)) // the salt as base64
+	var decoded MyServiceConfig
+	assert.NilError(t, json.Unmarshal([]byte(`{"Salt":"c2FsdA=="}`), &decoded))
+	assert.DeepEqual(t, []byte("salt"), decoded.Salt)
+
+	out := c.Redacted()
+	assert.Assert(t, !strings.Contains(out, "hunter2"), out)
+	for _, line := range []string{
+		`MyService.Name: "test"`,
+		"MyService.SecretKey: [REDACTED]",
+		"MyService.Salt: [REDACTED]",
+		"MyService.SessionKey: none",
+		`MyService.ServerConfig.RemoteAddress: "https://127.0.0.1"`,
+		"MyService.ServerConfig.TlsEnabled: false",
+		"MyDB.Port: 8080",
+		"MyDB.QueryTimeout: 5s",
+		"MyDB.Password: [REDACTED]",
+		`Backends[0].Address: "10.0.0.2"`,
+	} {
+		assert.Assert(t, strings.Contains(out, line+"\n"), "missing %q in:\n%s", line, out)
+	}
+
+	c.MyDB.Password = ezconf.NoSecret()
+	assert.Assert(t, strings.Contains(c.Redacted(), "MyDB.Password: none\n"))
+}
+
+func TestMyAppConfigLoaderInto(t *testing.T) {
+	l := testLoader(t)
+	l.MyService.Name.Clear()
+	l.MyDB.Address = optional.SomeStr("10.0.0.1")
+
+	cfg := MyAppConfig{}
+	cfg.MyService.Name = "preset"
+	cfg.MyService.Priority = 7
+	cfg.MyDB.Port = 1234
+
+	err := l.Into(&cfg)
+	assert.NilError(t, err)
+	assert.Equal(t, "preset", cfg.MyService.Name)
+	assert.Equal(t, uint16(7), cfg.MyService.Priority)
+	assert.Equal(t, "hunter2", cfg.MyService.SecretKey.MustGet())
+	assert.Equal(t, "10.0.0.1", cfg.MyDB.Address)
+	assert.Equal(t, uint16(1234), cfg.MyDB.Port)
+
+	// A missing required field leaves the caller's struct untouched.
+	empty := MyAppConfig{}
+	empty.MyDB.Port = 1234
+	err = l.Into(&empty)
+	assert.ErrorContains(t, err, "missing required field: Name")
+	assert.Equal(t, "", empty.MyDB.Address)
+
+	// Every missing field is reported along with invalid values, and a required field which a source set to zero is
+	// invalid rather than missing.
+	l.MyService.NodeID.Clear()
+	l.MyService.LogFormat = optional.SomeStr("xml")
+	err = l.Into(&empty)
+	assert.ErrorContains(t, err, "MyServiceConfig missing required fields: Name, NodeID")
+	assert.ErrorContains(t, err, `"xml" must be one of [json, text, logfmt]`)
+
+	l.MyService.Name = optional.SomeStr("test")
+	l.MyService.NodeID = optional.SomeUint32(0)
+	l.MyService.LogFormat.Clear()
+	err = l.Into(&empty)
+	assert.ErrorContains(t, err, "invalid MyServiceConfig.NodeID: required field is 0")
+	assert.Assert(t, !errors.Is(err, &ezconf.MissingRequiredError{}))
+	assert.Equal(t, "", empty.MyService.Name)
+}
+
+func TestMyAppConfigLoaderCompute(t *testing.T) {
+	l := testLoader(t)
+	l.Compute("MyService.Description", func(c *MyAppConfig) error {
+		c.MyService.Description = c.MyService.Name + " backed by " + c.MyDB.Address
+		return nil
+	})
+
+	c, err := l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, "test backed by 127.0.0.1", c.MyService.Description)
+
+	// Computed fields follow their inputs on reload.
+	l.MyDB.Address = optional.SomeStr("10.0.0.1")
+	c, err = l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, "test backed by 10.0.0.1", c.MyService.Description)
+
+	l.Compute("MyService.Description", func(c *MyAppConfig) error {
+		return errors.New("no description for you")
+	})
+	_, err = l.Update()
+	assert.Error(t, err, "failed to compute MyAppConfig field MyService.Description: no description for you")
+}
+
+func TestMyAppConfigLoaderComputedFieldSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myapp.toml")
+	assert.NilError(t, os.WriteFile(path, []byte("[MyDB]\nPort = 5432\n"), 0600))
+
+	tests := []struct {
+		name    string
+		flags   []string
+		env     string
+		loader  optional.Uint16
+		file    bool
+		wantErr string
+	}{
+		{name: "no source"},
+		{
+			name: "loader", loader: optional.SomeUint16(9000),
+			wantErr: "MyAppConfig field MyDB.Port is computed, so it cannot be set by the loader source",
+		},
+		{name: "flag", flags: []string{"-myDBPort", "9001"}, wantErr: "cannot be set by the flag source"},
+		{name: "env", env: "9002", wantErr: "cannot be set by the env source"},
+		{name: "file", file: true, wantErr: "cannot be set by the file source"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("MY_APP_MY_DB_PORT", tc.env)
+			l := testLoader(t)
+			l.Flags = testFlags(t, tc.flags...)
+			l.MyDB.Port = tc.loader
+			if tc.file {
+				l.ConfigFile = file.SomeFile(path)
+			}
+			l.Compute("MyDB.Port", func(c *MyAppConfig) error {
+				c.MyDB.Port = 6000 + c.MyService.Priority
+				return nil
+			})
+
+			c, err := l.Update()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, uint16(6001), c.MyDB.Port)
+
+			// Save leaves the computed field out, so that the file loads again with the same compute function.
+			saved := filepath.Join(t.TempDir(), "saved.toml")
+			assert.NilError(t, l.Save(saved))
+			data, err := os.ReadFile(saved)
+			assert.NilError(t, err)
+			assert.Assert(t, !strings.Contains(string(data), "Port"), string(data))
+
+			l.ConfigFile = file.SomeFile(saved)
+			c, err = l.Update()
+			assert.NilError(t, err)
+			assert.Equal(t, uint16(6001), c.MyDB.Port)
+		})
+	}
+}
+
+// hostNodeID derives a node id from the hostname, so that every host gets its own without configuring one.
+func hostNodeID() (string, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	h := fnv.New32a()
+	h.Write([]byte(host))
+	return strconv.FormatUint(uint64(h.Sum32()), 10), nil
+}
+
+func TestMyAppConfigLoaderTemplate(t *testing.T) {
+	t.Setenv("MY_APP_TEST_ZONE", "eu-1")
+	tests := []struct {
+		name        string
+		description string
+		want        string
+		wantErr     string
+	}{
+		{name: "plain", description: "just text", want: "just text"},
+		{
+			name:        "config and env",
+			description: `{{.Config.MyService.Name}}/{{.Config.MyService.NodeID}} in {{env "MY_APP_TEST_ZONE"}}`,
+			want:        "test/1 in eu-1",
+		},
+		{name: "now", description: `{{.Now.Year | printf "%d" | len}}`, want: "4"},
+		{
+			name: "bad field", description: "{{.Config.MyService.Nope}}",
+			wantErr: "failed to execute template of config field MyService.Description",
+		},
+		{
+			name: "bad syntax", description: "{{.Config",
+			wantErr: "failed to parse template of config field MyService.Description",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := testLoader(t)
+			l.MyService.Description = optional.SomeStr(tc.description)
+
+			c, err := l.Update()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, c.MyService.Description)
+
+			// Save keeps the template, so the saved file is executed again when it is loaded.
+			path := filepath.Join(t.TempDir(), "myapp.toml")
+			assert.NilError(t, l.Save(path))
+			data, err := os.ReadFile(path)
+			assert.NilError(t, err)
+			assert.Assert(t, strings.Contains(string(data), strings.ReplaceAll(tc.description, `"`, `\"`)), string(data))
+		})
+	}
+}
+
+func TestMyAppConfigLoaderSourceHooks(t *testing.T) {
+	errHook := errors.New("hook failed")
+	all := []string{
+		"before default", "after default <nil>", "before file", "after file <nil>", "before keyed", "after keyed <nil>",
+		"before env", "after env <nil>", "before flag", "after flag <nil>",
+	}
+
+	tests := []struct {
+		name       string
+		failBefore string
+		failAfter  string
+		env        string
+		want       []string
+		wantErr    string
+	}{
+		{name: "precedence order", want: all},
+		{
+			name: "before hook error", failBefore: ezconf.SourceKeyed, want: all[:5],
+			wantErr: "hook before the keyed source failed: hook failed",
+		},
+		{
+			name: "after hook error", failAfter: ezconf.SourceFile, want: all[:4],
+			wantErr: "hook after the file source failed: hook failed",
+		},
+		{
+			name: "source error", env: "not-a-port", want: append(slices.Clone(all[:7]), "after env failed"),
+			wantErr: "failed to load env var MY_APP_MY_DB_PORT",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("MY_APP_MY_DB_PORT", tc.env)
+			var got []string
+			l := testLoader(t)
+			l.BeforeSource(func(source string) error {
+				got = append(got, "before "+source)
+				if source == tc.failBefore {
+					return errHook
+				}
+				return nil
+			})
+			l.AfterSource(func(source string, err error) error {
+				result := "<nil>"
+				if err != nil {
+					result = "failed"
+				}
+				got = append(got, "after "+source+" "+result)
+				if source == tc.failAfter {
+					return errHook
+				}
+				return nil
+			})
+
+			_, err := l.Update()
+			assert.DeepEqual(t, tc.want, got)
+			if tc.wantErr == "" {
+				assert.NilError(t, err)
+				assert.Equal(t, "test", l.Previous().MyService.Name)
+				return
+			}
+			assert.ErrorContains(t, err, tc.wantErr)
+			assert.Equal(t, "", l.Previous().MyService.Name)
+		})
+	}
+}
+
+func TestMyAppConfigLoaderDefaultFunc(t *testing.T) {
+	id, err := hostNodeID()
+	assert.NilError(t, err)
+	want, err := strconv.ParseUint(id, 10, 32)
+	assert.NilError(t, err)
+
+	l := testLoader(t)
+	l.MyService.NodeID.Clear()
+	l.DefaultFunc("MyService.NodeID", hostNodeID)
+
+	c, err := l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, uint32(want), c.MyService.NodeID)
+	assert.Equal(t, ezconf.SourceDefault, l.Sources()["MyService.NodeID"])
+
+	got, err := l.GetMyServiceNodeID()
+	assert.NilError(t, err)
+	assert.Equal(t, uint32(want), got)
+
+	// Every other source overrides the default.
+	t.Setenv("MY_APP_MY_SERVICE_NODE", "42")
+	c, err = l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, uint32(42), c.MyService.NodeID)
+
+	// The function replaces the compiled default, and is not applied by Into.
+	l.DefaultFunc("MyDB.Port", func() (string, error) { return "9000", nil })
+	c, err = l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, uint16(9000), c.MyDB.Port)
+
+	var into MyAppConfig
+	assert.NilError(t, l.Into(&into))
+	assert.Equal(t, uint16(0), into.MyDB.Port)
+
+	// Values are checked like any other source.
+	l.DefaultFunc("MyDB.Port", func() (string, error) { return "80", nil })
+	_, err = l.Update()
+	assert.ErrorContains(t, err, "invalid MyDBConfig.Port")
+
+	l.DefaultFunc("MyDB.Port", func() (string, error) { return "", errors.New("no port for you") })
+	_, err = l.Update()
+	assert.Error(t, err, "failed to compute default of MyAppConfig field MyDB.Port: no port for you")
+
+	l.DefaultFunc("MyDB.Port", func() (string, error) { return "not-a-port", nil })
+	_, err = l.Update()
+	assert.ErrorContains(t, err, "failed to compute default of MyAppConfig field MyDB.Port")
+
+	l = testLoader(t)
+	l.DefaultFunc("MyDB.Nope", func() (string, error) { return "", nil })
+	_, err = l.Update()
+	assert.Error(t, err, "failed to compute default of MyAppConfig field MyDB.Nope: no such field")
+}
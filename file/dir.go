@@ -0,0 +1,50 @@
+package file
+
+import (
+	"os"
+
+	"github.com/brnsampson/optional"
+)
+
+// Dir wraps an optional path to a directory, such as the directory relative default paths are joined with. Flags of
+// this type complete directory names rather than file names.
+type Dir struct {
+	optional.Str
+}
+
+func SomeDir(path string) Dir {
+	return Dir{optional.SomeStr(path)}
+}
+
+func NoDir() Dir {
+	return Dir{optional.NoStr()}
+}
+
+// Override the Type() method from the inner Str. Part of the flag.Value interface.
+func (o Dir) Type() string {
+	return "Dir"
+}
+
+// Override the String() method from the inner Str just so we return the correct None[Type] string.
+func (o Dir) String() string {
+	if o.IsNone() {
+		return "None[Dir]"
+	}
+
+	tmp, ok := o.Get()
+	if !ok {
+		return "Error[Dir]"
+	}
+	return tmp
+}
+
+// Exists reports whether the path is set and names an existing directory.
+func (o Dir) Exists() bool {
+	path, ok := o.Get()
+	if !ok {
+		return false
+	}
+
+	stat, err := os.Stat(path)
+	return err == nil && stat.IsDir()
+}
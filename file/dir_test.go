@@ -0,0 +1,35 @@
+package file_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/brnsampson/ezconf/file"
+	"gotest.tools/v3/assert"
+)
+
+func TestDirType(t *testing.T) {
+	o := file.SomeDir("../testing")
+	assert.Equal(t, reflect.TypeOf(o).Name(), o.Type())
+	assert.Equal(t, "../testing", o.String())
+	assert.Equal(t, "None[Dir]", file.NoDir().String())
+}
+
+func TestDirExists(t *testing.T) {
+	tests := []struct {
+		name string
+		dir  file.Dir
+		want bool
+	}{
+		{name: "directory", dir: file.SomeDir("../testing"), want: true},
+		{name: "file", dir: file.SomeDir("../testing/rsa/key.pem")},
+		{name: "missing", dir: file.SomeDir("../testing/nothing")},
+		{name: "none", dir: file.NoDir()},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.dir.Exists())
+		})
+	}
+}
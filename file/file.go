@@ -4,6 +4,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/brnsampson/optional"
 )
@@ -36,6 +37,25 @@ func NoFile() File {
 	return File{optional.NoStr()}
 }
 
+// FirstExisting returns a File set to the first of the given paths that exists, or None if none of them do. This is
+// useful for files which may live in one of several conventional locations, such as cert bundles across distros. An
+// explicitly configured path should still be preferred, e.g. optional.Or(userFile, file.FirstExisting(paths...)).
+func FirstExisting(paths ...string) File {
+	for _, path := range paths {
+		f := SomeFile(path)
+		if f.Exists() {
+			return f
+		}
+	}
+	return NoFile()
+}
+
+// Cascade is the same as FirstExisting, but accepts the candidate paths as a single '|' separated string. This is the
+// format used for cascading default tags, e.g. `default:"tls/cert.pem|/etc/ssl/cert.pem"`.
+func Cascade(paths string) File {
+	return FirstExisting(strings.Split(paths, "|")...)
+}
+
 // Overrides Option.Match to account for relative paths potentially being different strings but representing the same file.
 func (o File) Match(probe string) bool {
 	if o.IsNone() {
@@ -203,6 +223,25 @@ func (o File) ReadFile() (contents optional.Str, ok bool) {
 	return optional.SomeStr(string(data)), true
 }
 
+// Read is the same as ReadFile, but tells an unset path apart from a file which could not be read. It returns
+// ErrFileNone if the path is not set and the error from os.ReadFile, which names the path, if the file cannot be read.
+func (o File) Read() (contents optional.Str, err error) {
+	path, ok := o.Get()
+	if !ok {
+		return optional.NoStr(), ErrFileNone
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return optional.NoStr(), err
+	}
+
+	if len(data) == 0 {
+		return optional.NoStr(), nil
+	}
+	return optional.SomeStr(string(data)), nil
+}
+
 func (o File) WriteFile(data []byte, perm os.FileMode) (err error) {
 	path, ok := o.Get()
 	if !ok {
@@ -1,6 +1,7 @@
 package file_test
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -122,3 +123,76 @@ func TestFileReadFile(t *testing.T) {
 	assert.Assert(t, !ok)
 	assert.Assert(t, str.IsNone())
 }
+
+func TestFileRead(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	assert.NilError(t, os.WriteFile(empty, nil, 0600))
+
+	tests := []struct {
+		name     string
+		file     file.File
+		wantSome bool
+		wantErr  error
+	}{
+		{name: "exists", file: file.SomeFile("../testing/rsa/cert.pem"), wantSome: true},
+		{name: "empty", file: file.SomeFile(empty)},
+		{name: "unset", file: file.NoFile(), wantErr: file.ErrFileNone},
+		{name: "missing", file: file.SomeFile("does/not/exist.txt"), wantErr: fs.ErrNotExist},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			str, err := tc.file.Read()
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Assert(t, str.IsNone())
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantSome, str.IsSome())
+		})
+	}
+}
+
+func TestFileFirstExisting(t *testing.T) {
+	path := "../testing/rsa/cert.pem"
+	badpath := "does/not/exist.txt"
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{name: "first exists", paths: []string{path, badpath}, want: path},
+		{name: "second exists", paths: []string{badpath, path}, want: path},
+		{name: "none exist", paths: []string{badpath}},
+		{name: "no candidates"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := file.FirstExisting(tc.paths...)
+			if tc.want == "" {
+				assert.Assert(t, o.IsNone())
+				return
+			}
+
+			ret, ok := o.Get()
+			assert.Assert(t, ok)
+			assert.Equal(t, tc.want, ret)
+		})
+	}
+}
+
+func TestFileCascade(t *testing.T) {
+	path := "../testing/rsa/cert.pem"
+
+	o := file.Cascade("does/not/exist.txt|" + path)
+	ret, ok := o.Get()
+	assert.Assert(t, ok)
+	assert.Equal(t, path, ret)
+
+	o = file.Cascade("does/not/exist.txt|also/not/here.txt")
+	assert.Assert(t, o.IsNone())
+}
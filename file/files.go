@@ -0,0 +1,64 @@
+package file
+
+import "strings"
+
+// Files is an optional list of file paths, e.g. config files which are loaded in order. As a flag it can be repeated,
+// and each value may hold several comma separated paths, so -config=base.toml -config=prod.toml and
+// -config=base.toml,prod.toml are the same.
+type Files struct {
+	paths []string
+	some  bool
+}
+
+func SomeFiles(paths ...string) Files {
+	return Files{append([]string{}, paths...), true}
+}
+
+func NoFiles() Files {
+	return Files{}
+}
+
+func (o Files) IsSome() bool {
+	return o.some
+}
+
+func (o Files) IsNone() bool {
+	return !o.some
+}
+
+// Get returns a copy of the paths and whether any were set.
+func (o Files) Get() ([]string, bool) {
+	if !o.some {
+		return nil, false
+	}
+	return append([]string{}, o.paths...), true
+}
+
+func (o *Files) Clear() {
+	o.paths = nil
+	o.some = false
+}
+
+// Part of the flag.Value interface.
+func (o Files) Type() string {
+	return "Files"
+}
+
+func (o Files) String() string {
+	if !o.some {
+		return "None[Files]"
+	}
+	return strings.Join(o.paths, ",")
+}
+
+// Set appends the comma separated paths in str. Empty paths are skipped. Part of the flag.Value interface.
+func (o *Files) Set(str string) error {
+	for _, path := range strings.Split(str, ",") {
+		path = strings.TrimSpace(path)
+		if path != "" {
+			o.paths = append(o.paths, path)
+		}
+	}
+	o.some = true
+	return nil
+}
@@ -0,0 +1,46 @@
+package file_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/brnsampson/ezconf/file"
+	"gotest.tools/v3/assert"
+)
+
+func TestFilesType(t *testing.T) {
+	o := file.SomeFiles("base.toml", "prod.toml")
+	assert.Equal(t, reflect.TypeOf(o).Name(), o.Type())
+	assert.Equal(t, "base.toml,prod.toml", o.String())
+	assert.Equal(t, "None[Files]", file.NoFiles().String())
+}
+
+func TestFilesSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []string
+	}{
+		{name: "single", values: []string{"base.toml"}, want: []string{"base.toml"}},
+		{name: "repeated", values: []string{"base.toml", "prod.toml"}, want: []string{"base.toml", "prod.toml"}},
+		{name: "comma separated", values: []string{"base.toml, prod.toml"}, want: []string{"base.toml", "prod.toml"}},
+		{
+			name: "mixed", values: []string{"base.toml,prod.toml", "local.toml"},
+			want: []string{"base.toml", "prod.toml", "local.toml"},
+		},
+		{name: "empty elements", values: []string{"base.toml,,"}, want: []string{"base.toml"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := file.NoFiles()
+			for _, v := range tc.values {
+				assert.NilError(t, o.Set(v))
+			}
+
+			paths, ok := o.Get()
+			assert.Assert(t, ok)
+			assert.DeepEqual(t, tc.want, paths)
+		})
+	}
+}
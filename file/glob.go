@@ -0,0 +1,50 @@
+package file
+
+import (
+	"path/filepath"
+
+	"github.com/brnsampson/optional"
+)
+
+// Glob wraps an optional glob pattern such as plugins/*.so, which is expanded into the list of matching paths when the
+// config is loaded. This is handy for discovering modular components, e.g. a `Plugins []string` config field.
+type Glob struct {
+	optional.Str
+}
+
+func SomeGlob(pattern string) Glob {
+	return Glob{optional.SomeStr(pattern)}
+}
+
+func NoGlob() Glob {
+	return Glob{optional.NoStr()}
+}
+
+// Override the Type() method from the inner Str. Part of the flag.Value interface.
+func (o Glob) Type() string {
+	return "Glob"
+}
+
+// Override the String() method from the inner Str just so we return the correct None[Type] string.
+func (o Glob) String() string {
+	if o.IsNone() {
+		return "None[Glob]"
+	}
+
+	tmp, ok := o.Get()
+	if !ok {
+		return "Error[Glob]"
+	}
+	return tmp
+}
+
+// Expand returns the paths matching the pattern in lexical order, as filepath.Glob does. A None Glob expands to no
+// paths, while a malformed pattern returns filepath.ErrBadPattern.
+func (o Glob) Expand() ([]string, error) {
+	pattern, ok := o.Get()
+	if !ok {
+		return nil, nil
+	}
+
+	return filepath.Glob(pattern)
+}
@@ -0,0 +1,48 @@
+package file_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/brnsampson/ezconf/file"
+	"gotest.tools/v3/assert"
+)
+
+func TestGlobType(t *testing.T) {
+	o := file.SomeGlob("../testing/*/key.pem")
+	assert.Equal(t, reflect.TypeOf(o).Name(), o.Type())
+	assert.Equal(t, "../testing/*/key.pem", o.String())
+	assert.Equal(t, "None[Glob]", file.NoGlob().String())
+}
+
+func TestGlobExpand(t *testing.T) {
+	tests := []struct {
+		name    string
+		glob    file.Glob
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "matches",
+			glob: file.SomeGlob("../testing/*/key.pem"),
+			want: []string{"../testing/ecdsa/key.pem", "../testing/ed25519/key.pem", "../testing/rsa/key.pem"},
+		},
+		{name: "no matches", glob: file.SomeGlob("../testing/*/nothing.pem")},
+		{name: "none", glob: file.NoGlob()},
+		{name: "bad pattern", glob: file.SomeGlob("../testing/[/key.pem"), wantErr: filepath.ErrBadPattern},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			paths, err := tc.glob.Expand()
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.want, paths)
+		})
+	}
+}
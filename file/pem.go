@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 
 	"github.com/brnsampson/optional"
+	"github.com/youmark/pkcs8"
 )
 
 // Verifying and setting file permissions for public/private keys and certificates use the following file mode masks.
@@ -26,6 +27,10 @@ const (
 	PubKeyFilePermsMask fs.FileMode = 0133
 )
 
+var (
+	ErrIncorrectPassphrase = fileOptionError("could not decrypt private key, the passphrase may be incorrect")
+)
+
 type pemFile struct {
 	File
 	setPerms     fs.FileMode
@@ -102,6 +107,10 @@ func (o pemFile) ReadBlocks() (blocks []*pem.Block, err error) {
 		return
 	}
 
+	return decodeBlocks(encoded), nil
+}
+
+func decodeBlocks(encoded []byte) (blocks []*pem.Block) {
 	var block *pem.Block
 	for {
 		block, encoded = pem.Decode(encoded)
@@ -341,14 +350,35 @@ func (o PrivateKey) String() string {
 // *ecdsa.PrivateKey, ed25519.PrivateKey (Note: that is not a pointer), or *ecdh.PrivateKey, depending on the contents of
 // the file.
 func (o PrivateKey) ReadPrivateKey() (key any, err error) {
+	return o.ReadPrivateKeyWithPassphrase(optional.NoSecret())
+}
+
+// ReadPrivateKeyWithPassphrase is the same as ReadPrivateKey, but also decrypts "ENCRYPTED PRIVATE KEY" (PKCS#8) blocks
+// using the passphrase. Unencrypted keys are read as normal, so this is safe to use whenever a passphrase may be set.
+func (o PrivateKey) ReadPrivateKeyWithPassphrase(passphrase optional.Secret) (key any, err error) {
 	blocks, err := o.ReadBlocks()
 	if err != nil {
 		return
 	}
 
+	return parsePrivateKey(blocks, passphrase, o.String())
+}
+
+// parsePrivateKey returns the first private key in blocks. name identifies where the blocks came from in errors.
+func parsePrivateKey(blocks []*pem.Block, passphrase optional.Secret, name string) (key any, err error) {
 	var tmp any
 	for _, block := range blocks {
 		switch block.Type {
+		case "ENCRYPTED PRIVATE KEY":
+			pass, ok := passphrase.Get()
+			if !ok {
+				return key, fmt.Errorf("private key %s is encrypted, but no passphrase was given", name)
+			}
+
+			tmp, err = pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(pass))
+			if err != nil {
+				return key, fmt.Errorf("%w: %s: %w", ErrIncorrectPassphrase, name, err)
+			}
 		case "PRIVATE KEY":
 			tmp, err = x509.ParsePKCS8PrivateKey(block.Bytes)
 		case "RSA PRIVATE KEY":
@@ -391,6 +421,52 @@ func (o PrivateKey) ReadCert(in Cert) (cert tls.Certificate, err error) {
 	return tls.LoadX509KeyPair(certFile, keyFile)
 }
 
+// ReadCertWithPassphrase is the same as ReadCert, but decrypts the private key with the passphrase first if it is an
+// encrypted PKCS#8 key. Unencrypted keys load the same as with ReadCert, and a None passphrase is fine for those.
+func (o PrivateKey) ReadCertWithPassphrase(in Cert, passphrase optional.Secret) (cert tls.Certificate, err error) {
+	key, err := o.ReadPrivateKeyWithPassphrase(passphrase)
+	if err != nil {
+		return
+	}
+
+	blocks, err := in.ReadBlocks()
+	if err != nil {
+		return
+	}
+
+	return keyPair(blocks, key)
+}
+
+// X509KeyPair is the same as ReadCertWithPassphrase, but reads the certificate chain and private key from PEM encoded
+// bytes instead of files, e.g. when they are passed inline through an env var.
+func X509KeyPair(certPEM, keyPEM []byte, passphrase optional.Secret) (cert tls.Certificate, err error) {
+	key, err := parsePrivateKey(decodeBlocks(keyPEM), passphrase, "<inline>")
+	if err != nil {
+		return
+	}
+	if key == nil {
+		return cert, fmt.Errorf("no private key found in inline PEM")
+	}
+
+	return keyPair(decodeBlocks(certPEM), key)
+}
+
+// keyPair builds a tls.Certificate from the certificate blocks and a parsed private key.
+func keyPair(blocks []*pem.Block, key any) (cert tls.Certificate, err error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return
+	}
+
+	var certPEM []byte
+	for _, block := range blocks {
+		certPEM = append(certPEM, pem.EncodeToMemory(block)...)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
 // WritePrivateKey will accept any of an *rsa.PrivateKey, *dsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey (Note:
 // a pointer), or *ecdh.PrivateKey. The key will be encoded and written to the path the PrivateKey option is set to
 // with file permissions set appropriately.
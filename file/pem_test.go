@@ -7,12 +7,15 @@ import (
 	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/pem"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/brnsampson/ezconf/file"
+	"github.com/brnsampson/optional"
+	"github.com/youmark/pkcs8"
 	"gotest.tools/v3/assert"
 )
 
@@ -631,6 +634,98 @@ func TestPrivateKeyReadCert(t *testing.T) {
 	}
 }
 
+// encryptedPrivateKey writes the rsa testing key to a temporary file as an encrypted PKCS#8 key.
+func encryptedPrivateKey(t *testing.T, passphrase string) file.PrivateKey {
+	t.Helper()
+	plain, err := file.SomePrivateKey("../testing/rsa/key.pem")
+	assert.NilError(t, err)
+	key, err := plain.ReadPrivateKey()
+	assert.NilError(t, err)
+
+	der, err := pkcs8.MarshalPrivateKey(key, []byte(passphrase), nil)
+	assert.NilError(t, err)
+
+	path := filepath.Join(t.TempDir(), "encrypted.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der})
+	assert.NilError(t, os.WriteFile(path, data, file.KeyFilePerms))
+
+	o, err := file.SomePrivateKey(path)
+	assert.NilError(t, err)
+	return o
+}
+
+func TestPrivateKeyReadCertWithPassphrase(t *testing.T) {
+	co, err := file.SomeCert("../testing/rsa/cert.pem")
+	assert.NilError(t, err)
+	ko := encryptedPrivateKey(t, "correct horse")
+
+	tests := []struct {
+		name       string
+		passphrase optional.Secret
+		wantErr    string
+	}{
+		{name: "correct passphrase", passphrase: optional.SomeSecret("correct horse")},
+		{
+			name: "incorrect passphrase", passphrase: optional.SomeSecret("battery staple"),
+			wantErr: file.ErrIncorrectPassphrase.Error(),
+		},
+		{name: "missing passphrase", passphrase: optional.NoSecret(), wantErr: "is encrypted, but no passphrase was given"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			certificate, err := ko.ReadCertWithPassphrase(co, tc.passphrase)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Assert(t, len(certificate.Certificate) == 1)
+		})
+	}
+
+	_, err = ko.ReadPrivateKeyWithPassphrase(optional.SomeSecret("battery staple"))
+	assert.ErrorIs(t, err, file.ErrIncorrectPassphrase)
+}
+
+func TestX509KeyPair(t *testing.T) {
+	certPEM, err := os.ReadFile("../testing/rsa/cert.pem")
+	assert.NilError(t, err)
+	keyPEM, err := os.ReadFile("../testing/rsa/key.pem")
+	assert.NilError(t, err)
+	encrypted, err := os.ReadFile(encryptedPrivateKey(t, "correct horse").String())
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name       string
+		keyPEM     []byte
+		passphrase optional.Secret
+		wantErr    string
+	}{
+		{name: "plain key", keyPEM: keyPEM},
+		{name: "encrypted key", keyPEM: encrypted, passphrase: optional.SomeSecret("correct horse")},
+		{
+			name: "incorrect passphrase", keyPEM: encrypted, passphrase: optional.SomeSecret("battery staple"),
+			wantErr: file.ErrIncorrectPassphrase.Error(),
+		},
+		{name: "no key", keyPEM: certPEM, wantErr: "no private key found in inline PEM"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			certificate, err := file.X509KeyPair(certPEM, tc.keyPEM, tc.passphrase)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Assert(t, len(certificate.Certificate) == 1)
+		})
+	}
+}
+
 func TestPrivateKeyReadPrivateKeyRSA(t *testing.T) {
 	keyPath := "../testing/rsa/key.pem"
 	p, err := file.SomePrivateKey(keyPath)
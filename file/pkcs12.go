@@ -0,0 +1,89 @@
+package file
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/brnsampson/optional"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// PKCS12 wraps an optional path string and provides a method for reading a tls.Certificate from a PKCS#12 (.p12 or
+// .pfx) bundle, which holds a certificate chain and its private key in a single password protected file. Since the
+// bundle contains a private key, it is expected to have the same permissions as a PrivateKey.
+type PKCS12 struct {
+	pemFile
+}
+
+func SomePKCS12(path string) (PKCS12, error) {
+	p, err := somePem(path, KeyFilePerms, KeyFilePermsMask)
+	if err != nil {
+		return PKCS12{}, err
+	}
+	return PKCS12{p}, nil
+}
+
+func NoPKCS12() PKCS12 {
+	return PKCS12{noPem(KeyFilePerms, KeyFilePermsMask)}
+}
+
+func (o PKCS12) Type() string {
+	return "PKCS12"
+}
+
+func (o *PKCS12) Set(str string) error {
+	return o.UnmarshalText([]byte(str))
+}
+
+func (o PKCS12) String() string {
+	if o.IsNone() {
+		return "None[PKCS12]"
+	}
+
+	tmp, ok := o.Get()
+	if !ok {
+		return "Error[PKCS12]"
+	}
+	return tmp
+}
+
+// ReadCert decodes the bundle with password and returns a tls.Certificate holding its certificate chain and private
+// key. ErrIncorrectPassphrase is returned if the password is wrong.
+func (o PKCS12) ReadCert(password optional.Secret) (cert tls.Certificate, err error) {
+	valid, err := o.FilePermsValid()
+	if err != nil {
+		return
+	}
+	if !valid {
+		return cert, fmt.Errorf("PKCS12.ReadCert failed for file %s: Expected file permissions %o", o.String(), o.setPerms)
+	}
+
+	reader, err := o.Open()
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return
+	}
+
+	key, leaf, chain, err := pkcs12.DecodeChain(data, optional.GetOr(password.Str, ""))
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		return cert, fmt.Errorf("%w: %s", ErrIncorrectPassphrase, o.String())
+	}
+	if err != nil {
+		return cert, fmt.Errorf("failed to decode PKCS#12 bundle %s: %w", o.String(), err)
+	}
+
+	// The chain must start with the leaf certificate, followed by any intermediates.
+	blocks := []*pem.Block{{Type: "CERTIFICATE", Bytes: leaf.Raw}}
+	for _, c := range chain {
+		blocks = append(blocks, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+	}
+	return keyPair(blocks, key)
+}
@@ -0,0 +1,81 @@
+package file_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brnsampson/ezconf/file"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// pkcs12Bundle writes the rsa test cert and key to a .p12 file protected by password.
+func pkcs12Bundle(t *testing.T, password string) file.PKCS12 {
+	t.Helper()
+	co, err := file.SomeCert("../testing/rsa/cert.pem")
+	assert.NilError(t, err)
+	certs, err := co.ReadCerts()
+	assert.NilError(t, err)
+	ko, err := file.SomePrivateKey("../testing/rsa/key.pem")
+	assert.NilError(t, err)
+	key, err := ko.ReadPrivateKey()
+	assert.NilError(t, err)
+
+	data, err := pkcs12.Modern.Encode(key, certs[0], certs[1:], password)
+	assert.NilError(t, err)
+
+	path := filepath.Join(t.TempDir(), "bundle.p12")
+	assert.NilError(t, os.WriteFile(path, data, file.KeyFilePerms))
+	o, err := file.SomePKCS12(path)
+	assert.NilError(t, err)
+	return o
+}
+
+func TestPKCS12ReadCert(t *testing.T) {
+	o := pkcs12Bundle(t, "correct horse")
+	assert.Equal(t, "PKCS12", o.Type())
+
+	tests := []struct {
+		name     string
+		password optional.Secret
+		wantErr  string
+	}{
+		{name: "correct password", password: optional.SomeSecret("correct horse")},
+		{
+			name: "incorrect password", password: optional.SomeSecret("battery staple"),
+			wantErr: file.ErrIncorrectPassphrase.Error(),
+		},
+		{name: "missing password", password: optional.NoSecret(), wantErr: file.ErrIncorrectPassphrase.Error()},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cert, err := o.ReadCert(tc.password)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				assert.ErrorIs(t, err, file.ErrIncorrectPassphrase)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, 1, len(cert.Certificate))
+			assert.Assert(t, cert.PrivateKey != nil)
+		})
+	}
+}
+
+func TestPKCS12ReadCertInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.p12")
+	assert.NilError(t, os.WriteFile(path, []byte("not a bundle"), file.KeyFilePerms))
+	o, err := file.SomePKCS12(path)
+	assert.NilError(t, err)
+
+	_, err = o.ReadCert(optional.SomeSecret("correct horse"))
+	assert.ErrorContains(t, err, "failed to decode PKCS#12 bundle")
+
+	assert.NilError(t, os.Chmod(path, 0644))
+	_, err = o.ReadCert(optional.SomeSecret("correct horse"))
+	assert.ErrorContains(t, err, "Expected file permissions 600")
+}
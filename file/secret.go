@@ -12,6 +12,11 @@ const (
 	SecretFilePermsExclude fs.FileMode = 0177
 )
 
+// SecretFile is a File holding a secret such as a key or password. Only the path is stored, which is not sensitive and
+// is printed as usual so that misconfigured paths can be debugged. The contents are only ever read into an
+// optional.Secret, which is redacted by fmt, log, and slog regardless of the formatting verb. It is still marshaled in
+// the clear, e.g. by slog.JSONHandler, so config structs hold it as an ezconf.Secret. Use MustGet or Get on that Secret
+// to get the actual value.
 type SecretFile struct {
 	File
 }
@@ -73,3 +78,10 @@ func (o SecretFile) ReadFile() (secret optional.Secret, ok bool) {
 	secret = optional.MakeSecret(&str)
 	return secret, ok
 }
+
+// Read is the same as File.Read, but reads the contents into an optional.Secret.
+func (o SecretFile) Read() (secret optional.Secret, err error) {
+	str, err := o.File.Read()
+	secret = optional.MakeSecret(&str)
+	return secret, err
+}
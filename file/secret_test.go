@@ -0,0 +1,44 @@
+package file_test
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brnsampson/ezconf/file"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+func TestSecretFileRedacted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.txt")
+	assert.NilError(t, os.WriteFile(path, []byte("hunter2"), 0600))
+
+	f := file.SomeSecretFile(path)
+	secret, ok := f.ReadFile()
+	assert.Assert(t, ok)
+	assert.Equal(t, "hunter2", secret.MustGet())
+
+	conf := struct {
+		Path   file.SecretFile
+		Secret optional.Secret
+	}{f, secret}
+
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%q"} {
+		out := fmt.Sprintf(verb, conf)
+		assert.Assert(t, !strings.Contains(out, "hunter2"), "%s leaked the secret: %s", verb, out)
+	}
+
+	var buf bytes.Buffer
+	log.New(&buf, "", 0).Println(conf)
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("loaded", "conf", conf, "secret", secret)
+	assert.Assert(t, !strings.Contains(buf.String(), "hunter2"), buf.String())
+
+	// The path itself is not sensitive and is kept visible for debugging.
+	assert.Equal(t, path, f.String())
+}
@@ -0,0 +1,72 @@
+package ezconf
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/brnsampson/optional"
+)
+
+// ParseBool accepts everything strconv.ParseBool does along with the common feature toggle spellings enabled/disabled,
+// enable/disable, on/off, and yes/no (case insensitive). This gives operators an unambiguous way to turn a feature off
+// from an env var such as MY_APP_FEATURE_X=disabled.
+func ParseBool(str string) (bool, error) {
+	switch strings.ToLower(str) {
+	case "enabled", "enable", "on", "yes", "y":
+		return true, nil
+	case "disabled", "disable", "off", "no", "n":
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(str)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean value %q", str)
+	}
+	return b, nil
+}
+
+// boolFlag is a flag.Value which sets an optional.Bool, inverting the parsed value when negate is set.
+type boolFlag struct {
+	b      *optional.Bool
+	negate bool
+}
+
+func (f *boolFlag) String() string {
+	if f.b == nil {
+		return ""
+	}
+	return f.b.String()
+}
+
+func (f *boolFlag) Set(str string) error {
+	b, err := ParseBool(str)
+	if err != nil {
+		return err
+	}
+
+	f.b.Replace(b != f.negate)
+	return nil
+}
+
+// Get returns the optional.Bool set by the flag, so that flag.Getter can read it back from a parsed FlagSet.
+func (f *boolFlag) Get() any {
+	if f.b == nil {
+		return optional.NoBool()
+	}
+	return *f.b
+}
+
+// IsBoolFlag allows the flag to be passed without a value, e.g. -feature-x rather than -feature-x=true.
+func (f *boolFlag) IsBoolFlag() bool {
+	return true
+}
+
+// BoolVar registers a pair of flags on fs for a boolean feature toggle: -name sets b to true and -no-name sets it to
+// false. If both are given, the last one on the command line wins. Neither flag being passed leaves b untouched, so a
+// default of true can still be turned off explicitly.
+func BoolVar(fs *flag.FlagSet, b *optional.Bool, name, usage string) {
+	fs.Var(&boolFlag{b, false}, name, usage)
+	fs.Var(&boolFlag{b, true}, "no-"+name, "Disable: "+usage)
+}
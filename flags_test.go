@@ -0,0 +1,71 @@
+package ezconf_test
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseBool(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    bool
+		wantErr bool
+	}{
+		{in: "true", want: true},
+		{in: "1", want: true},
+		{in: "Enabled", want: true},
+		{in: "on", want: true},
+		{in: "false", want: false},
+		{in: "DISABLED", want: false},
+		{in: "off", want: false},
+		{in: "no", want: false},
+		{in: "maybe", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			b, err := ezconf.ParseBool(tc.in)
+			if tc.wantErr {
+				assert.ErrorContains(t, err, "invalid boolean value")
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, b)
+		})
+	}
+}
+
+func TestBoolVar(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want optional.Bool
+	}{
+		{name: "unset", args: []string{}, want: optional.NoBool()},
+		{name: "enable", args: []string{"-feature-x"}, want: optional.SomeBool(true)},
+		{name: "disable", args: []string{"-no-feature-x"}, want: optional.SomeBool(false)},
+		{name: "explicit value", args: []string{"-feature-x=off"}, want: optional.SomeBool(false)},
+		{name: "last wins", args: []string{"-no-feature-x", "-feature-x"}, want: optional.SomeBool(true)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var b optional.Bool
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			ezconf.BoolVar(fs, &b, "feature-x", "enable feature x")
+
+			err := fs.Parse(tc.args)
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, b)
+			// Both flags hand the value back through flag.Getter.
+			for _, name := range []string{"feature-x", "no-feature-x"} {
+				assert.Equal(t, tc.want, fs.Lookup(name).Value.(flag.Getter).Get())
+			}
+		})
+	}
+}
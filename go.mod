@@ -5,8 +5,20 @@ go 1.25.4
 require (
 	github.com/BurntSushi/toml v1.3.2
 	github.com/brnsampson/optional v0.3.0
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/quic-go/quic-go v0.59.1
+	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78
 	go-simpler.org/env v0.12.0
+	golang.org/x/crypto v0.41.0
+	gopkg.in/yaml.v3 v3.0.1
 	gotest.tools/v3 v3.5.2
+	software.sslmate.com/src/go-pkcs12 v0.5.0
 )
 
-require github.com/google/go-cmp v0.5.9 // indirect
+require (
+	github.com/google/go-cmp v0.5.9 // indirect
+	github.com/quic-go/qpack v0.6.0 // indirect
+	golang.org/x/net v0.43.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/text v0.28.0 // indirect
+)
@@ -0,0 +1,56 @@
+package ezconf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// gzipMagic is the header every gzip stream starts with.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// maxGunzipSize caps the output of Gunzip at the size FetchURL accepts, so that a small gzip bomb cannot get past that
+// limit once it is decompressed.
+const maxGunzipSize = maxConfigURLSize
+
+// IsGzip reports whether data is a gzip stream, going by its magic bytes.
+func IsGzip(data []byte) bool {
+	return bytes.HasPrefix(data, gzipMagic)
+}
+
+// Gunzip returns data decompressed if it is a gzip stream, and data itself otherwise, so that Decode and DecodeStrict
+// read gzipped config files, such as app.toml.gz, transparently. A truncated or corrupt stream is an error, as is one
+// which decompresses to more than 16 MiB.
+func Gunzip(data []byte) ([]byte, error) {
+	if !IsGzip(data) {
+		return data, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("corrupt gzip stream: %w", err)
+	}
+	defer r.Close()
+	data, err = io.ReadAll(io.LimitReader(r, maxGunzipSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("corrupt gzip stream: %w", err)
+	}
+	if len(data) > maxGunzipSize {
+		return nil, fmt.Errorf("gzip stream is larger than %d bytes once decompressed", maxGunzipSize)
+	}
+	return data, nil
+}
+
+// configFormat returns the format of the config file at p given by its extension, lowercased and without the leading
+// dot, looking past a .gz extension so that app.toml.gz is toml. It is empty if p has no other extension, e.g. app or
+// app.gz. The second value reports whether p has the .gz extension.
+func configFormat(p string) (string, bool) {
+	gz := strings.EqualFold(filepath.Ext(p), ".gz")
+	if gz {
+		p = p[:len(p)-len(".gz")]
+	}
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(p), ".")), gz
+}
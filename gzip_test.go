@@ -0,0 +1,90 @@
+package ezconf_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+// gzipped returns data compressed with gzip.
+func gzipped(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(data))
+	assert.NilError(t, err)
+	assert.NilError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDecodeFileGzip(t *testing.T) {
+	toml := "Name = \"app\"\nPort = 8080\n"
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		strict  bool
+		wantErr string
+	}{
+		{name: "inner extension", file: "app.toml.gz", data: gzipped(t, toml)},
+		{name: "upper case", file: "app.JSON.GZ", data: gzipped(t, `{"Name": "app", "Port": 8080}`)},
+		{name: "detected format", file: "app.gz", data: gzipped(t, "name: app\nport: 8080\n")},
+		{name: "magic bytes", file: "app.toml", data: gzipped(t, toml)},
+		{name: "strict", file: "app.toml.gz", data: gzipped(t, toml), strict: true},
+		{name: "not gzipped", file: "app.toml.gz", data: []byte(toml), wantErr: "corrupt gzip stream"},
+		{name: "truncated", file: "app.toml.gz", data: gzipped(t, toml)[:20], wantErr: "corrupt gzip stream"},
+		{name: "unknown inner extension", file: "app.ini.gz", data: gzipped(t, toml), wantErr: `extension ".ini"`},
+		{
+			name: "too large", file: "app.toml.gz", data: gzipped(t, strings.Repeat("#", 16<<20+1)),
+			wantErr: "gzip stream is larger than 16777216 bytes once decompressed",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			assert.NilError(t, os.WriteFile(path, tc.data, 0600))
+
+			var target decodeTarget
+			err := ezconf.DecodeFile(path, &target)
+			if tc.strict {
+				err = ezconf.DecodeFileStrict(context.Background(), path, "", &target)
+			}
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, "failed to decode config file")
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, optional.SomeStr("app"), target.Name)
+			assert.Equal(t, optional.SomeUint16(8080), target.Port)
+		})
+	}
+}
+
+func TestFetchURLGzip(t *testing.T) {
+	body := gzipped(t, "Name = \"app\"\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+
+	data, format, err := ezconf.FetchURL(context.Background(), srv.Client(), srv.URL+"/app.toml.gz")
+	assert.NilError(t, err)
+	assert.Equal(t, ezconf.FormatTOML, format)
+
+	var target decodeTarget
+	assert.NilError(t, ezconf.Decode(data, format, &target))
+	assert.Equal(t, optional.SomeStr("app"), target.Name)
+}
@@ -1,27 +1,49 @@
 package httpconf
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/netip"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/brnsampson/ezconf"
 	"github.com/brnsampson/ezconf/file"
 	"github.com/brnsampson/optional"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/crypto/ocsp"
 )
 
 // Loaders for generic fields
 
-type Loader[Conf any] interface {
-	Update() (Conf, error)
-	Previous() (Conf, error)
-}
+// Loader is the same as ezconf.Loader. It is kept here so that existing references to httpconf.Loader still work.
+type Loader[Conf any] = ezconf.Loader[Conf]
 
 type HTTPConfLoader Loader[HttpServerConfig]
 
+// UnixSocketPrefix marks a BindAddr as the path of a unix domain socket, e.g. unix:/var/run/app.sock.
+const UnixSocketPrefix = "unix:"
+
+// SocketFilePerms are the permissions set on unix domain sockets created by Listen, so that a reverse proxy running as
+// another user in the same group can connect.
+const SocketFilePerms fs.FileMode = 0660
+
 // Loading HTTP and TLS config from files is not that bad, but this removes a lot of boilerplate that exists in every web-based project.
 type HttpServerConfigProtos int
 
@@ -30,6 +52,7 @@ const (
 	HTTPS
 	HTTP2
 	UNENCRYPTEDHTTP2
+	HTTP3 // HTTP/3 over QUIC, served by NewHttp3Server alongside an HTTP/1.1 and HTTP/2 server for fallback. Requires TLS.
 )
 
 func (p HttpServerConfigProtos) String() string {
@@ -41,7 +64,9 @@ func (p HttpServerConfigProtos) String() string {
 	case HTTP2:
 		return "https"
 	case UNENCRYPTEDHTTP2:
-		return "hcl"
+		return "http"
+	case HTTP3:
+		return "https"
 	default:
 		return "unknown"
 	}
@@ -50,12 +75,17 @@ func (p HttpServerConfigProtos) String() string {
 func (p HttpServerConfigProtos) GetHttpProtos() *http.Protocols {
 	protos := new(http.Protocols)
 	switch p {
-	case HTTP | HTTPS:
+	case HTTP:
+		protos.SetHTTP1(true)
+	case HTTPS:
 		protos.SetHTTP1(true)
 	case HTTP2:
 		protos.SetHTTP2(true)
 	case UNENCRYPTEDHTTP2:
 		protos.SetUnencryptedHTTP2(true)
+	case HTTP3: // HTTP/3 itself is served over UDP by NewHttp3Server. These are for clients which fall back to TCP.
+		protos.SetHTTP1(true)
+		protos.SetHTTP2(true)
 	default: // Default to just allowing either http or http2
 		protos.SetHTTP1(true)
 		protos.SetHTTP2(true)
@@ -66,17 +96,81 @@ func (p HttpServerConfigProtos) GetHttpProtos() *http.Protocols {
 
 // HttpServerConfig is the struct produced by the loader. It has pretty much everything needed to create an http.Server
 type HttpServerConfig struct {
-	Protos            *http.Protocols
-	Hostname          string      // The hostname as given OR ip address if no hostname was given.
-	BindAddr          string      // The address to bind to
-	Port              uint16      // The port to bind to
-	RemoteAddress     string      // The address clients should connect to. This is generally [proto]://[hostname]:[port] (although port is omitted if it is the standard http[s] port)
-	TlsConf           *tls.Config // TLS config to use. If tls was disabled you can still use this and it will correctly be a non-TLS connetion.
+	Protos   *http.Protocols
+	Hostname string // The hostname as given OR ip address if no hostname was given.
+	BindAddr string // The address to bind to
+	Port     uint16 // The port to bind to
+	// RemoteAddress is the address clients should connect to. This is generally [proto]://[hostname]:[port] (although
+	// port is omitted if it is the standard http[s] port)
+	RemoteAddress string
+	// SocketPath is set when BindAddr is unix:/path. Use Listen to serve on the socket instead of BindAddr:Port.
+	SocketPath string
+	// TlsConf is the TLS config to use. If tls was disabled you can still use this and it will correctly be a non-TLS
+	// connetion.
+	TlsConf           *tls.Config
 	handler           http.Handler
 	readTimeout       time.Duration
 	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
 	maxHeaderBytes    int
+	maxConns          int
+	keepAlive         time.Duration
 	errorLog          *log.Logger
+	baseContext       func(net.Listener) context.Context
+	connContext       func(context.Context, net.Conn) context.Context
+}
+
+// MarshalJSON writes the fields of an HttpServerConfig that are meaningful to a reader, e.g. for a /config API. The TLS
+// config is summarized as whether TLS is enabled rather than exposing certificates and keys.
+func (c HttpServerConfig) MarshalJSON() ([]byte, error) {
+	protos := ""
+	if c.Protos != nil {
+		protos = c.Protos.String()
+	}
+
+	return json.Marshal(struct {
+		Protos        string
+		Hostname      string
+		BindAddr      string
+		Port          uint16
+		RemoteAddress string
+		SocketPath    string `json:",omitempty"`
+		TlsEnabled    bool
+	}{
+		Protos:        protos,
+		Hostname:      c.Hostname,
+		BindAddr:      c.BindAddr,
+		Port:          c.Port,
+		RemoteAddress: c.RemoteAddress,
+		SocketPath:    c.SocketPath,
+		TlsEnabled:    tlsEnabled(c.TlsConf),
+	})
+}
+
+// JSONSchema describes an HttpServerConfig as written by MarshalJSON. It is marked read only since server configs are
+// set up through HttpServerLoader, so the fields are reported by a config API but not read from config files.
+func (c HttpServerConfig) JSONSchema() *ezconf.Schema {
+	str := &ezconf.Schema{Type: "string"}
+	return &ezconf.Schema{
+		Description: "Resolved HTTP server config. Set up through httpconf.HttpServerLoader rather than config files.",
+		Type:        "object",
+		ReadOnly:    true,
+		Properties: map[string]*ezconf.Schema{
+			"Protos":        str,
+			"Hostname":      str,
+			"BindAddr":      str,
+			"Port":          {Type: "integer"},
+			"RemoteAddress": str,
+			"SocketPath":    str,
+			"TlsEnabled":    {Type: "boolean"},
+		},
+	}
+}
+
+// tlsEnabled reports whether conf can actually serve TLS, i.e. whether it has a certificate or a way to get one.
+func tlsEnabled(conf *tls.Config) bool {
+	return conf != nil && (len(conf.Certificates) > 0 || conf.GetCertificate != nil)
 }
 
 type HttpServerConfigOption func(HttpServerConfig) HttpServerConfig
@@ -102,6 +196,22 @@ func HttpReadHeaderTimeout(timeout time.Duration) HttpServerConfigOption {
 	}
 }
 
+func HttpWriteTimeout(timeout time.Duration) HttpServerConfigOption {
+	return func(c HttpServerConfig) HttpServerConfig {
+		c.writeTimeout = timeout
+		return c
+	}
+}
+
+func HttpIdleTimeout(timeout time.Duration) HttpServerConfigOption {
+	return func(c HttpServerConfig) HttpServerConfig {
+		c.idleTimeout = timeout
+		return c
+	}
+}
+
+// HttpMaxHeaderBytes sets the MaxHeaderBytes of the servers made from the config, the same as the loader's
+// MaxHeaderBytes field. A count of 0 or less uses http.DefaultMaxHeaderBytes.
 func HttpMaxHeaderBytes(count int) HttpServerConfigOption {
 	return func(c HttpServerConfig) HttpServerConfig {
 		c.maxHeaderBytes = count
@@ -109,6 +219,72 @@ func HttpMaxHeaderBytes(count int) HttpServerConfigOption {
 	}
 }
 
+// HttpMaxConns limits the listeners returned by Listen, and so Bind and Serve, to count concurrent connections, the
+// same as the loader's MaxConns field. Connections beyond the limit wait in the listen backlog until an accepted one is
+// closed. A count of 0 or less means no limit.
+func HttpMaxConns(count int) HttpServerConfigOption {
+	return func(c HttpServerConfig) HttpServerConfig {
+		c.maxConns = count
+		return c
+	}
+}
+
+// HttpKeepAlive sets the TCP keepalive period of connections accepted by the listeners returned by Listen, the same as
+// the loader's KeepAlive field. A period of 0 uses the default of net.ListenConfig, currently 15s, and a negative one
+// disables keepalives.
+func HttpKeepAlive(period time.Duration) HttpServerConfigOption {
+	return func(c HttpServerConfig) HttpServerConfig {
+		c.keepAlive = period
+		return c
+	}
+}
+
+// HttpAltSvc wraps the handler to advertise h3 in the Alt-Svc header of every response, which is how clients discover
+// that they can switch to HTTP/3. h3 is the server returned by NewHttp3Server.
+func HttpAltSvc(h3 *http3.Server) HttpServerConfigOption {
+	return func(c HttpServerConfig) HttpServerConfig {
+		next := c.handler
+		if next == nil {
+			next = http.DefaultServeMux
+		}
+		c.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h3.SetQUICHeaders(w.Header())
+			next.ServeHTTP(w, r)
+		})
+		return c
+	}
+}
+
+// HttpHealthEndpoints wraps the handler to answer liveness and readiness probes, e.g. /healthz and /readyz. livePath
+// always returns 200 OK while the server is up, and readyPath returns 200 OK while ready reports true and 503 Service
+// Unavailable otherwise. ready is called on every probe, so it should be cheap, and a nil ready is always ready. Every
+// other request goes to the handler, so apply this after HttpHandler:
+//
+//	conf = conf.With(httpconf.HttpHandler(mux)).With(httpconf.HttpHealthEndpoints("/healthz", "/readyz", isReady))
+func HttpHealthEndpoints(livePath, readyPath string, ready func() bool) HttpServerConfigOption {
+	return func(c HttpServerConfig) HttpServerConfig {
+		next := c.handler
+		if next == nil {
+			next = http.DefaultServeMux
+		}
+		c.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case livePath:
+				io.WriteString(w, "ok\n")
+			case readyPath:
+				if ready != nil && !ready() {
+					http.Error(w, "not ready", http.StatusServiceUnavailable)
+					return
+				}
+				io.WriteString(w, "ok\n")
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+		return c
+	}
+}
+
 func HttpErrorLog(errLog *log.Logger) HttpServerConfigOption {
 	return func(c HttpServerConfig) HttpServerConfig {
 		c.errorLog = errLog
@@ -116,41 +292,271 @@ func HttpErrorLog(errLog *log.Logger) HttpServerConfigOption {
 	}
 }
 
+// HttpBaseContext sets the BaseContext of the servers made by NewHttpServer, which returns the context every request
+// served on a listener starts from, e.g. to carry values shared by all handlers.
+func HttpBaseContext(f func(net.Listener) context.Context) HttpServerConfigOption {
+	return func(c HttpServerConfig) HttpServerConfig {
+		c.baseContext = f
+		return c
+	}
+}
+
+// HttpConnContext sets the ConnContext of the servers made by NewHttpServer, which derives the context of each new
+// connection from the base context, e.g. to attach the peer address or a connection ID to every request on it.
+func HttpConnContext(f func(context.Context, net.Conn) context.Context) HttpServerConfigOption {
+	return func(c HttpServerConfig) HttpServerConfig {
+		c.connContext = f
+		return c
+	}
+}
+
 func (c HttpServerConfig) With(o HttpServerConfigOption) HttpServerConfig {
 	return o(c)
 }
 
+// Addr returns the address NewHttpServer and Listen bind to, e.g. 127.0.0.1:8443, or the socket path for unix sockets.
+// This is the address to log when starting up. With a Port of 0 the OS picks a free port when listening, so use Bind
+// to find out which one was chosen.
+func (c HttpServerConfig) Addr() string {
+	if c.SocketPath != "" {
+		return c.SocketPath
+	}
+
+	// http.Server will accept an empty ip address to bind to all available interfaces. IPv6 addresses are bracketed, e.g.
+	// [::1]:8443.
+	host := c.BindAddr
+	ip, err := netip.ParseAddr(host)
+	if err == nil {
+		host = ip.String()
+	}
+	return net.JoinHostPort(host, strconv.FormatUint(uint64(c.Port), 10))
+}
+
+// urlHost returns host as it is written in a URL without a port, which brackets IPv6 addresses.
+func urlHost(host string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// Bind listens the same way as Listen and also returns a copy of c with Port set to the port actually bound. It only
+// differs from c when Port is 0 and the OS picked a free port, in which case a RemoteAddress ending in :0 is updated to
+// match as well. Serve the listener with a server created from the returned config.
+func (c HttpServerConfig) Bind() (net.Listener, HttpServerConfig, error) {
+	ln, err := c.Listen()
+	if err != nil {
+		return nil, c, err
+	}
+
+	addr, ok := ln.Addr().(*net.TCPAddr)
+	if !ok || c.Port != 0 {
+		return ln, c, nil
+	}
+
+	c.Port = uint16(addr.Port)
+	remote, found := strings.CutSuffix(c.RemoteAddress, ":0")
+	if found {
+		c.RemoteAddress = remote + ":" + strconv.Itoa(addr.Port)
+	}
+	return ln, c, nil
+}
+
 // NewHttpServer returns an *http.Http configured according to HttpServerConfig's fields.
 //
 // Calling (HttpServerConfig.NewHttpServer()).ListenAndServe() should do what you want most of the time unless you
-// have specific needs.
+// have specific needs. ListenAndServe only handles TCP, so use Listen and Serve instead when SocketPath is set.
 func (c HttpServerConfig) NewHttpServer() *http.Server {
-	port := strconv.FormatUint(uint64(c.Port), 10)
-	var addr string
-	if c.BindAddr != "" {
-		ip := net.ParseIP(c.BindAddr)
-		addr = ip.String() + ":" + port
+	return &http.Server{
+		Addr:              c.Addr(),
+		Handler:           c.handler,
+		TLSConfig:         c.TlsConf,
+		ReadTimeout:       c.readTimeout,
+		ReadHeaderTimeout: c.readHeaderTimeout,
+		WriteTimeout:      c.writeTimeout,
+		IdleTimeout:       c.idleTimeout,
+		MaxHeaderBytes:    c.maxHeaderBytes,
+		ErrorLog:          c.errorLog,
+		Protocols:         c.Protos,
+		BaseContext:       c.baseContext,
+		ConnContext:       c.connContext,
+	}
+}
+
+// NewHttp3Server returns an *http3.Server which serves HTTP/3 over QUIC on the same address and port as NewHttpServer,
+// using UDP instead of TCP. HTTP/3 requires TLS, so an error is returned if TLS is not enabled, or if SocketPath is
+// set. Clients only find the HTTP/3 server through the Alt-Svc header, so run it alongside the usual server:
+//
+//	h3, err := conf.NewHttp3Server()
+//	...
+//	go h3.ListenAndServe()
+//	err = conf.With(httpconf.HttpAltSvc(h3)).NewHttpServer().ListenAndServeTLS("", "")
+func (c HttpServerConfig) NewHttp3Server() (*http3.Server, error) {
+	if !tlsEnabled(c.TlsConf) {
+		return nil, fmt.Errorf("cannot create an HTTP/3 server: HTTP/3 requires TLS, but TLS is not enabled")
+	}
+	if c.SocketPath != "" {
+		return nil, fmt.Errorf("cannot create an HTTP/3 server: HTTP/3 runs over UDP and cannot use the unix socket %s",
+			c.SocketPath)
+	}
+
+	return &http3.Server{
+		Addr:           c.NewHttpServer().Addr,
+		Handler:        c.handler,
+		TLSConfig:      c.TlsConf,
+		MaxHeaderBytes: c.maxHeaderBytes,
+		IdleTimeout:    c.idleTimeout,
+	}, nil
+}
+
+// NewRedirectServer returns an *http.Server which listens on port 80 of BindAddr and permanently redirects every
+// request to the same path and query at RemoteAddress. It is meant to run alongside an HTTPS server so that clients
+// which try plain HTTP first end up in the right place, and returns nil if RemoteAddress is not an https address.
+func (c HttpServerConfig) NewRedirectServer() *http.Server {
+	if !strings.HasPrefix(c.RemoteAddress, "https://") {
+		return nil
+	}
+
+	host := c.BindAddr
+	if c.SocketPath != "" {
+		host = ""
+	}
+
+	target := strings.TrimSuffix(c.RemoteAddress, "/")
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+	return &http.Server{
+		Addr:              net.JoinHostPort(host, "80"),
+		Handler:           handler,
+		ReadHeaderTimeout: c.readHeaderTimeout,
+		ErrorLog:          c.errorLog,
+	}
+}
+
+// Listen returns a listener for the server, which works for both TCP and unix domain sockets:
+//
+//	ln, err := conf.Listen()
+//	...
+//	err = conf.NewHttpServer().Serve(ln)
+//
+// A stale socket file left behind by a previous process is removed first, but Listen fails if another process is still
+// accepting connections on it or if the path is not a socket. Created sockets get SocketFilePerms. TCP connections use
+// the keepalive period set by HttpKeepAlive, and both kinds are limited by HttpMaxConns.
+func (c HttpServerConfig) Listen() (net.Listener, error) {
+	ln, err := c.listen()
+	if err != nil || c.maxConns <= 0 {
+		return ln, err
+	}
+	return newLimitListener(ln, c.maxConns), nil
+}
+
+// listen returns a listener for the server without the connection limit.
+func (c HttpServerConfig) listen() (net.Listener, error) {
+	if c.SocketPath == "" {
+		lc := net.ListenConfig{KeepAlive: c.keepAlive}
+		return lc.Listen(context.Background(), "tcp", c.Addr())
+	}
+
+	err := removeStaleSocket(c.SocketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", c.SocketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(c.SocketPath, SocketFilePerms)
+	if err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to set permissions on socket %s: %w", c.SocketPath, err)
+	}
+	return ln, nil
+}
+
+// Serve listens with Listen and serves a server made by NewHttpServer, over TLS if it is enabled, until ctx is done.
+// The server is then shut down gracefully, and Serve only returns once active requests have finished, with the error of
+// Shutdown if any. Use Listen and NewHttpServer directly for more control, e.g. to serve on a listener bound before
+// dropping privileges.
+func (c HttpServerConfig) Serve(ctx context.Context) error {
+	ln, err := c.Listen()
+	if err != nil {
+		return err
+	}
+
+	srv := c.NewHttpServer()
+	shutdown := make(chan error, 1)
+	stop := context.AfterFunc(ctx, func() { shutdown <- srv.Shutdown(context.WithoutCancel(ctx)) })
+
+	if tlsEnabled(c.TlsConf) {
+		err = srv.ServeTLS(ln, "", "")
 	} else {
-		// http.Server will accept an empty ip address to bind to all available interfaces.
-		addr = ":" + port
+		err = srv.Serve(ln)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return <-shutdown
+	}
+
+	// Serve failed on its own. If ctx was done meanwhile, Shutdown is already running and is waited for all the same.
+	if !stop() {
+		<-shutdown
 	}
-	return &http.Server{Addr: addr, Handler: c.handler, TLSConfig: c.TlsConf, ReadTimeout: c.readTimeout, ReadHeaderTimeout: c.readHeaderTimeout, ErrorLog: c.errorLog, Protocols: c.Protos}
+	return err
+}
+
+func removeStaleSocket(path string) error {
+	stat, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if stat.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("cannot listen on %s: file exists and is not a socket", path)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("cannot listen on %s: socket is in use", path)
+	}
+	return os.Remove(path)
 }
 
 // HttpServerLoader gets parameters from the environment and user overrides in order to produce an HttpServerConfig struct.
 // The HttpServerConfig struct in turn can be used to create a new http.Server.
 type HttpServerLoader struct {
-	Protocol          optional.Option[HttpServerConfigProtos] // Default behavior is to set this based on Tls.TlsEnabled.
-	Hostname          optional.Str
-	BindAddr          optional.Str    // an empty string will cause us to bind to all interfaces. Defaults to 127.0.0.1
+	Protocol optional.Option[HttpServerConfigProtos] // Default behavior is to set this based on Tls.TlsEnabled.
+	Hostname optional.Str
+	// BindAddr is an IP address; an empty string will cause us to bind to all interfaces. unix:/path binds to a unix
+	// domain socket. Defaults to 127.0.0.1. DNS names such as localhost go in Hostname.
+	BindAddr optional.Str
+	// BindInterface is a network interface name such as eth0, bound to by its first address instead of BindAddr. Cannot
+	// be set along with BindAddr.
+	BindInterface     optional.Str
 	BindPort          optional.Uint16 // Defaults to 80 for HTTP, 443 for HTTPS
 	Tls               Loader[*tls.Config]
 	ReadTimeout       optional.Duration // Defaults to 0. Same as http.Server
 	ReadHeaderTimeout optional.Duration // Defaults to 0. Same as http.Server
-	MaxHeaderBytes    optional.Int
-	handler           http.Handler
-	errorLog          *log.Logger
-	prev              HttpServerConfig
+	WriteTimeout      optional.Duration // Defaults to 0. Same as http.Server
+	IdleTimeout       optional.Duration // Defaults to 0. Same as http.Server
+	// MaxHeaderBytes defaults to 0, which http.Server treats as http.DefaultMaxHeaderBytes (1MB). Must not be negative.
+	MaxHeaderBytes optional.Int
+	// MaxConns limits concurrent connections, see HttpMaxConns. Defaults to 0, no limit. Must not be negative.
+	MaxConns optional.Int
+	// KeepAlive is the TCP keepalive period, see HttpKeepAlive. Defaults to 0, which uses the net.ListenConfig default of
+	// 15s.
+	KeepAlive optional.Duration
+	// DisableTls forces plain HTTP, e.g. for local debugging, without touching Tls or Protocol. The Tls loader is not
+	// resolved at all.
+	DisableTls optional.Bool
+	handler    http.Handler
+	errorLog   *log.Logger
+	prev       ezconf.Snapshot[HttpServerConfig]
 }
 
 type HttpServerLoaderOption func(HttpServerLoader) HttpServerLoader
@@ -173,41 +579,123 @@ func (c HttpServerLoader) With(o HttpServerLoaderOption) HttpServerLoader {
 	return o(c)
 }
 
+// Previous returns the config stored by the last successful Update. It is safe to call while another goroutine runs
+// Update.
 func (l *HttpServerLoader) Previous() HttpServerConfig {
-	return l.prev
+	return l.prev.Load()
 }
 
-func (l *HttpServerLoader) Update() (result HttpServerConfig, err error) {
+// Update resolves a new HttpServerConfig and stores it to be returned by Previous.
+func (l *HttpServerLoader) Update() (HttpServerConfig, error) {
+	return l.UpdateContext(context.Background())
+}
+
+// UpdateContext is the same as Update, but stops loading once ctx is done.
+func (l *HttpServerLoader) UpdateContext(ctx context.Context) (HttpServerConfig, error) {
+	result, err := l.ResolveContext(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	l.prev.Store(result)
+	return result, nil
+}
+
+// Resolve produces a new HttpServerConfig from the loader's fields without storing it.
+func (l *HttpServerLoader) Resolve() (HttpServerConfig, error) {
+	return l.ResolveContext(context.Background())
+}
+
+// ResolveContext is the same as Resolve, but stops loading once ctx is done. ctx is passed on to the Tls loader if it
+// implements ezconf.ContextLoader.
+func (l *HttpServerLoader) ResolveContext(ctx context.Context) (result HttpServerConfig, err error) {
+	return l.ResolveDisableTls(ctx, optional.NoBool())
+}
+
+// ResolveDisableTls is the same as ResolveContext, with fallback used when DisableTls is not set. It lets a loader
+// which embeds an HttpServerLoader layer its own flags and env vars under DisableTls without copying the loader.
+func (l *HttpServerLoader) ResolveDisableTls(ctx context.Context, fallback optional.Bool) (result HttpServerConfig,
+	err error) {
 	// Produce new config
 	proto := optional.GetOr(l.Protocol, HTTPS) // Default to HTTPS because we don't have anything better to do.
-	bindAddr := optional.GetOr(l.BindAddr, "127.0.0.0")
+	disableTls := optional.GetOr(optional.Or(l.DisableTls, fallback), false)
+	if disableTls {
+		proto = HTTP
+	}
+	bindAddr := optional.GetOr(l.BindAddr, "127.0.0.1")
+	iface, ok := l.BindInterface.Get()
+	if ok && l.BindAddr.IsSome() {
+		err = &ezconf.ValidationError{Field: "HttpServerLoader.BindInterface", Reason: "cannot be set along with BindAddr"}
+		return result, fmt.Errorf("Failed to update HttpServerLoader: %w", err)
+	}
+	if ok {
+		bindAddr, err = interfaceAddr(iface)
+		if err != nil {
+			return result, fmt.Errorf("Failed to update HttpServerLoader: %w", err)
+		}
+	}
+	socketPath, isSocket := strings.CutPrefix(bindAddr, UnixSocketPrefix)
+	if !isSocket {
+		socketPath = ""
+	}
+	if !isSocket && bindAddr != "" {
+		_, err = netip.ParseAddr(bindAddr)
+		if err != nil {
+			reason := fmt.Sprintf(
+				"must be an IP address such as 127.0.0.1, 0.0.0.0, or ::, got %q. Set Hostname for DNS names instead", bindAddr)
+			err = &ezconf.ValidationError{Field: "HttpServerLoader.BindAddr", Reason: reason}
+			return result, fmt.Errorf("Failed to update HttpServerLoader: %w", err)
+		}
+	}
 	hostname := optional.GetOr(l.Hostname, bindAddr)
+	if isSocket {
+		hostname = optional.GetOr(l.Hostname, "localhost")
+	}
+	maxHeaderBytes := optional.GetOr(l.MaxHeaderBytes, 0)
+	if maxHeaderBytes < 0 {
+		err = &ezconf.ValidationError{Field: "HttpServerLoader.MaxHeaderBytes",
+			Reason: fmt.Sprintf("must not be negative, got %d", maxHeaderBytes)}
+		return result, fmt.Errorf("Failed to update HttpServerLoader: %w", err)
+	}
+	maxConns := optional.GetOr(l.MaxConns, 0)
+	if maxConns < 0 {
+		err = &ezconf.ValidationError{Field: "HttpServerLoader.MaxConns", Reason: fmt.Sprintf("must not be negative, got %d",
+			maxConns)}
+		return result, fmt.Errorf("Failed to update HttpServerLoader: %w", err)
+	}
+
 	port, ok := l.BindPort.Get()
 	if !ok {
 		switch proto {
 		case HTTP:
 			port = 80
-		case HTTPS:
+		case HTTPS, HTTP3:
 			port = 443
 		default:
-			return result, fmt.Errorf("Failed to update HttpServerLoader: BindPort unset, but protocol only has default ports for HTTP and HTTPS")
+			return result, fmt.Errorf("Failed to update HttpServerLoader: " +
+				"BindPort unset, but protocol only has default ports for HTTP, HTTPS, and HTTP3")
 		}
 	}
 
 	var remoteAddr string
 	if ok {
-		remoteAddr = proto.String() + "://" + hostname + ":" + strconv.FormatUint(uint64(port), 10)
+		remoteAddr = proto.String() + "://" + net.JoinHostPort(hostname, strconv.FormatUint(uint64(port), 10))
 	} else {
 		// If we defaulted to a port, that means we should not have to specify it in the url
-		remoteAddr = proto.String() + "://" + hostname
+		remoteAddr = proto.String() + "://" + urlHost(hostname)
 	}
 
-	tlsConf, err := l.Tls.Update()
-	if err != nil {
-		return
+	var tlsConf *tls.Config
+	if !disableTls {
+		tlsConf, err = ezconf.ResolveContext(ctx, l.Tls)
+		if err != nil {
+			return
+		}
+	}
+	if proto == HTTP3 && !tlsEnabled(tlsConf) {
+		return result, fmt.Errorf("Failed to update HttpServerLoader: HTTP3 requires TLS, but TLS is not enabled")
 	}
 
-	// Update internal state
 	result = HttpServerConfig{
 		Protos:            proto.GetHttpProtos(),
 		Hostname:          hostname,   // The hostname as given OR ip address if no hostname was given.
@@ -215,80 +703,680 @@ func (l *HttpServerLoader) Update() (result HttpServerConfig, err error) {
 		Port:              port,       // The port to bind to
 		RemoteAddress:     remoteAddr, // The address clients should connect to. This is generally [proto]://[hostname]:[port] (although port is omitted if it is the standard http[s] port)
 		TlsConf:           tlsConf,    // TLS config to use. If tls was disabled you can still use this and it will correctly be a non-TLS connetion.
+		SocketPath:        socketPath,
 		handler:           l.handler,
 		readTimeout:       optional.GetOr(l.ReadTimeout, 0),
 		readHeaderTimeout: optional.GetOr(l.ReadHeaderTimeout, 0),
-		maxHeaderBytes:    optional.GetOr(l.MaxHeaderBytes, 0),
+		writeTimeout:      optional.GetOr(l.WriteTimeout, 0),
+		idleTimeout:       optional.GetOr(l.IdleTimeout, 0),
+		maxHeaderBytes:    maxHeaderBytes,
+		maxConns:          maxConns,
+		keepAlive:         optional.GetOr(l.KeepAlive, 0),
 		errorLog:          l.errorLog,
 	}
 
-	l.prev = result
 	return
 }
 
+// interfaceAddr returns the first address of the network interface name which can be bound without a zone, i.e. which
+// is not link-local, in the order the OS lists them.
+func interfaceAddr(name string) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		reason := fmt.Sprintf("no network interface %q: %s", name, err)
+		return "", &ezconf.ValidationError{Field: "HttpServerLoader.BindInterface", Reason: reason}
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the addresses of network interface %s: %w", name, err)
+	}
+
+	for _, a := range addrs {
+		prefix, err := netip.ParsePrefix(a.String())
+		if err != nil {
+			continue
+		}
+		ip := prefix.Addr().Unmap()
+		if ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+			continue
+		}
+		return ip.String(), nil
+	}
+	reason := fmt.Sprintf("network interface %q has no address to bind to", name)
+	return "", &ezconf.ValidationError{Field: "HttpServerLoader.BindInterface", Reason: reason}
+}
+
 type TlsConfigLoader struct {
-	TlsEnabled         optional.Bool
-	ServerName         optional.Str
-	PrivateKey         file.PrivateKey `default:"tls/key.pem"`
-	Certificate        file.Cert       `default:"tls/cert.pem"`
-	InsecureSkipVerify optional.Bool   `default:"false"`
-	prev               *tls.Config
+	TlsEnabled optional.Bool
+	ServerName optional.Str
+	PrivateKey file.PrivateKey `default:"tls/key.pem"`
+	// KeyPassphrase is only needed if PrivateKey is an encrypted PKCS#8 key. Also the password of PKCS12.
+	KeyPassphrase optional.Secret
+	Certificate   file.Cert `default:"tls/cert.pem"`
+	// CertificatePEM is a PEM encoded certificate chain given inline, e.g. through an env var. Used instead of
+	// Certificate.
+	CertificatePEM optional.Str
+	// PrivateKeyPEM is a PEM encoded private key given inline. Used instead of PrivateKey, and decrypted with
+	// KeyPassphrase if needed.
+	PrivateKeyPEM optional.Secret
+	// PKCS12 is a .p12 or .pfx bundle holding both the certificate chain and private key. Used instead of Certificate and
+	// PrivateKey.
+	PKCS12             file.PKCS12
+	InsecureSkipVerify optional.Bool `default:"false"`
+	// MinVersion is one of 1.0, 1.1, 1.2, or 1.3. A TLS prefix such as TLS1.2 is also accepted.
+	MinVersion optional.Str `default:"1.3"`
+	MaxVersion optional.Str // Defaults to the highest version supported by crypto/tls.
+	// CipherSuites are names as given by tls.CipherSuiteName. Only used for TLS 1.2 and below, so MinVersion must be lower
+	// than 1.3.
+	CipherSuites ezconf.List[string]
+	// AcmeDirectory is an ACME directory URL, e.g. autocert.DefaultACMEDirectory. Setting this gets certificates
+	// automatically instead of from Certificate and PrivateKey.
+	AcmeDirectory optional.Str
+	AcmeEmail     optional.Str        // Contact email for the ACME account.
+	AcmeHosts     ezconf.List[string] // Hostnames to get certificates for. Required with AcmeDirectory.
+	// AcmeCacheDir is the directory to cache certificates in. Without one, certificates are requested again after every
+	// restart.
+	AcmeCacheDir optional.Str
+	ClientCAFile file.Cert // CA bundle used to verify client certificates.
+	// RequireClientCert requires every client to present a certificate signed by ClientCAFile.
+	RequireClientCert optional.Bool `default:"false"`
+	// HostCerts are certificates for virtual hosts keyed by hostname, chosen through SNI. Keys may be wildcards such as
+	// *.example.com. Other hostnames get the default certificate.
+	HostCerts map[string]HostCert
+	// SessionTicketsDisabled turns off session resumption through tickets, so every connection does a full handshake.
+	SessionTicketsDisabled optional.Bool `default:"false"`
+	// OCSPStapling staples an OCSP response to the default certificate, fetched at startup and refreshed halfway
+	// through its validity. The certificate is served without a staple while the responder is unreachable. The
+	// certificate chain must include the issuer.
+	OCSPStapling optional.Bool `default:"false"`
+	// OCSPResponder is the OCSP responder URL used for stapling. Defaults to the first OCSP server named by the
+	// certificate.
+	OCSPResponder optional.Str
+	onConnection  func(tls.ConnectionState)
+	retry         ezconf.RetryPolicy
+	sessionCache  tls.ClientSessionCache
+	ticketKeys    [][32]byte
+	cert          *certHolder // Set by TlsLoaderCertReload and shared by copies of the loader.
+	acmeManager   *acmeHolder // Set by TlsLoaderAcmeReuse and shared by copies of the loader.
+	prev          ezconf.Snapshot[*tls.Config]
+}
+
+// HostCert is a certificate and private key served to clients which ask for one hostname through SNI.
+type HostCert struct {
+	Certificate   file.Cert
+	PrivateKey    file.PrivateKey
+	KeyPassphrase optional.Secret // Only needed if PrivateKey is an encrypted PKCS#8 key.
+}
+
+// hostCerts reads the HostCerts keypairs and sets config up to choose between them by the SNI hostname of each client,
+// falling back to config.Certificates for hostnames without one. If ServerName is set it must be covered by one of the
+// host certificates or the default certificate.
+func (l *TlsConfigLoader) hostCerts(ctx context.Context, config *tls.Config) error {
+	certs := make(map[string]*tls.Certificate, len(l.HostCerts))
+	for host, hc := range l.HostCerts {
+		if hc.Certificate.IsNone() || hc.PrivateKey.IsNone() {
+			return fmt.Errorf("host certificate for %s needs both a Certificate and a PrivateKey", host)
+		}
+		var cert tls.Certificate
+		err := l.retry.Do(ctx, func() (err error) {
+			cert, err = hc.PrivateKey.ReadCertWithPassphrase(hc.Certificate, hc.KeyPassphrase)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read host certificate for %s: %w", host, err)
+		}
+		certs[strings.ToLower(host)] = &cert
+	}
+
+	name, ok := l.ServerName.Get()
+	if ok && lookupHostCert(certs, name) == nil && !coversHost(config.Certificates, name) {
+		return fmt.Errorf("no certificate matches ServerName %s", name)
+	}
+
+	config.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		// A nil certificate makes crypto/tls fall back to config.Certificates.
+		return lookupHostCert(certs, hello.ServerName), nil
+	}
+	return nil
 }
 
+// lookupHostCert returns the certificate for host, trying an exact match before a wildcard for its parent domain.
+func lookupHostCert(certs map[string]*tls.Certificate, host string) *tls.Certificate {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	cert, ok := certs[host]
+	if ok {
+		return cert
+	}
+
+	_, parent, ok := strings.Cut(host, ".")
+	if !ok {
+		return nil
+	}
+	return certs["*."+parent]
+}
+
+// coversHost reports whether the leaf of any of certs is valid for host. Legacy certificates without any DNS names are
+// matched by their common name instead.
+func coversHost(certs []tls.Certificate, host string) bool {
+	for _, cert := range certs {
+		leaf := cert.Leaf
+		if leaf == nil && len(cert.Certificate) > 0 {
+			leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+		}
+		if leaf == nil {
+			continue
+		}
+		if leaf.VerifyHostname(host) == nil {
+			return true
+		}
+		if len(leaf.DNSNames) == 0 && strings.EqualFold(leaf.Subject.CommonName, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// acme sets config up to get certificates from the ACME directory on demand with an autocert.Manager. This includes
+// answering tls-alpn-01 challenges, so the server must be reachable on port 443 of every host in AcmeHosts. Every call
+// makes a new manager unless the loader was made with TlsLoaderAcmeReuse.
+func (l *TlsConfigLoader) acme(config *tls.Config) error {
+	hosts, _ := l.AcmeHosts.Get()
+	if len(hosts) == 0 {
+		return fmt.Errorf("ACME was enabled: %w", &ezconf.MissingRequiredError{Field: "TlsConfigLoader.AcmeHosts"})
+	}
+
+	holder := l.acmeManager
+	if holder == nil {
+		holder = new(acmeHolder)
+	}
+	manager := holder.get(acmeSettings{
+		directory: optional.GetOr(l.AcmeDirectory, ""),
+		email:     optional.GetOr(l.AcmeEmail, ""),
+		hosts:     strings.Join(hosts, ","),
+		cacheDir:  optional.GetOr(l.AcmeCacheDir, ""),
+	})
+
+	config.GetCertificate = manager.GetCertificate
+	config.NextProtos = []string{"h2", "http/1.1", acme.ALPNProto}
+	return nil
+}
+
+// acmeSettings are the loader fields an autocert.Manager is made from. hosts holds AcmeHosts joined with commas so that
+// settings can be compared with ==.
+type acmeSettings struct {
+	directory string
+	email     string
+	hosts     string
+	cacheDir  string
+}
+
+// acmeHolder holds the autocert.Manager of a loader along with the settings it was made from.
+type acmeHolder struct {
+	mu       sync.Mutex
+	settings acmeSettings
+	manager  *autocert.Manager
+}
+
+// get returns the held manager, or a new one if there is none yet or it was made from other settings.
+func (h *acmeHolder) get(settings acmeSettings) *autocert.Manager {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.manager != nil && h.settings == settings {
+		return h.manager
+	}
+
+	h.settings = settings
+	h.manager = &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Email:      settings.email,
+		HostPolicy: autocert.HostWhitelist(strings.Split(settings.hosts, ",")...),
+		Client:     &acme.Client{DirectoryURL: settings.directory},
+	}
+	if settings.cacheDir != "" {
+		h.manager.Cache = autocert.DirCache(settings.cacheDir)
+	}
+	return h.manager
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTlsVersion parses a TLS version such as 1.2 or TLS1.3 into one of the tls.VersionTLS* constants.
+func ParseTlsVersion(str string) (uint16, error) {
+	v := strings.TrimSpace(strings.ToUpper(str))
+	v = strings.TrimSpace(strings.TrimPrefix(v, "TLS"))
+	version, ok := tlsVersions[v]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q: must be one of 1.0, 1.1, 1.2, or 1.3", str)
+	}
+	return version, nil
+}
+
+// ParseCipherSuites looks up cipher suites by the names given by tls.CipherSuiteName, e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Only the suites returned by tls.CipherSuites are accepted, since the others
+// have known security problems.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	ids := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		ids[suite.Name] = suite.ID
+	}
+
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := ids[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
+
+// versions returns the TLS version range and cipher suites. Cipher suites cannot be configured in TLS 1.3, so nil is
+// returned for them unless they are explicitly set, leaving crypto/tls to pick its secure defaults.
+func (l *TlsConfigLoader) versions() (minVersion, maxVersion uint16, suites []uint16, err error) {
+	minVersion, err = ParseTlsVersion(optional.GetOr(l.MinVersion, "1.3"))
+	if err != nil {
+		return
+	}
+
+	str, ok := l.MaxVersion.Get()
+	if ok {
+		maxVersion, err = ParseTlsVersion(str)
+		if err != nil {
+			return
+		}
+		if maxVersion < minVersion {
+			err = fmt.Errorf("TLS MinVersion %s is higher than MaxVersion %s", tls.VersionName(minVersion),
+				tls.VersionName(maxVersion))
+			return
+		}
+	}
+
+	names, ok := l.CipherSuites.Get()
+	if !ok {
+		return
+	}
+	if minVersion >= tls.VersionTLS13 {
+		err = fmt.Errorf("TLS CipherSuites only apply to TLS 1.2 and below, but MinVersion is %s",
+			tls.VersionName(minVersion))
+		return
+	}
+
+	suites, err = ParseCipherSuites(names)
+	return
+}
+
+// clientAuth configures verification of client certificates. A client CA without RequireClientCert verifies any client
+// certificate that is given without requiring one.
+func (l *TlsConfigLoader) clientAuth(ctx context.Context, config *tls.Config) error {
+	require := optional.GetOr(l.RequireClientCert, false)
+	if l.ClientCAFile.IsNone() {
+		if require {
+			return fmt.Errorf("client certificates are required: %w",
+				&ezconf.MissingRequiredError{Field: "TlsConfigLoader.ClientCAFile"})
+		}
+		return nil
+	}
+
+	var cas []*x509.Certificate
+	err := l.retry.Do(ctx, func() (err error) {
+		cas, err = l.ClientCAFile.ReadCerts()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read client CA file %s: %w", l.ClientCAFile.String(), err)
+	}
+	if len(cas) == 0 {
+		return fmt.Errorf("client CA file %s contains no certificates", l.ClientCAFile.String())
+	}
+
+	pool := x509.NewCertPool()
+	for _, ca := range cas {
+		pool.AddCert(ca)
+	}
+
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	if require {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return nil
+}
+
+type TlsConfigLoaderOption func(TlsConfigLoader) TlsConfigLoader
+
+// TlsLoaderConnectionCallback registers a callback which is handed the negotiated state of every TLS connection made
+// with the produced config, e.g. for auditing that clients are not downgrading. This is opt-in so that connections do
+// not pay for it by default.
+func TlsLoaderConnectionCallback(cb func(tls.ConnectionState)) TlsConfigLoaderOption {
+	return func(c TlsConfigLoader) TlsConfigLoader {
+		c.onConnection = cb
+		return c
+	}
+}
+
+// TlsLoaderConnectionLog logs the negotiated TLS version and cipher suite of every connection to logger.
+func TlsLoaderConnectionLog(logger *slog.Logger) TlsConfigLoaderOption {
+	return TlsLoaderConnectionCallback(func(s tls.ConnectionState) {
+		logger.Info(
+			"TLS connection negotiated",
+			slog.String("version", tls.VersionName(s.Version)),
+			slog.String("cipher", tls.CipherSuiteName(s.CipherSuite)),
+			slog.String("serverName", s.ServerName),
+		)
+	})
+}
+
+// TlsLoaderReadRetry retries reading certificates, keys, and CA files according to p, e.g. so that a certificate on a
+// network filesystem which is briefly missing while it is rotated does not fail startup. By default reads are not
+// retried.
+func TlsLoaderReadRetry(p ezconf.RetryPolicy) TlsConfigLoaderOption {
+	return func(c TlsConfigLoader) TlsConfigLoader {
+		c.retry = p
+		return c
+	}
+}
+
+// TlsLoaderClientSessionCache sets the ClientSessionCache of the produced config, so that clients using it resume
+// sessions with servers they have connected to before, e.g. tls.NewLRUClientSessionCache(0).
+func TlsLoaderClientSessionCache(cache tls.ClientSessionCache) TlsConfigLoaderOption {
+	return func(c TlsConfigLoader) TlsConfigLoader {
+		c.sessionCache = cache
+		return c
+	}
+}
+
+// TlsLoaderSessionTicketKeys sets the keys used to encrypt and decrypt session tickets instead of the random keys
+// crypto/tls rotates itself, e.g. to share them between the servers behind a load balancer. The first key encrypts new
+// tickets and all of them decrypt, so rotate by putting the new key first and updating the loader. Keep the keys
+// secret, since anyone with one can decrypt the sessions it protected.
+func TlsLoaderSessionTicketKeys(keys ...[32]byte) TlsConfigLoaderOption {
+	return func(c TlsConfigLoader) TlsConfigLoader {
+		c.ticketKeys = slices.Clone(keys)
+		return c
+	}
+}
+
+// TlsLoaderCertReload makes the produced configs serve the default certificate through GetCertificate instead of
+// Certificates, so that Reload can swap in a renewed certificate without replacing the config or restarting the server.
+// Connections which are already open keep the certificate they were made with. This cannot be combined with ACME,
+// which renews certificates itself.
+func TlsLoaderCertReload() TlsConfigLoaderOption {
+	return func(c TlsConfigLoader) TlsConfigLoader {
+		c.cert = new(certHolder)
+		return c
+	}
+}
+
+// TlsLoaderAcmeReuse keeps the autocert.Manager made by the first Resolve with ACME and shares it with every copy of
+// the loader, so that a reload keeps the certificates and ACME account the manager holds in memory. The manager is
+// only replaced when one of the ACME fields changes. Resolve itself never writes to the loader, so the option must be
+// given before the loader is copied or used from several goroutines.
+func TlsLoaderAcmeReuse() TlsConfigLoaderOption {
+	return func(c TlsConfigLoader) TlsConfigLoader {
+		c.acmeManager = new(acmeHolder)
+		return c
+	}
+}
+
+func (c TlsConfigLoader) With(o TlsConfigLoaderOption) TlsConfigLoader {
+	return o(c)
+}
+
+// Previous returns the config stored by the last successful Update. It is safe to call while another goroutine runs
+// Update.
 func (l *TlsConfigLoader) Previous() *tls.Config {
-	return l.prev
+	return l.prev.Load()
+}
+
+// Update resolves a new *tls.Config and stores it to be returned by Previous.
+func (l *TlsConfigLoader) Update() (*tls.Config, error) {
+	return l.UpdateContext(context.Background())
+}
+
+// UpdateContext is the same as Update, but stops loading once ctx is done.
+func (l *TlsConfigLoader) UpdateContext(ctx context.Context) (*tls.Config, error) {
+	config, err := l.ResolveContext(ctx)
+	if err != nil {
+		return config, err
+	}
+
+	l.prev.Store(config)
+	return config, nil
+}
+
+// holdCert moves the default certificate of config into the certHolder of the loader, or a new one if certificates are
+// not reloaded, so that it can be swapped for a renewed certificate or OCSP staple while config is in use. Host
+// certificates are still tried first. If the OCSP responder cannot be reached, the certificate is served without a
+// staple until the background refresh gets one, so that an outage of the responder does not stop the server starting.
+func (l *TlsConfigLoader) holdCert(ctx context.Context, config *tls.Config) error {
+	holder := l.cert
+	if holder == nil {
+		holder = new(certHolder)
+	}
+
+	cert := config.Certificates[0]
+	stapling := optional.GetOr(l.OCSPStapling, false)
+	responder := optional.GetOr(l.OCSPResponder, "")
+	var resp *ocsp.Response
+	if stapling {
+		var err error
+		var fetchErr *ocspFetchError
+		resp, err = staple(ctx, responder, &cert)
+		if errors.As(err, &fetchErr) {
+			slog.Warn("serving the certificate without an OCSP staple until the responder is reachable", "error", err)
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	holder.store(&cert, resp)
+	if stapling && resp == nil {
+		// Ask the responder again on the next handshake.
+		holder.refreshAt.Store(0)
+	}
+
+	hostCert := config.GetCertificate
+	config.Certificates = nil
+	config.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if hostCert != nil {
+			cert, err := hostCert(hello)
+			if cert != nil || err != nil {
+				return cert, err
+			}
+		}
+		return holder.get(responder, stapling), nil
+	}
+	return nil
 }
 
-func (l *TlsConfigLoader) Update() (config *tls.Config, err error) {
+// Reload reads the certificate and private key again and serves them to new connections of every config produced by
+// the loader, e.g. after the files were renewed on disk. It requires the TlsLoaderCertReload option. With OCSPStapling
+// an OCSP response is fetched for the new certificate before it is served. On error the current certificate is kept.
+func (l *TlsConfigLoader) Reload() error {
+	return l.ReloadContext(context.Background())
+}
+
+// ReloadContext is the same as Reload, but stops retrying reads once ctx is done.
+func (l *TlsConfigLoader) ReloadContext(ctx context.Context) error {
+	if l.cert == nil {
+		return fmt.Errorf("cannot reload TLS certificates: the loader was not made with TlsLoaderCertReload")
+	}
+
+	var cert tls.Certificate
+	err := l.retry.Do(ctx, func() (err error) {
+		cert, err = l.keyPair()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reload TLS certificate: %w", err)
+	}
+
+	var resp *ocsp.Response
+	if optional.GetOr(l.OCSPStapling, false) {
+		resp, err = staple(ctx, optional.GetOr(l.OCSPResponder, ""), &cert)
+		if err != nil {
+			return fmt.Errorf("failed to reload TLS certificate: %w", err)
+		}
+	}
+
+	l.cert.store(&cert, resp)
+	return nil
+}
+
+// keyPair reads the server certificate from the PKCS12 bundle or from CertificatePEM and PrivateKeyPEM if they are set,
+// and from the Certificate and PrivateKey files otherwise.
+func (l *TlsConfigLoader) keyPair() (tls.Certificate, error) {
+	if l.PKCS12.IsSome() {
+		return l.PKCS12.ReadCert(l.KeyPassphrase)
+	}
+
+	certPEM, ok := l.CertificatePEM.Get()
+	if !ok {
+		return l.PrivateKey.ReadCertWithPassphrase(l.Certificate, l.KeyPassphrase)
+	}
+
+	keyPEM, ok := l.PrivateKeyPEM.Get()
+	if !ok {
+		return tls.Certificate{}, fmt.Errorf("CertificatePEM was set without PrivateKeyPEM")
+	}
+	return file.X509KeyPair([]byte(certPEM), []byte(keyPEM), l.KeyPassphrase)
+}
+
+// Resolve produces a new *tls.Config from the loader's fields without storing it.
+func (l *TlsConfigLoader) Resolve() (*tls.Config, error) {
+	return l.ResolveContext(context.Background())
+}
+
+// ResolveContext is the same as Resolve, but returns ctx.Err() instead of reading certificates and keys once ctx is
+// done. Certificates requested through ACME are fetched later during handshakes, so ctx does not apply to them.
+func (l *TlsConfigLoader) ResolveContext(ctx context.Context) (config *tls.Config, err error) {
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
 	enabled := optional.GetOr(l.TlsEnabled, false)
 	skipVerify := optional.GetOr(l.InsecureSkipVerify, false)
 	name := l.ServerName
 	cert := l.Certificate
 	key := l.PrivateKey
 
+	acmeEnabled := l.AcmeDirectory.IsSome()
+	inline := l.CertificatePEM.IsSome() || l.PrivateKeyPEM.IsSome()
+	bundle := l.PKCS12.IsSome()
+	if bundle && (acmeEnabled || inline || cert.IsSome() || key.IsSome()) {
+		return nil, fmt.Errorf("TLS certificates cannot come from both a PKCS12 bundle and another source, " +
+			"set only one of them")
+	}
+	if acmeEnabled && (cert.IsSome() || key.IsSome() || inline) {
+		return nil, fmt.Errorf("TLS certificates cannot come from both ACME and a Certificate and PrivateKey, " +
+			"set only one of them")
+	}
+	if inline && (cert.IsSome() || key.IsSome()) {
+		return nil, fmt.Errorf("TLS certificates cannot come from both inline PEM and a Certificate and PrivateKey file, " +
+			"set only one of them")
+	}
+	if acmeEnabled && len(l.HostCerts) > 0 {
+		return nil, fmt.Errorf("TLS certificates cannot come from both ACME and HostCerts, set only one of them")
+	}
+	if acmeEnabled && l.cert != nil {
+		return nil, fmt.Errorf("TLS certificates from ACME are renewed automatically and cannot be reloaded")
+	}
+	stapling := optional.GetOr(l.OCSPStapling, false)
+	if enabled && stapling && !(inline || bundle || cert.IsSome() || key.IsSome()) {
+		return nil, fmt.Errorf("OCSP stapling was enabled, " +
+			"but there is no Certificate, inline PEM, or PKCS12 certificate to staple")
+	}
+
 	// Validate key error modes
-	if enabled && (cert.IsNone() || key.IsNone()) {
+	if enabled && inline && (l.CertificatePEM.IsNone() || l.PrivateKeyPEM.IsNone()) {
+		return config, fmt.Errorf("TLS was enabled, but only one of CertificatePEM and PrivateKeyPEM was set.")
+	}
+	if enabled && !acmeEnabled && !inline && !bundle && len(l.HostCerts) == 0 && (cert.IsNone() || key.IsNone()) {
 		// Cert and key not specified, so we can't continue with tls enabled
-		return config, fmt.Errorf("TLS was enabled, but cert or key file was not set.")
+		var missing []string
+		if cert.IsNone() {
+			missing = append(missing, "Certificate")
+		}
+		if key.IsNone() {
+			missing = append(missing, "PrivateKey")
+		}
+		return config, fmt.Errorf("TLS was enabled, but cert or key file was not set: %w", ezconf.Required("TlsConfigLoader",
+			missing))
 	}
 
 	if enabled && !(name.IsSome() || skipVerify) {
 		// If TLS is enabled, then ServerName must be specified unless InsecureSkipVerify is set.
 		// Otherwise we could not actually validate against the certificate.
-		return nil, fmt.Errorf("cannot make a valid tls config with enabled=true, insecureSkipVerify=false, serverName=None")
+		err = &ezconf.MissingRequiredError{Field: "TlsConfigLoader.ServerName"}
+		return nil, fmt.Errorf("cannot make a valid tls config with enabled=true, insecureSkipVerify=false: %w", err)
+	}
+
+	minVersion, maxVersion, suites, err := l.versions()
+	if err != nil {
+		return nil, err
+	}
+
+	ticketsDisabled := optional.GetOr(l.SessionTicketsDisabled, false)
+	if ticketsDisabled && len(l.ticketKeys) > 0 {
+		return nil, fmt.Errorf("session ticket keys were given, but SessionTicketsDisabled is set")
 	}
 
 	// Create the config
-	if enabled {
-		cert, err := key.ReadCert(cert)
+	config = &tls.Config{
+		Certificates:           []tls.Certificate{},
+		MinVersion:             minVersion,
+		MaxVersion:             maxVersion,
+		CurvePreferences:       []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
+		CipherSuites:           suites,
+		InsecureSkipVerify:     skipVerify,
+		SessionTicketsDisabled: ticketsDisabled,
+		ClientSessionCache:     l.sessionCache,
+	}
+	if len(l.ticketKeys) > 0 {
+		config.SetSessionTicketKeys(l.ticketKeys)
+	}
+	if enabled && acmeEnabled {
+		err = l.acme(config)
 		if err != nil {
-			return config, err
+			return nil, err
+		}
+	}
+	if enabled && !acmeEnabled {
+		err = ctx.Err()
+		if err != nil {
+			return nil, err
 		}
 
-		config = &tls.Config{
-			Certificates:     []tls.Certificate{cert},
-			MinVersion:       tls.VersionTLS13,
-			CurvePreferences: []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
-			CipherSuites: []uint16{
-				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-				tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-			},
-			InsecureSkipVerify: skipVerify,
+		if inline || bundle || cert.IsSome() || key.IsSome() {
+			var cert tls.Certificate
+			err := l.retry.Do(ctx, func() (err error) {
+				cert, err = l.keyPair()
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			config.Certificates = []tls.Certificate{cert}
 		}
-	} else {
-		config = &tls.Config{
-			Certificates:     []tls.Certificate{},
-			MinVersion:       tls.VersionTLS13,
-			CurvePreferences: []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
-			CipherSuites: []uint16{
-				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-				tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-			},
-			InsecureSkipVerify: skipVerify,
+	}
+	if enabled && len(l.HostCerts) > 0 {
+		err = l.hostCerts(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(config.Certificates) > 0 && (l.cert != nil || stapling) {
+		err = l.holdCert(ctx, config)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -297,6 +1385,18 @@ func (l *TlsConfigLoader) Update() (config *tls.Config, err error) {
 		config.ServerName = serverName
 	}
 
-	l.prev = config
+	err = l.clientAuth(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.onConnection != nil {
+		cb := l.onConnection
+		config.VerifyConnection = func(s tls.ConnectionState) error {
+			cb(s)
+			return nil
+		}
+	}
+
 	return config, nil
 }
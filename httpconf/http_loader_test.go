@@ -0,0 +1,1731 @@
+package httpconf_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/ezconf/file"
+	"github.com/brnsampson/ezconf/httpconf"
+	"github.com/brnsampson/optional"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/youmark/pkcs8"
+	"golang.org/x/crypto/acme"
+	"gotest.tools/v3/assert"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	testCert       = "../testing/rsa/cert.pem"
+	testKey        = "../testing/rsa/key.pem"
+	testServerName = "www.whobe.us"
+)
+
+var (
+	_ ezconf.Loader[httpconf.HttpServerConfig] = (*httpconf.HttpServerLoader)(nil)
+	_ ezconf.Loader[*tls.Config]               = (*httpconf.TlsConfigLoader)(nil)
+	_ ezconf.Loader[*tls.Config]               = noTls{}
+
+	_ ezconf.ContextLoader[httpconf.HttpServerConfig] = (*httpconf.HttpServerLoader)(nil)
+	_ ezconf.ContextLoader[*tls.Config]               = (*httpconf.TlsConfigLoader)(nil)
+)
+
+// noTls is a Loader[*tls.Config] for tests which do not care about TLS.
+type noTls struct{}
+
+func (noTls) Resolve() (*tls.Config, error) { return nil, nil }
+func (noTls) Update() (*tls.Config, error)  { return nil, nil }
+func (noTls) Previous() *tls.Config         { return nil }
+
+// tlsLoader returns a TlsConfigLoader with TLS enabled using the rsa testing keypair. git does not preserve file
+// permissions, so they are set here before the loader checks them.
+func tlsLoader(t *testing.T) httpconf.TlsConfigLoader {
+	t.Helper()
+	assert.NilError(t, os.Chmod(testCert, file.CertFilePerms))
+	assert.NilError(t, os.Chmod(testKey, file.KeyFilePerms))
+
+	cert, err := file.SomeCert(testCert)
+	assert.NilError(t, err)
+	key, err := file.SomePrivateKey(testKey)
+	assert.NilError(t, err)
+
+	return httpconf.TlsConfigLoader{
+		TlsEnabled:  optional.SomeBool(true),
+		ServerName:  optional.SomeStr(testServerName),
+		Certificate: cert,
+		PrivateKey:  key,
+	}
+}
+
+// handshake performs a TLS handshake over a loopback connection using conf for the server side.
+func handshake(t *testing.T, conf *tls.Config) tls.ConnectionState {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", conf)
+	assert.NilError(t, err)
+	defer ln.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer conn.Close()
+		errs <- conn.(*tls.Conn).Handshake()
+	}()
+
+	client, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{ServerName: testServerName, InsecureSkipVerify: true})
+	assert.NilError(t, err)
+	defer client.Close()
+	assert.NilError(t, <-errs)
+	return client.ConnectionState()
+}
+
+func TestTlsConfigLoaderConnectionCallback(t *testing.T) {
+	states := make(chan tls.ConnectionState, 1)
+	l := tlsLoader(t).With(httpconf.TlsLoaderConnectionCallback(func(s tls.ConnectionState) {
+		states <- s
+	}))
+
+	conf, err := l.Update()
+	assert.NilError(t, err)
+
+	handshake(t, conf)
+	s := <-states
+	assert.Equal(t, uint16(tls.VersionTLS13), s.Version)
+	assert.Assert(t, tls.CipherSuiteName(s.CipherSuite) != "")
+}
+
+func TestTlsConfigLoaderNoConnectionCallback(t *testing.T) {
+	l := tlsLoader(t)
+	conf, err := l.Update()
+	assert.NilError(t, err)
+	assert.Assert(t, conf.VerifyConnection == nil)
+}
+
+func TestTlsConfigLoaderResolveIsPure(t *testing.T) {
+	l := tlsLoader(t)
+
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, testServerName, conf.ServerName)
+	assert.Assert(t, l.Previous() == nil)
+
+	conf, err = l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, conf, l.Previous())
+}
+
+// resumed connects to a server using first and then to one using second with the same client session cache, and
+// reports whether the second connection resumed the session of the first.
+func resumed(t *testing.T, first, second *tls.Config) bool {
+	t.Helper()
+	client := &tls.Config{
+		ServerName:         testServerName,
+		InsecureSkipVerify: true,
+		ClientSessionCache: tls.NewLRUClientSessionCache(1),
+	}
+	var state tls.ConnectionState
+	for _, conf := range []*tls.Config{first, second} {
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", conf)
+		assert.NilError(t, err)
+		defer ln.Close()
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			// Session tickets are sent after the handshake, so write something for the client to read them with.
+			conn.Write([]byte{0})
+		}()
+
+		conn, err := tls.Dial("tcp", ln.Addr().String(), client)
+		assert.NilError(t, err)
+		_, err = conn.Read(make([]byte, 1))
+		assert.NilError(t, err)
+		state = conn.ConnectionState()
+		conn.Close()
+	}
+	return state.DidResume
+}
+
+// ticketLoader returns a tlsLoader with a fresh certificate, since clients do not resume sessions with servers whose
+// certificate has expired, as the testing keypair has.
+func ticketLoader(t *testing.T) httpconf.TlsConfigLoader {
+	t.Helper()
+	hc := hostCert(t, testServerName)
+	l := tlsLoader(t)
+	l.Certificate = hc.Certificate
+	l.PrivateKey = hc.PrivateKey
+	return l
+}
+
+func TestTlsConfigLoaderSessionTickets(t *testing.T) {
+	tests := []struct {
+		name        string
+		disabled    optional.Bool
+		keys        [][32]byte
+		wantResumed bool
+		wantErr     string
+	}{
+		{name: "enabled by default", wantResumed: true},
+		{name: "disabled", disabled: optional.SomeBool(true)},
+		{name: "with keys", keys: [][32]byte{{1}, {2}}, wantResumed: true},
+		{
+			name: "keys while disabled", disabled: optional.SomeBool(true), keys: [][32]byte{{1}},
+			wantErr: "SessionTicketsDisabled is set",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := ticketLoader(t)
+			l.SessionTicketsDisabled = tc.disabled
+			if tc.keys != nil {
+				l = l.With(httpconf.TlsLoaderSessionTicketKeys(tc.keys...))
+			}
+
+			conf, err := l.Resolve()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.disabled.IsSome(), conf.SessionTicketsDisabled)
+			assert.Equal(t, tc.wantResumed, resumed(t, conf, conf))
+		})
+	}
+}
+
+func TestTlsConfigLoaderSessionTicketKeyRotation(t *testing.T) {
+	old, next := [32]byte{1}, [32]byte{2}
+	before := ticketLoader(t).With(httpconf.TlsLoaderSessionTicketKeys(old))
+	beforeConf, err := before.Resolve()
+	assert.NilError(t, err)
+	after := ticketLoader(t).With(httpconf.TlsLoaderSessionTicketKeys(next, old))
+	afterConf, err := after.Resolve()
+	assert.NilError(t, err)
+
+	// After rotating, tickets issued with the old key still resume, but servers which only have the old key cannot
+	// decrypt tickets issued with the new one.
+	assert.Assert(t, resumed(t, beforeConf, afterConf))
+	assert.Assert(t, !resumed(t, afterConf, beforeConf))
+}
+
+func TestTlsConfigLoaderClientSessionCache(t *testing.T) {
+	cache := tls.NewLRUClientSessionCache(1)
+	l := tlsLoader(t).With(httpconf.TlsLoaderClientSessionCache(cache))
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, cache, conf.ClientSessionCache)
+
+	l = tlsLoader(t)
+	conf, err = l.Resolve()
+	assert.NilError(t, err)
+	assert.Assert(t, conf.ClientSessionCache == nil)
+}
+
+func TestGetHttpProtos(t *testing.T) {
+	tests := []struct {
+		name             string
+		proto            httpconf.HttpServerConfigProtos
+		http1            bool
+		http2            bool
+		unencryptedHttp2 bool
+	}{
+		{name: "HTTP", proto: httpconf.HTTP, http1: true},
+		{name: "HTTPS", proto: httpconf.HTTPS, http1: true},
+		{name: "HTTP2", proto: httpconf.HTTP2, http2: true},
+		{name: "UNENCRYPTEDHTTP2", proto: httpconf.UNENCRYPTEDHTTP2, unencryptedHttp2: true},
+		{name: "HTTP3", proto: httpconf.HTTP3, http1: true, http2: true},
+		{name: "unknown", proto: httpconf.HttpServerConfigProtos(42), http1: true, http2: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			protos := tc.proto.GetHttpProtos()
+			assert.Equal(t, tc.http1, protos.HTTP1())
+			assert.Equal(t, tc.http2, protos.HTTP2())
+			assert.Equal(t, tc.unencryptedHttp2, protos.UnencryptedHTTP2())
+		})
+	}
+}
+
+func TestHttpServerLoaderRemoteAddress(t *testing.T) {
+	tests := []struct {
+		name  string
+		proto httpconf.HttpServerConfigProtos
+		want  string
+	}{
+		{name: "HTTP", proto: httpconf.HTTP, want: "http://example.com:8080"},
+		{name: "HTTPS", proto: httpconf.HTTPS, want: "https://example.com:8080"},
+		{name: "HTTP2", proto: httpconf.HTTP2, want: "https://example.com:8080"},
+		{name: "UNENCRYPTEDHTTP2", proto: httpconf.UNENCRYPTEDHTTP2, want: "http://example.com:8080"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := httpconf.HttpServerLoader{
+				Protocol: optional.Some(tc.proto),
+				Hostname: optional.SomeStr("example.com"),
+				BindPort: optional.SomeUint16(8080),
+				Tls:      noTls{},
+			}
+
+			conf, err := l.Resolve()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, conf.RemoteAddress)
+		})
+	}
+}
+
+func TestHttpServerLoaderDisableTls(t *testing.T) {
+	tests := []struct {
+		name       string
+		disable    optional.Bool
+		proto      optional.Option[httpconf.HttpServerConfigProtos]
+		port       optional.Uint16
+		wantRemote string
+		wantPort   uint16
+		wantTls    bool
+	}{
+		{name: "unset", wantRemote: "https://127.0.0.1", wantPort: 443, wantTls: true},
+		{name: "false", disable: optional.SomeBool(false), wantRemote: "https://127.0.0.1", wantPort: 443, wantTls: true},
+		{name: "true", disable: optional.SomeBool(true), wantRemote: "http://127.0.0.1", wantPort: 80},
+		{
+			name: "true with HTTP3", disable: optional.SomeBool(true), proto: optional.Some(httpconf.HTTP3),
+			wantRemote: "http://127.0.0.1", wantPort: 80,
+		},
+		{
+			name: "true with port", disable: optional.SomeBool(true), port: optional.SomeUint16(8080),
+			wantRemote: "http://127.0.0.1:8080", wantPort: 8080,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			loader := tlsLoader(t)
+			l := httpconf.HttpServerLoader{Protocol: tc.proto, BindPort: tc.port, DisableTls: tc.disable, Tls: &loader}
+
+			conf, err := l.Resolve()
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantRemote, conf.RemoteAddress)
+			assert.Equal(t, tc.wantPort, conf.Port)
+			assert.Equal(t, tc.wantTls, conf.TlsConf != nil)
+			assert.Equal(t, tc.wantTls, conf.NewHttpServer().TLSConfig != nil)
+		})
+	}
+}
+
+func TestHttpServerLoaderDisableTlsSkipsTlsLoader(t *testing.T) {
+	// TLS is enabled without any certificate, which fails unless the TLS loader is skipped.
+	loader := httpconf.TlsConfigLoader{TlsEnabled: optional.SomeBool(true), InsecureSkipVerify: optional.SomeBool(true)}
+	l := httpconf.HttpServerLoader{Tls: &loader}
+	_, err := l.Resolve()
+	assert.ErrorContains(t, err, "cert or key file was not set")
+	assert.Assert(t, errors.Is(err, &ezconf.MissingRequiredError{Field: "TlsConfigLoader.Certificate"}))
+	assert.Assert(t, errors.Is(err, &ezconf.MissingRequiredError{Field: "TlsConfigLoader.PrivateKey"}))
+
+	l.DisableTls = optional.SomeBool(true)
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	assert.Assert(t, conf.TlsConf == nil)
+}
+
+func TestHttpServerLoaderResolveDisableTls(t *testing.T) {
+	tests := []struct {
+		name     string
+		disable  optional.Bool
+		fallback optional.Bool
+		wantTls  bool
+	}{
+		{name: "neither", wantTls: true},
+		{name: "fallback", fallback: optional.SomeBool(true)},
+		{name: "field over fallback", disable: optional.SomeBool(false), fallback: optional.SomeBool(true), wantTls: true},
+		{name: "field", disable: optional.SomeBool(true), fallback: optional.SomeBool(false)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			loader := tlsLoader(t)
+			l := httpconf.HttpServerLoader{DisableTls: tc.disable, Tls: &loader}
+
+			conf, err := l.ResolveDisableTls(context.Background(), tc.fallback)
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantTls, conf.TlsConf != nil)
+		})
+	}
+}
+
+func TestHttpServerLoaderDefaultBindAddr(t *testing.T) {
+	l := httpconf.HttpServerLoader{Tls: noTls{}}
+
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, "127.0.0.1", conf.BindAddr)
+	assert.Equal(t, "127.0.0.1:443", conf.NewHttpServer().Addr)
+}
+
+func TestHttpServerLoaderBindAddr(t *testing.T) {
+	tests := []struct {
+		name       string
+		bindAddr   string
+		port       optional.Uint16
+		wantAddr   string
+		wantRemote string
+		wantErr    string
+	}{
+		{
+			name: "IPv4", bindAddr: "10.0.0.1", port: optional.SomeUint16(8080), wantAddr: "10.0.0.1:8080",
+			wantRemote: "http://10.0.0.1:8080",
+		},
+		{
+			name: "IPv6", bindAddr: "::1", port: optional.SomeUint16(8080), wantAddr: "[::1]:8080",
+			wantRemote: "http://[::1]:8080",
+		},
+		{name: "IPv6 default port", bindAddr: "::1", wantAddr: "[::1]:80", wantRemote: "http://[::1]"},
+		{
+			name: "IPv4 all interfaces", bindAddr: "0.0.0.0", port: optional.SomeUint16(8080), wantAddr: "0.0.0.0:8080",
+			wantRemote: "http://0.0.0.0:8080",
+		},
+		{
+			name: "IPv6 all interfaces", bindAddr: "::", port: optional.SomeUint16(8080), wantAddr: "[::]:8080",
+			wantRemote: "http://[::]:8080",
+		},
+		{name: "invalid", bindAddr: "not-an-ip", wantErr: `invalid HttpServerLoader.BindAddr: must be an IP address`},
+		{name: "DNS name", bindAddr: "localhost", wantErr: `got "localhost". Set Hostname for DNS names instead`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := httpconf.HttpServerLoader{
+				Protocol: optional.Some(httpconf.HTTP),
+				BindAddr: optional.SomeStr(tc.bindAddr),
+				BindPort: tc.port,
+				Tls:      noTls{},
+			}
+
+			conf, err := l.Resolve()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				var invalid *ezconf.ValidationError
+				assert.Assert(t, errors.As(err, &invalid))
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantAddr, conf.NewHttpServer().Addr)
+			assert.Equal(t, tc.wantRemote, conf.RemoteAddress)
+		})
+	}
+
+	// DNS names belong in Hostname, and a failed Update keeps the previous config.
+	l := httpconf.HttpServerLoader{
+		Protocol: optional.Some(httpconf.HTTP),
+		Hostname: optional.SomeStr("localhost"),
+		BindPort: optional.SomeUint16(8080),
+		Tls:      noTls{},
+	}
+	conf, err := l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, "127.0.0.1:8080", conf.NewHttpServer().Addr)
+	assert.Equal(t, "http://localhost:8080", conf.RemoteAddress)
+
+	l.BindAddr = optional.SomeStr("localhost")
+	_, err = l.Update()
+	assert.ErrorContains(t, err, "Set Hostname for DNS names instead")
+	assert.Equal(t, "127.0.0.1", l.Previous().BindAddr)
+}
+
+// loopback returns the name of the loopback interface, which is lo on Linux and lo0 on macOS.
+func loopback(t *testing.T) string {
+	t.Helper()
+	ifaces, err := net.Interfaces()
+	assert.NilError(t, err)
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 && iface.Flags&net.FlagUp != 0 {
+			return iface.Name
+		}
+	}
+	t.Skip("no loopback interface")
+	return ""
+}
+
+func TestHttpServerLoaderBindInterface(t *testing.T) {
+	l := httpconf.HttpServerLoader{
+		Protocol:      optional.Some(httpconf.HTTP),
+		BindInterface: optional.SomeStr(loopback(t)),
+		BindPort:      optional.SomeUint16(0),
+		Tls:           noTls{},
+	}
+
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	ip := net.ParseIP(conf.BindAddr)
+	assert.Assert(t, ip != nil && ip.IsLoopback(), conf.BindAddr)
+	assert.Equal(t, conf.BindAddr, conf.Hostname)
+
+	ln, err := conf.Listen()
+	assert.NilError(t, err)
+	ln.Close()
+
+	l.BindInterface = optional.SomeStr("no-such-interface0")
+	_, err = l.Resolve()
+	assert.ErrorContains(t, err, `invalid HttpServerLoader.BindInterface: no network interface "no-such-interface0"`)
+	var invalid *ezconf.ValidationError
+	assert.Assert(t, errors.As(err, &invalid))
+
+	l.BindInterface = optional.SomeStr(loopback(t))
+	l.BindAddr = optional.SomeStr("127.0.0.1")
+	_, err = l.Resolve()
+	assert.ErrorContains(t, err, "invalid HttpServerLoader.BindInterface: cannot be set along with BindAddr")
+}
+
+func TestHttpServerConfigAddr(t *testing.T) {
+	tests := []struct {
+		name string
+		conf httpconf.HttpServerConfig
+		want string
+	}{
+		{name: "explicit port", conf: httpconf.HttpServerConfig{BindAddr: "127.0.0.1", Port: 8080}, want: "127.0.0.1:8080"},
+		{name: "all interfaces", conf: httpconf.HttpServerConfig{Port: 8080}, want: ":8080"},
+		{name: "IPv6", conf: httpconf.HttpServerConfig{BindAddr: "::1", Port: 8080}, want: "[::1]:8080"},
+		{name: "IPv6 all interfaces", conf: httpconf.HttpServerConfig{BindAddr: "::", Port: 8080}, want: "[::]:8080"},
+		{
+			name: "IPv6 with zone", conf: httpconf.HttpServerConfig{BindAddr: "fe80::1%eth0", Port: 8080},
+			want: "[fe80::1%eth0]:8080",
+		},
+		{
+			name: "unix socket", conf: httpconf.HttpServerConfig{BindAddr: "unix:/run/app.sock", SocketPath: "/run/app.sock"},
+			want: "/run/app.sock",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.conf.Addr())
+			assert.Equal(t, tc.want, tc.conf.NewHttpServer().Addr)
+		})
+	}
+}
+
+func TestHttpServerConfigBind(t *testing.T) {
+	tests := []struct {
+		name string
+		port uint16
+	}{
+		{name: "port 0 picks a free port", port: 0},
+		{name: "explicit port is kept", port: freePort(t)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := httpconf.HttpServerLoader{
+				Protocol: optional.Some(httpconf.HTTP),
+				BindPort: optional.SomeUint16(tc.port),
+				Tls:      noTls{},
+			}
+			conf, err := l.Resolve()
+			assert.NilError(t, err)
+
+			ln, bound, err := conf.Bind()
+			assert.NilError(t, err)
+			defer ln.Close()
+
+			assert.Assert(t, bound.Port != 0)
+			if tc.port != 0 {
+				assert.Equal(t, tc.port, bound.Port)
+			}
+			assert.Equal(t, ln.Addr().String(), bound.Addr())
+			assert.Equal(t, "http://127.0.0.1:"+strconv.Itoa(int(bound.Port)), bound.RemoteAddress)
+		})
+	}
+}
+
+// freePort returns a port which was free a moment ago.
+func freePort(t *testing.T) uint16 {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer ln.Close()
+	return uint16(ln.Addr().(*net.TCPAddr).Port)
+}
+
+func TestHttpServerTimeouts(t *testing.T) {
+	l := httpconf.HttpServerLoader{
+		Tls:          noTls{},
+		WriteTimeout: optional.SomeDuration(10 * time.Second),
+		IdleTimeout:  optional.SomeDuration(time.Minute),
+	}
+
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	srv := conf.NewHttpServer()
+	assert.Equal(t, 10*time.Second, srv.WriteTimeout)
+	assert.Equal(t, time.Minute, srv.IdleTimeout)
+
+	srv = conf.With(httpconf.HttpWriteTimeout(5 * time.Second)).With(httpconf.HttpIdleTimeout(0)).NewHttpServer()
+	assert.Equal(t, 5*time.Second, srv.WriteTimeout)
+	assert.Equal(t, time.Duration(0), srv.IdleTimeout)
+
+	l = httpconf.HttpServerLoader{Tls: noTls{}}
+	conf, err = l.Resolve()
+	assert.NilError(t, err)
+	srv = conf.NewHttpServer()
+	assert.Equal(t, time.Duration(0), srv.WriteTimeout)
+	assert.Equal(t, time.Duration(0), srv.IdleTimeout)
+}
+
+func TestHttpServerMaxHeaderBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   optional.Int
+		want    int
+		wantErr string
+	}{
+		{name: "unset uses the http.Server default", want: 0},
+		{name: "zero uses the http.Server default", value: optional.SomeInt(0), want: 0},
+		{name: "positive", value: optional.SomeInt(4096), want: 4096},
+		{
+			name: "negative", value: optional.SomeInt(-1),
+			wantErr: "invalid HttpServerLoader.MaxHeaderBytes: must not be negative, got -1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := httpconf.HttpServerLoader{Tls: noTls{}, MaxHeaderBytes: tc.value}
+			conf, err := l.Resolve()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				var invalid *ezconf.ValidationError
+				assert.Assert(t, errors.As(err, &invalid))
+				assert.Equal(t, "HttpServerLoader.MaxHeaderBytes", invalid.Field)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, conf.NewHttpServer().MaxHeaderBytes)
+			// The option sets the same value as the loader.
+			conf = httpconf.HttpServerConfig{}.With(httpconf.HttpMaxHeaderBytes(tc.want))
+			assert.Equal(t, tc.want, conf.NewHttpServer().MaxHeaderBytes)
+		})
+	}
+}
+
+type ctxKey string
+
+func TestHttpServerContext(t *testing.T) {
+	var bases, conns atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		base, _ := r.Context().Value(ctxKey("base")).(string)
+		conn, _ := r.Context().Value(ctxKey("conn")).(string)
+		io.WriteString(w, base+" "+conn)
+	})
+
+	l := httpconf.HttpServerLoader{Tls: noTls{}}
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	conf = conf.With(httpconf.HttpHandler(handler)).
+		With(httpconf.HttpBaseContext(func(net.Listener) context.Context {
+			bases.Add(1)
+			return context.WithValue(context.Background(), ctxKey("base"), "from-base")
+		})).
+		With(httpconf.HttpConnContext(func(ctx context.Context, c net.Conn) context.Context {
+			conns.Add(1)
+			base, _ := ctx.Value(ctxKey("base")).(string)
+			return context.WithValue(ctx, ctxKey("conn"), "conn-after-"+base)
+		}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	srv := conf.NewHttpServer()
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	resp, err := http.Get("http://" + ln.Addr().String())
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NilError(t, err)
+	assert.Equal(t, "from-base conn-after-from-base", string(body))
+	assert.Equal(t, int32(1), bases.Load())
+	assert.Equal(t, int32(1), conns.Load())
+
+	// Without the options the server uses the http.Server defaults.
+	srv = httpconf.HttpServerConfig{}.NewHttpServer()
+	assert.Assert(t, srv.BaseContext == nil)
+	assert.Assert(t, srv.ConnContext == nil)
+}
+
+func TestHttpServerHealthEndpoints(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "app")
+	})
+	var ready atomic.Bool
+	conf := httpconf.HttpServerConfig{}.
+		With(httpconf.HttpHandler(handler)).
+		With(httpconf.HttpHealthEndpoints("/healthz", "/readyz", ready.Load))
+	srv := httptest.NewServer(conf.NewHttpServer().Handler)
+	defer srv.Close()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		assert.NilError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		assert.NilError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	tests := []struct {
+		name     string
+		ready    bool
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "live while not ready", path: "/healthz", wantCode: http.StatusOK, wantBody: "ok\n"},
+		{name: "not ready", path: "/readyz", wantCode: http.StatusServiceUnavailable, wantBody: "not ready\n"},
+		{name: "ready", ready: true, path: "/readyz", wantCode: http.StatusOK, wantBody: "ok\n"},
+		{name: "live while ready", ready: true, path: "/healthz", wantCode: http.StatusOK, wantBody: "ok\n"},
+		{name: "other paths", path: "/app", wantCode: http.StatusOK, wantBody: "app"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ready.Store(tc.ready)
+			code, body := get(tc.path)
+			assert.Equal(t, tc.wantCode, code)
+			assert.Equal(t, tc.wantBody, body)
+		})
+	}
+
+	// Readiness follows ready back down, e.g. while draining before shutdown.
+	ready.Store(true)
+	code, _ := get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	ready.Store(false)
+	code, _ = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	// A nil ready is always ready.
+	nilReady := httpconf.HttpServerConfig{}.With(httpconf.HttpHealthEndpoints("/healthz", "/readyz", nil))
+	rec := httptest.NewRecorder()
+	nilReady.NewHttpServer().Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestHttpServerUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	l := httpconf.HttpServerLoader{
+		Protocol: optional.Some(httpconf.HTTP),
+		BindAddr: optional.SomeStr(httpconf.UnixSocketPrefix + path),
+		Tls:      noTls{},
+	}
+	l = l.With(httpconf.HttpLoaderHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello over "+r.URL.Path)
+	})))
+
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, path, conf.SocketPath)
+	assert.Equal(t, "http://localhost", conf.RemoteAddress)
+
+	// A stale socket from a previous process is cleaned up.
+	stale, err := net.Listen("unix", path)
+	assert.NilError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := conf.Listen()
+	assert.NilError(t, err)
+	stat, err := os.Stat(path)
+	assert.NilError(t, err)
+	assert.Equal(t, httpconf.SocketFilePerms, stat.Mode().Perm())
+
+	// A socket which is still in use is not.
+	_, err = conf.Listen()
+	assert.ErrorContains(t, err, "socket is in use")
+
+	srv := conf.NewHttpServer()
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get(conf.RemoteAddress + "/ping")
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NilError(t, err)
+	assert.Equal(t, "hello over /ping", string(body))
+}
+
+func TestHttpServerUnixSocketNotASocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	assert.NilError(t, os.WriteFile(path, nil, 0600))
+
+	conf := httpconf.HttpServerConfig{SocketPath: path}
+	_, err := conf.Listen()
+	assert.ErrorContains(t, err, "file exists and is not a socket")
+}
+
+func TestHttpServerRedirect(t *testing.T) {
+	tests := []struct {
+		name   string
+		proto  httpconf.HttpServerConfigProtos
+		port   optional.Uint16
+		target string
+		want   string
+	}{
+		{name: "default port", proto: httpconf.HTTPS, target: "/a/b?x=1&y=2", want: "https://example.com/a/b?x=1&y=2"},
+		{
+			name: "custom port", proto: httpconf.HTTPS, port: optional.SomeUint16(8443), target: "/",
+			want: "https://example.com:8443/",
+		},
+		{name: "plain http", proto: httpconf.HTTP},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := httpconf.HttpServerLoader{
+				Protocol: optional.Some(tc.proto),
+				Hostname: optional.SomeStr("example.com"),
+				BindPort: tc.port,
+				Tls:      noTls{},
+			}
+			conf, err := l.Resolve()
+			assert.NilError(t, err)
+
+			srv := conf.NewRedirectServer()
+			if tc.want == "" {
+				assert.Assert(t, srv == nil)
+				return
+			}
+
+			assert.Equal(t, "127.0.0.1:80", srv.Addr)
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com"+tc.target, nil))
+			assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+			assert.Equal(t, tc.want, rec.Header().Get("Location"))
+		})
+	}
+}
+
+// clientCA writes a new CA certificate to a temporary file and returns it along with a client certificate it signed.
+func clientCA(t *testing.T) (file.Cert, tls.Certificate) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test client CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	assert.NilError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	assert.NilError(t, err)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	clientTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTmpl, ca, &clientKey.PublicKey, caKey)
+	assert.NilError(t, err)
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NilError(t, os.WriteFile(path, nil, file.CertFilePerms))
+	caFile, err := file.SomeCert(path)
+	assert.NilError(t, err)
+	assert.NilError(t, caFile.WriteCerts([]*x509.Certificate{ca}))
+	return caFile, tls.Certificate{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}
+}
+
+// serverHandshake connects to a TLS server using conf with a client using clientConf, and returns the server's
+// handshake error. With TLS 1.3 the client finishes its side before the server has checked the client certificate, so
+// a rejected client certificate only shows up on the server.
+func serverHandshake(t *testing.T, conf, clientConf *tls.Config) error {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", conf)
+	assert.NilError(t, err)
+	defer ln.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer conn.Close()
+		errs <- conn.(*tls.Conn).Handshake()
+	}()
+
+	client, err := tls.Dial("tcp", ln.Addr().String(), clientConf)
+	if err == nil {
+		defer client.Close()
+	}
+	return <-errs
+}
+
+func TestTlsConfigLoaderClientAuth(t *testing.T) {
+	caFile, clientCert := clientCA(t)
+	_, otherCert := clientCA(t)
+
+	tests := []struct {
+		name    string
+		require bool
+		certs   []tls.Certificate
+		wantErr bool
+	}{
+		{name: "required and valid", require: true, certs: []tls.Certificate{clientCert}},
+		{name: "required and missing", require: true, wantErr: true},
+		{name: "required and wrong CA", require: true, certs: []tls.Certificate{otherCert}, wantErr: true},
+		{name: "optional and missing"},
+		{name: "optional and wrong CA", certs: []tls.Certificate{otherCert}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := tlsLoader(t)
+			l.ClientCAFile = caFile
+			l.RequireClientCert = optional.SomeBool(tc.require)
+			conf, err := l.Resolve()
+			assert.NilError(t, err)
+
+			clientConf := &tls.Config{ServerName: testServerName, InsecureSkipVerify: true, Certificates: tc.certs}
+			err = serverHandshake(t, conf, clientConf)
+			if tc.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
+
+// renameLater copies src to dst after delay the way a rotated file is written: to a temporary file which is then
+// renamed over dst.
+func renameLater(t *testing.T, src, dst string, delay time.Duration) <-chan error {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	assert.NilError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		time.Sleep(delay)
+		err := os.WriteFile(dst+".tmp", data, 0600)
+		if err == nil {
+			err = os.Rename(dst+".tmp", dst)
+		}
+		done <- err
+	}()
+	return done
+}
+
+func TestTlsConfigLoaderReadRetry(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ezconf.RetryPolicy
+		wantErr string
+	}{
+		{name: "no retry by default", wantErr: "no such file or directory"},
+		{
+			name:   "retry until the key is rotated in",
+			policy: ezconf.RetryPolicy{Attempts: 100, Backoff: 5 * time.Millisecond, MaxBackoff: 10 * time.Millisecond},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "key.pem")
+			key, err := file.SomePrivateKey(path)
+			assert.NilError(t, err)
+
+			l := tlsLoader(t).With(httpconf.TlsLoaderReadRetry(tc.policy))
+			l.PrivateKey = key
+			done := renameLater(t, testKey, path, 20*time.Millisecond)
+
+			conf, err := l.Resolve()
+			assert.NilError(t, <-done)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, 1, len(conf.Certificates))
+		})
+	}
+}
+
+func TestTlsConfigLoaderClientAuthWithoutCA(t *testing.T) {
+	l := tlsLoader(t)
+	l.RequireClientCert = optional.SomeBool(true)
+
+	_, err := l.Resolve()
+	assert.ErrorContains(t, err, "client certificates are required: missing required field: TlsConfigLoader.ClientCAFile")
+	var missing *ezconf.MissingRequiredError
+	assert.Assert(t, errors.As(err, &missing))
+	assert.Equal(t, "TlsConfigLoader.ClientCAFile", missing.Field)
+}
+
+func TestTlsConfigLoaderVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		min     optional.Str
+		max     optional.Str
+		suites  ezconf.List[string]
+		wantMin uint16
+		wantMax uint16
+		want    []uint16
+		wantErr string
+	}{
+		{name: "defaults", wantMin: tls.VersionTLS13},
+		{
+			name: "range", min: optional.SomeStr("1.2"), max: optional.SomeStr("TLS1.3"), wantMin: tls.VersionTLS12,
+			wantMax: tls.VersionTLS13,
+		},
+		{
+			name:    "suites",
+			min:     optional.SomeStr("tls 1.2"),
+			suites:  ezconf.SomeList("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"),
+			wantMin: tls.VersionTLS12,
+			want:    []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256},
+		},
+		{
+			name: "min above max", min: optional.SomeStr("1.3"), max: optional.SomeStr("1.2"),
+			wantErr: "TLS MinVersion TLS 1.3 is higher than MaxVersion TLS 1.2",
+		},
+		{name: "unknown version", min: optional.SomeStr("1.4"), wantErr: `unknown TLS version "1.4"`},
+		{
+			name: "suites with TLS 1.3", suites: ezconf.SomeList("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"),
+			wantErr: "only apply to TLS 1.2 and below",
+		},
+		{
+			name: "insecure suite", min: optional.SomeStr("1.2"), suites: ezconf.SomeList("TLS_RSA_WITH_RC4_128_SHA"),
+			wantErr: `unknown or insecure cipher suite "TLS_RSA_WITH_RC4_128_SHA"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := tlsLoader(t)
+			l.MinVersion = tc.min
+			l.MaxVersion = tc.max
+			l.CipherSuites = tc.suites
+
+			conf, err := l.Resolve()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantMin, conf.MinVersion)
+			assert.Equal(t, tc.wantMax, conf.MaxVersion)
+			assert.DeepEqual(t, tc.want, conf.CipherSuites)
+		})
+	}
+}
+
+func TestTlsConfigLoaderAcme(t *testing.T) {
+	var hits atomic.Int32
+	directory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer directory.Close()
+
+	l := httpconf.TlsConfigLoader{
+		TlsEnabled:    optional.SomeBool(true),
+		ServerName:    optional.SomeStr(testServerName),
+		AcmeDirectory: optional.SomeStr(directory.URL),
+		AcmeEmail:     optional.SomeStr("admin@whobe.us"),
+		AcmeHosts:     ezconf.SomeList(testServerName),
+		AcmeCacheDir:  optional.SomeStr(t.TempDir()),
+	}
+
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(conf.Certificates))
+	assert.Assert(t, conf.GetCertificate != nil)
+	assert.Assert(t, slices.Contains(conf.NextProtos, acme.ALPNProto))
+
+	// Hosts outside of AcmeHosts are refused without contacting the directory.
+	_, err = conf.GetCertificate(&tls.ClientHelloInfo{ServerName: "evil.example.com"})
+	assert.ErrorContains(t, err, "not configured in HostWhitelist")
+	assert.Equal(t, int32(0), hits.Load())
+
+	// Allowed hosts go to the configured directory.
+	hello := &tls.ClientHelloInfo{ServerName: testServerName, CipherSuites: []uint16{tls.TLS_AES_128_GCM_SHA256}}
+	_, err = conf.GetCertificate(hello)
+	assert.Assert(t, err != nil)
+	assert.Assert(t, hits.Load() > 0)
+}
+
+func TestTlsConfigLoaderAcmeManagerReused(t *testing.T) {
+	// The directory is fetched once by every new manager, as each one gets its own acme.Client caching it.
+	var discovered atomic.Int32
+	directory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		discovered.Add(1)
+		io.WriteString(w, "{}")
+	}))
+	defer directory.Close()
+
+	l := httpconf.TlsConfigLoader{
+		TlsEnabled:    optional.SomeBool(true),
+		ServerName:    optional.SomeStr(testServerName),
+		AcmeDirectory: optional.SomeStr(directory.URL),
+		AcmeHosts:     ezconf.SomeList(testServerName),
+		AcmeCacheDir:  optional.SomeStr(t.TempDir()),
+	}
+	hello := func(host string) *tls.ClientHelloInfo {
+		return &tls.ClientHelloInfo{ServerName: host, CipherSuites: []uint16{tls.TLS_AES_128_GCM_SHA256}}
+	}
+	resolve := func(l *httpconf.TlsConfigLoader, host string) {
+		conf, err := l.Resolve()
+		assert.Check(t, err)
+		_, err = conf.GetCertificate(hello(host))
+		assert.Check(t, err != nil)
+	}
+
+	// Without TlsLoaderAcmeReuse every Resolve makes its own manager.
+	resolve(&l, testServerName)
+	resolve(&l, testServerName)
+	assert.Equal(t, int32(2), discovered.Load())
+
+	// With it, the manager is shared by every copy of the loader, including ones made before the first Resolve and ones
+	// resolved concurrently.
+	l = l.With(httpconf.TlsLoaderAcmeReuse())
+	copied := l
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			resolve(&l, testServerName)
+		}()
+		go func() {
+			defer wg.Done()
+			resolve(&copied, testServerName)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(3), discovered.Load())
+
+	// A change to the ACME fields makes a new manager, which contacts the directory for the newly allowed host.
+	l.AcmeHosts = ezconf.SomeList(testServerName, "other.example.com")
+	resolve(&l, "other.example.com")
+	assert.Equal(t, int32(4), discovered.Load())
+}
+
+func TestTlsConfigLoaderAcmeInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		loader  func(t *testing.T) httpconf.TlsConfigLoader
+		wantErr string
+	}{
+		{
+			name: "static cert as well",
+			loader: func(t *testing.T) httpconf.TlsConfigLoader {
+				l := tlsLoader(t)
+				l.AcmeDirectory = optional.SomeStr("https://acme.invalid/directory")
+				l.AcmeHosts = ezconf.SomeList(testServerName)
+				return l
+			},
+			wantErr: "cannot come from both ACME and a Certificate and PrivateKey",
+		},
+		{
+			name: "no hosts",
+			loader: func(t *testing.T) httpconf.TlsConfigLoader {
+				return httpconf.TlsConfigLoader{
+					TlsEnabled:    optional.SomeBool(true),
+					ServerName:    optional.SomeStr(testServerName),
+					AcmeDirectory: optional.SomeStr("https://acme.invalid/directory"),
+				}
+			},
+			wantErr: "ACME was enabled: missing required field: TlsConfigLoader.AcmeHosts",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := tc.loader(t)
+			_, err := l.Resolve()
+			assert.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+// hostCert writes a new self-signed certificate for host and its key to temporary files.
+func hostCert(t *testing.T, host string) httpconf.HostCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NilError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	assert.NilError(t, err)
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	assert.NilError(t, os.WriteFile(certPath, nil, file.CertFilePerms))
+	assert.NilError(t, os.WriteFile(keyPath, nil, file.KeyFilePerms))
+	certFile, err := file.SomeCert(certPath)
+	assert.NilError(t, err)
+	keyFile, err := file.SomePrivateKey(keyPath)
+	assert.NilError(t, err)
+	assert.NilError(t, certFile.WriteCerts([]*x509.Certificate{leaf}))
+	assert.NilError(t, keyFile.WritePrivateKey(key))
+	return httpconf.HostCert{Certificate: certFile, PrivateKey: keyFile}
+}
+
+// rotateHostCert replaces the files of hc with a new certificate for the same host and returns the new leaf.
+func rotateHostCert(t *testing.T, hc httpconf.HostCert) []byte {
+	t.Helper()
+	next := hostCert(t, testServerName)
+	renewed := [][2]string{
+		{hc.Certificate.String(), next.Certificate.String()},
+		{hc.PrivateKey.String(), next.PrivateKey.String()},
+	}
+	for _, paths := range renewed {
+		data, err := os.ReadFile(paths[1])
+		assert.NilError(t, err)
+		assert.NilError(t, os.WriteFile(paths[0], data, 0))
+	}
+
+	certs, err := next.Certificate.ReadCerts()
+	assert.NilError(t, err)
+	return certs[0].Raw
+}
+
+func TestTlsConfigLoaderCertReload(t *testing.T) {
+	hc := hostCert(t, testServerName)
+	l := tlsLoader(t).With(httpconf.TlsLoaderCertReload())
+	l.Certificate = hc.Certificate
+	l.PrivateKey = hc.PrivateKey
+
+	conf, err := l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(conf.Certificates))
+	first := handshake(t, conf).PeerCertificates[0].Raw
+
+	want := rotateHostCert(t, hc)
+	assert.DeepEqual(t, first, handshake(t, conf).PeerCertificates[0].Raw)
+	assert.NilError(t, l.Reload())
+	assert.DeepEqual(t, want, handshake(t, conf).PeerCertificates[0].Raw)
+	assert.Equal(t, conf, l.Previous())
+
+	// A certificate which cannot be read leaves the current one in place.
+	assert.NilError(t, os.WriteFile(hc.Certificate.String(), []byte("not a certificate"), 0))
+	assert.Assert(t, l.Reload() != nil)
+	assert.DeepEqual(t, want, handshake(t, conf).PeerCertificates[0].Raw)
+}
+
+func TestTlsConfigLoaderCertReloadHostCerts(t *testing.T) {
+	hc := hostCert(t, testServerName)
+	l := tlsLoader(t).With(httpconf.TlsLoaderCertReload())
+	l.Certificate = hc.Certificate
+	l.PrivateKey = hc.PrivateKey
+	l.HostCerts = map[string]httpconf.HostCert{"other.example.com": hostCert(t, "other.example.com")}
+
+	conf, err := l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, "other.example.com", sniHandshake(t, conf, "other.example.com"))
+
+	// Hostnames without a host certificate get the reloaded default certificate.
+	rotateHostCert(t, hc)
+	assert.NilError(t, l.Reload())
+	assert.Equal(t, testServerName, sniHandshake(t, conf, testServerName))
+}
+
+func TestTlsConfigLoaderCertReloadInline(t *testing.T) {
+	certPEM, err := os.ReadFile(testCert)
+	assert.NilError(t, err)
+	keyPEM, err := os.ReadFile(testKey)
+	assert.NilError(t, err)
+
+	l := httpconf.TlsConfigLoader{
+		TlsEnabled:     optional.SomeBool(true),
+		ServerName:     optional.SomeStr(testServerName),
+		CertificatePEM: optional.SomeStr(string(certPEM)),
+		PrivateKeyPEM:  optional.SomeSecret(string(keyPEM)),
+	}
+	l = l.With(httpconf.TlsLoaderCertReload())
+	_, err = l.Update()
+	assert.NilError(t, err)
+	assert.NilError(t, l.Reload())
+
+	// Reload does not validate the loader again, so a key removed since is an error rather than a panic.
+	l.PrivateKeyPEM = optional.NoSecret()
+	err = l.Reload()
+	assert.ErrorContains(t, err, "CertificatePEM was set without PrivateKeyPEM")
+}
+
+func TestTlsConfigLoaderCertReloadInvalid(t *testing.T) {
+	l := tlsLoader(t)
+	err := l.Reload()
+	assert.ErrorContains(t, err, "not made with TlsLoaderCertReload")
+
+	l = httpconf.TlsConfigLoader{
+		TlsEnabled:         optional.SomeBool(true),
+		InsecureSkipVerify: optional.SomeBool(true),
+		AcmeDirectory:      optional.SomeStr("https://acme.example.com/directory"),
+		AcmeHosts:          ezconf.SomeList("example.com"),
+	}
+	l = l.With(httpconf.TlsLoaderCertReload())
+	_, err = l.Resolve()
+	assert.ErrorContains(t, err, "cannot be reloaded")
+}
+
+// sniHandshake connects to a TLS server using conf with the SNI hostname host and returns the name of the
+// certificate it served.
+func sniHandshake(t *testing.T, conf *tls.Config, host string) string {
+	t.Helper()
+	var served string
+	clientConf := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		VerifyConnection: func(s tls.ConnectionState) error {
+			served = s.PeerCertificates[0].Subject.CommonName
+			return nil
+		},
+	}
+	assert.NilError(t, serverHandshake(t, conf, clientConf))
+	return served
+}
+
+func TestTlsConfigLoaderHostCerts(t *testing.T) {
+	l := tlsLoader(t)
+	l.HostCerts = map[string]httpconf.HostCert{
+		"a.example.com":    hostCert(t, "a.example.com"),
+		"B.example.com":    hostCert(t, "b.example.com"),
+		"*.wild.example":   hostCert(t, "*.wild.example"),
+		"api.wild.example": hostCert(t, "api.wild.example"),
+	}
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "a.example.com", want: "a.example.com"},
+		{host: "b.example.com", want: "b.example.com"},
+		{host: "x.wild.example", want: "*.wild.example"},
+		{host: "api.wild.example", want: "api.wild.example"},
+		{host: "c.example.com", want: testServerName},
+		{host: testServerName, want: testServerName},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.host, func(t *testing.T) {
+			assert.Equal(t, tc.want, sniHandshake(t, conf, tc.host))
+		})
+	}
+}
+
+func TestTlsConfigLoaderHostCertsOnly(t *testing.T) {
+	l := httpconf.TlsConfigLoader{
+		TlsEnabled: optional.SomeBool(true),
+		ServerName: optional.SomeStr("a.example.com"),
+		HostCerts:  map[string]httpconf.HostCert{"a.example.com": hostCert(t, "a.example.com")},
+	}
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(conf.Certificates))
+	assert.Equal(t, "a.example.com", sniHandshake(t, conf, "a.example.com"))
+}
+
+func TestTlsConfigLoaderHostCertsInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		loader  func(t *testing.T) httpconf.TlsConfigLoader
+		wantErr string
+	}{
+		{
+			name: "server name not covered",
+			loader: func(t *testing.T) httpconf.TlsConfigLoader {
+				return httpconf.TlsConfigLoader{
+					TlsEnabled: optional.SomeBool(true),
+					ServerName: optional.SomeStr("b.example.com"),
+					HostCerts:  map[string]httpconf.HostCert{"a.example.com": hostCert(t, "a.example.com")},
+				}
+			},
+			wantErr: "no certificate matches ServerName b.example.com",
+		},
+		{
+			name: "missing key",
+			loader: func(t *testing.T) httpconf.TlsConfigLoader {
+				l := tlsLoader(t)
+				hc := hostCert(t, "a.example.com")
+				hc.PrivateKey = file.NoPrivateKey()
+				l.HostCerts = map[string]httpconf.HostCert{"a.example.com": hc}
+				return l
+			},
+			wantErr: "host certificate for a.example.com needs both a Certificate and a PrivateKey",
+		},
+		{
+			name: "acme as well",
+			loader: func(t *testing.T) httpconf.TlsConfigLoader {
+				return httpconf.TlsConfigLoader{
+					TlsEnabled:    optional.SomeBool(true),
+					ServerName:    optional.SomeStr("a.example.com"),
+					AcmeDirectory: optional.SomeStr("https://acme.invalid/directory"),
+					AcmeHosts:     ezconf.SomeList("a.example.com"),
+					HostCerts:     map[string]httpconf.HostCert{"a.example.com": hostCert(t, "a.example.com")},
+				}
+			},
+			wantErr: "cannot come from both ACME and HostCerts",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := tc.loader(t)
+			_, err := l.Resolve()
+			assert.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestHttpServerLoaderWithTlsConfigLoader(t *testing.T) {
+	loader := tlsLoader(t)
+	l := httpconf.HttpServerLoader{Tls: &loader}
+
+	conf, err := l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(conf.TlsConf.Certificates))
+	assert.Equal(t, conf.TlsConf, l.Previous().TlsConf)
+	// Update on the server loader only resolves the TLS loader, it does not store anything on it.
+	assert.Assert(t, loader.Previous() == nil)
+}
+
+// TestHttpServerLoaderConcurrent is meant to be run with -race. It updates both loaders while other goroutines read
+// them.
+func TestHttpServerLoaderConcurrent(t *testing.T) {
+	loader := tlsLoader(t)
+	l := httpconf.HttpServerLoader{Tls: &loader}
+	_, err := l.Update()
+	assert.NilError(t, err)
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				conf := l.Previous()
+				assert.Check(t, conf.TlsConf == nil || len(conf.TlsConf.Certificates) == 1)
+				tlsConf := loader.Previous()
+				assert.Check(t, tlsConf == nil || len(tlsConf.Certificates) == 1)
+			}
+		}()
+	}
+
+	for range 20 {
+		_, err = l.Update()
+		assert.NilError(t, err)
+		_, err = loader.Update()
+		assert.NilError(t, err)
+	}
+	wg.Wait()
+}
+
+func TestHttpServerLoaderWithCopiesPrevious(t *testing.T) {
+	l := httpconf.HttpServerLoader{
+		Protocol: optional.Some(httpconf.HTTP),
+		Hostname: optional.SomeStr("localhost"),
+		BindPort: optional.SomeUint16(8080),
+		Tls:      noTls{},
+	}
+	_, err := l.Update()
+	assert.NilError(t, err)
+
+	c := l.With(httpconf.HttpLoaderErrorLog(nil))
+	assert.Equal(t, uint16(8080), c.Previous().Port)
+
+	l.BindPort = optional.SomeUint16(9090)
+	_, err = l.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, uint16(9090), l.Previous().Port)
+	assert.Equal(t, uint16(8080), c.Previous().Port)
+
+	c.BindPort = optional.SomeUint16(7070)
+	_, err = c.Update()
+	assert.NilError(t, err)
+	assert.Equal(t, uint16(7070), c.Previous().Port)
+	assert.Equal(t, uint16(9090), l.Previous().Port)
+}
+
+func TestHttpServerLoaderUpdateContext(t *testing.T) {
+	loader := tlsLoader(t)
+	l := httpconf.HttpServerLoader{Tls: &loader}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := l.UpdateContext(ctx)
+	assert.Assert(t, errors.Is(err, context.Canceled), "got %v", err)
+	_, err = loader.UpdateContext(ctx)
+	assert.Assert(t, errors.Is(err, context.Canceled), "got %v", err)
+	assert.Assert(t, loader.Previous() == nil)
+}
+
+func TestHttpServerHttp3(t *testing.T) {
+	assert.Equal(t, "https", httpconf.HTTP3.String())
+
+	l := httpconf.HttpServerLoader{Protocol: optional.Some(httpconf.HTTP3), Tls: noTls{}}
+	_, err := l.Resolve()
+	assert.ErrorContains(t, err, "HTTP3 requires TLS")
+
+	_, err = httpconf.HttpServerConfig{}.NewHttp3Server()
+	assert.ErrorContains(t, err, "HTTP/3 requires TLS")
+
+	loader := tlsLoader(t)
+	l.Tls = &loader
+	l.BindAddr = optional.SomeStr(httpconf.UnixSocketPrefix + filepath.Join(t.TempDir(), "app.sock"))
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	_, err = conf.NewHttp3Server()
+	assert.ErrorContains(t, err, "cannot use the unix socket")
+
+	l.BindAddr.Clear()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, r.Proto) })
+	l = l.With(httpconf.HttpLoaderHandler(handler))
+	conf, err = l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, "https://127.0.0.1", conf.RemoteAddress)
+	assert.Equal(t, uint16(443), conf.Port)
+
+	h3, err := conf.NewHttp3Server()
+	assert.NilError(t, err)
+	assert.Equal(t, "127.0.0.1:443", h3.Addr)
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	go h3.Serve(udp)
+	defer h3.Close()
+
+	client := &http.Client{Transport: &http3.Transport{
+		TLSClientConfig: &tls.Config{ServerName: testServerName, InsecureSkipVerify: true},
+	}}
+	resp, err := client.Get("https://" + udp.LocalAddr().String() + "/")
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NilError(t, err)
+	assert.Equal(t, "HTTP/3.0", string(body))
+
+	// The TCP server advertises the port the HTTP/3 server is listening on through Alt-Svc.
+	_, port, err := net.SplitHostPort(udp.LocalAddr().String())
+	assert.NilError(t, err)
+	rec := httptest.NewRecorder()
+	srv := conf.With(httpconf.HttpAltSvc(h3)).NewHttpServer()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, `h3=":`+port+`"; ma=2592000`, rec.Header().Get("Alt-Svc"))
+	assert.Equal(t, "HTTP/1.1", rec.Body.String())
+}
+
+func TestTlsConfigLoaderInlinePEM(t *testing.T) {
+	certPEM, err := os.ReadFile(testCert)
+	assert.NilError(t, err)
+	keyPEM, err := os.ReadFile(testKey)
+	assert.NilError(t, err)
+	files := tlsLoader(t)
+
+	tests := []struct {
+		name    string
+		loader  httpconf.TlsConfigLoader
+		wantErr string
+	}{
+		{
+			name: "inline",
+			loader: httpconf.TlsConfigLoader{
+				TlsEnabled:     optional.SomeBool(true),
+				ServerName:     optional.SomeStr(testServerName),
+				CertificatePEM: optional.SomeStr(string(certPEM)),
+				PrivateKeyPEM:  optional.SomeSecret(string(keyPEM)),
+			},
+		},
+		{
+			name: "missing key",
+			loader: httpconf.TlsConfigLoader{
+				TlsEnabled:     optional.SomeBool(true),
+				ServerName:     optional.SomeStr(testServerName),
+				CertificatePEM: optional.SomeStr(string(certPEM)),
+			},
+			wantErr: "only one of CertificatePEM and PrivateKeyPEM was set",
+		},
+		{
+			name: "inline and files",
+			loader: httpconf.TlsConfigLoader{
+				TlsEnabled:     optional.SomeBool(true),
+				ServerName:     optional.SomeStr(testServerName),
+				Certificate:    files.Certificate,
+				PrivateKey:     files.PrivateKey,
+				CertificatePEM: optional.SomeStr(string(certPEM)),
+				PrivateKeyPEM:  optional.SomeSecret(string(keyPEM)),
+			},
+			wantErr: "cannot come from both inline PEM and a Certificate and PrivateKey file",
+		},
+		{
+			name: "garbage",
+			loader: httpconf.TlsConfigLoader{
+				TlsEnabled:     optional.SomeBool(true),
+				ServerName:     optional.SomeStr(testServerName),
+				CertificatePEM: optional.SomeStr("not a cert"),
+				PrivateKeyPEM:  optional.SomeSecret("not a key"),
+			},
+			wantErr: "no private key found in inline PEM",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conf, err := tc.loader.Resolve()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, 1, len(conf.Certificates))
+			state := handshake(t, conf)
+			assert.Equal(t, testServerName, state.ServerName)
+		})
+	}
+}
+
+func TestTlsConfigLoaderKeyPassphrase(t *testing.T) {
+	loader := tlsLoader(t)
+	key, err := loader.PrivateKey.ReadPrivateKey()
+	assert.NilError(t, err)
+	der, err := pkcs8.MarshalPrivateKey(key, []byte("correct horse"), nil)
+	assert.NilError(t, err)
+
+	path := filepath.Join(t.TempDir(), "encrypted.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der})
+	assert.NilError(t, os.WriteFile(path, data, file.KeyFilePerms))
+	loader.PrivateKey, err = file.SomePrivateKey(path)
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name       string
+		passphrase optional.Secret
+		wantErr    string
+	}{
+		{name: "correct passphrase", passphrase: optional.SomeSecret("correct horse")},
+		{
+			name: "incorrect passphrase", passphrase: optional.SomeSecret("battery staple"),
+			wantErr: file.ErrIncorrectPassphrase.Error(),
+		},
+		{name: "missing passphrase", passphrase: optional.NoSecret(), wantErr: "is encrypted, but no passphrase was given"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			loader.KeyPassphrase = tc.passphrase
+			conf, err := loader.Resolve()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			state := handshake(t, conf)
+			assert.Equal(t, 1, len(state.PeerCertificates))
+		})
+	}
+
+	loader.KeyPassphrase = optional.SomeSecret("battery staple")
+	_, err = loader.Resolve()
+	assert.ErrorIs(t, err, file.ErrIncorrectPassphrase)
+}
+
+func TestTlsConfigLoaderPKCS12(t *testing.T) {
+	files := tlsLoader(t)
+	certs, err := files.Certificate.ReadCerts()
+	assert.NilError(t, err)
+	key, err := files.PrivateKey.ReadPrivateKey()
+	assert.NilError(t, err)
+	data, err := pkcs12.Modern.Encode(key, certs[0], nil, "correct horse")
+	assert.NilError(t, err)
+
+	path := filepath.Join(t.TempDir(), "bundle.p12")
+	assert.NilError(t, os.WriteFile(path, data, file.KeyFilePerms))
+	bundle, err := file.SomePKCS12(path)
+	assert.NilError(t, err)
+
+	loader := httpconf.TlsConfigLoader{
+		TlsEnabled:    optional.SomeBool(true),
+		ServerName:    optional.SomeStr(testServerName),
+		PKCS12:        bundle,
+		KeyPassphrase: optional.SomeSecret("correct horse"),
+	}
+	conf, err := loader.Resolve()
+	assert.NilError(t, err)
+	state := handshake(t, conf)
+	assert.Equal(t, 1, len(state.PeerCertificates))
+
+	loader.KeyPassphrase = optional.SomeSecret("battery staple")
+	_, err = loader.Resolve()
+	assert.ErrorIs(t, err, file.ErrIncorrectPassphrase)
+
+	loader.Certificate = files.Certificate
+	_, err = loader.Resolve()
+	assert.ErrorContains(t, err, "cannot come from both a PKCS12 bundle and another source")
+}
@@ -0,0 +1,208 @@
+package httpconf_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/ezconf/httpconf"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+// accept accepts the next connection on ln in the background.
+func accept(ln net.Listener) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		ch <- err
+	}()
+	return ch
+}
+
+func TestHttpServerMaxConns(t *testing.T) {
+	l := httpconf.HttpServerLoader{
+		BindPort:  optional.SomeUint16(0),
+		Tls:       noTls{},
+		MaxConns:  optional.SomeInt(1),
+		KeepAlive: optional.SomeDuration(30 * time.Second),
+	}
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+
+	ln, err := conf.Listen()
+	assert.NilError(t, err)
+	defer ln.Close()
+
+	first, err := net.Dial("tcp", ln.Addr().String())
+	assert.NilError(t, err)
+	defer first.Close()
+	conn, err := ln.Accept()
+	assert.NilError(t, err)
+
+	// The second connection is queued rather than refused, and only accepted once the first one is closed.
+	second, err := net.Dial("tcp", ln.Addr().String())
+	assert.NilError(t, err)
+	defer second.Close()
+	accepted := accept(ln)
+	select {
+	case err := <-accepted:
+		t.Fatalf("accepted a connection beyond the limit: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	conn.Close()
+	select {
+	case err := <-accepted:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued connection was never accepted")
+	}
+
+	// Closing the listener wakes up an Accept waiting for a slot.
+	third, err := net.Dial("tcp", ln.Addr().String())
+	assert.NilError(t, err)
+	defer third.Close()
+	conn, err = ln.Accept()
+	assert.NilError(t, err)
+	defer conn.Close()
+
+	accepted = accept(ln)
+	ln.Close()
+	select {
+	case err := <-accepted:
+		assert.Assert(t, errors.Is(err, net.ErrClosed), err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Accept did not return after Close")
+	}
+
+	l.MaxConns = optional.SomeInt(-1)
+	_, err = l.Resolve()
+	assert.ErrorContains(t, err, "invalid HttpServerLoader.MaxConns: must not be negative, got -1")
+	var invalid *ezconf.ValidationError
+	assert.Assert(t, errors.As(err, &invalid))
+}
+
+func TestHttpServerServe(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "served")
+	})
+
+	port := freePort(t)
+	l := httpconf.HttpServerLoader{
+		Protocol: optional.Some(httpconf.HTTP),
+		BindPort: optional.SomeUint16(port),
+		Tls:      noTls{},
+	}
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	conf = conf.With(httpconf.HttpHandler(handler)).With(httpconf.HttpMaxConns(4)).With(httpconf.HttpKeepAlive(-1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- conf.Serve(ctx) }()
+
+	url := "http://127.0.0.1:" + strconv.Itoa(int(port))
+	var resp *http.Response
+	for range 50 {
+		resp, err = http.Get(url)
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.NilError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NilError(t, err)
+	assert.Equal(t, "served", string(body))
+
+	cancel()
+	select {
+	case err := <-served:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after ctx was done")
+	}
+}
+
+func TestHttpServerServeWaitsForRequests(t *testing.T) {
+	started := make(chan struct{})
+	var finished atomic.Bool
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "slow")
+		finished.Store(true)
+	})
+
+	port := freePort(t)
+	l := httpconf.HttpServerLoader{
+		Protocol: optional.Some(httpconf.HTTP),
+		BindPort: optional.SomeUint16(port),
+		Tls:      noTls{},
+	}
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	conf = conf.With(httpconf.HttpHandler(handler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- conf.Serve(ctx) }()
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		url := "http://127.0.0.1:" + strconv.Itoa(int(port))
+		var err error
+		var resp *http.Response
+		for range 50 {
+			resp, err = http.Get(url)
+			if err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{string(body), err}
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request never reached the handler")
+	}
+	cancel()
+
+	select {
+	case err := <-served:
+		assert.NilError(t, err)
+		assert.Assert(t, finished.Load(), "Serve returned before the active request finished")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after ctx was done")
+	}
+
+	resp := <-responses
+	assert.NilError(t, resp.err)
+	assert.Equal(t, "slow", resp.body)
+}
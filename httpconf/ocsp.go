@@ -0,0 +1,174 @@
+package httpconf
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	ocspTimeout     = 10 * time.Second // How long fetching an OCSP response may take.
+	ocspRetry       = time.Minute      // How long to wait before asking the OCSP responder again after it failed.
+	ocspRefresh     = time.Hour        // How often to refresh responses without a NextUpdate.
+	maxOCSPResponse = 1 << 20
+)
+
+// certHolder holds the default certificate of a tls.Config so that it can be replaced while the config is in use,
+// either by TlsConfigLoader.Reload or to refresh its OCSP staple.
+type certHolder struct {
+	cert       atomic.Pointer[tls.Certificate]
+	refreshAt  atomic.Int64 // Unix nanoseconds after which the OCSP staple should be fetched again.
+	expires    atomic.Int64 // Unix nanoseconds after which the OCSP staple is no longer valid.
+	refreshing atomic.Bool
+}
+
+// store serves cert from now on. resp is the OCSP response stapled to cert, if any, and decides when it is refreshed.
+func (h *certHolder) store(cert *tls.Certificate, resp *ocsp.Response) {
+	h.cert.Store(cert)
+	if resp != nil {
+		h.setTimes(resp)
+	}
+}
+
+// setTimes schedules the next refresh halfway between the ThisUpdate and NextUpdate of resp, as responders are expected
+// to have a new response ready by then.
+func (h *certHolder) setTimes(resp *ocsp.Response) {
+	if resp.NextUpdate.IsZero() {
+		h.refreshAt.Store(time.Now().Add(ocspRefresh).UnixNano())
+		h.expires.Store(math.MaxInt64)
+		return
+	}
+
+	h.refreshAt.Store(resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate) / 2).UnixNano())
+	h.expires.Store(resp.NextUpdate.UnixNano())
+}
+
+// get returns the current certificate. With stapling, a new OCSP response is fetched from responder in the background
+// once the current one is due for a refresh, so that handshakes never wait on the responder.
+func (h *certHolder) get(responder string, stapling bool) *tls.Certificate {
+	cert := h.cert.Load()
+	if stapling && time.Now().UnixNano() > h.refreshAt.Load() && h.refreshing.CompareAndSwap(false, true) {
+		go h.refresh(responder, cert)
+	}
+	return cert
+}
+
+// refresh staples a new OCSP response to a copy of cert and serves it unless the certificate was replaced meanwhile.
+// If the responder fails, the current staple is kept until it expires and then dropped, since clients reject expired
+// responses.
+func (h *certHolder) refresh(responder string, cert *tls.Certificate) {
+	defer h.refreshing.Store(false)
+
+	next := *cert
+	resp, err := staple(context.Background(), responder, &next)
+	if err == nil {
+		if h.cert.CompareAndSwap(cert, &next) {
+			h.setTimes(resp)
+		}
+		return
+	}
+
+	h.refreshAt.Store(time.Now().Add(ocspRetry).UnixNano())
+	if cert.OCSPStaple != nil && time.Now().UnixNano() > h.expires.Load() {
+		next.OCSPStaple = nil
+		h.cert.CompareAndSwap(cert, &next)
+	}
+}
+
+// ocspFetchError is returned by staple when the OCSP responder could not be reached or gave no usable response, which
+// may well be temporary, as opposed to a certificate which cannot be stapled at all or which the responder reports as
+// bad.
+type ocspFetchError struct {
+	err error
+}
+
+func (e *ocspFetchError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ocspFetchError) Unwrap() error {
+	return e.err
+}
+
+// staple fetches an OCSP response for the leaf of cert and sets it as the OCSPStaple of cert. The response is requested
+// from responder, or from the first OCSP server named by the leaf if responder is empty. The issuer must follow the
+// leaf in the certificate chain, and an error is returned unless the response says the certificate is good. Failing to
+// get a response at all is an *ocspFetchError.
+func staple(ctx context.Context, responder string, cert *tls.Certificate) (*ocsp.Response, error) {
+	if len(cert.Certificate) < 2 {
+		return nil, fmt.Errorf("cannot staple an OCSP response: the certificate chain must include the issuer after the leaf")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("cannot staple an OCSP response: %w", err)
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, fmt.Errorf("cannot staple an OCSP response: %w", err)
+	}
+
+	if responder == "" {
+		if len(leaf.OCSPServer) == 0 {
+			return nil, fmt.Errorf("cannot staple an OCSP response: " +
+				"the certificate names no OCSP server, so OCSPResponder must be set")
+		}
+		responder = leaf.OCSPServer[0]
+	}
+
+	body, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ocspTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responder, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+
+	httpResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, &ocspFetchError{fmt.Errorf("failed to fetch OCSP response from %s: %w", responder, err)}
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, &ocspFetchError{fmt.Errorf("failed to fetch OCSP response from %s: %s", responder, httpResp.Status)}
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(httpResp.Body, maxOCSPResponse))
+	if err != nil {
+		return nil, &ocspFetchError{fmt.Errorf("failed to fetch OCSP response from %s: %w", responder, err)}
+	}
+	resp, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return nil, &ocspFetchError{fmt.Errorf("invalid OCSP response from %s: %w", responder, err)}
+	}
+	if resp.Status != ocsp.Good {
+		return nil, fmt.Errorf("OCSP responder %s reports the certificate as %s", responder, ocspStatus(resp.Status))
+	}
+
+	cert.OCSPStaple = raw
+	return resp, nil
+}
+
+// ocspStatus names the certificate status of an OCSP response.
+func ocspStatus(status int) string {
+	switch status {
+	case ocsp.Good:
+		return "good"
+	case ocsp.Revoked:
+		return "revoked"
+	}
+	return "unknown"
+}
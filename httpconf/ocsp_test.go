@@ -0,0 +1,324 @@
+package httpconf_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brnsampson/ezconf/file"
+	"github.com/brnsampson/ezconf/httpconf"
+	"github.com/brnsampson/optional"
+	"golang.org/x/crypto/ocsp"
+	"gotest.tools/v3/assert"
+)
+
+// ocspResponder is a mock OCSP responder which signs responses with the CA that issued the certificate they are for.
+type ocspResponder struct {
+	*httptest.Server
+	ca     *x509.Certificate
+	caKey  crypto.Signer
+	mu     sync.Mutex
+	status int
+	down   bool          // Whether requests fail with 503 Service Unavailable.
+	valid  time.Duration // NextUpdate - ThisUpdate of the next response.
+	age    time.Duration // How long ago the next response was produced.
+	served [][]byte
+}
+
+func newOCSPResponder(t *testing.T) *ocspResponder {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test OCSP CA"},
+		NotBefore:             time.Now().Add(-2 * time.Hour),
+		NotAfter:              time.Now().Add(2 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &caKey.PublicKey, caKey)
+	assert.NilError(t, err)
+	ca, err := x509.ParseCertificate(der)
+	assert.NilError(t, err)
+
+	r := &ocspResponder{ca: ca, caKey: caKey, status: ocsp.Good, valid: time.Hour}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.ServeHTTP))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *ocspResponder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ocspReq, err := ocsp.ParseRequest(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.down {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+		return
+	}
+	now := time.Now().Add(-r.age).Truncate(time.Second)
+	resp, err := ocsp.CreateResponse(r.ca, r.ca, ocsp.Response{
+		Status:       r.status,
+		SerialNumber: ocspReq.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(r.valid),
+		RevokedAt:    now,
+	}, r.caKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	r.served = append(r.served, resp)
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(resp)
+}
+
+// latest returns the last response served and how many have been served.
+func (r *ocspResponder) latest() ([]byte, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.served) == 0 {
+		return nil, 0
+	}
+	return r.served[len(r.served)-1], len(r.served)
+}
+
+// cert writes a certificate for testServerName issued by the CA of r, followed by the CA, and its key to temporary
+// files. The certificate names ocspServer as its OCSP server unless it is empty.
+func (r *ocspResponder) cert(t *testing.T, ocspServer string) httpconf.HostCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: testServerName},
+		DNSNames:     []string{testServerName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ocspServer != "" {
+		tmpl.OCSPServer = []string{ocspServer}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, r.ca, &key.PublicKey, r.caKey)
+	assert.NilError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	assert.NilError(t, err)
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	assert.NilError(t, os.WriteFile(certPath, nil, file.CertFilePerms))
+	assert.NilError(t, os.WriteFile(keyPath, nil, file.KeyFilePerms))
+	certFile, err := file.SomeCert(certPath)
+	assert.NilError(t, err)
+	keyFile, err := file.SomePrivateKey(keyPath)
+	assert.NilError(t, err)
+	assert.NilError(t, certFile.WriteCerts([]*x509.Certificate{leaf, r.ca}))
+	assert.NilError(t, keyFile.WritePrivateKey(key))
+	return httpconf.HostCert{Certificate: certFile, PrivateKey: keyFile}
+}
+
+// stapledLoader returns a tlsLoader serving hc with OCSP stapling enabled.
+func stapledLoader(t *testing.T, hc httpconf.HostCert) httpconf.TlsConfigLoader {
+	t.Helper()
+	l := tlsLoader(t)
+	l.Certificate = hc.Certificate
+	l.PrivateKey = hc.PrivateKey
+	l.OCSPStapling = optional.SomeBool(true)
+	return l
+}
+
+func TestTlsConfigLoaderOCSPStapling(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		certServer bool   // Whether the certificate names the responder as its OCSP server.
+		responder  string // OCSPResponder, with "mock" replaced by the responder URL.
+		wantErr    string
+	}{
+		{name: "responder from the certificate", status: ocsp.Good, certServer: true},
+		{name: "configured responder", status: ocsp.Good, responder: "mock"},
+		{name: "configured responder overrides the certificate", status: ocsp.Good, certServer: true, responder: "mock"},
+		{name: "revoked", status: ocsp.Revoked, certServer: true, wantErr: "reports the certificate as revoked"},
+		{name: "no responder", status: ocsp.Good, wantErr: "OCSPResponder must be set"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newOCSPResponder(t)
+			r.status = tc.status
+			certServer := ""
+			if tc.certServer {
+				// Requests to the server named by the certificate fail if the configured responder is not used first.
+				certServer = r.URL
+				if tc.responder != "" {
+					certServer = "http://127.0.0.1:1"
+				}
+			}
+
+			l := stapledLoader(t, r.cert(t, certServer))
+			if tc.responder == "mock" {
+				l.OCSPResponder = optional.SomeStr(r.URL)
+			}
+			if tc.responder != "" && tc.responder != "mock" {
+				l.OCSPResponder = optional.SomeStr(tc.responder)
+			}
+
+			conf, err := l.Resolve()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			state := handshake(t, conf)
+			want, _ := r.latest()
+			assert.DeepEqual(t, want, state.OCSPResponse)
+			resp, err := ocsp.ParseResponseForCert(state.OCSPResponse, state.PeerCertificates[0], r.ca)
+			assert.NilError(t, err)
+			assert.Equal(t, ocsp.Good, resp.Status)
+		})
+	}
+}
+
+func TestTlsConfigLoaderOCSPResponderDown(t *testing.T) {
+	r := newOCSPResponder(t)
+	r.down = true
+	l := stapledLoader(t, r.cert(t, r.URL))
+
+	// The server starts without a staple rather than failing.
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	r.mu.Lock()
+	r.down = false
+	r.mu.Unlock()
+	assert.Equal(t, 0, len(handshake(t, conf).OCSPResponse))
+
+	// The first handshake fetches the staple in the background.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		latest, served := r.latest()
+		staple := handshake(t, conf).OCSPResponse
+		if served == 1 && string(staple) == string(latest) {
+			break
+		}
+		assert.Assert(t, time.Now().Before(deadline), "the OCSP staple was not fetched, %d responses served", served)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTlsConfigLoaderOCSPStaplingDisabled(t *testing.T) {
+	r := newOCSPResponder(t)
+	l := stapledLoader(t, r.cert(t, r.URL))
+	l.OCSPStapling = optional.SomeBool(false)
+
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(handshake(t, conf).OCSPResponse))
+	_, served := r.latest()
+	assert.Equal(t, 0, served)
+}
+
+func TestTlsConfigLoaderOCSPRefresh(t *testing.T) {
+	r := newOCSPResponder(t)
+	// The first response is past the halfway point of its validity, so it is refreshed on the first handshake.
+	r.age = 45 * time.Minute
+	l := stapledLoader(t, r.cert(t, r.URL))
+
+	conf, err := l.Resolve()
+	assert.NilError(t, err)
+	first, _ := r.latest()
+	r.mu.Lock()
+	r.age = 0
+	r.mu.Unlock()
+	assert.DeepEqual(t, first, handshake(t, conf).OCSPResponse)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		latest, served := r.latest()
+		staple := handshake(t, conf).OCSPResponse
+		if served == 2 && string(staple) == string(latest) {
+			break
+		}
+		assert.Assert(t, time.Now().Before(deadline), "the OCSP staple was not refreshed, %d responses served", served)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The new response is fresh, so it is not fetched again.
+	handshake(t, conf)
+	_, served := r.latest()
+	assert.Equal(t, 2, served)
+}
+
+func TestTlsConfigLoaderOCSPReload(t *testing.T) {
+	r := newOCSPResponder(t)
+	hc := r.cert(t, r.URL)
+	l := stapledLoader(t, hc).With(httpconf.TlsLoaderCertReload())
+
+	conf, err := l.Update()
+	assert.NilError(t, err)
+	first, _ := r.latest()
+	assert.DeepEqual(t, first, handshake(t, conf).OCSPResponse)
+
+	next := r.cert(t, r.URL)
+	renewed := [][2]string{
+		{hc.Certificate.String(), next.Certificate.String()},
+		{hc.PrivateKey.String(), next.PrivateKey.String()},
+	}
+	for _, paths := range renewed {
+		data, err := os.ReadFile(paths[1])
+		assert.NilError(t, err)
+		assert.NilError(t, os.WriteFile(paths[0], data, 0))
+	}
+
+	// The reloaded certificate gets its own staple.
+	assert.NilError(t, l.Reload())
+	latest, served := r.latest()
+	assert.Equal(t, 2, served)
+	state := handshake(t, conf)
+	assert.DeepEqual(t, latest, state.OCSPResponse)
+	_, err = ocsp.ParseResponseForCert(state.OCSPResponse, state.PeerCertificates[0], r.ca)
+	assert.NilError(t, err)
+}
+
+func TestTlsConfigLoaderOCSPStaplingInvalid(t *testing.T) {
+	l := tlsLoader(t)
+	l.OCSPStapling = optional.SomeBool(true)
+	l.OCSPResponder = optional.SomeStr("http://127.0.0.1:1")
+	_, err := l.Resolve()
+	// The testing keypair is self-signed, so there is no issuer to ask about it.
+	assert.ErrorContains(t, err, "the certificate chain must include the issuer")
+
+	l = tlsLoader(t)
+	l.OCSPStapling = optional.SomeBool(true)
+	l.Certificate = file.Cert{}
+	l.PrivateKey = file.PrivateKey{}
+	l.HostCerts = map[string]httpconf.HostCert{testServerName: hostCert(t, testServerName)}
+	_, err = l.Resolve()
+	assert.ErrorContains(t, err, "no Certificate, inline PEM, or PKCS12 certificate to staple")
+}
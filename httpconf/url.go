@@ -0,0 +1,187 @@
+package httpconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+)
+
+// URL is an optional url.URL for endpoint fields. It parses with url.Parse when loaded from flags, env vars, or config
+// files and rejects anything that is not an absolute url. If a set of schemes is given, with NoURL or a schemes tag
+// applied by ApplyURLSchemes, the url must also use one of them, which catches bad endpoint config at load time rather
+// than at first use.
+type URL struct {
+	optional.Option[url.URL]
+	schemes []string
+}
+
+// SomeURL parses raw and returns a URL holding the result. An error is returned if raw is not a valid absolute url or
+// does not use one of the given schemes.
+func SomeURL(raw string, schemes ...string) (URL, error) {
+	o := NoURL(schemes...)
+	err := o.Set(raw)
+	return o, err
+}
+
+// NoURL returns a None URL which will only accept the given schemes when it is later set. No schemes means any scheme
+// is accepted.
+func NoURL(schemes ...string) URL {
+	return URL{optional.None[url.URL](), schemes}
+}
+
+// WithSchemes returns a copy of the URL which will only accept the given schemes. The existing value is not checked
+// against the new schemes.
+func (o URL) WithSchemes(schemes ...string) URL {
+	o.schemes = schemes
+	return o
+}
+
+func (o URL) Schemes() []string {
+	return o.schemes
+}
+
+var urlType = reflect.TypeFor[URL]()
+
+// ApplyURLSchemes sets the schemes of every URL field of the loader pointed to by loader from its schemes tag, e.g.
+// Endpoint URL `schemes:"https,grpc"`. Nested structs and non-nil pointers to them are walked, and ignored fields are
+// skipped. Call it on every loader before loading values into it, e.g. with ezconf.LoadEnvStruct or a config file
+// decoder, since a URL only checks the scheme when it is set.
+func ApplyURLSchemes(loader any) error {
+	v := reflect.ValueOf(loader)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot apply url schemes to %T: not a pointer to a struct", loader)
+	}
+	applyURLSchemes(v.Elem())
+	return nil
+}
+
+func applyURLSchemes(v reflect.Value) {
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		if !f.IsExported() || ezconf.Ignored(f) {
+			continue
+		}
+
+		fv := v.Field(i)
+		for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Type() == urlType {
+			tag := f.Tag.Get("schemes")
+			if tag == "" {
+				continue
+			}
+			var schemes []string
+			for scheme := range strings.SplitSeq(tag, ",") {
+				schemes = append(schemes, strings.TrimSpace(scheme))
+			}
+			u := fv.Addr().Interface().(*URL)
+			*u = u.WithSchemes(schemes...)
+			continue
+		}
+		if fv.Kind() == reflect.Struct {
+			applyURLSchemes(fv)
+		}
+	}
+}
+
+// URL returns the wrapped value as a *url.URL, or nil if the option is None.
+func (o URL) URL() *url.URL {
+	tmp, ok := o.Get()
+	if !ok {
+		return nil
+	}
+	return &tmp
+}
+
+func (o URL) Type() string {
+	return "URL"
+}
+
+func (o *URL) Set(str string) error {
+	return o.UnmarshalText([]byte(str))
+}
+
+func (o URL) String() string {
+	if o.IsNone() {
+		return "None[URL]"
+	}
+
+	tmp, ok := o.Get()
+	if !ok {
+		return "Error[URL]"
+	}
+	return tmp.String()
+}
+
+func (o URL) MarshalText() (text []byte, err error) {
+	if o.IsNone() {
+		return []byte("None"), nil
+	}
+
+	tmp, ok := o.Get()
+	if !ok {
+		return text, fmt.Errorf("attempted to Get URL with None value")
+	}
+	return []byte(tmp.String()), nil
+}
+
+func (o *URL) UnmarshalText(text []byte) error {
+	tmp := string(text)
+	if tmp == "None" || tmp == "none" || tmp == "null" || tmp == "nil" {
+		o.Clear()
+		return nil
+	}
+
+	parsed, err := url.Parse(tmp)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %w", tmp, err)
+	}
+
+	if !parsed.IsAbs() {
+		return fmt.Errorf("invalid url %q: must be an absolute url including the scheme", tmp)
+	}
+
+	allowed := func(s string) bool { return strings.EqualFold(s, parsed.Scheme) }
+	if len(o.schemes) > 0 && !slices.ContainsFunc(o.schemes, allowed) {
+		return fmt.Errorf("invalid url %q: scheme %q is not one of [%s]", tmp, parsed.Scheme, strings.Join(o.schemes, ", "))
+	}
+
+	o.Replace(*parsed)
+	return nil
+}
+
+// MarshalJSON overrides the inner Option so that urls are written as a string rather than as a url.URL struct.
+func (o URL) MarshalJSON() ([]byte, error) {
+	if o.IsNone() {
+		return json.Marshal(nil)
+	}
+
+	tmp, err := o.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(tmp))
+}
+
+// UnmarshalJSON overrides the inner Option so that urls are read from a string and validated the same way as Set.
+func (o *URL) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		o.Clear()
+		return nil
+	}
+
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+
+	return o.UnmarshalText([]byte(s))
+}
@@ -0,0 +1,109 @@
+package httpconf_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/ezconf/httpconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestURLSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		schemes []string
+		wantErr string
+	}{
+		{name: "any scheme", raw: "http://example.com/path"},
+		{name: "allowed scheme", raw: "https://example.com", schemes: []string{"https", "grpc"}},
+		{name: "allowed scheme case insensitive", raw: "GRPC://example.com:9000", schemes: []string{"https", "grpc"}},
+		{
+			name: "disallowed scheme", raw: "http://example.com", schemes: []string{"https", "grpc"},
+			wantErr: `scheme "http" is not one of [https, grpc]`,
+		},
+		{name: "relative url", raw: "example.com/path", wantErr: "must be an absolute url"},
+		{name: "malformed url", raw: "https://exa mple.com", wantErr: "invalid url"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := httpconf.NoURL(tc.schemes...)
+			err := o.Set(tc.raw)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				assert.Assert(t, o.IsNone())
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Assert(t, o.IsSome())
+			assert.Assert(t, o.URL() != nil)
+		})
+	}
+}
+
+func TestURLNone(t *testing.T) {
+	o, err := httpconf.SomeURL("https://example.com", "https")
+	assert.NilError(t, err)
+	assert.Equal(t, "https://example.com", o.String())
+
+	err = o.Set("none")
+	assert.NilError(t, err)
+	assert.Assert(t, o.IsNone())
+	assert.Assert(t, o.URL() == nil)
+	assert.Equal(t, "None[URL]", o.String())
+}
+
+func TestURLJSON(t *testing.T) {
+	o, err := httpconf.SomeURL("grpc://example.com:9000/svc")
+	assert.NilError(t, err)
+
+	data, err := json.Marshal(o)
+	assert.NilError(t, err)
+	assert.Equal(t, `"grpc://example.com:9000/svc"`, string(data))
+
+	u := httpconf.NoURL("https")
+	err = json.Unmarshal(data, &u)
+	assert.ErrorContains(t, err, `scheme "grpc"`)
+
+	u = httpconf.NoURL("grpc")
+	err = json.Unmarshal(data, &u)
+	assert.NilError(t, err)
+	assert.Equal(t, "example.com:9000", u.URL().Host)
+}
+
+type endpointsLoader struct {
+	Endpoint httpconf.URL `schemes:"https, grpc" env:"ENDPOINT"`
+	Any      httpconf.URL `env:"ANY"`
+	Backend  *struct {
+		Health httpconf.URL `schemes:"http" env:"BACKEND_HEALTH"`
+	}
+}
+
+func TestApplyURLSchemes(t *testing.T) {
+	l := endpointsLoader{}
+	l.Backend = &struct {
+		Health httpconf.URL `schemes:"http" env:"BACKEND_HEALTH"`
+	}{}
+	assert.NilError(t, httpconf.ApplyURLSchemes(&l))
+	assert.DeepEqual(t, []string{"https", "grpc"}, l.Endpoint.Schemes())
+	assert.Equal(t, 0, len(l.Any.Schemes()))
+	assert.DeepEqual(t, []string{"http"}, l.Backend.Health.Schemes())
+
+	// The schemes are checked by every source, e.g. env vars and config files.
+	t.Setenv("TEST_ENDPOINT", "grpc://example.com:9000")
+	t.Setenv("TEST_ANY", "ftp://example.com")
+	t.Setenv("TEST_BACKEND_HEALTH", "https://example.com/healthz")
+	err := ezconf.LoadEnvStruct(&l, "TEST_", false)
+	assert.ErrorContains(t, err, `scheme "https" is not one of [http]`)
+	assert.Equal(t, "example.com:9000", l.Endpoint.URL().Host)
+	assert.Equal(t, "ftp", l.Any.URL().Scheme)
+
+	err = json.Unmarshal([]byte(`{"Endpoint": "http://example.com"}`), &l)
+	assert.ErrorContains(t, err, `scheme "http" is not one of [https, grpc]`)
+
+	err = httpconf.ApplyURLSchemes(l)
+	assert.ErrorContains(t, err, "not a pointer to a struct")
+}
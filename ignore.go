@@ -0,0 +1,10 @@
+package ezconf
+
+import "reflect"
+
+// Ignored reports whether f is tagged config:"-" or ezconf:"-". Such fields are runtime-only, e.g. caches or values set
+// once the service is running, so they are never loaded, saved, diffed, checked for env collisions, or described by
+// JSONSchema, and keep whatever value the program gives them.
+func Ignored(f reflect.StructField) bool {
+	return f.Tag.Get("config") == "-" || f.Tag.Get("ezconf") == "-"
+}
@@ -0,0 +1,35 @@
+package ezconf
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/brnsampson/optional"
+)
+
+// KeyedSource is a source of config values looked up by key, such as a *viper.Viper, so that a service can move its
+// config over from another library one field at a time. Loaders use field paths such as MyDB.Port as keys, which viper
+// matches case-insensitively.
+type KeyedSource interface {
+	IsSet(key string) bool
+	GetString(key string) string
+}
+
+// LoadKeyed sets v from key in src, parsing the value as a string in the same way as an env var. Keys which src does
+// not have leave v untouched, as does a nil src.
+func LoadKeyed(src KeyedSource, v flag.Value, key string) error {
+	if src == nil || !src.IsSet(key) {
+		return nil
+	}
+
+	b, ok := v.(*optional.Bool)
+	if ok {
+		v = &boolFlag{b, false}
+	}
+
+	err := v.Set(src.GetString(key))
+	if err != nil {
+		return fmt.Errorf("failed to load key %s: %w", key, err)
+	}
+	return nil
+}
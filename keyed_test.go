@@ -0,0 +1,56 @@
+package ezconf_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+// keyed is a KeyedSource backed by a map, standing in for a *viper.Viper.
+type keyed map[string]string
+
+func (k keyed) IsSet(key string) bool {
+	_, ok := k[key]
+	return ok
+}
+
+func (k keyed) GetString(key string) string {
+	return k[key]
+}
+
+func TestLoadKeyed(t *testing.T) {
+	src := keyed{"Port": "8080", "Debug": "enabled", "Bad": "x"}
+
+	tests := []struct {
+		name    string
+		src     ezconf.KeyedSource
+		key     string
+		value   optional.Uint16
+		want    optional.Uint16
+		wantErr string
+	}{
+		{name: "set", src: src, key: "Port", want: optional.SomeUint16(8080)},
+		{name: "unset", src: src, key: "Other", value: optional.SomeUint16(1), want: optional.SomeUint16(1)},
+		{name: "nil source", key: "Port", value: optional.SomeUint16(1), want: optional.SomeUint16(1)},
+		{name: "invalid", src: src, key: "Bad", wantErr: "failed to load key Bad"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ezconf.LoadKeyed(tc.src, &tc.value, tc.key)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, tc.value)
+		})
+	}
+
+	// Booleans are parsed like env vars.
+	debug := optional.NoBool()
+	assert.NilError(t, ezconf.LoadKeyed(src, &debug, "Debug"))
+	assert.Equal(t, optional.SomeBool(true), debug)
+}
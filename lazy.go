@@ -0,0 +1,162 @@
+package ezconf
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/brnsampson/ezconf/file"
+	"github.com/brnsampson/optional"
+)
+
+type lazy struct {
+	mu      sync.Mutex
+	fetched bool
+	fetch   func() (optional.Secret, error)
+	check   func() error
+	secret  optional.Secret
+}
+
+// LazySecret is a secret which is not fetched until the first call to Get, for secrets which are expensive to fetch and
+// rarely used so that they do not slow down startup. The first successful fetch is cached and shared by every copy of
+// the config. A failed fetch is not cached, so the next Get tries again, e.g. after a rate limit has passed.
+type LazySecret struct {
+	l *lazy
+}
+
+// NewLazySecret returns a LazySecret which calls fetch on first access. The check function is called by Check to
+// confirm that the secret is reachable without fetching it, and may be nil.
+func NewLazySecret(fetch func() (optional.Secret, error), check func() error) LazySecret {
+	return LazySecret{&lazy{fetch: fetch, check: check}}
+}
+
+var lazySecrets = struct {
+	sync.Mutex
+	m map[string]LazySecret
+}{m: make(map[string]LazySecret)}
+
+// LazySecretRef returns a LazySecret which fetches the secret named by ref, which has the form provider:key as given in
+// a secret tag, with LoadSecret on first access. Every call with the same ref returns the same LazySecret, so a config
+// loaded again by Update fetches the secret at most once and compares equal to the one before, until a provider is
+// registered under the same name again. Check confirms that the provider is registered and, if it is a SecretChecker,
+// that it can reach the secret.
+func LazySecretRef(ref string) LazySecret {
+	lazySecrets.Lock()
+	defer lazySecrets.Unlock()
+	s, ok := lazySecrets.m[ref]
+	if ok {
+		return s
+	}
+
+	fetch := func() (optional.Secret, error) {
+		secret := optional.NoSecret()
+		err := LoadSecret(&secret, ref)
+		return secret, err
+	}
+
+	check := func() error {
+		p, key, err := secretProvider(ref)
+		if err != nil {
+			return fmt.Errorf("failed to check secret %s: %w", ref, err)
+		}
+		c, ok := p.(SecretChecker)
+		if !ok {
+			return nil
+		}
+		err = c.Check(key)
+		if err != nil {
+			return fmt.Errorf("failed to check secret %s: %w", ref, err)
+		}
+		return nil
+	}
+
+	s = NewLazySecret(fetch, check)
+	lazySecrets.m[ref] = s
+	return s
+}
+
+// forgetLazySecrets drops the LazySecrets returned by LazySecretRef for the provider registered as name.
+func forgetLazySecrets(name string) {
+	lazySecrets.Lock()
+	defer lazySecrets.Unlock()
+	for ref := range lazySecrets.m {
+		if strings.HasPrefix(ref, name+":") {
+			delete(lazySecrets.m, ref)
+		}
+	}
+}
+
+// LazySecretFile returns a LazySecret which reads f on first access. Check confirms that the file exists and has
+// acceptable permissions for a secret.
+func LazySecretFile(f file.SecretFile) LazySecret {
+	fetch := func() (optional.Secret, error) {
+		secret, ok := f.ReadFile()
+		if !ok {
+			return secret, fmt.Errorf("failed to read secret file %s", f.File.String())
+		}
+		return secret, nil
+	}
+
+	check := func() error {
+		ok, err := f.FilePermsValid()
+		if err != nil {
+			return fmt.Errorf("failed to check secret file %s: %w", f.File.String(), err)
+		}
+		if !ok {
+			return fmt.Errorf("secret file %s has invalid permissions", f.File.String())
+		}
+		return nil
+	}
+
+	return NewLazySecret(fetch, check)
+}
+
+// Check returns an error if the secret is known to be unreachable. It never fetches the secret, so it is cheap enough
+// to call from Update.
+func (s LazySecret) Check() error {
+	if s.l == nil || s.l.check == nil {
+		return nil
+	}
+	return s.l.check()
+}
+
+// Get fetches the secret until a fetch succeeds and returns the cached secret afterwards. Concurrent calls wait for the
+// fetch in progress rather than starting their own. A zero LazySecret returns None.
+func (s LazySecret) Get() (optional.Secret, error) {
+	if s.l == nil {
+		return optional.NoSecret(), nil
+	}
+
+	s.l.mu.Lock()
+	defer s.l.mu.Unlock()
+	if s.l.fetched {
+		return s.l.secret, nil
+	}
+
+	secret, err := s.l.fetch()
+	if err != nil {
+		return secret, err
+	}
+	s.l.secret = secret
+	s.l.fetched = true
+	return secret, nil
+}
+
+// MarshalText returns the redacted placeholder without fetching the secret, so that configs holding a LazySecret can be
+// marshaled like those holding a Secret.
+func (s LazySecret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s LazySecret) String() string {
+	return optional.NoSecret().String()
+}
+
+func (s LazySecret) Format(f fmt.State, verb rune) {
+	f.Write([]byte(s.String()))
+}
+
+func (s LazySecret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
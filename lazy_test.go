@@ -0,0 +1,161 @@
+package ezconf_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/ezconf/file"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+func TestLazySecretFetchesOnce(t *testing.T) {
+	calls := 0
+	s := ezconf.NewLazySecret(func() (optional.Secret, error) {
+		calls++
+		return optional.SomeSecret("hunter2"), nil
+	}, nil)
+
+	assert.NilError(t, s.Check())
+	assert.Equal(t, 0, calls)
+
+	copied := s
+	for range 3 {
+		secret, err := copied.Get()
+		assert.NilError(t, err)
+		assert.Equal(t, "hunter2", secret.MustGet())
+	}
+	_, err := s.Get()
+	assert.NilError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "***REDACTED***", fmt.Sprint(s))
+}
+
+func TestLazySecretError(t *testing.T) {
+	want := errors.New("rate limited")
+	calls := 0
+	s := ezconf.NewLazySecret(func() (optional.Secret, error) {
+		calls++
+		if calls == 1 {
+			return optional.NoSecret(), want
+		}
+		return optional.SomeSecret("hunter2"), nil
+	}, nil)
+
+	_, err := s.Get()
+	assert.ErrorIs(t, err, want)
+
+	// The error is not cached, so the next Get fetches again and keeps the secret once it succeeds.
+	for range 2 {
+		secret, err := s.Get()
+		assert.NilError(t, err)
+		assert.Equal(t, "hunter2", secret.MustGet())
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestLazySecretZero(t *testing.T) {
+	var s ezconf.LazySecret
+	assert.NilError(t, s.Check())
+
+	secret, err := s.Get()
+	assert.NilError(t, err)
+	assert.Assert(t, secret.IsNone())
+}
+
+func TestLazySecretFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	err := os.WriteFile(path, []byte("hunter2"), 0600)
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name     string
+		path     string
+		checkErr string
+		getErr   string
+	}{
+		{name: "exists", path: path},
+		{
+			name: "missing", path: path + ".missing", checkErr: "failed to check secret file",
+			getErr: "failed to read secret file",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := ezconf.LazySecretFile(file.SomeSecretFile(tc.path))
+
+			err := s.Check()
+			if tc.checkErr != "" {
+				assert.ErrorContains(t, err, tc.checkErr)
+			}
+			if tc.checkErr == "" {
+				assert.NilError(t, err)
+			}
+
+			secret, err := s.Get()
+			if tc.getErr != "" {
+				assert.ErrorContains(t, err, tc.getErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, "hunter2", secret.MustGet())
+		})
+	}
+}
+
+// countingSecrets is a SecretProvider and SecretChecker which counts its calls, failing Check with err if it is set.
+type countingSecrets struct {
+	gets   *int
+	checks *int
+	err    error
+}
+
+func (c countingSecrets) Get(key string) (string, bool, error) {
+	*c.gets++
+	return "hunter2", true, nil
+}
+
+func (c countingSecrets) Check(key string) error {
+	*c.checks++
+	return c.err
+}
+
+func TestLazySecretRef(t *testing.T) {
+	var gets, checks int
+	ezconf.RegisterSecretProvider("counting", countingSecrets{gets: &gets, checks: &checks})
+	t.Cleanup(func() { ezconf.RegisterSecretProvider("counting", nil) })
+
+	// The same ref returns the same LazySecret, so checking it never fetches and the secret is fetched only once.
+	s := ezconf.LazySecretRef("counting:myapp/token")
+	assert.Assert(t, reflect.DeepEqual(s, ezconf.LazySecretRef("counting:myapp/token")))
+	assert.NilError(t, s.Check())
+	assert.Equal(t, 1, checks)
+	assert.Equal(t, 0, gets)
+	for range 2 {
+		secret, err := ezconf.LazySecretRef("counting:myapp/token").Get()
+		assert.NilError(t, err)
+		assert.Equal(t, "hunter2", secret.MustGet())
+	}
+	assert.Equal(t, 1, gets)
+
+	// Registering the provider again drops the cached secret.
+	ezconf.RegisterSecretProvider("counting", countingSecrets{gets: &gets, checks: &checks, err: errors.New("sealed")})
+	s = ezconf.LazySecretRef("counting:myapp/token")
+	assert.ErrorContains(t, s.Check(), "failed to check secret counting:myapp/token: sealed")
+	_, err := s.Get()
+	assert.NilError(t, err)
+	assert.Equal(t, 2, gets)
+}
+
+func TestLazySecretRefUnknownProvider(t *testing.T) {
+	s := ezconf.LazySecretRef("vault:myapp/token")
+	assert.ErrorContains(t, s.Check(), `no secret provider registered as "vault"`)
+	_, err := s.Get()
+	assert.ErrorContains(t, err, `no secret provider registered as "vault"`)
+}
@@ -0,0 +1,37 @@
+package ezconf
+
+// LintIssue is a problem found in a config file by a loader's Lint, such as an unknown key or a missing required field.
+// Path is the dotted path of the key or field the problem is with, and is empty for problems with the file as a whole,
+// such as a value which does not decode.
+type LintIssue struct {
+	Path    string
+	Message string
+}
+
+func (i LintIssue) String() string {
+	if i.Path == "" {
+		return i.Message
+	}
+	return i.Path + ": " + i.Message
+}
+
+// LintErrors returns an issue for every error in err, which is normally returned by resolving a loader, so that Lint
+// can report all of them rather than failing. Joined errors and MissingFieldsErrors are split into their parts, and
+// MissingRequiredErrors and ValidationErrors are reported at the path of their field.
+func LintErrors(err error) []LintIssue {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *MissingRequiredError:
+		return []LintIssue{{Path: e.Field, Message: "required field is not set by any source"}}
+	case *ValidationError:
+		return []LintIssue{{Path: e.Field, Message: e.Reason}}
+	case interface{ Unwrap() []error }:
+		var issues []LintIssue
+		for _, part := range e.Unwrap() {
+			issues = append(issues, LintErrors(part)...)
+		}
+		return issues
+	}
+	return []LintIssue{{Message: err.Error()}}
+}
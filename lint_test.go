@@ -0,0 +1,51 @@
+package ezconf_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestLintErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want []ezconf.LintIssue
+	}{
+		{name: "nil"},
+		{name: "other", err: errors.New("boom"), want: []ezconf.LintIssue{{Message: "boom"}}},
+		{
+			name: "missing fields",
+			err:  ezconf.Required("AppConfig", []string{"Name", "NodeID"}),
+			want: []ezconf.LintIssue{
+				{Path: "AppConfig.Name", Message: "required field is not set by any source"},
+				{Path: "AppConfig.NodeID", Message: "required field is not set by any source"},
+			},
+		},
+		{
+			name: "joined",
+			err: errors.Join(
+				&ezconf.ValidationError{Field: "AppConfig.Port", Reason: "must be positive"},
+				nil,
+				fmt.Errorf("failed to read secret: %w", errors.New("no such file")),
+			),
+			want: []ezconf.LintIssue{
+				{Path: "AppConfig.Port", Message: "must be positive"},
+				{Message: "failed to read secret: no such file"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.DeepEqual(t, tc.want, ezconf.LintErrors(tc.err))
+		})
+	}
+
+	issue := ezconf.LintIssue{Path: "AppConfig.Port", Message: "must be positive"}
+	assert.Equal(t, "AppConfig.Port: must be positive", issue.String())
+	assert.Equal(t, "boom", ezconf.LintIssue{Message: "boom"}.String())
+}
@@ -0,0 +1,254 @@
+package ezconf
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// List is an optional list of values for slice config fields such as Hosts []string or Ports []uint16. None means the
+// field was not set by any source, which is distinct from Some of an empty list, e.g. `Hosts = []` in a config file.
+//
+// Sources fill a List differently:
+//   - env vars and other text sources give a comma separated list, e.g. MY_APP_HOSTS=a,b,c
+//   - flags may be passed several times, and every value is appended, e.g. -host a -host b,c
+//   - config files use their native arrays
+//
+// The first Set replaces whatever a lower priority source loaded, so lists are not merged across sources.
+type List[T comparable] struct {
+	values    []T
+	some      bool
+	appending bool
+}
+
+// SomeList returns a List holding values.
+func SomeList[T comparable](values ...T) List[T] {
+	return List[T]{values: values, some: true}
+}
+
+// NoList returns an empty List.
+func NoList[T comparable]() List[T] {
+	return List[T]{}
+}
+
+func (o List[T]) IsSome() bool {
+	return o.some
+}
+
+func (o List[T]) IsNone() bool {
+	return !o.some
+}
+
+// Get returns a copy of the values and whether the List is set.
+func (o List[T]) Get() ([]T, bool) {
+	if !o.some {
+		return nil, false
+	}
+	return append([]T{}, o.values...), true
+}
+
+// GetOr returns a copy of the values, or val if the List is not set.
+func (o List[T]) GetOr(val []T) []T {
+	values, ok := o.Get()
+	if !ok {
+		return val
+	}
+	return values
+}
+
+// Or returns o if it is set and other otherwise.
+func (o List[T]) Or(other List[T]) List[T] {
+	if o.some {
+		return o
+	}
+	return other
+}
+
+func (o *List[T]) Replace(values ...T) {
+	o.values = append([]T{}, values...)
+	o.some = true
+	o.appending = false
+}
+
+func (o *List[T]) Clear() {
+	o.values = nil
+	o.some = false
+	o.appending = false
+}
+
+func (o List[T]) Type() string {
+	return "List"
+}
+
+func (o List[T]) String() string {
+	if !o.some {
+		return "None[List]"
+	}
+
+	strs := make([]string, 0, len(o.values))
+	for _, v := range o.values {
+		strs = append(strs, fmt.Sprint(v))
+	}
+	return strings.Join(strs, ",")
+}
+
+// Set appends the comma separated values in str. The first call replaces any values already loaded so that a flag
+// overrides the lower priority sources, while repeated calls append so that a flag can be passed several times.
+func (o *List[T]) Set(str string) error {
+	values, err := parseList[T](str)
+	if err != nil {
+		return err
+	}
+
+	if !o.appending {
+		o.values = nil
+	}
+	o.values = append(o.values, values...)
+	o.some = true
+	o.appending = true
+	return nil
+}
+
+// UnmarshalText replaces the values with the comma separated values in text.
+func (o *List[T]) UnmarshalText(text []byte) error {
+	values, err := parseList[T](string(text))
+	if err != nil {
+		return err
+	}
+
+	o.Replace(values...)
+	return nil
+}
+
+func (o List[T]) MarshalJSON() ([]byte, error) {
+	if !o.some {
+		return []byte("null"), nil
+	}
+	return json.Marshal(append([]T{}, o.values...))
+}
+
+// UnmarshalJSON accepts a JSON array. Elements may be strings or bare values, e.g. ["80", 443].
+func (o *List[T]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		o.Clear()
+		return nil
+	}
+
+	var raw []json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	strs := make([]string, 0, len(raw))
+	for _, r := range raw {
+		var s string
+		err = json.Unmarshal(r, &s)
+		if err != nil {
+			s = string(r)
+		}
+		strs = append(strs, s)
+	}
+	return o.replaceStrings(strs)
+}
+
+// UnmarshalTOML accepts a TOML array.
+func (o *List[T]) UnmarshalTOML(data any) error {
+	raw, ok := data.([]any)
+	if !ok {
+		return fmt.Errorf("expected a TOML array, got %T", data)
+	}
+
+	strs := make([]string, 0, len(raw))
+	for _, r := range raw {
+		strs = append(strs, fmt.Sprint(r))
+	}
+	return o.replaceStrings(strs)
+}
+
+// UnmarshalYAML accepts a YAML sequence.
+func (o *List[T]) UnmarshalYAML(node *yaml.Node) error {
+	var strs []string
+	err := node.Decode(&strs)
+	if err != nil {
+		return err
+	}
+	return o.replaceStrings(strs)
+}
+
+func (o *List[T]) replaceStrings(strs []string) error {
+	values := make([]T, 0, len(strs))
+	for _, s := range strs {
+		v, err := parseElem[T](s)
+		if err != nil {
+			return err
+		}
+		values = append(values, v)
+	}
+
+	o.Replace(values...)
+	return nil
+}
+
+func parseList[T comparable](str string) ([]T, error) {
+	var values []T
+	for _, s := range strings.Split(str, ",") {
+		v, err := parseElem[T](strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// parseElem parses a single list element. Types implementing encoding.TextUnmarshaler parse themselves, otherwise the
+// basic kinds are parsed with strconv and time.ParseDuration.
+func parseElem[T comparable](str string) (val T, err error) {
+	u, ok := any(&val).(encoding.TextUnmarshaler)
+	if ok {
+		err = u.UnmarshalText([]byte(str))
+		return val, err
+	}
+
+	v := reflect.ValueOf(&val).Elem()
+	switch {
+	case v.Type() == durationType:
+		var d time.Duration
+		d, err = time.ParseDuration(str)
+		v.SetInt(int64(d))
+	case v.CanInt():
+		var i int64
+		i, err = strconv.ParseInt(str, 10, v.Type().Bits())
+		v.SetInt(i)
+	case v.CanUint():
+		var u uint64
+		u, err = strconv.ParseUint(str, 10, v.Type().Bits())
+		v.SetUint(u)
+	case v.CanFloat():
+		var f float64
+		f, err = strconv.ParseFloat(str, v.Type().Bits())
+		v.SetFloat(f)
+	case v.Kind() == reflect.Bool:
+		var b bool
+		b, err = ParseBool(str)
+		v.SetBool(b)
+	case v.Kind() == reflect.String:
+		v.SetString(str)
+	default:
+		err = fmt.Errorf("unsupported list element type %s", v.Type())
+	}
+
+	if err != nil {
+		return val, fmt.Errorf("invalid list element %q: %w", str, err)
+	}
+	return val, nil
+}
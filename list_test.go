@@ -0,0 +1,92 @@
+package ezconf_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestListSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []uint16
+		wantErr string
+	}{
+		{name: "single", args: []string{"-port", "80"}, want: []uint16{80}},
+		{name: "comma separated", args: []string{"-port", "80,443"}, want: []uint16{80, 443}},
+		{name: "repeated", args: []string{"-port", "80", "-port", "443,8080"}, want: []uint16{80, 443, 8080}},
+		{name: "invalid", args: []string{"-port", "80,http"}, wantErr: `invalid list element "http"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Flags replace values from lower priority sources rather than appending to them.
+			ports := ezconf.SomeList[uint16](1)
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.Var(&ports, "port", "ports to listen on")
+
+			err := fs.Parse(tc.args)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			got, ok := ports.Get()
+			assert.Assert(t, ok)
+			assert.DeepEqual(t, tc.want, got)
+		})
+	}
+}
+
+func TestListEnv(t *testing.T) {
+	t.Setenv("EZCONF_TEST_HOSTS", "a, b,c")
+
+	var hosts ezconf.List[string]
+	err := ezconf.LoadEnv(&hosts, "EZCONF_TEST_HOSTS")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"a", "b", "c"}, hosts.GetOr(nil))
+	assert.Equal(t, "a,b,c", hosts.String())
+}
+
+func TestListDecodeFile(t *testing.T) {
+	type target struct {
+		Hosts    ezconf.List[string]
+		Ports    ezconf.List[uint16]
+		Timeouts ezconf.List[time.Duration]
+		Empty    ezconf.List[string]
+		Unset    ezconf.List[string]
+	}
+
+	tests := []struct {
+		file string
+		data string
+	}{
+		{file: "app.toml", data: "Hosts = [\"a\", \"b\"]\nPorts = [80, 443]\nTimeouts = [\"1s\", \"1m\"]\nEmpty = []\n"},
+		{file: "app.json", data: `{"Hosts": ["a", "b"], "Ports": [80, "443"], "Timeouts": ["1s", "1m"], "Empty": []}`},
+		{file: "app.yaml", data: "hosts: [a, b]\nports: [80, 443]\ntimeouts: [1s, 1m]\nempty: []\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			assert.NilError(t, os.WriteFile(path, []byte(tc.data), 0600))
+
+			var got target
+			err := ezconf.DecodeFile(path, &got)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, []string{"a", "b"}, got.Hosts.GetOr(nil))
+			assert.DeepEqual(t, []uint16{80, 443}, got.Ports.GetOr(nil))
+			assert.DeepEqual(t, []time.Duration{time.Second, time.Minute}, got.Timeouts.GetOr(nil))
+			assert.Assert(t, got.Empty.IsSome())
+			assert.Equal(t, 0, len(got.Empty.GetOr(nil)))
+			assert.Assert(t, got.Unset.IsNone())
+		})
+	}
+}
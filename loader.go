@@ -0,0 +1,37 @@
+package ezconf
+
+import "context"
+
+// Loader is implemented by every config loader, both generated ones and the hand-written loaders in httpconf. Resolve
+// reads all sources and produces a config without storing any state, Update does the same and also stores the result,
+// and Previous returns the config stored by the most recent successful Update. Previous must be safe to call while
+// another goroutine runs Update, so that a reloading goroutine never hands readers a partly written config.
+type Loader[Conf any] interface {
+	Resolve() (Conf, error)
+	Update() (Conf, error)
+	Previous() Conf
+}
+
+// ContextLoader is implemented by loaders whose loads can be cancelled or given a deadline through a context, e.g.
+// because they read files or make network calls. Resolve and Update behave the same as ResolveContext and UpdateContext
+// with context.Background().
+type ContextLoader[Conf any] interface {
+	Loader[Conf]
+	ResolveContext(ctx context.Context) (Conf, error)
+	UpdateContext(ctx context.Context) (Conf, error)
+}
+
+// ResolveContext resolves l with ctx if it is a ContextLoader. Other loaders cannot be interrupted, so ctx is only
+// checked before calling Resolve.
+func ResolveContext[Conf any](ctx context.Context, l Loader[Conf]) (conf Conf, err error) {
+	cl, ok := l.(ContextLoader[Conf])
+	if ok {
+		return cl.ResolveContext(ctx)
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return conf, err
+	}
+	return l.Resolve()
+}
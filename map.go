@@ -0,0 +1,209 @@
+package ezconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Map is an optional map for config fields such as Labels map[string]string or Limits map[string]int. Text sources
+// such as env vars and flags give comma separated key=value pairs, e.g. MY_APP_LABELS=team=infra,tier=1, while config
+// files use their native tables.
+//
+// Unlike List, maps are merged key by key across sources: every source adds its keys to those of the lower priority
+// sources, and replaces the value of any key they share. A flag passed several times merges the same way.
+type Map[V comparable] struct {
+	values map[string]V
+	some   bool
+}
+
+// SomeMap returns a Map holding a copy of values.
+func SomeMap[V comparable](values map[string]V) Map[V] {
+	return Map[V]{maps.Clone(values), true}
+}
+
+// NoMap returns an empty Map.
+func NoMap[V comparable]() Map[V] {
+	return Map[V]{}
+}
+
+func (o Map[V]) IsSome() bool {
+	return o.some
+}
+
+func (o Map[V]) IsNone() bool {
+	return !o.some
+}
+
+// Get returns a copy of the map and whether the Map is set.
+func (o Map[V]) Get() (map[string]V, bool) {
+	if !o.some {
+		return nil, false
+	}
+	return maps.Clone(o.values), true
+}
+
+// GetOr returns a copy of the map, or val if the Map is not set.
+func (o Map[V]) GetOr(val map[string]V) map[string]V {
+	values, ok := o.Get()
+	if !ok {
+		return val
+	}
+	return values
+}
+
+// Merge returns the keys of both o and lower, with the values in o winning for keys they share. The result is set if
+// either of them is.
+func (o Map[V]) Merge(lower Map[V]) Map[V] {
+	if !lower.some {
+		return o
+	}
+	if !o.some {
+		return lower
+	}
+
+	merged := maps.Clone(lower.values)
+	maps.Copy(merged, o.values)
+	return Map[V]{merged, true}
+}
+
+func (o *Map[V]) Replace(values map[string]V) {
+	o.values = maps.Clone(values)
+	o.some = true
+}
+
+func (o *Map[V]) Clear() {
+	o.values = nil
+	o.some = false
+}
+
+func (o Map[V]) Type() string {
+	return "Map"
+}
+
+// String returns the map as key=value pairs sorted by key.
+func (o Map[V]) String() string {
+	if !o.some {
+		return "None[Map]"
+	}
+
+	pairs := make([]string, 0, len(o.values))
+	for _, k := range slices.Sorted(maps.Keys(o.values)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, o.values[k]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set merges the comma separated key=value pairs in str into the map.
+func (o *Map[V]) Set(str string) error {
+	values, err := parseMap[V](str)
+	if err != nil {
+		return err
+	}
+
+	*o = Map[V]{values, true}.Merge(*o)
+	return nil
+}
+
+// UnmarshalText replaces the map with the comma separated key=value pairs in text.
+func (o *Map[V]) UnmarshalText(text []byte) error {
+	values, err := parseMap[V](string(text))
+	if err != nil {
+		return err
+	}
+
+	o.Replace(values)
+	return nil
+}
+
+func (o Map[V]) MarshalJSON() ([]byte, error) {
+	if !o.some {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.values)
+}
+
+// UnmarshalJSON accepts a JSON object. Values may be strings or bare values, e.g. {"a": "1", "b": 2}.
+func (o *Map[V]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		o.Clear()
+		return nil
+	}
+
+	var raw map[string]json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	strs := make(map[string]string, len(raw))
+	for k, r := range raw {
+		var s string
+		err = json.Unmarshal(r, &s)
+		if err != nil {
+			s = string(r)
+		}
+		strs[k] = s
+	}
+	return o.replaceStrings(strs)
+}
+
+// UnmarshalTOML accepts a TOML table.
+func (o *Map[V]) UnmarshalTOML(data any) error {
+	raw, ok := data.(map[string]any)
+	if !ok {
+		return fmt.Errorf("expected a TOML table, got %T", data)
+	}
+
+	strs := make(map[string]string, len(raw))
+	for k, r := range raw {
+		strs[k] = fmt.Sprint(r)
+	}
+	return o.replaceStrings(strs)
+}
+
+// UnmarshalYAML accepts a YAML mapping.
+func (o *Map[V]) UnmarshalYAML(node *yaml.Node) error {
+	var strs map[string]string
+	err := node.Decode(&strs)
+	if err != nil {
+		return err
+	}
+	return o.replaceStrings(strs)
+}
+
+func (o *Map[V]) replaceStrings(strs map[string]string) error {
+	values := make(map[string]V, len(strs))
+	for k, s := range strs {
+		v, err := parseElem[V](s)
+		if err != nil {
+			return fmt.Errorf("invalid value for map key %s: %w", k, err)
+		}
+		values[k] = v
+	}
+
+	o.Replace(values)
+	return nil
+}
+
+func parseMap[V comparable](str string) (map[string]V, error) {
+	values := make(map[string]V)
+	for _, pair := range strings.Split(str, ",") {
+		k, s, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid map entry %q: expected key=value", pair)
+		}
+
+		v, err := parseElem[V](strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for map key %s: %w", k, err)
+		}
+		values[k] = v
+	}
+	return values, nil
+}
@@ -0,0 +1,90 @@
+package ezconf_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestMapSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    map[string]int
+		wantErr string
+	}{
+		{name: "single", args: []string{"-limit", "cpu=2"}, want: map[string]int{"cpu": 2, "disk": 10}},
+		{
+			name: "comma separated", args: []string{"-limit", "cpu=2, mem=4"},
+			want: map[string]int{"cpu": 2, "mem": 4, "disk": 10},
+		},
+		{
+			name: "repeated", args: []string{"-limit", "cpu=2", "-limit", "cpu=3,mem=4"},
+			want: map[string]int{"cpu": 3, "mem": 4, "disk": 10},
+		},
+		{name: "missing value", args: []string{"-limit", "cpu"}, wantErr: `invalid map entry "cpu"`},
+		{name: "invalid value", args: []string{"-limit", "cpu=lots"}, wantErr: "invalid value for map key cpu"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Flags merge with the values from lower priority sources.
+			limits := ezconf.SomeMap(map[string]int{"cpu": 1, "disk": 10})
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.Var(&limits, "limit", "resource limits")
+
+			err := fs.Parse(tc.args)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.want, limits.GetOr(nil))
+		})
+	}
+}
+
+func TestMapSetDoesNotModifyCopies(t *testing.T) {
+	base := ezconf.SomeMap(map[string]string{"team": "infra"})
+	env := base
+	assert.NilError(t, env.Set("team=web"))
+
+	assert.Equal(t, "team=infra", base.String())
+	assert.Equal(t, "team=web", env.String())
+}
+
+func TestMapDecodeFile(t *testing.T) {
+	type target struct {
+		Labels ezconf.Map[string]
+		Limits ezconf.Map[int]
+		Unset  ezconf.Map[string]
+	}
+
+	tests := []struct {
+		file string
+		data string
+	}{
+		{file: "app.toml", data: "[Labels]\nteam = \"infra\"\ntier = \"1\"\n[Limits]\ncpu = 2\n"},
+		{file: "app.json", data: `{"Labels": {"team": "infra", "tier": "1"}, "Limits": {"cpu": 2}}`},
+		{file: "app.yaml", data: "labels:\n  team: infra\n  tier: 1\nlimits:\n  cpu: 2\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			assert.NilError(t, os.WriteFile(path, []byte(tc.data), 0600))
+
+			var got target
+			err := ezconf.DecodeFile(path, &got)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, map[string]string{"team": "infra", "tier": "1"}, got.Labels.GetOr(nil))
+			assert.DeepEqual(t, map[string]int{"cpu": 2}, got.Limits.GetOr(nil))
+			assert.Assert(t, got.Unset.IsNone())
+		})
+	}
+}
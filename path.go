@@ -0,0 +1,12 @@
+package ezconf
+
+import "path/filepath"
+
+// DefaultPath joins a relative default file path with dir, which is the directory given to the generator with -path
+// unless it was overridden at load time. Absolute paths and paths with an empty dir are returned unchanged.
+func DefaultPath(dir, path string) string {
+	if dir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,28 @@
+package ezconf_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestDefaultPath(t *testing.T) {
+	tests := []struct {
+		name string
+		dir  string
+		path string
+		want string
+	}{
+		{name: "relative", dir: "/etc/myapp/", path: "secretkey.txt", want: "/etc/myapp/secretkey.txt"},
+		{name: "nested", dir: "/opt/myapp", path: "tls/cert.pem", want: "/opt/myapp/tls/cert.pem"},
+		{name: "absolute", dir: "/etc/myapp/", path: "/run/secrets/key", want: "/run/secrets/key"},
+		{name: "no dir", path: "secretkey.txt", want: "secretkey.txt"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ezconf.DefaultPath(tc.dir, tc.path))
+		})
+	}
+}
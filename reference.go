@@ -0,0 +1,68 @@
+package ezconf
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"text/tabwriter"
+)
+
+// FieldReference documents a single config field for PrintReference. Env is the env tag of the field without the env
+// var prefix, and Flag is the flag name without the leading dash. Leave either empty if the field does not have one.
+// Access is the access tag of the field, e.g. admin, which FieldAccess looks up. It is not printed.
+type FieldReference struct {
+	Path     string
+	Type     string
+	Default  string
+	Env      string
+	Flag     string
+	Required bool
+	Access   string
+}
+
+// FieldAccess returns the access level of the field at path in fields, as given by its access tag, e.g. for an admin
+// endpoint to decide which fields a caller may change. Fields without an access tag and paths which are not in fields
+// return an empty string. Indexes such as Backends[0] match the Backends[N] entries of fields.
+func FieldAccess(fields []FieldReference, path string) string {
+	path = fieldIndex.ReplaceAllString(path, "[N]")
+	for _, f := range fields {
+		if f.Path == path {
+			return f.Access
+		}
+	}
+	return ""
+}
+
+// fieldIndex matches the indexes of slice elements in field paths.
+var fieldIndex = regexp.MustCompile(`\[[0-9]+\]`)
+
+// PrintReference writes a table listing every field along with its type, default, env var, flag, and whether it is
+// required, one field per line in the order given. Env var names are prefixed with prefix. Missing values are printed
+// as a dash so that every column is always filled in.
+func PrintReference(w io.Writer, prefix string, fields []FieldReference) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tTYPE\tDEFAULT\tENV\tFLAG\tREQUIRED")
+	for _, f := range fields {
+		env := f.Env
+		if env != "" {
+			env = prefix + env
+		}
+		flag := f.Flag
+		if flag != "" {
+			flag = "-" + flag
+		}
+		required := "no"
+		if f.Required {
+			required = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", f.Path, dash(f.Type), dash(f.Default), dash(env), dash(flag), required)
+	}
+	return tw.Flush()
+}
+
+func dash(str string) string {
+	if str == "" {
+		return "-"
+	}
+	return str
+}
@@ -0,0 +1,54 @@
+package ezconf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestPrintReference(t *testing.T) {
+	fields := []ezconf.FieldReference{
+		{Path: "DB.Address", Type: "string", Default: "127.0.0.1", Env: "DB_ADDRESS", Flag: "dbAddress"},
+		{Path: "Name", Type: "string", Env: "NAME", Required: true},
+		{Path: "Description", Type: "string"},
+	}
+
+	var b strings.Builder
+	err := ezconf.PrintReference(&b, "APP_", fields)
+	assert.NilError(t, err)
+
+	want := `FIELD        TYPE    DEFAULT    ENV             FLAG        REQUIRED
+DB.Address   string  127.0.0.1  APP_DB_ADDRESS  -dbAddress  no
+Name         string  -          APP_NAME        -           yes
+Description  string  -          -               -           no
+`
+	assert.Equal(t, want, b.String())
+}
+
+func TestFieldAccess(t *testing.T) {
+	fields := []ezconf.FieldReference{
+		{Path: "DB.Address", Type: "string", Access: "admin"},
+		{Path: "Name", Type: "string"},
+		{Path: "Backends[N].Address", Type: "string", Access: "operator"},
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "DB.Address", want: "admin"},
+		{path: "Name", want: ""},
+		{path: "Unknown", want: ""},
+		{path: "Backends[0].Address", want: "operator"},
+		{path: "Backends[12].Address", want: "operator"},
+		{path: "Backends[N].Address", want: "operator"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.want, ezconf.FieldAccess(fields, tc.path))
+		})
+	}
+}
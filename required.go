@@ -0,0 +1,53 @@
+package ezconf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MissingFieldsError is returned by loaders when fields tagged `required:"true"` are still unset after every config
+// source has been merged. All missing fields of a config are listed at once so that they can be fixed in one pass.
+type MissingFieldsError struct {
+	Type   string
+	Fields []string
+}
+
+func (e *MissingFieldsError) Error() string {
+	if len(e.Fields) == 1 {
+		return fmt.Sprintf("%s missing required field: %s", e.Type, e.Fields[0])
+	}
+	return fmt.Sprintf("%s missing required fields: %s", e.Type, strings.Join(e.Fields, ", "))
+}
+
+// Unwrap returns a MissingRequiredError for each missing field, so that errors.As and errors.Is can pick out a field.
+func (e *MissingFieldsError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		errs = append(errs, &MissingRequiredError{Field: e.Type + "." + f})
+	}
+	return errs
+}
+
+// MissingRequiredError reports a single required field which is unset, named by its path such as MyServiceConfig.Name.
+// Loaders either return it directly or as one of the fields of a MissingFieldsError.
+type MissingRequiredError struct {
+	Field string
+}
+
+func (e *MissingRequiredError) Error() string {
+	return "missing required field: " + e.Field
+}
+
+// Is reports whether target is a *MissingRequiredError for the same field. A target with no Field matches any field.
+func (e *MissingRequiredError) Is(target error) bool {
+	t, ok := target.(*MissingRequiredError)
+	return ok && (t.Field == "" || t.Field == e.Field)
+}
+
+// Required returns a MissingFieldsError naming every field in fields, or nil if fields is empty.
+func Required(typ string, fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return &MissingFieldsError{Type: typ, Fields: fields}
+}
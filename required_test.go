@@ -0,0 +1,43 @@
+package ezconf_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestRequired(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		want   string
+	}{
+		{name: "none missing"},
+		{name: "one missing", fields: []string{"Name"}, want: "Conf missing required field: Name"},
+		{name: "several missing", fields: []string{"Name", "NodeID"}, want: "Conf missing required fields: Name, NodeID"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ezconf.Required("Conf", tc.fields)
+			if tc.want == "" {
+				assert.NilError(t, err)
+				return
+			}
+
+			assert.Error(t, err, tc.want)
+			var missing *ezconf.MissingFieldsError
+			assert.Assert(t, errors.As(err, &missing))
+			assert.DeepEqual(t, tc.fields, missing.Fields)
+
+			var field *ezconf.MissingRequiredError
+			assert.Assert(t, errors.As(err, &field))
+			assert.Equal(t, "Conf."+tc.fields[0], field.Field)
+			assert.Assert(t, errors.Is(err, &ezconf.MissingRequiredError{Field: "Conf.Name"}))
+			assert.Assert(t, errors.Is(err, &ezconf.MissingRequiredError{}))
+			assert.Assert(t, !errors.Is(err, &ezconf.MissingRequiredError{Field: "Conf.Port"}))
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package ezconf
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/brnsampson/ezconf/file"
+)
+
+// RetryPolicy retries file reads which may fail for a moment, e.g. while a certificate or secret on a network
+// filesystem is rotated with an atomic rename and briefly does not exist. The zero value makes a single attempt and
+// never retries.
+type RetryPolicy struct {
+	Attempts   int           // Total number of attempts. 0 and 1 both mean no retries.
+	Backoff    time.Duration // Wait before the first retry, doubled before each one after it.
+	MaxBackoff time.Duration // Caps the wait between attempts, unless it is 0.
+}
+
+// Do calls read until it succeeds, all attempts have failed, or ctx is done, and returns the error of the last attempt.
+// A failed read cannot tell a file being rotated from a broken one, so every error is retried except file.ErrFileNone,
+// since a path which is not set will not become set by waiting.
+func (p RetryPolicy) Do(ctx context.Context, read func() error) error {
+	wait := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := read()
+		if err == nil || attempt >= p.Attempts || errors.Is(err, file.ErrFileNone) {
+			return err
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-t.C:
+		}
+
+		wait *= 2
+		if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+			wait = p.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package ezconf_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/ezconf/file"
+	"gotest.tools/v3/assert"
+)
+
+// flakyReader fails with err the first failures times it is called and then succeeds.
+type flakyReader struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (r *flakyReader) read() error {
+	r.calls++
+	if r.calls <= r.failures {
+		return r.err
+	}
+	return nil
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    ezconf.RetryPolicy
+		failures  int
+		err       error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "no retry by default", failures: 2, err: fs.ErrNotExist, wantCalls: 1, wantErr: fs.ErrNotExist},
+		{
+			name: "fails twice then succeeds", policy: ezconf.RetryPolicy{Attempts: 3, Backoff: time.Millisecond}, failures: 2,
+			err: fs.ErrNotExist, wantCalls: 3,
+		},
+		{
+			name: "runs out of attempts", policy: ezconf.RetryPolicy{Attempts: 2, Backoff: time.Millisecond}, failures: 2,
+			err: fs.ErrNotExist, wantCalls: 2, wantErr: fs.ErrNotExist,
+		},
+		{
+			name: "unset path is not retried", policy: ezconf.RetryPolicy{Attempts: 3, Backoff: time.Millisecond}, failures: 2,
+			err: file.ErrFileNone, wantCalls: 1, wantErr: file.ErrFileNone,
+		},
+		{name: "first attempt succeeds", policy: ezconf.RetryPolicy{Attempts: 3, Backoff: time.Hour}, wantCalls: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &flakyReader{failures: tc.failures, err: tc.err}
+			err := tc.policy.Do(context.Background(), r.read)
+			assert.Equal(t, tc.wantCalls, r.calls)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func TestRetryPolicyContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	r := &flakyReader{failures: 2, err: errors.New("stale file handle")}
+	policy := ezconf.RetryPolicy{Attempts: 3, Backoff: time.Hour}
+	err := policy.Do(ctx, r.read)
+	assert.ErrorContains(t, err, "stale file handle")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, r.calls)
+}
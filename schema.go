@@ -0,0 +1,337 @@
+package ezconf
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SchemaDraft is the JSON Schema dialect produced by JSONSchema.
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document, or a subschema of one, covering the keywords needed to describe a config struct.
+type Schema struct {
+	Draft                string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	ReadOnly             bool               `json:"readOnly,omitempty"`
+}
+
+// SchemaDescriber is implemented by config types which describe themselves instead of having their fields walked by
+// JSONSchema, e.g. library configs such as httpconf.HttpServerConfig whose fields are not plain values.
+type SchemaDescriber interface {
+	JSONSchema() *Schema
+}
+
+// durationPattern matches the strings accepted by time.ParseDuration.
+const durationPattern = `^[-+]?((\d+(\.\d*)?|\.\d+)(ns|us|µs|ms|s|m|h))+$|^0$`
+
+var (
+	describerType       = reflect.TypeFor[SchemaDescriber]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// JSONSchema returns a JSON Schema document describing the config struct config, e.g. to validate config files in CI or
+// to give editors autocompletion. Properties are named by the json tag of each field, then the field tag, then the
+// field name, which matches the keys read from config files. Fields tagged required:"true" are listed as required,
+// default tags become defaults, and the min, max, oneof, and nonempty rules of validate tags become the matching
+// keywords. The values of oneOfValues tags become an enum, or a pattern if the field is also tagged ignoreCase:"true".
+// Fields tagged defaultUnit accept bare numbers as well as strings, so they only get a description. As JSON
+// Schema cannot express it, nonempty is left out for numbers and booleans. Durations are strings in the format
+// accepted by time.ParseDuration, []byte fields are base64 strings, and other types which unmarshal from text are plain
+// strings. Ignored fields are left out, as are fields tagged lazy:"true", which are only fetched from their secret tag.
+// An error is returned for fields which cannot appear in a config file, such as funcs and channels.
+func JSONSchema(config any) (*Schema, error) {
+	t := reflect.TypeOf(config)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot generate a JSON schema for %T: not a struct", config)
+	}
+
+	s, err := schemaFor(t, t.Name(), make(map[reflect.Type]bool))
+	if err != nil {
+		return nil, err
+	}
+	s.Draft = SchemaDraft
+	s.Title = t.Name()
+	return s, nil
+}
+
+func schemaFor(t reflect.Type, path string, visiting map[reflect.Type]bool) (*Schema, error) {
+	if t.Implements(describerType) {
+		return reflect.Zero(t).Interface().(SchemaDescriber).JSONSchema(), nil
+	}
+	if t.Kind() == reflect.Pointer {
+		return schemaFor(t.Elem(), path, visiting)
+	}
+	if t == durationType {
+		return &Schema{Type: "string", Pattern: durationPattern}, nil
+	}
+	if t.Kind() != reflect.String && reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return &Schema{Type: "string"}, nil
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		return &Schema{Type: "string", ContentEncoding: "base64"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		lo := -float64(uint64(1) << (t.Bits() - 1))
+		hi := float64(uint64(1)<<(t.Bits()-1) - 1)
+		return &Schema{Type: "integer", Minimum: &lo, Maximum: &hi}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		lo := 0.0
+		hi := float64(^uint64(0) >> (64 - t.Bits()))
+		return &Schema{Type: "integer", Minimum: &lo, Maximum: &hi}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaFor(t.Elem(), path+"[]", visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot generate a JSON schema for %s: map keys must be strings", path)
+		}
+		values, err := schemaFor(t.Elem(), path+"[]", visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return structSchema(t, path, visiting)
+	}
+	return nil, fmt.Errorf("cannot generate a JSON schema for %s: unsupported type %s", path, t)
+}
+
+func structSchema(t reflect.Type, path string, visiting map[reflect.Type]bool) (*Schema, error) {
+	if visiting[t] {
+		return nil, fmt.Errorf("cannot generate a JSON schema for %s: %s contains itself", path, t)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	var errs []error
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name := schemaName(f)
+		if !f.IsExported() || name == "" || Ignored(f) || f.Tag.Get("lazy") == "true" {
+			continue
+		}
+
+		p := path + "." + f.Name
+		prop, err := schemaFor(f.Type, p, visiting)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		err = applyTags(prop, f, p)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		s.Properties[name] = prop
+		if f.Tag.Get("required") == "true" {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s, errors.Join(errs...)
+}
+
+// schemaName returns the config file key of f, or an empty string if f is skipped by json.
+func schemaName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name != "" {
+		return name
+	}
+
+	name = f.Tag.Get("field")
+	if name != "" {
+		return name
+	}
+	return f.Name
+}
+
+// applyTags sets the default of s from the default tag of f and translates its validate tag into schema keywords. An
+// encoding tag of hex marks []byte fields as hex rather than base64, and a glob tag marks a slice of paths as the glob
+// pattern it is given as, with the tag as its default.
+func applyTags(s *Schema, f reflect.StructField, path string) error {
+	if s.ContentEncoding != "" && f.Tag.Get("encoding") == "hex" {
+		s.ContentEncoding = "base16"
+	}
+	pattern, ok := f.Tag.Lookup("glob")
+	if ok {
+		*s = Schema{Type: "string", Description: "glob pattern expanded into the matching paths", Default: pattern}
+	}
+
+	unit, ok := f.Tag.Lookup("defaultUnit")
+	if ok {
+		err := schemaUnit(s, f.Type, unit)
+		if err != nil {
+			return fmt.Errorf("invalid defaultUnit for %s: %w", path, err)
+		}
+	}
+
+	values, ok := f.Tag.Lookup("oneOfValues")
+	if ok {
+		s.Enum = strings.Split(values, ",")
+		if f.Tag.Get("ignoreCase") == "true" {
+			s.Pattern = ignoreCasePattern(s.Enum)
+			s.Enum = nil
+		}
+	}
+
+	def, ok := f.Tag.Lookup("default")
+	if ok {
+		value, err := schemaDefault(s.Type, def)
+		if err != nil {
+			return fmt.Errorf("invalid default for %s: %w", path, err)
+		}
+		s.Default = value
+	}
+
+	for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "nonempty":
+			if s.Type == "integer" || s.Type == "number" || s.Type == "boolean" {
+				continue
+			}
+			lo, _ := lengthKeywords(s)
+			one := 1
+			*lo = &one
+		case "oneof":
+			s.Enum = strings.Fields(arg)
+		case "min", "max":
+			err := schemaBound(s, name, arg)
+			if err != nil {
+				return fmt.Errorf("invalid validate tag for %s: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// schemaUnit describes a field tagged defaultUnit, which is either a bare number in unit or a string with a unit of its
+// own. JSON Schema has no single type for that, so the type is left out. Only durations and unsigned integers, which
+// are read as sizes, can have a unit.
+func schemaUnit(s *Schema, t reflect.Type, unit string) error {
+	if t == durationType {
+		_, err := ParseDuration("1", unit)
+		*s = Schema{Description: "duration such as 1m30s, or a number of " + unit}
+		return err
+	}
+
+	switch t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		_, err := ParseSize("1", unit)
+		*s = Schema{Description: "size such as 512KiB, or a number of " + unit}
+		return err
+	}
+	return fmt.Errorf("%s fields cannot have a unit", t)
+}
+
+// ignoreCasePattern returns a pattern matching any of values regardless of case, as JSON Schema has no case insensitive
+// enum and not every validator supports the (?i) flag. Each letter becomes a class of both of its cases.
+func ignoreCasePattern(values []string) string {
+	alternatives := make([]string, len(values))
+	for i, value := range values {
+		var b strings.Builder
+		for _, r := range value {
+			upper, lower := unicode.ToUpper(r), unicode.ToLower(r)
+			if upper == lower {
+				b.WriteString(regexp.QuoteMeta(string(r)))
+				continue
+			}
+			fmt.Fprintf(&b, "[%c%c]", upper, lower)
+		}
+		alternatives[i] = b.String()
+	}
+	return "^(" + strings.Join(alternatives, "|") + ")$"
+}
+
+// schemaDefault converts a default tag to the JSON type given by typ so that it is written as a number, boolean, or
+// string.
+func schemaDefault(typ, def string) (any, error) {
+	switch typ {
+	case "integer":
+		return strconv.ParseInt(def, 10, 64)
+	case "number":
+		return strconv.ParseFloat(def, 64)
+	case "boolean":
+		return strconv.ParseBool(def)
+	}
+	return def, nil
+}
+
+// schemaBound applies a min or max rule, which bounds the value of numbers and the length of everything else.
+func schemaBound(s *Schema, name, arg string) error {
+	n, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return fmt.Errorf("%s needs a number: %w", name, err)
+	}
+
+	if s.Type == "integer" || s.Type == "number" {
+		if name == "min" {
+			s.Minimum = &n
+			return nil
+		}
+		s.Maximum = &n
+		return nil
+	}
+
+	length := int(n)
+	lo, hi := lengthKeywords(s)
+	if name == "min" {
+		*lo = &length
+		return nil
+	}
+	*hi = &length
+	return nil
+}
+
+// lengthKeywords returns the keywords which bound the length of s, since arrays and objects each have their own.
+func lengthKeywords(s *Schema) (lo, hi **int) {
+	switch s.Type {
+	case "array":
+		return &s.MinItems, &s.MaxItems
+	case "object":
+		return &s.MinProperties, &s.MaxProperties
+	}
+	return &s.MinLength, &s.MaxLength
+}
@@ -0,0 +1,143 @@
+package ezconf_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+type schemaDB struct {
+	Address string            `default:"127.0.0.1" validate:"nonempty"`
+	Port    uint16            `default:"5432" validate:"min=1024,max=49151"`
+	Mode    string            `validate:"oneof=a b"`
+	Hosts   []string          `validate:"max=3"`
+	Params  map[string]string `json:"params"`
+	Timeout time.Duration     `default:"5s"`
+	Salt    []byte
+	Key     []byte        `encoding:"hex"`
+	Plugins []string      `glob:"plugins/*.so"`
+	Format  string        `oneOfValues:"json,text" default:"text"`
+	Level   string        `oneOfValues:"debug,info" ignoreCase:"true"`
+	Wait    time.Duration `default:"30" defaultUnit:"s"`
+	Limit   uint64        `defaultUnit:"MiB"`
+}
+
+type schemaApp struct {
+	Name    string `required:"true"`
+	NodeID  int32  `field:"node" required:"true"`
+	Debug   bool   `default:"true"`
+	Secret  optional.Secret
+	DB      schemaDB
+	Skipped string            `json:"-"`
+	Cache   map[string]string `config:"-"`
+	Started time.Time         `ezconf:"-"`
+	Token   ezconf.LazySecret `lazy:"true" secret:"vault:myapp/token"`
+	ignored string
+}
+
+type schemaBad struct {
+	Ready chan bool
+}
+
+type schemaBadDefault struct {
+	Port uint16 `default:"lots"`
+}
+
+type schemaBadUnits struct {
+	Name  string        `defaultUnit:"s"`
+	Wait  time.Duration `defaultUnit:"MB"`
+	Limit uint64        `defaultUnit:"s"`
+}
+
+func TestJSONSchema(t *testing.T) {
+	s, err := ezconf.JSONSchema(&schemaApp{})
+	assert.NilError(t, err)
+	assert.Equal(t, ezconf.SchemaDraft, s.Draft)
+	assert.Equal(t, "schemaApp", s.Title)
+	assert.DeepEqual(t, []string{"Name", "node"}, s.Required)
+	assert.Equal(t, 5, len(s.Properties))
+
+	db := s.Properties["DB"].Properties
+	tests := []struct {
+		name   string
+		schema *ezconf.Schema
+		want   string
+	}{
+		{name: "string", schema: s.Properties["Name"], want: `{"type":"string"}`},
+		{
+			name: "field tag", schema: s.Properties["node"],
+			want: `{"type":"integer","minimum":-2147483648,"maximum":2147483647}`,
+		},
+		{name: "bool default", schema: s.Properties["Debug"], want: `{"type":"boolean","default":true}`},
+		{name: "text unmarshaler", schema: s.Properties["Secret"], want: `{"type":"string"}`},
+		{name: "nonempty", schema: db["Address"], want: `{"type":"string","minLength":1,"default":"127.0.0.1"}`},
+		{name: "min max", schema: db["Port"], want: `{"type":"integer","minimum":1024,"maximum":49151,"default":5432}`},
+		{name: "oneof", schema: db["Mode"], want: `{"type":"string","enum":["a","b"]}`},
+		{name: "one of values", schema: db["Format"], want: `{"type":"string","enum":["json","text"],"default":"text"}`},
+		{
+			name: "one of values ignoring case", schema: db["Level"],
+			want: `{"type":"string","pattern":"^([Dd][Ee][Bb][Uu][Gg]|[Ii][Nn][Ff][Oo])$"}`,
+		},
+		{
+			name: "duration unit", schema: db["Wait"],
+			want: `{"description":"duration such as 1m30s, or a number of s","default":"30"}`,
+		},
+		{name: "size unit", schema: db["Limit"], want: `{"description":"size such as 512KiB, or a number of MiB"}`},
+		{name: "max items", schema: db["Hosts"], want: `{"type":"array","items":{"type":"string"},"maxItems":3}`},
+		{name: "json tag", schema: db["params"], want: `{"type":"object","additionalProperties":{"type":"string"}}`},
+		{name: "bytes", schema: db["Salt"], want: `{"type":"string","contentEncoding":"base64"}`},
+		{name: "hex bytes", schema: db["Key"], want: `{"type":"string","contentEncoding":"base16"}`},
+		{
+			name: "glob", schema: db["Plugins"],
+			want: `{"description":"glob pattern expanded into the matching paths","type":"string","default":"plugins/*.so"}`,
+		},
+		{
+			name: "duration", schema: db["Timeout"],
+			want: `{"type":"string","pattern":"^[-+]?((\\d+(\\.\\d*)?|\\.\\d+)(ns|us|µs|ms|s|m|h))+$|^0$","default":"5s"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Assert(t, tc.schema != nil)
+			data, err := json.Marshal(tc.schema)
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, string(data))
+		})
+	}
+}
+
+func TestJSONSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  any
+		wantErr string
+	}{
+		{name: "not a struct", config: "config", wantErr: "cannot generate a JSON schema for string: not a struct"},
+		{name: "unsupported field", config: schemaBad{}, wantErr: "schemaBad.Ready: unsupported type chan bool"},
+		{name: "bad default", config: schemaBadDefault{}, wantErr: "invalid default for schemaBadDefault.Port"},
+		{
+			name: "unit on a string", config: schemaBadUnits{},
+			wantErr: "invalid defaultUnit for schemaBadUnits.Name: string fields cannot have a unit",
+		},
+		{
+			name: "size unit on a duration", config: schemaBadUnits{},
+			wantErr: `invalid defaultUnit for schemaBadUnits.Wait: invalid default duration unit "MB"`,
+		},
+		{
+			name: "duration unit on a size", config: schemaBadUnits{},
+			wantErr: `invalid defaultUnit for schemaBadUnits.Limit: invalid default size unit "s"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ezconf.JSONSchema(tc.config)
+			assert.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
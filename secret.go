@@ -0,0 +1,214 @@
+package ezconf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/brnsampson/ezconf/file"
+	"github.com/brnsampson/optional"
+)
+
+// SecretProvider fetches secrets by key from a secret store such as Vault or AWS Secrets Manager. Get returns false
+// without an error if the store has no secret for key, and an error only if the store could not be asked.
+type SecretProvider interface {
+	Get(key string) (string, bool, error)
+}
+
+var secretProviders = struct {
+	sync.RWMutex
+	m map[string]SecretProvider
+}{m: map[string]SecretProvider{"file": FileSecretProvider{}}}
+
+// RegisterSecretProvider makes p available to LoadSecret under name, so that secret:"name:key" tags are fetched from
+// it. Registering a name again replaces the earlier provider, and a nil p removes it. A FileSecretProvider is
+// registered as "file" by default.
+func RegisterSecretProvider(name string, p SecretProvider) {
+	// LazySecrets fetched from the provider registered before must not hand out its secrets any more.
+	defer forgetLazySecrets(name)
+
+	secretProviders.Lock()
+	defer secretProviders.Unlock()
+	if p == nil {
+		delete(secretProviders.m, name)
+		return
+	}
+	secretProviders.m[name] = p
+}
+
+// SecretChecker is implemented by SecretProviders which can tell whether a secret is reachable without fetching it,
+// e.g. with a metadata call which does not count against the rate limit of the store. LazySecret.Check uses it.
+type SecretChecker interface {
+	Check(key string) error
+}
+
+// secretProvider returns the provider named by ref, which has the form provider:key, along with the key to ask it for.
+func secretProvider(ref string) (SecretProvider, string, error) {
+	name, key, ok := strings.Cut(ref, ":")
+	if !ok {
+		return nil, "", fmt.Errorf("invalid secret reference %q: expected provider:key", ref)
+	}
+
+	secretProviders.RLock()
+	p, ok := secretProviders.m[name]
+	secretProviders.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("no secret provider registered as %q", name)
+	}
+	return p, key, nil
+}
+
+// LoadSecret sets o from the secret named by ref, which has the form provider:key as given in a secret tag, e.g.
+// vault:myapp/secretkey. An empty ref and secrets the provider does not have both leave o untouched. An error is
+// returned if the provider is not registered or fails, and never includes the secret itself.
+func LoadSecret(o *optional.Secret, ref string) error {
+	if ref == "" {
+		return nil
+	}
+
+	p, key, err := secretProvider(ref)
+	if err != nil {
+		return fmt.Errorf("failed to load secret %s: %w", ref, err)
+	}
+
+	value, ok, err := p.Get(key)
+	if err != nil {
+		return fmt.Errorf("failed to load secret %s: %w", ref, err)
+	}
+	if ok {
+		o.Replace(value)
+	}
+	return nil
+}
+
+// FileSecretProvider reads secrets from files, using the key as the path. Relative paths are joined with Dir. A file
+// which does not exist is reported as a missing secret, while a file whose permissions fail
+// file.SecretFile.FilePermsValid, e.g. because others can read it, is an error.
+type FileSecretProvider struct {
+	Dir string
+}
+
+// Get reads the secret file at key.
+func (p FileSecretProvider) Get(key string) (string, bool, error) {
+	path, ok, err := p.stat(key)
+	if !ok || err != nil {
+		return "", false, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+// Check confirms that the permissions of the secret file at key are valid without reading it. A file which does not
+// exist is not an error, as Get reports it as a missing secret.
+func (p FileSecretProvider) Check(key string) error {
+	_, _, err := p.stat(key)
+	return err
+}
+
+// stat returns the path of the secret file at key and whether it exists, or an error if its permissions are invalid.
+func (p FileSecretProvider) stat(key string) (string, bool, error) {
+	path := DefaultPath(p.Dir, key)
+	valid, err := file.SomeSecretFile(path).FilePermsValid()
+	if errors.Is(err, fs.ErrNotExist) {
+		return path, false, nil
+	}
+	if err != nil {
+		return path, false, err
+	}
+	if !valid {
+		return path, false, fmt.Errorf("secret file %s must be readable and writable by its owner only", path)
+	}
+	return path, true, nil
+}
+
+// Secret is an optional.Secret which stays redacted when marshaled. optional.Secret only redacts itself when printed by
+// fmt, log, or slog, and marshals its value in the clear, so a config holding one leaks it through json.Marshal or
+// slog.JSONHandler. Secret marshals to the same placeholder with MarshalJSON and MarshalText, which TOML and YAML
+// encoders use as well, and None to JSON null. Decoding and flag parsing are unchanged, and Get or MustGet return the
+// value. Use it for secret fields of config structs, which end up in logs and APIs.
+type Secret struct {
+	optional.Secret
+}
+
+// SomeSecret returns a Secret holding value.
+func SomeSecret(value string) Secret {
+	return Secret{optional.SomeSecret(value)}
+}
+
+// NoSecret returns a Secret holding no value.
+func NoSecret() Secret {
+	return Secret{optional.NoSecret()}
+}
+
+// MarshalText returns the redacted placeholder instead of the secret.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// MarshalJSON returns the redacted placeholder as a JSON string, or null for None.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	if s.IsNone() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(s.String())
+}
+
+// SecretFlag is a flag.Value for secrets. Command line arguments end up in shell history and process listings, so a
+// value starting with @ is read from the file it names instead, e.g. -apiKey @/run/secrets/api-key, and @- reads it
+// from stdin. A single trailing newline is dropped from both, as left by echo and most editors. Any other value is
+// taken literally, with a leading @@ standing for a literal @. Like optional.Secret, the value is redacted when
+// printed.
+type SecretFlag struct {
+	optional.Secret
+	stdin bool
+}
+
+// Set replaces the value with str, or with the contents of the file or stdin it names.
+func (s *SecretFlag) Set(str string) error {
+	s.stdin = str == "@"+StdinPath
+	if strings.HasPrefix(str, "@@") || !strings.HasPrefix(str, "@") {
+		s.Replace(strings.TrimPrefix(str, "@"))
+		return nil
+	}
+
+	data, err := readSecretArg(str[1:])
+	if err != nil {
+		return err
+	}
+
+	value := strings.TrimSuffix(string(data), "\n")
+	s.Replace(strings.TrimSuffix(value, "\r"))
+	return nil
+}
+
+// FromStdin reports whether the value was read from stdin with @-. Stdin can only be read once, so loaders use it to
+// reject a config file read from stdin as well.
+func (s SecretFlag) FromStdin() bool {
+	return s.stdin
+}
+
+// readSecretArg reads the file at path, or stdin if path is StdinPath.
+func readSecretArg(path string) ([]byte, error) {
+	if path != StdinPath {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret from %s: %w", path, err)
+		}
+		return data, nil
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret from stdin: %w", err)
+	}
+	return data, nil
+}
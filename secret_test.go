@@ -0,0 +1,197 @@
+package ezconf_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+// fakeSecrets is a SecretProvider backed by a map, or failing with err if it is set.
+type fakeSecrets struct {
+	secrets map[string]string
+	err     error
+}
+
+func (f fakeSecrets) Get(key string) (string, bool, error) {
+	if f.err != nil {
+		return "", false, f.err
+	}
+	value, ok := f.secrets[key]
+	return value, ok, nil
+}
+
+func TestLoadSecret(t *testing.T) {
+	ezconf.RegisterSecretProvider("fake", fakeSecrets{secrets: map[string]string{"myapp/secretkey": "hunter2"}})
+	ezconf.RegisterSecretProvider("broken", fakeSecrets{err: errors.New("permission denied")})
+	t.Cleanup(func() {
+		ezconf.RegisterSecretProvider("fake", nil)
+		ezconf.RegisterSecretProvider("broken", nil)
+	})
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    optional.Secret
+		wantErr string
+	}{
+		{name: "found", ref: "fake:myapp/secretkey", want: optional.SomeSecret("hunter2")},
+		{name: "not found", ref: "fake:myapp/other", want: optional.NoSecret()},
+		{name: "empty ref", ref: "", want: optional.NoSecret()},
+		{
+			name: "provider error", ref: "broken:myapp/secretkey",
+			wantErr: "failed to load secret broken:myapp/secretkey: permission denied",
+		},
+		{name: "unknown provider", ref: "vault:myapp/secretkey", wantErr: `no secret provider registered as "vault"`},
+		{name: "no provider", ref: "myapp/secretkey", wantErr: "expected provider:key"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			secret := optional.NoSecret()
+			err := ezconf.LoadSecret(&secret, tc.ref)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				assert.Assert(t, secret.IsNone())
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, secret)
+		})
+	}
+}
+
+func TestFileSecretProvider(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "secretkey.txt"), []byte("hunter2"), 0600))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "open.txt"), []byte("hunter2"), 0644))
+
+	tests := []struct {
+		name    string
+		key     string
+		want    string
+		found   bool
+		wantErr string
+	}{
+		{name: "relative", key: "secretkey.txt", want: "hunter2", found: true},
+		{name: "absolute", key: filepath.Join(dir, "secretkey.txt"), want: "hunter2", found: true},
+		{name: "missing", key: "missing.txt"},
+		{name: "readable by others", key: "open.txt", wantErr: "must be readable and writable by its owner only"},
+	}
+
+	p := ezconf.FileSecretProvider{Dir: dir}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			value, found, err := p.Get(tc.key)
+			checkErr := p.Check(tc.key)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				assert.ErrorContains(t, checkErr, tc.wantErr)
+				return
+			}
+			assert.NilError(t, checkErr)
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.found, found)
+			assert.Equal(t, tc.want, value)
+		})
+	}
+}
+
+// fakeStdin replaces os.Stdin with a file holding data until the test ends.
+func fakeStdin(t *testing.T, data string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdin")
+	assert.NilError(t, os.WriteFile(path, []byte(data), 0600))
+	f, err := os.Open(path)
+	assert.NilError(t, err)
+
+	stdin := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		f.Close()
+	})
+}
+
+func TestSecretFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	assert.NilError(t, os.WriteFile(path, []byte("from-file\n"), 0600))
+
+	tests := []struct {
+		name    string
+		value   string
+		stdin   string
+		want    string
+		wantErr string
+	}{
+		{name: "literal", value: "hunter2", want: "hunter2"},
+		{name: "file", value: "@" + path, want: "from-file"},
+		{name: "stdin", value: "@-", stdin: "from-stdin\r\n", want: "from-stdin"},
+		{name: "only one newline is dropped", value: "@-", stdin: "two\n\n", want: "two\n"},
+		{name: "escaped @", value: "@@literal", want: "@literal"},
+		{name: "missing file", value: "@" + path + ".missing", wantErr: "failed to read secret from " + path + ".missing"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeStdin(t, tc.stdin)
+			var s ezconf.SecretFlag
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			fs.Var(&s, "apiKey", "API key")
+
+			err := fs.Parse([]string{"-apiKey", tc.value})
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				assert.Assert(t, s.IsNone())
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, s.MustGet())
+			assert.Equal(t, tc.value == "@-", s.FromStdin())
+			assert.Equal(t, "***REDACTED***", fmt.Sprint(s))
+		})
+	}
+}
+
+func TestSecretMarshalRedacted(t *testing.T) {
+	type conf struct {
+		Key   ezconf.Secret
+		Unset ezconf.Secret
+	}
+	c := conf{Key: ezconf.SomeSecret("hunter2")}
+	assert.Equal(t, "hunter2", c.Key.MustGet())
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("loaded", "conf", c)
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("loaded", "conf", c, "key", c.Key)
+	assert.Assert(t, !strings.Contains(buf.String(), "hunter2"), buf.String())
+
+	data, err := json.Marshal(c)
+	assert.NilError(t, err)
+	assert.Equal(t, `{"Key":"***REDACTED***","Unset":null}`, string(data))
+
+	text, err := c.Key.MarshalText()
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(text), "hunter2"))
+
+	// Decoding still reads the actual value.
+	var decoded conf
+	assert.NilError(t, json.Unmarshal([]byte(`{"Key":"swordfish","Unset":null}`), &decoded))
+	assert.Equal(t, "swordfish", decoded.Key.MustGet())
+	assert.Assert(t, decoded.Unset.IsNone())
+}
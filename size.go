@@ -0,0 +1,57 @@
+package ezconf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits are the size suffixes accepted by ParseSize, with the number of bytes in each. KB, MB, and so on are powers
+// of 1000 and KiB, MiB, and so on are powers of 1024.
+var sizeUnits = map[string]float64{
+	"B":   1,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+}
+
+// ParseSize parses a byte size such as 512KiB or 1.5GB into a number of bytes. A bare number such as 16 is read in
+// unit, which is how fields tagged defaultUnit:"MB" are read. Units are one of B, KB, MB, GB, TB, KiB, MiB, GiB, or
+// TiB. An error is returned for an unknown unit, a bare number with no unit to read it in, and sizes which are not a
+// whole number of bytes.
+func ParseSize(s, unit string) (uint64, error) {
+	_, ok := sizeUnits[unit]
+	if unit != "" && !ok {
+		return 0, fmt.Errorf("invalid default size unit %q", unit)
+	}
+
+	s = strings.TrimSpace(s)
+	number, suffix := s, unit
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i >= 0 {
+		number, suffix = s[:i], strings.TrimSpace(s[i:])
+	}
+	mult, ok := sizeUnits[suffix]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: the unit must be one of B, KB, MB, GB, TB, KiB, MiB, GiB, or TiB", s)
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	bytes := f * mult
+	if bytes != math.Trunc(bytes) {
+		return 0, fmt.Errorf("invalid size %q: not a whole number of bytes", s)
+	}
+	if bytes >= math.MaxUint64 {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return uint64(bytes), nil
+}
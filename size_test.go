@@ -0,0 +1,44 @@
+package ezconf_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		unit    string
+		want    uint64
+		wantErr string
+	}{
+		{name: "bare megabytes", value: "16", unit: "MB", want: 16_000_000},
+		{name: "bare mebibytes", value: "16", unit: "MiB", want: 16 << 20},
+		{name: "explicit unit wins", value: "512KiB", unit: "MB", want: 512 << 10},
+		{name: "fractional", value: "1.5GB", want: 1_500_000_000},
+		{name: "space before unit", value: "2 KB", want: 2000},
+		{name: "bytes", value: "100B", want: 100},
+		{name: "bare without a unit", value: "16", wantErr: `invalid size "16": the unit must be one of`},
+		{name: "unknown unit", value: "16mb", unit: "MB", wantErr: `invalid size "16mb": the unit must be one of`},
+		{name: "duration unit", value: "16", unit: "s", wantErr: `invalid default size unit "s"`},
+		{name: "partial byte", value: "1.5", unit: "B", wantErr: `invalid size "1.5": not a whole number of bytes`},
+		{name: "negative", value: "-1KB", wantErr: `invalid size "-1KB"`},
+		{name: "too large", value: "20000000TB", wantErr: `invalid size "20000000TB": too large`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ezconf.ParseSize(tc.value, tc.unit)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
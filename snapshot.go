@@ -0,0 +1,28 @@
+package ezconf
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// Snapshot holds the config stored by the most recent Update of a loader. Unlike an atomic.Value it may be copied
+// along with the loader, e.g. by a With method: the copy starts out with the config stored so far, and later Stores to
+// either one do not change the other. Load and Store are safe to call from several goroutines at once, but like any
+// other field a Snapshot must not be copied while another goroutine calls Store.
+type Snapshot[Conf any] struct {
+	p unsafe.Pointer // *Conf, swapped atomically so that Load never sees a partly written config.
+}
+
+// Load returns the config passed to the most recent Store, or the zero value if Store has not been called.
+func (s *Snapshot[Conf]) Load() (conf Conf) {
+	p := (*Conf)(atomic.LoadPointer(&s.p))
+	if p == nil {
+		return conf
+	}
+	return *p
+}
+
+// Store replaces the config returned by Load.
+func (s *Snapshot[Conf]) Store(conf Conf) {
+	atomic.StorePointer(&s.p, unsafe.Pointer(&conf))
+}
@@ -0,0 +1,47 @@
+package ezconf_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestSnapshotZero(t *testing.T) {
+	var s ezconf.Snapshot[string]
+	assert.Equal(t, s.Load(), "")
+}
+
+func TestSnapshotCopy(t *testing.T) {
+	var s ezconf.Snapshot[string]
+	s.Store("first")
+
+	c := s
+	assert.Equal(t, c.Load(), "first")
+
+	s.Store("second")
+	c.Store("third")
+	assert.Equal(t, s.Load(), "second")
+	assert.Equal(t, c.Load(), "third")
+}
+
+func TestSnapshotConcurrent(t *testing.T) {
+	var s ezconf.Snapshot[[]int]
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.Store([]int{i, i})
+		}()
+		go func() {
+			defer wg.Done()
+			conf := s.Load()
+			if len(conf) > 0 {
+				assert.Equal(t, conf[0], conf[1])
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,29 @@
+package ezconf
+
+// The sources reported by the Sources method of loaders, naming the layer which supplied the final value of a field.
+const (
+	SourceLoader   = "loader"   // Set directly on the loader, i.e. programmatically.
+	SourceFlag     = "flag"     // Set by a command line flag.
+	SourceEnv      = "env"      // Set by an env var.
+	SourceKeyed    = "keyed"    // Set by a KeyedSource, such as a *viper.Viper.
+	SourceFile     = "file"     // Set by a config file.
+	SourceSecret   = "secret"   // Fetched from a SecretProvider.
+	SourceComputed = "computed" // Set by a function registered with Compute.
+	SourceDefault  = "default"  // Not set by any source, so the default or zero value is used.
+)
+
+// Source returns the highest precedence source which set a field, given whether the field was set on the loader, by a
+// flag, by an env var, and by a config file, or SourceDefault if none of them did.
+func Source(loader, flag, env, file bool) string {
+	switch {
+	case loader:
+		return SourceLoader
+	case flag:
+		return SourceFlag
+	case env:
+		return SourceEnv
+	case file:
+		return SourceFile
+	}
+	return SourceDefault
+}
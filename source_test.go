@@ -0,0 +1,28 @@
+package ezconf_test
+
+import (
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name                    string
+		loader, flag, env, file bool
+		want                    string
+	}{
+		{name: "unset", want: ezconf.SourceDefault},
+		{name: "file", file: true, want: ezconf.SourceFile},
+		{name: "env over file", env: true, file: true, want: ezconf.SourceEnv},
+		{name: "flag over env", flag: true, env: true, file: true, want: ezconf.SourceFlag},
+		{name: "loader over everything", loader: true, flag: true, env: true, file: true, want: ezconf.SourceLoader},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ezconf.Source(tc.loader, tc.flag, tc.env, tc.file))
+		})
+	}
+}
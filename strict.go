@@ -0,0 +1,187 @@
+package ezconf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// UnknownFieldsError is returned by DecodeStrict for keys in config data which match no field of the value decoded
+// into, most often because of a typo. Keys holds the path of each such key as written in the data, e.g. MyService.Nmae
+// or Backends[1].Prot.
+type UnknownFieldsError struct {
+	Keys []string
+}
+
+func (e *UnknownFieldsError) Error() string {
+	if len(e.Keys) == 1 {
+		return fmt.Sprintf("unknown config file field %s", e.Keys[0])
+	}
+	return fmt.Sprintf("unknown config file fields %s", strings.Join(e.Keys, ", "))
+}
+
+// DecodeFileStrict is the same as DecodeFileFormat, but decodes the file with DecodeStrict.
+func DecodeFileStrict[T any](ctx context.Context, path, format string, v *T) error {
+	return decodeFile(ctx, path, format, v, DecodeStrict[T])
+}
+
+// DecodeStrict is the same as Decode, but returns an *UnknownFieldsError without touching v if data has keys which
+// match no field of v, where Decode silently ignores them. Keys are matched the way the decoder for format matches
+// them: json and toml keys by struct tag or field name regardless of case, and yaml keys by struct tag or the lowercase
+// field name. Formats added with RegisterDecoder are matched like json, using the struct tag named after the format,
+// and their decoder must be able to decode into a map[string]any. The contents of fields which decode themselves, such
+// as optional values, lists, and maps, are not checked.
+func DecodeStrict[T any](data []byte, format string, v *T) error {
+	data, err := Gunzip(data)
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		format, err = DetectFormat(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	d, ok := decoder(format)
+	if !ok {
+		// Let Decode report the unsupported format.
+		return Decode(data, format, v)
+	}
+
+	var raw map[string]any
+	err = d(data, reflect.ValueOf(&raw))
+	if err != nil {
+		return err
+	}
+
+	tags := strings.ToLower(format)
+	if tags == "yml" {
+		tags = FormatYAML
+	}
+	var unknown []string
+	unknownFields(reflect.TypeOf(v), reflect.ValueOf(raw), "", tags, &unknown)
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return &UnknownFieldsError{Keys: unknown}
+	}
+	return d(data, reflect.ValueOf(v))
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+	tomlUnmarshalerType = reflect.TypeFor[toml.Unmarshaler]()
+	yamlUnmarshalerType = reflect.TypeFor[yaml.Unmarshaler]()
+)
+
+// unknownFields appends the path of every key in data, prefixed by path, which matches no field of t to unknown.
+func unknownFields(t reflect.Type, data reflect.Value, path, format string, unknown *[]string) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for data.Kind() == reflect.Interface && !data.IsNil() {
+		data = data.Elem()
+	}
+	if t == nil || !data.IsValid() || decodesItself(t) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if data.Kind() != reflect.Slice {
+			return
+		}
+		for i := range data.Len() {
+			unknownFields(t.Elem(), data.Index(i), path+"["+strconv.Itoa(i)+"]", format, unknown)
+		}
+	case reflect.Map:
+		if data.Kind() != reflect.Map || data.Type().Key().Kind() != reflect.String {
+			return
+		}
+		for _, key := range data.MapKeys() {
+			unknownFields(t.Elem(), data.MapIndex(key), joinKey(path, key.String()), format, unknown)
+		}
+	case reflect.Struct:
+		if data.Kind() != reflect.Map || data.Type().Key().Kind() != reflect.String {
+			return
+		}
+		for _, key := range data.MapKeys() {
+			p := joinKey(path, key.String())
+			f, ok := fieldForKey(t, key.String(), format)
+			if !ok {
+				*unknown = append(*unknown, p)
+				continue
+			}
+			unknownFields(f.Type, data.MapIndex(key), p, format, unknown)
+		}
+	}
+}
+
+// decodesItself reports whether t, or a pointer to it, unmarshals itself, so that the keys inside it are its own
+// concern.
+func decodesItself(t reflect.Type) bool {
+	if t.Kind() == reflect.Interface {
+		return true
+	}
+	p := reflect.PointerTo(t)
+	return p.Implements(textUnmarshalerType) || p.Implements(jsonUnmarshalerType) ||
+		p.Implements(tomlUnmarshalerType) || p.Implements(yamlUnmarshalerType)
+}
+
+// fieldForKey returns the field of the struct t which the decoder for format sets from key, looking through embedded
+// structs as the decoder does.
+func fieldForKey(t reflect.Type, key, format string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, opts, _ := strings.Cut(f.Tag.Get(format), ",")
+		if tag == "-" {
+			continue
+		}
+
+		embedded := f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct
+		if embedded && (format != FormatYAML || strings.Contains(opts, "inline")) {
+			inner, ok := fieldForKey(f.Type, key, format)
+			if ok {
+				return inner, true
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if format == FormatYAML {
+			if tag == "" {
+				tag = strings.ToLower(f.Name)
+			}
+			if tag == key {
+				return f, true
+			}
+			continue
+		}
+
+		name := tag
+		if name == "" {
+			name = f.Name
+		}
+		if strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// joinKey appends key to the key path path.
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,90 @@
+package ezconf_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+type strictBackend struct {
+	Address optional.Str
+	Port    optional.Uint16
+}
+
+type strictTarget struct {
+	Name     optional.Str
+	NodeID   optional.Uint32 `json:"node" toml:"node" yaml:"node"`
+	Tags     ezconf.List[string]
+	Backends []strictBackend
+}
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		data    string
+		wantErr string
+	}{
+		{name: "toml", format: "toml", data: "Name = \"app\"\nnode = 3\nTags = [\"a\"]\n\n[[Backends]]\nAddress = \"b0\"\n"},
+		{name: "json", format: "json", data: `{"name": "app", "node": 3, "Tags": ["a"], "Backends": [{"Address": "b0"}]}`},
+		{name: "yaml", format: "yaml", data: "name: app\nnode: 3\ntags: [a]\nbackends:\n  - address: b0\n"},
+		{name: "detected", data: `{"Name": "app", "node": 3, "Tags": ["a"], "Backends": [{"Address": "b0"}]}`},
+		{
+			name: "toml unknown key", format: "toml", data: "Nmae = \"app\"\nnode = 3\n",
+			wantErr: "unknown config file field Nmae",
+		},
+		{
+			name: "json unknown keys", format: "json",
+			data:    `{"Name": "app", "NodeID": 3, "Backends": [{"Address": "b0"}, {"Prot": 1}]}`,
+			wantErr: "unknown config file fields Backends[1].Prot, NodeID",
+		},
+		{
+			name: "yaml field names are lowercase", format: "yml", data: "Name: app\nnode: 3\n",
+			wantErr: "unknown config file field Name",
+		},
+		{name: "unsupported format", format: "ini", data: "Name=app", wantErr: `unsupported config file format "ini"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var target strictTarget
+			err := ezconf.DecodeStrict([]byte(tc.data), tc.format, &target)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				assert.Assert(t, target.Name.IsNone())
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, optional.SomeStr("app"), target.Name)
+			assert.Equal(t, optional.SomeUint32(3), target.NodeID)
+			assert.DeepEqual(t, []string{"a"}, target.Tags.GetOr(nil))
+			assert.Equal(t, 1, len(target.Backends))
+			assert.Equal(t, optional.SomeStr("b0"), target.Backends[0].Address)
+		})
+	}
+}
+
+func TestDecodeFileStrict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.toml")
+	assert.NilError(t, os.WriteFile(path, []byte("Name = \"app\"\n\n[[Backends]]\nAddress = \"b0\"\nWieght = 2\n"), 0600))
+
+	// Unknown keys are ignored unless decoding strictly.
+	var target strictTarget
+	assert.NilError(t, ezconf.DecodeFileFormat(context.Background(), path, "", &target))
+	assert.Equal(t, optional.SomeStr("app"), target.Name)
+
+	target = strictTarget{}
+	err := ezconf.DecodeFileStrict(context.Background(), path, "", &target)
+	assert.ErrorContains(t, err, "failed to decode config file")
+
+	var unknown *ezconf.UnknownFieldsError
+	assert.Assert(t, errors.As(err, &unknown))
+	assert.DeepEqual(t, []string{"Backends[0].Wieght"}, unknown.Keys)
+}
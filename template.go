@@ -0,0 +1,34 @@
+package ezconf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData is what the values of fields tagged template:"true" are executed with as Go text/templates, e.g.
+// {{.Config.MyService.Name}}-{{.Now.Format "2006-01-02"}}.log. The env function returns the value of an env var, e.g.
+// {{env "HOSTNAME"}}.
+type TemplateData[Conf any] struct {
+	Config Conf      // The loaded config, with every templated field still holding its template.
+	Now    time.Time // The time the config was loaded.
+}
+
+// ExecTemplate executes value, the value of the templated field at path, as a Go text/template with data. References
+// to missing map keys are errors, and every error names the field.
+func ExecTemplate[Conf any](path, value string, data TemplateData[Conf]) (string, error) {
+	funcs := template.FuncMap{"env": os.Getenv}
+	t, err := template.New(path).Option("missingkey=error").Funcs(funcs).Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template of config field %s: %w", path, err)
+	}
+
+	var b strings.Builder
+	err = t.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute template of config field %s: %w", path, err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,55 @@
+package ezconf_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestExecTemplate(t *testing.T) {
+	type config struct {
+		Name   string
+		Labels map[string]string
+	}
+	t.Setenv("EZCONF_TEST_HOST", "node-1")
+	data := ezconf.TemplateData[config]{
+		Config: config{Name: "myapp", Labels: map[string]string{"zone": "a"}},
+		Now:    time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "plain", value: "no template", want: "no template"},
+		{name: "config", value: "{{.Config.Name}}-{{.Config.Labels.zone}}", want: "myapp-a"},
+		{name: "now", value: `{{.Config.Name}}-{{.Now.Format "2006-01-02"}}.log`, want: "myapp-2024-05-06.log"},
+		{name: "env", value: `{{env "EZCONF_TEST_HOST"}}`, want: "node-1"},
+		{
+			name: "parse error", value: "{{.Config.Name", wantErr: "failed to parse template of config field App.Log",
+		},
+		{
+			name: "missing field", value: "{{.Config.Other}}", wantErr: "failed to execute template of config field App.Log",
+		},
+		{
+			name: "missing key", value: "{{.Config.Labels.region}}",
+			wantErr: "failed to execute template of config field App.Log",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ezconf.ExecTemplate("App.Log", tc.value, data)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package ezconf
+
+import (
+	"fmt"
+
+	"github.com/brnsampson/optional"
+	"gopkg.in/yaml.v3"
+)
+
+// UnitValue is an optional number with an optional unit suffix, e.g. 30, 500ms, or 16MiB, kept as the text it was
+// given. Generated loaders hold fields tagged defaultUnit as a UnitValue and read it with Duration or Size once every
+// source is merged, since the zero value a config file is decoded into cannot know the unit from the tag. Config files
+// may give it as a string or a bare number.
+type UnitValue struct {
+	optional.Str
+}
+
+// SomeUnitValue returns a UnitValue holding value, e.g. "30" or "500ms".
+func SomeUnitValue(value string) UnitValue {
+	return UnitValue{optional.SomeStr(value)}
+}
+
+// NoUnitValue returns an empty UnitValue.
+func NoUnitValue() UnitValue {
+	return UnitValue{optional.NoStr()}
+}
+
+func (o UnitValue) Type() string {
+	return "UnitValue"
+}
+
+// Duration reads the value with ParseDuration, so that a bare number is in unit. The error for an invalid value is a
+// *ValidationError for field.
+func (o UnitValue) Duration(field, unit string) (optional.Duration, error) {
+	value, ok := o.Get()
+	if !ok {
+		return optional.NoDuration(), nil
+	}
+
+	d, err := ParseDuration(value, unit)
+	if err != nil {
+		return optional.NoDuration(), &ValidationError{Field: field, Reason: err.Error()}
+	}
+	return optional.SomeDuration(d), nil
+}
+
+// Size reads the value with ParseSize, so that a bare number is in unit. The error for an invalid value is a
+// *ValidationError for field.
+func (o UnitValue) Size(field, unit string) (optional.Uint64, error) {
+	value, ok := o.Get()
+	if !ok {
+		return optional.NoUint64(), nil
+	}
+
+	size, err := ParseSize(value, unit)
+	if err != nil {
+		return optional.NoUint64(), &ValidationError{Field: field, Reason: err.Error()}
+	}
+	return optional.SomeUint64(size), nil
+}
+
+// UnmarshalJSON accepts both strings and bare numbers.
+func (o *UnitValue) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] != '"' && string(data) != "null" {
+		o.Replace(string(data))
+		return nil
+	}
+	return o.Str.UnmarshalJSON(data)
+}
+
+// UnmarshalTOML accepts both strings and bare numbers.
+func (o *UnitValue) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case string:
+		return o.UnmarshalText([]byte(v))
+	case int64, float64:
+		o.Replace(fmt.Sprint(v))
+		return nil
+	}
+	return fmt.Errorf("cannot read %T as a number with a unit", v)
+}
+
+// UnmarshalYAML accepts both strings and bare numbers.
+func (o *UnitValue) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("cannot read a YAML %s as a number with a unit", node.ShortTag())
+	}
+	if node.ShortTag() == "!!null" {
+		o.Clear()
+		return nil
+	}
+	return o.UnmarshalText([]byte(node.Value))
+}
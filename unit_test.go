@@ -0,0 +1,57 @@
+package ezconf_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+	"gopkg.in/yaml.v3"
+	"gotest.tools/v3/assert"
+)
+
+func TestUnitValueDecode(t *testing.T) {
+	type doc struct {
+		Timeout ezconf.UnitValue
+		Limit   ezconf.UnitValue
+	}
+
+	tests := []struct {
+		name      string
+		unmarshal func([]byte, any) error
+		data      string
+	}{
+		{name: "json", unmarshal: json.Unmarshal, data: `{"Timeout": 30, "Limit": "16MiB"}`},
+		{name: "toml", unmarshal: toml.Unmarshal, data: "Timeout = 30\nLimit = \"16MiB\"\n"},
+		{name: "yaml", unmarshal: yaml.Unmarshal, data: "timeout: 30\nlimit: 16MiB\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var d doc
+			assert.NilError(t, tc.unmarshal([]byte(tc.data), &d))
+
+			timeout, err := d.Timeout.Duration("Timeout", "s")
+			assert.NilError(t, err)
+			assert.Equal(t, optional.SomeDuration(30*time.Second), timeout)
+
+			limit, err := d.Limit.Size("Limit", "MB")
+			assert.NilError(t, err)
+			assert.Equal(t, optional.SomeUint64(16<<20), limit)
+		})
+	}
+}
+
+func TestUnitValueErrors(t *testing.T) {
+	none, err := ezconf.NoUnitValue().Duration("Timeout", "s")
+	assert.NilError(t, err)
+	assert.Assert(t, none.IsNone())
+
+	_, err = ezconf.SomeUnitValue("soon").Duration("Timeout", "s")
+	assert.ErrorContains(t, err, `invalid Timeout: time: invalid duration "soon"`)
+
+	_, err = ezconf.SomeUnitValue("16").Size("Limit", "s")
+	assert.Error(t, err, `invalid Limit: invalid default size unit "s"`)
+}
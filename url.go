@@ -0,0 +1,63 @@
+package ezconf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// maxConfigURLSize caps the config documents read by FetchURL, so that a misbehaving server cannot make the program
+// read an unbounded response into memory.
+const maxConfigURLSize = 16 << 20
+
+// IsURL reports whether the config file path p is an http or https URL, e.g. -config https://config.internal/app.toml,
+// rather than a path on disk.
+func IsURL(p string) bool {
+	u, err := url.Parse(p)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// FetchURL fetches the config document at the http or https URL rawURL with client, or http.DefaultClient if it is nil,
+// and returns it along with its format, taken from the extension of the URL path in the same way as DecodeFile, e.g.
+// toml for app.toml.gz. The format is empty if the path has no extension, so that Decode detects it. A gzipped document
+// is returned as fetched and decompressed by Decode. Set a timeout and TLS settings, such as the CA of a private config
+// server, on client. Any status other than 200 OK is an error, as is a document larger than 16 MiB, and the request is
+// cancelled once ctx is done.
+func FetchURL(ctx context.Context, client *http.Client, rawURL string) (data []byte, format string, err error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid config URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, "", fmt.Errorf("unsupported config URL scheme %q: must be http or https", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err = io.ReadAll(io.LimitReader(resp.Body, maxConfigURLSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxConfigURLSize {
+		return nil, "", fmt.Errorf("config document is larger than %d bytes", maxConfigURLSize)
+	}
+	format, _ = configFormat(u.Path)
+	return data, format, nil
+}
@@ -0,0 +1,89 @@
+package ezconf_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brnsampson/ezconf"
+	"github.com/brnsampson/optional"
+	"gotest.tools/v3/assert"
+)
+
+func TestIsURL(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "https://config.internal/app.toml", want: true},
+		{path: "http://127.0.0.1:8080/app", want: true},
+		{path: "/etc/app/config.toml"},
+		{path: "config.toml"},
+		{path: "-"},
+		{path: "ftp://config.internal/app.toml"},
+		{path: "https:app.toml"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.want, ezconf.IsURL(tc.path))
+		})
+	}
+}
+
+func TestFetchURL(t *testing.T) {
+	body := "Name = \"app\"\nPort = 8080\n"
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.toml" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/huge.toml" {
+			// One byte over the 16 MiB FetchURL reads.
+			w.Write(bytes.Repeat([]byte("#"), 16<<20+1))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantFormat string
+		wantErr    string
+	}{
+		{name: "extension", path: "/app.TOML", wantFormat: "toml"},
+		{name: "no extension", path: "/app", wantFormat: ""},
+		{name: "query", path: "/app.toml?env=prod", wantFormat: "toml"},
+		{name: "not found", path: "/missing.toml", wantErr: "unexpected status 404 Not Found"},
+		{name: "too large", path: "/huge.toml", wantErr: "config document is larger than 16777216 bytes"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data, format, err := ezconf.FetchURL(context.Background(), srv.Client(), srv.URL+tc.path)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantFormat, format)
+			assert.Equal(t, body, string(data))
+
+			var target decodeTarget
+			assert.NilError(t, ezconf.Decode(data, format, &target))
+			assert.Equal(t, optional.SomeStr("app"), target.Name)
+		})
+	}
+
+	// The default client does not trust the test server's certificate.
+	_, _, err := ezconf.FetchURL(context.Background(), nil, srv.URL+"/app.toml")
+	assert.ErrorContains(t, err, "certificate")
+
+	_, _, err = ezconf.FetchURL(context.Background(), nil, "ftp://config.internal/app.toml")
+	assert.ErrorContains(t, err, `unsupported config URL scheme "ftp"`)
+}
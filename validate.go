@@ -0,0 +1,112 @@
+package ezconf
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Validate checks a resolved config value against the rules of a `validate` struct tag and returns an error keyed by
+// path for every rule it breaks. Rules are separated by commas:
+//
+//   - min=N and max=N bound numbers by value and strings, slices, and maps by length.
+//   - oneof=a b c requires the value, printed with fmt, to be one of the space separated values.
+//   - nonempty requires the value not to be the zero value, or for strings, slices, and maps to have a length.
+//
+// An unknown rule or a bad argument is reported as an error as well. Each error is a ValidationError.
+func Validate(path string, value any, tag string) error {
+	v := reflect.ValueOf(value)
+	var errs []error
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "" {
+			continue
+		}
+
+		err := check(v, name, arg)
+		if err != nil {
+			errs = append(errs, &ValidationError{Field: path, Reason: err.Error()})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidationError reports a field whose value breaks one of its rules. Field is the path of the field, such as
+// MyDBConfig.Port, and Reason says what is wrong with the value.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// Is reports whether target is a *ValidationError for the same field. A target with no Field matches any field.
+func (e *ValidationError) Is(target error) bool {
+	t, ok := target.(*ValidationError)
+	return ok && (t.Field == "" || t.Field == e.Field)
+}
+
+func check(v reflect.Value, name, arg string) error {
+	switch name {
+	case "nonempty":
+		if v.IsZero() || (hasLen(v) && v.Len() == 0) {
+			return fmt.Errorf("must not be empty")
+		}
+		return nil
+	case "oneof":
+		allowed := strings.Fields(arg)
+		str := fmt.Sprint(v.Interface())
+		if !slices.Contains(allowed, str) {
+			return fmt.Errorf("%q must be one of [%s]", str, strings.Join(allowed, ", "))
+		}
+		return nil
+	case "min", "max":
+		return bound(v, name, arg)
+	}
+	return fmt.Errorf("unknown validate rule %q", name)
+}
+
+// bound checks the min and max rules. Lengths are checked for strings, slices, and maps.
+func bound(v reflect.Value, name, arg string) error {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s argument %q: %w", name, arg, err)
+	}
+
+	var n float64
+	what := "value"
+	switch {
+	case hasLen(v):
+		n = float64(v.Len())
+		what = "length"
+	case v.CanInt():
+		n = float64(v.Int())
+	case v.CanUint():
+		n = float64(v.Uint())
+	case v.CanFloat():
+		n = v.Float()
+	default:
+		return fmt.Errorf("%s cannot be applied to %s", name, v.Type())
+	}
+
+	if name == "min" && n < limit {
+		return fmt.Errorf("%s %v is less than min %s", what, n, arg)
+	}
+	if name == "max" && n > limit {
+		return fmt.Errorf("%s %v is greater than max %s", what, n, arg)
+	}
+	return nil
+}
+
+func hasLen(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
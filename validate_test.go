@@ -0,0 +1,75 @@
+package ezconf_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		tag     string
+		wantErr string
+	}{
+		{name: "port in range", value: uint16(8080), tag: "min=1,max=65535"},
+		{
+			name: "port below min", value: uint16(0), tag: "min=1,max=65535",
+			wantErr: "invalid Conf.Field: value 0 is less than min 1",
+		},
+		{
+			name: "int above max", value: 70000, tag: "min=1,max=65535",
+			wantErr: "invalid Conf.Field: value 70000 is greater than max 65535",
+		},
+		{name: "negative float", value: -0.5, tag: "min=0", wantErr: "value -0.5 is less than min 0"},
+		{name: "duration", value: time.Second, tag: "max=1e9"},
+		{name: "string length", value: "ab", tag: "min=3", wantErr: "length 2 is less than min 3"},
+		{name: "slice length", value: []string{"a", "b"}, tag: "max=1", wantErr: "length 2 is greater than max 1"},
+		{name: "oneof", value: "prefer", tag: "oneof=disable prefer require"},
+		{
+			name: "not oneof", value: "maybe", tag: "oneof=disable prefer require",
+			wantErr: `"maybe" must be one of [disable, prefer, require]`,
+		},
+		{name: "nonempty", value: "x", tag: "nonempty"},
+		{name: "empty string", value: "", tag: "nonempty", wantErr: "must not be empty"},
+		{name: "empty map", value: map[string]string{}, tag: "nonempty", wantErr: "must not be empty"},
+		{name: "zero number", value: 0, tag: "nonempty", wantErr: "must not be empty"},
+		{
+			name: "every rule is checked", value: "", tag: "nonempty,min=1",
+			wantErr: "must not be empty\ninvalid Conf.Field: length 0 is less than min 1",
+		},
+		{name: "unknown rule", value: 1, tag: "even", wantErr: `unknown validate rule "even"`},
+		{name: "bad argument", value: 1, tag: "min=one", wantErr: `invalid min argument "one"`},
+		{name: "no length or value", value: struct{}{}, tag: "max=1", wantErr: "max cannot be applied to struct {}"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ezconf.Validate("Conf.Field", tc.value, tc.tag)
+			if tc.wantErr == "" {
+				assert.NilError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	err := errors.Join(
+		ezconf.Validate("Conf.Port", 80, "min=1024"),
+		ezconf.Validate("Conf.Mode", "sometimes", "oneof=on off"),
+	)
+
+	var invalid *ezconf.ValidationError
+	assert.Assert(t, errors.As(err, &invalid))
+	assert.Equal(t, "Conf.Port", invalid.Field)
+	assert.Equal(t, "value 80 is less than min 1024", invalid.Reason)
+	assert.Assert(t, errors.Is(err, &ezconf.ValidationError{Field: "Conf.Mode"}))
+	assert.Assert(t, errors.Is(err, &ezconf.ValidationError{}))
+	assert.Assert(t, !errors.Is(err, &ezconf.ValidationError{Field: "Conf.Name"}))
+}
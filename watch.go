@@ -0,0 +1,122 @@
+package ezconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchFile calls f with a nil error whenever the file at path is written or replaced, until ctx is done. Events are
+// debounced so that the several writes many editors make when saving result in a single call once the file has been
+// quiet for the debounce period. Errors from the underlying watcher are passed to f as they happen.
+//
+// The parent directory is watched rather than the file itself so that editors which save by renaming a new file over
+// the old one are handled. Setup errors are returned directly, after which f is called from a separate goroutine.
+func WatchFile(ctx context.Context, path string, debounce time.Duration, f func(error)) error {
+	return WatchFiles(ctx, []string{path}, debounce, f)
+}
+
+// WatchFiles is the same as WatchFile for several files, e.g. a base config and its overlays. The debounce period is
+// shared, so changing several of the files at once results in a single call.
+func WatchFiles(ctx context.Context, paths []string, debounce time.Duration, f func(error)) error {
+	return WatchGlobs(ctx, paths, nil, debounce, f)
+}
+
+// WatchGlobs is the same as WatchFiles, but also calls f whenever a path matching one of the glob patterns, such as
+// plugins/*.so, is created, written, removed, or renamed, so that a slice expanded from the pattern can be loaded
+// again. Only the last element of a pattern may contain wildcards, as the directory holding the matches is what gets
+// watched.
+func WatchGlobs(ctx context.Context, paths, patterns []string, debounce time.Duration, f func(error)) error {
+	all := strings.Join(slices.Concat(paths, patterns), ", ")
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", all, err)
+	}
+
+	watched := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			w.Close()
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+
+		err = w.Add(filepath.Dir(abs))
+		if err != nil {
+			w.Close()
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		watched[abs] = true
+	}
+
+	globs := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		abs, err := filepath.Abs(pattern)
+		if err != nil {
+			w.Close()
+			return fmt.Errorf("failed to watch %s: %w", pattern, err)
+		}
+
+		dir := filepath.Dir(abs)
+		_, err = filepath.Match(abs, abs)
+		if err == nil && strings.ContainsAny(dir, "*?[") {
+			err = errors.New("only the last path element may contain wildcards")
+		}
+		if err == nil {
+			err = w.Add(dir)
+		}
+		if err != nil {
+			w.Close()
+			return fmt.Errorf("failed to watch %s: %w", pattern, err)
+		}
+		globs = append(globs, abs)
+	}
+
+	changed := func(e fsnotify.Event) bool {
+		name := filepath.Clean(e.Name)
+		if watched[name] && e.Has(fsnotify.Write|fsnotify.Create) {
+			return true
+		}
+		if !e.Has(fsnotify.Create | fsnotify.Write | fsnotify.Remove | fsnotify.Rename) {
+			return false
+		}
+		return slices.ContainsFunc(globs, func(glob string) bool {
+			ok, _ := filepath.Match(glob, name)
+			return ok
+		})
+	}
+
+	go func() {
+		defer w.Close()
+		var fire <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if !changed(e) {
+					continue
+				}
+				fire = time.After(debounce)
+			case <-fire:
+				fire = nil
+				f(nil)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				f(fmt.Errorf("error watching %s: %w", all, err))
+			}
+		}
+	}()
+	return nil
+}
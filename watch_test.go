@@ -0,0 +1,146 @@
+package ezconf_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brnsampson/ezconf"
+	"gotest.tools/v3/assert"
+)
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.toml")
+	assert.NilError(t, os.WriteFile(path, []byte("Port = 80\n"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := make(chan error, 10)
+	err := ezconf.WatchFile(ctx, path, 50*time.Millisecond, func(err error) { calls <- err })
+	assert.NilError(t, err)
+
+	// Other files in the directory are ignored.
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "other.toml"), []byte("x"), 0600))
+
+	// Two quick writes, like an editor saving, only result in one call.
+	assert.NilError(t, os.WriteFile(path, []byte("Port = 81\n"), 0600))
+	assert.NilError(t, os.WriteFile(path, []byte("Port = 82\n"), 0600))
+
+	select {
+	case err := <-calls:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the file change")
+	}
+
+	select {
+	case <-calls:
+		t.Fatal("expected a single call for debounced writes")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Replacing the file by renaming a new one over it counts as a change.
+	tmp := filepath.Join(dir, ".app.toml.tmp")
+	assert.NilError(t, os.WriteFile(tmp, []byte("Port = 83\n"), 0600))
+	assert.NilError(t, os.Rename(tmp, path))
+
+	select {
+	case err := <-calls:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the file to be replaced")
+	}
+}
+
+func TestWatchFileMissingDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "app.toml")
+	err := ezconf.WatchFile(context.Background(), path, time.Millisecond, func(error) {})
+	assert.ErrorContains(t, err, "failed to watch")
+}
+
+func TestWatchFiles(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "base.toml")
+	overlay := filepath.Join(t.TempDir(), "prod.toml")
+	assert.NilError(t, os.WriteFile(base, []byte("Port = 80\n"), 0600))
+	assert.NilError(t, os.WriteFile(overlay, []byte("Port = 443\n"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := make(chan error, 10)
+	err := ezconf.WatchFiles(ctx, []string{base, overlay}, 50*time.Millisecond, func(err error) { calls <- err })
+	assert.NilError(t, err)
+
+	for _, path := range []string{base, overlay} {
+		assert.NilError(t, os.WriteFile(path, []byte("Port = 8080\n"), 0600))
+		select {
+		case err := <-calls:
+			assert.NilError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s to change", path)
+		}
+	}
+
+	// Changing both files at once only results in one call.
+	assert.NilError(t, os.WriteFile(base, []byte("Port = 81\n"), 0600))
+	assert.NilError(t, os.WriteFile(overlay, []byte("Port = 444\n"), 0600))
+	select {
+	case err := <-calls:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the files to change")
+	}
+
+	select {
+	case <-calls:
+		t.Fatal("expected a single call for debounced writes")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatchGlobs(t *testing.T) {
+	dir := t.TempDir()
+	plugin := filepath.Join(dir, "a.so")
+	assert.NilError(t, os.WriteFile(plugin, []byte("a"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := make(chan error, 10)
+	patterns := []string{filepath.Join(dir, "*.so")}
+	err := ezconf.WatchGlobs(ctx, nil, patterns, 50*time.Millisecond, func(err error) { calls <- err })
+	assert.NilError(t, err)
+
+	next := func(what string) {
+		t.Helper()
+		select {
+		case err := <-calls:
+			assert.NilError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+
+	// Files which do not match the pattern are ignored.
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("x"), 0600))
+
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "b.so"), []byte("b"), 0600))
+	next("a new match")
+	assert.NilError(t, os.Remove(plugin))
+	next("a removed match")
+
+	select {
+	case <-calls:
+		t.Fatal("expected no call for files which do not match")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	err = ezconf.WatchGlobs(ctx, nil, []string{filepath.Join(dir, "*", "plugin.so")}, time.Millisecond, func(error) {})
+	assert.ErrorContains(t, err, "only the last path element may contain wildcards")
+	err = ezconf.WatchGlobs(ctx, nil, []string{filepath.Join(dir, "[")}, time.Millisecond, func(error) {})
+	assert.ErrorContains(t, err, "syntax error in pattern")
+}